@@ -0,0 +1,223 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/ekefan/discord-bot/domain/access"
+	"github.com/ekefan/discord-bot/domain/command"
+	"github.com/ekefan/discord-bot/domain/interaction"
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/tracing"
+)
+
+// requiredPermissions returns the permission bit set each command of the bot requires,
+// the same bits its default member permissions are installed with
+func requiredPermissions() map[string]uint64 {
+	commands, err := command.All()
+	if err != nil {
+		// the configurations are static, they cannot fail at runtime
+		panic(err)
+	}
+	perms := make(map[string]uint64, len(commands))
+	for _, cmd := range commands {
+		perms[cmd.Name] = cmd.RequiredPermissions()
+	}
+	return perms
+}
+
+// authorize is the router middleware checking a member may use a command,
+// denied members get an ephemeral reply and the command is not dispatched
+func (bs *BotServer) authorize(next InteractionHandler) InteractionHandler {
+	return func(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+		cmdData, ok := reqData.CommandData()
+		if reqData.Type != APPLICATION_COMMMAND || !ok {
+			next(ctx, w, reqData)
+			return
+		}
+		err := bs.checkAccess(ctx, reqData, cmdData.Name)
+		var denied *access.DeniedError
+		switch {
+		case err == nil:
+			next(ctx, w, reqData)
+		case errors.As(err, &denied):
+			logging.FromContext(ctx).Info("denied command", "reason", denied.Reason)
+			bs.respondEphemeral(ctx, w, deniedMessage(denied))
+		default:
+			http.Error(w, "Server Error", http.StatusInternalServerError)
+			logging.FromContext(ctx).Error("could not check access", logging.KeyError, err)
+		}
+	}
+}
+
+// checkAccess checks the member has the permissions the command requires,
+// the channel is one the guild allows the bot in and the access policy of the guild allows it
+func (bs *BotServer) checkAccess(ctx context.Context, reqData *interaction.Interaction, name string) error {
+	if required := bs.commandPermissions[name]; required != 0 && !reqData.Member.HasPermission(required) {
+		return &access.DeniedError{Command: name, Reason: access.ReasonPermission, Permissions: required}
+	}
+	if reqData.GuildID == "" || reqData.Member == nil {
+		return nil
+	}
+	manager := reqData.Member.HasPermission(interaction.PermissionManageGuild)
+	channels := bs.guildSettings(ctx, reqData.GuildID).AllowedChannels
+	if !manager && len(channels) > 0 && !slices.Contains(channels, reqData.ChannelID) {
+		return &access.DeniedError{Command: name, Reason: access.ReasonChannel, Channels: channels}
+	}
+	policy, err := bs.Access.Policy(reqData.GuildID)
+	if err != nil {
+		return err
+	}
+	return policy.Check(access.Request{
+		Command:   name,
+		ChannelID: reqData.ChannelID,
+		Roles:     reqData.Member.Roles,
+		Manager:   manager,
+	})
+}
+
+// deniedMessage tells a member why they can't use a command
+func deniedMessage(denied *access.DeniedError) string {
+	switch denied.Reason {
+	case access.ReasonPermission:
+		if denied.Permissions == interaction.PermissionManageGuild {
+			return fmt.Sprintf("You need the Manage Server permission to use `/%s`", denied.Command)
+		}
+		return fmt.Sprintf("You don't have the permissions `/%s` needs", denied.Command)
+	case access.ReasonChannel:
+		if len(denied.Channels) > 0 {
+			return fmt.Sprintf("`/%s` can only be used in %s", denied.Command, channelMentions(denied.Channels))
+		}
+		return fmt.Sprintf("`/%s` can't be used in this channel", denied.Command)
+	default:
+		return fmt.Sprintf("Your roles don't allow you to use `/%s` here", denied.Command)
+	}
+}
+
+// HandleAccessCmd lets admins allow and deny commands in channels and to roles
+func (bs *BotServer) HandleAccessCmd(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+	ctx, span := tracing.Start(ctx, "handler.HandleAccessCmd")
+	defer span.End()
+	if reqData.GuildID == "" {
+		bs.respondEphemeral(ctx, w, "Access rules only apply in servers")
+		return
+	}
+	cmdData, _ := reqData.CommandData()
+	subcommand, options := cmdData.Subcommand()
+	name, _ := interaction.OptionValue(options, "command")
+	name = strings.TrimPrefix(strings.TrimSpace(name), "/")
+	if name == "" {
+		name = access.AllCommands
+	} else if _, ok := bs.commandPermissions[name]; !ok {
+		bs.respondEphemeral(ctx, w, fmt.Sprintf("There is no `/%s` command", name))
+		return
+	}
+	channelID, _ := interaction.OptionValue(options, "channel")
+	roleID, _ := interaction.OptionValue(options, "role")
+
+	var update func(p *access.Policy) error
+	switch subcommand {
+	case command.AccessAllowSubcommand, command.AccessDenySubcommand:
+		update = func(p *access.Policy) error {
+			rule := p.Rule(name)
+			var err error
+			if subcommand == command.AccessAllowSubcommand {
+				err = rule.Allow(channelID, roleID)
+			} else {
+				err = rule.Deny(channelID, roleID)
+			}
+			if err != nil {
+				return err
+			}
+			p.SetRule(name, rule)
+			return nil
+		}
+	case command.AccessClearSubcommand:
+		update = func(p *access.Policy) error {
+			p.SetRule(name, access.Rule{})
+			return nil
+		}
+	case command.AccessShowSubcommand:
+		policy, err := bs.Access.Policy(reqData.GuildID)
+		if err != nil {
+			http.Error(w, "Server Error", http.StatusInternalServerError)
+			logging.FromContext(ctx).Error("could not load access policy", logging.KeyError, err)
+			return
+		}
+		bs.respondEphemeral(ctx, w, policyMessage(policy))
+		return
+	default:
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		logging.FromContext(ctx).Error("received unknown access sub command", "subcommand", subcommand)
+		return
+	}
+
+	policy, err := bs.Access.UpdatePolicy(reqData.GuildID, update)
+	switch {
+	case err == nil:
+		bs.respondEphemeral(ctx, w, policyMessage(policy))
+	case errors.Is(err, access.ErrInvalidRule):
+		bs.respondEphemeral(ctx, w, "Pick a channel or a role to allow or deny")
+	default:
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not update access policy", logging.KeyError, err)
+	}
+}
+
+// policyMessage lists the access rules of a guild
+func policyMessage(p access.Policy) string {
+	if len(p.Rules) == 0 {
+		return "Every command can be used by everyone in every channel"
+	}
+	names := make([]string, 0, len(p.Rules))
+	for name := range p.Rules {
+		names = append(names, name)
+	}
+	// the rule of every command comes first
+	slices.Sort(names)
+
+	var b strings.Builder
+	b.WriteString("**Access rules**\n-# members who can manage the server are never restricted")
+	for _, name := range names {
+		r := p.Rules[name]
+		label := fmt.Sprintf("`/%s`", name)
+		if name == access.AllCommands {
+			label = "Every command"
+		}
+		var parts []string
+		if len(r.AllowChannels) > 0 {
+			parts = append(parts, "only in "+channelMentions(r.AllowChannels))
+		}
+		if len(r.DenyChannels) > 0 {
+			parts = append(parts, "not in "+channelMentions(r.DenyChannels))
+		}
+		if len(r.AllowRoles) > 0 {
+			parts = append(parts, "only for "+roleMentions(r.AllowRoles))
+		}
+		if len(r.DenyRoles) > 0 {
+			parts = append(parts, "not for "+roleMentions(r.DenyRoles))
+		}
+		fmt.Fprintf(&b, "\n%s: %s", label, strings.Join(parts, ", "))
+	}
+	return b.String()
+}
+
+func channelMentions(ids []string) string {
+	mentions := make([]string, len(ids))
+	for i, id := range ids {
+		mentions[i] = fmt.Sprintf("<#%s>", id)
+	}
+	return strings.Join(mentions, " ")
+}
+
+func roleMentions(ids []string) string {
+	mentions := make([]string, len(ids))
+	for i, id := range ids {
+		mentions[i] = fmt.Sprintf("<@&%s>", id)
+	}
+	return strings.Join(mentions, " ")
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/domain/interaction"
+	"github.com/ekefan/discord-bot/domain/strategy"
+	"github.com/ekefan/discord-bot/events"
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/tracing"
+)
+
+// botUserID returns the user id of the bot, which is its application id
+func (bs *BotServer) botUserID() string {
+	return strconv.Itoa(bs.Config.AppID)
+}
+
+// HandlePlayBotCmd plays a game against the computer player
+func (bs *BotServer) HandlePlayBotCmd(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+	ctx, span := tracing.Start(ctx, "handler.HandlePlayBotCmd")
+	defer span.End()
+
+	cmdData, _ := reqData.CommandData()
+	choice, _ := interaction.OptionValue(cmdData.Options, "object")
+	strategyName, ok := interaction.OptionValue(cmdData.Options, "strategy")
+	if !ok {
+		strategyName = bs.Config.BotStrategy
+	}
+	bs.playBot(ctx, w, reqData, domain.RpsChoice(choice), strategyName)
+}
+
+// playBot resolves a challenge against the computer player right away
+func (bs *BotServer) playBot(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction, choice domain.RpsChoice, strategyName string) {
+	logger := logging.FromContext(ctx)
+	userID := reqData.InvokingUser().ID
+	botID := bs.botUserID()
+
+	botGame, err := challenge.NewChallenge(reqData.ID, scopeOf(reqData), &domain.Player{ID: userID, Choice: choice})
+	if err != nil {
+		bs.respondEphemeral(ctx, w, "Pick rock, paper or scissors")
+		return
+	}
+	botGame.SetBotGame(true)
+	botGame.SetTarget(botID)
+
+	computer, err := strategy.New(strategyName, time.Now().UnixNano())
+	if err != nil {
+		bs.respondEphemeral(ctx, w, fmt.Sprintf("I don't know the %q strategy", strategyName))
+		return
+	}
+	history, err := bs.Throws.Throws(userID)
+	if err != nil {
+		logger.Error("could not load throw history", logging.KeyError, err)
+	}
+
+	now := time.Now()
+	if err := botGame.Accept(botID, now); err != nil {
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logger.Error("bot could not accept challenge", logging.KeyError, err)
+		return
+	}
+	if err := botGame.MakeChoice(&domain.Player{ID: botID, Choice: computer.Next(history)}, now); err != nil {
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logger.Error("bot could not play challenge", logging.KeyError, err)
+		return
+	}
+	bs.recordChallengeEvents(ctx, botGame, botGame.History())
+
+	resultStr, err := botGame.GetResultMsg()
+	if err != nil {
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logger.Error("could not format challenge result", logging.KeyError, err)
+		return
+	}
+	resp := interaction.InteractionResponse{
+		Type: CHANNEL_MESSAGE_WITH_SOURCE,
+		Data: interaction.ResponseData{
+			Content: fmt.Sprintf("%s\n-# the bot played with the %s strategy", resultStr, computer.Name()),
+		},
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Error("failed to send interaction response", logging.KeyError, err)
+	}
+}
+
+// recordThrows keeps the throws of resolved games so the computer player can learn from them
+func (bs *BotServer) recordThrows(ctx context.Context, e events.Event) {
+	if e.Type != events.ChallengeEventType(challenge.EventResolved) || e.Challenge == nil || e.Challenge.Result == nil {
+		return
+	}
+	result := e.Challenge.Result
+	throws := map[string]string{
+		result.WinnerID: result.WinnerChoice,
+		result.LooserID: result.LooserChoice,
+	}
+	for userID, choice := range throws {
+		if userID == bs.botUserID() || choice == "" {
+			continue
+		}
+		if err := bs.Throws.RecordThrow(userID, domain.RpsChoice(choice)); err != nil {
+			logging.FromContext(ctx).Error("could not record throw", logging.KeyUserID, userID, logging.KeyError, err)
+		}
+	}
+}
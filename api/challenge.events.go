@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/events"
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/memory"
+)
+
+// transitionChallenge applies a compare-and-swap transition to a stored challenge
+// and records the events it produced
+func (bs *BotServer) transitionChallenge(ctx context.Context, scope challenge.Scope, id string, from challenge.Status, transition memory.Transition) (*challenge.Challenge, error) {
+	seen := 0
+	c, err := bs.store().TransitionChallenge(ctx, scope, id, from, func(c *challenge.Challenge) error {
+		seen = len(c.History())
+		return transition(c)
+	})
+	if err != nil {
+		return c, err
+	}
+	bs.recordChallengeEvents(ctx, c, c.History()[seen:])
+	return c, nil
+}
+
+// updateAttempts is how many times updateChallenge reads a challenge that keeps changing
+const updateAttempts = 5
+
+// updateChallenge applies update to the stored challenge and saves it, reading it again
+// when it changed in the meantime so the changes of others are never overwritten
+func (bs *BotServer) updateChallenge(ctx context.Context, scope challenge.Scope, id string, update func(c *challenge.Challenge)) error {
+	for attempt := 0; attempt < updateAttempts; attempt++ {
+		c, err := bs.store().GetChallenge(ctx, scope, id)
+		if err != nil {
+			return err
+		}
+		update(c)
+		if err := bs.store().UpdateChallenge(ctx, c); !errors.Is(err, memory.ErrVersionConflict) {
+			return err
+		}
+	}
+	return memory.ErrVersionConflict
+}
+
+// recordChallengeEvents writes challenge events to the audit log and publishes them on the event bus
+func (bs *BotServer) recordChallengeEvents(ctx context.Context, c *challenge.Challenge, history []challenge.Event) {
+	logger := logging.FromContext(ctx)
+	for _, e := range history {
+		attrs := []any{
+			logging.KeyChallengeID, e.ChallengeID,
+			"event", string(e.Type),
+			"actor", e.Actor,
+			"at", e.At,
+		}
+		logger.Info("challenge event", attrs...)
+		if bs.Events != nil {
+			bs.Events.Publish(ctx, events.FromChallenge(e, c))
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain/interaction"
+	"github.com/ekefan/discord-bot/domain/ledger"
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/memory"
+	"github.com/ekefan/discord-bot/tracing"
+)
+
+// HandleDailyCmd grants the invoking user their daily coins, once per UTC day
+func (bs *BotServer) HandleDailyCmd(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+	ctx, span := tracing.Start(ctx, "handler.HandleDailyCmd")
+	defer span.End()
+	if reqData.GuildID == "" {
+		bs.respondEphemeral(ctx, w, "Coins can only be earned in a server")
+		return
+	}
+	if !bs.guildSettings(ctx, reqData.GuildID).Economy {
+		bs.respondEphemeral(ctx, w, economyOff)
+		return
+	}
+	userID := reqData.InvokingUser().ID
+	now := time.Now()
+	// keyed by day so the coins are granted at most once a day
+	id := fmt.Sprintf("daily:%s:%s:%s", reqData.GuildID, userID, now.UTC().Format(time.DateOnly))
+	tx, err := ledger.Transfer(id, ledger.KindDaily, ledger.MintAccount(reqData.GuildID), ledger.UserAccount(reqData.GuildID, userID), bs.Config.DailyCoins, now)
+	if err != nil {
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not create daily transaction", logging.KeyError, err)
+		return
+	}
+	switch err := bs.Ledger.Post(tx); {
+	case err == nil:
+	case errors.Is(err, memory.ErrTransactionExists):
+		bs.respondEphemeral(ctx, w, "You already claimed your daily coins, come back tomorrow")
+		return
+	default:
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not grant daily coins", logging.KeyError, err)
+		return
+	}
+	balance, _ := bs.Ledger.Balance(ledger.UserAccount(reqData.GuildID, userID))
+	bs.respondEphemeral(ctx, w, fmt.Sprintf("You claimed **%d** coins, you now have **%d**", bs.Config.DailyCoins, balance))
+}
+
+// HandleBalanceCmd shows the coins of the invoking user or the user picked in the command
+func (bs *BotServer) HandleBalanceCmd(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+	ctx, span := tracing.Start(ctx, "handler.HandleBalanceCmd")
+	defer span.End()
+	if reqData.GuildID == "" {
+		bs.respondEphemeral(ctx, w, "Coins can only be earned in a server")
+		return
+	}
+	if !bs.guildSettings(ctx, reqData.GuildID).Economy {
+		bs.respondEphemeral(ctx, w, economyOff)
+		return
+	}
+	cmdData, _ := reqData.CommandData()
+	userID, ok := interaction.OptionValue(cmdData.Options, "user")
+	if !ok {
+		userID = reqData.InvokingUser().ID
+	}
+	balance, err := bs.Ledger.Balance(ledger.UserAccount(reqData.GuildID, userID))
+	if err != nil {
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not load balance", logging.KeyError, err)
+		return
+	}
+	bs.respondEphemeral(ctx, w, fmt.Sprintf("<@%s> has **%d** coins", userID, balance))
+}
+
+// HandleTransferCmd gives coins of the invoking user to another user
+func (bs *BotServer) HandleTransferCmd(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+	ctx, span := tracing.Start(ctx, "handler.HandleTransferCmd")
+	defer span.End()
+	if reqData.GuildID == "" {
+		bs.respondEphemeral(ctx, w, "Coins can only be earned in a server")
+		return
+	}
+	if !bs.guildSettings(ctx, reqData.GuildID).Economy {
+		bs.respondEphemeral(ctx, w, economyOff)
+		return
+	}
+	cmdData, _ := reqData.CommandData()
+	to, _ := interaction.OptionValue(cmdData.Options, "user")
+	amount, _ := interaction.OptionInt(cmdData.Options, "amount")
+	from := reqData.InvokingUser().ID
+	switch to {
+	case from:
+		bs.respondEphemeral(ctx, w, "You can't give coins to yourself")
+		return
+	case "", bs.botUserID():
+		bs.respondEphemeral(ctx, w, "Pick another player to give coins to")
+		return
+	}
+	tx, err := ledger.Transfer(reqData.ID, ledger.KindTransfer, ledger.UserAccount(reqData.GuildID, from), ledger.UserAccount(reqData.GuildID, to), amount, time.Now())
+	if err != nil {
+		bs.respondEphemeral(ctx, w, "Pick how many coins to give")
+		return
+	}
+	var funds *ledger.InsufficientFundsError
+	switch err := bs.Ledger.Post(tx); {
+	case err == nil, errors.Is(err, memory.ErrTransactionExists):
+	case errors.As(err, &funds):
+		bs.respondEphemeral(ctx, w, fmt.Sprintf("You only have **%d** coins", funds.Balance))
+		return
+	default:
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not transfer coins", logging.KeyError, err)
+		return
+	}
+	bs.respondEphemeral(ctx, w, fmt.Sprintf("You gave **%d** coins to <@%s>", amount, to))
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/memory"
+	"github.com/ekefan/discord-bot/tracing"
+)
+
+// RunChallengeExpiry expires unresolved challenges past their TTL every interval until ctx is done
+func (bs *BotServer) RunChallengeExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			bs.ExpireChallenges(ctx, now)
+		}
+	}
+}
+
+// ExpireChallenges moves every challenge past its expiry at now to the expired state,
+// removes it and edits its message, rematch series past their expiry are dropped.
+// A tournament match only one player threw in is forfeited by the other instead.
+// It returns the number of expired challenges
+func (bs *BotServer) ExpireChallenges(ctx context.Context, now time.Time) int {
+	ctx, span := tracing.Start(ctx, "expiry.ExpireChallenges")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+
+	unresolved, err := bs.store().ListChallenges(ctx, memory.ChallengeQuery{
+		Statuses: []challenge.Status{challenge.Open, challenge.Claimed},
+	})
+	if err != nil {
+		span.RecordError(err)
+		logger.Error("could not list challenges to expire", logging.KeyError, err)
+		return 0
+	}
+	expired := 0
+	for _, c := range unresolved.Challenges {
+		if !c.ExpiredAt(now) {
+			continue
+		}
+		id, _ := c.GetChallengeID()
+		wasOpen := c.Status() == challenge.Open
+		c, err := bs.transitionChallenge(ctx, c.Scope(), id, c.Status(), func(c *challenge.Challenge) error {
+			if idle := idleMatchPlayer(c); idle != "" {
+				return c.Forfeit(idle, now)
+			}
+			return c.Expire(now)
+		})
+		if err != nil {
+			// resolved or withdrawn since it was listed
+			continue
+		}
+		if err := bs.store().DeleteChallenge(ctx, c.Scope(), id); err != nil {
+			logger.Error("could not delete expired challenge", logging.KeyChallengeID, id, logging.KeyError, err)
+		}
+		expired++
+		if tournamentID, _ := c.Tournament(); tournamentID != "" {
+			bs.editChallengeMessage(logging.With(ctx, logging.KeyChallengeID, id), c, "", expiredMatchMessage(c))
+			continue
+		}
+		if !wasOpen {
+			// the accept message was removed when the challenge was claimed
+			continue
+		}
+		bs.editChallengeMessage(logging.With(ctx, logging.KeyChallengeID, id), c, "",
+			fmt.Sprintf("~~%s~~ challenge expired", challengeMessage(c)))
+	}
+	if _, err := bs.Series.DeleteExpiredSeries(now); err != nil {
+		logger.Error("could not delete expired series", logging.KeyError, err)
+	}
+	span.SetAttributes("challenge.expired", expired)
+	return expired
+}
+
+// idleMatchPlayer returns the player of a tournament match who didn't throw while the
+// other did, empty for other challenges and when neither or both players threw
+func idleMatchPlayer(c *challenge.Challenge) string {
+	if tournamentID, _ := c.Tournament(); tournamentID == "" || c.Status() != challenge.Claimed {
+		return ""
+	}
+	challenger, opponent := c.Challenger().ID, c.ClaimedBy()
+	switch {
+	case c.Chose(challenger) && !c.Chose(opponent):
+		return opponent
+	case c.Chose(opponent) && !c.Chose(challenger):
+		return challenger
+	default:
+		return ""
+	}
+}
+
+// expiredMatchMessage tells how a tournament match that ran out of time ended
+func expiredMatchMessage(c *challenge.Challenge) string {
+	if c.Status() == challenge.Forfeited {
+		return fmt.Sprintf("<@%s> didn't throw in time, <@%s> wins by forfeit", c.Result().Looser.ID, c.Result().Winner.ID)
+	}
+	return fmt.Sprintf("Neither <@%s> nor <@%s> threw in time", c.Challenger().ID, c.ClaimedBy())
+}
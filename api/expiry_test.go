@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/domain/tournament"
+	"github.com/ekefan/discord-bot/memory"
+	"github.com/ekefan/discord-bot/util"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeDiscord answers every request of the bot like discord posting a message, and keeps the bodies sent
+type fakeDiscord struct {
+	mu     sync.Mutex
+	bodies []string
+}
+
+func (fd *fakeDiscord) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	fd.mu.Lock()
+	fd.bodies = append(fd.bodies, string(body))
+	fd.mu.Unlock()
+	w.Write([]byte(`{"id":"m1"}`))
+}
+
+// contents returns the content of every message sent
+func (fd *fakeDiscord) contents() []string {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	contents := []string{}
+	for _, body := range fd.bodies {
+		var message struct {
+			Content string `json:"content"`
+		}
+		if json.Unmarshal([]byte(body), &message) == nil {
+			contents = append(contents, message.Content)
+		}
+	}
+	return contents
+}
+
+// newMatch starts a tournament between p1 and p2 and returns the challenge of its match
+func newMatch(t *testing.T) (*BotServer, *fakeDiscord, *challenge.Challenge) {
+	discord := &fakeDiscord{}
+	server := httptest.NewServer(discord)
+	t.Cleanup(server.Close)
+	config := &util.EnvConfig{DiscordBaseUrl: server.URL, ChallengeTTL: time.Minute, TournamentsEnabled: true}
+	bs := NewBotServer(config, memory.NewChallengeStore(memory.NewInMemory(memory.Limits{})))
+
+	scope := challenge.Scope{GuildID: "g1", ChannelID: "c1"}
+	cup, err := tournament.New("t1", scope, "cup", "p1", tournament.SingleElimination, tournament.SeedRandom, 0)
+	require.NoError(t, err)
+	require.NoError(t, cup.Join(tournament.Participant{ID: "p1"}))
+	require.NoError(t, cup.Join(tournament.Participant{ID: "p2"}))
+	require.NoError(t, cup.Start("p1", nil, rand.New(rand.NewSource(1))))
+	require.NoError(t, bs.Tournaments.CreateTournament(cup))
+	bs.scheduleMatches(context.Background(), "t1", func(m tournament.Match) bool { return true })
+
+	started, err := bs.Tournaments.GetTournament("t1")
+	require.NoError(t, err)
+	match, err := started.Match("M1")
+	require.NoError(t, err)
+	c, err := bs.store().GetChallenge(context.Background(), scope, match.Challenge)
+	require.NoError(t, err)
+	require.False(t, c.ExpiresAt().IsZero(), "matches expire with the guild TTL")
+	return bs, discord, c
+}
+
+func TestExpireMatchForfeitsIdlePlayer(t *testing.T) {
+	bs, discord, match := newMatch(t)
+	ctx := context.Background()
+	id, _ := match.GetChallengeID()
+	_, err := bs.transitionChallenge(ctx, match.Scope(), id, challenge.Claimed, func(c *challenge.Challenge) error {
+		return c.MakeChoice(&domain.Player{ID: "p2", Choice: domain.Rock}, time.Now())
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, 0, bs.ExpireChallenges(ctx, time.Now()))
+	require.Equal(t, 1, bs.ExpireChallenges(ctx, match.ExpiresAt()))
+
+	cup, err := bs.Tournaments.GetTournament("t1")
+	require.NoError(t, err)
+	require.Equal(t, tournament.Finished, cup.Status())
+	require.Equal(t, "p2", cup.Champion())
+	require.Contains(t, discord.contents(), "<@p1> didn't throw in time, <@p2> wins by forfeit")
+}
+
+func TestExpireUnplayedMatchIsPlayedAgain(t *testing.T) {
+	bs, _, match := newMatch(t)
+	ctx := context.Background()
+	id, _ := match.GetChallengeID()
+
+	require.Equal(t, 1, bs.ExpireChallenges(ctx, match.ExpiresAt()))
+	_, err := bs.store().GetChallenge(ctx, match.Scope(), id)
+	require.ErrorIs(t, err, memory.ErrChallengeNotFound)
+
+	require.Eventually(t, func() bool {
+		cup, err := bs.Tournaments.GetTournament("t1")
+		if err != nil {
+			return false
+		}
+		m, err := cup.Match("M1")
+		return err == nil && cup.Status() == tournament.Running && m.Challenge != "" && m.Challenge != id
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestExpireUnplayedMatchGoesToBetterSeed(t *testing.T) {
+	bs, discord, match := newMatch(t)
+	ctx := context.Background()
+	scope := match.Scope()
+
+	for replays := 0; ; replays++ {
+		require.Equal(t, 1, bs.ExpireChallenges(ctx, match.ExpiresAt()))
+		if replays == maxMatchReplays {
+			break
+		}
+		id, _ := match.GetChallengeID()
+		var next string
+		require.Eventually(t, func() bool {
+			cup, err := bs.Tournaments.GetTournament("t1")
+			if err != nil {
+				return false
+			}
+			m, err := cup.Match("M1")
+			next = m.Challenge
+			return err == nil && m.Replays == replays+1 && next != "" && next != id
+		}, time.Second, 10*time.Millisecond)
+		var err error
+		match, err = bs.store().GetChallenge(ctx, scope, next)
+		require.NoError(t, err)
+	}
+
+	cup, err := bs.Tournaments.GetTournament("t1")
+	require.NoError(t, err)
+	require.Equal(t, tournament.Finished, cup.Status())
+	best := cup.Participants()[0]
+	for _, p := range cup.Participants() {
+		if p.Seed < best.Seed {
+			best = p
+		}
+	}
+	require.Equal(t, best.ID, cup.Champion())
+	require.Eventually(t, func() bool {
+		for _, content := range discord.contents() {
+			if content == "Match M1 was never played, <@"+best.ID+"> advances as the better seed" {
+				return true
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+}
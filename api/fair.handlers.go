@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/domain/commitment"
+	"github.com/ekefan/discord-bot/domain/interaction"
+	"github.com/ekefan/discord-bot/events"
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/memory"
+	"github.com/ekefan/discord-bot/tracing"
+)
+
+// HandleVerifyCmd recomputes the commitment of a fair game from its revealed choice and nonce
+func (bs *BotServer) HandleVerifyCmd(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+	ctx, span := tracing.Start(ctx, "handler.HandleVerifyCmd")
+	defer span.End()
+	cmdData, _ := reqData.CommandData()
+	gameID, _ := interaction.OptionValue(cmdData.Options, "game_id")
+
+	record, err := bs.Commitments.GetCommitment(gameID)
+	if err != nil {
+		if errors.Is(err, memory.ErrCommitmentNotFound) {
+			bs.respondEphemeral(ctx, w, fmt.Sprintf("There is no fair game with id `%s`", gameID))
+			return
+		}
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not load commitment", logging.KeyError, err)
+		return
+	}
+	bs.respondEphemeral(ctx, w, verifyMessage(record))
+}
+
+// revealCommitments reveals the commitment of a fair game once it is over
+func (bs *BotServer) revealCommitments(ctx context.Context, e events.Event) {
+	if e.Challenge == nil {
+		return
+	}
+	switch e.Type {
+	case events.ChallengeEventType(challenge.EventResolved), events.ChallengeEventType(challenge.EventForfeited),
+		events.ChallengeEventType(challenge.EventExpired), events.ChallengeEventType(challenge.EventCancelled):
+	default:
+		return
+	}
+	if err := bs.Commitments.RevealCommitment(e.Challenge.ID, e.At); err != nil && !errors.Is(err, memory.ErrCommitmentNotFound) {
+		logging.FromContext(ctx).Error("could not reveal commitment", logging.KeyChallengeID, e.Challenge.ID, logging.KeyError, err)
+	}
+}
+
+// saveCommitment keeps the commitment of a fair challenge so it can be verified after the game
+func (bs *BotServer) saveCommitment(ctx context.Context, c *challenge.Challenge) {
+	hash, nonce := c.Commitment()
+	if hash == "" {
+		return
+	}
+	id, _ := c.GetChallengeID()
+	err := bs.Commitments.SaveCommitment(commitment.Record{
+		GameID:     id,
+		Commitment: hash,
+		Choice:     c.Challenger().Choice,
+		Nonce:      nonce,
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("could not save commitment", logging.KeyError, err)
+	}
+}
+
+// commitmentMessage publishes the commitment of a fair challenge
+func commitmentMessage(c *challenge.Challenge) string {
+	if hash, _ := c.Commitment(); hash != "" {
+		return fmt.Sprintf("\n-# commitment `%s`", hash)
+	}
+	return ""
+}
+
+// revealMessage reveals the nonce of a finished fair challenge
+func revealMessage(c *challenge.Challenge) string {
+	hash, nonce := c.Commitment()
+	if hash == "" {
+		return ""
+	}
+	id, _ := c.GetChallengeID()
+	return fmt.Sprintf("\n-# nonce `%s`, check it with `/verify game_id:%s`", nonce, id)
+}
+
+// verifyMessage explains whether the commitment of a game holds
+func verifyMessage(r commitment.Record) string {
+	if !r.Revealed() {
+		return fmt.Sprintf("Game `%s` committed to `%s`, the nonce is revealed once it is over", r.GameID, r.Commitment)
+	}
+	computed := commitment.Commit(r.Choice, r.Nonce)
+	verdict := "✅ the commitment matches, the choice was not changed"
+	if !r.Verify() {
+		verdict = "❌ the commitment does not match"
+	}
+	return fmt.Sprintf("Game `%s`\ncommitment `%s`\nsha256(`%s:%s`) = `%s`\n%s", r.GameID, r.Commitment, r.Choice, r.Nonce, computed, verdict)
+}
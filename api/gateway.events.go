@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/domain/interaction"
+	"github.com/ekefan/discord-bot/gateway"
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/memory"
+	"github.com/ekefan/discord-bot/tracing"
+)
+
+// HandleGatewayEvent reacts to the events of the gateway, it is subscribed to the gateway client
+func (bs *BotServer) HandleGatewayEvent(ctx context.Context, e gateway.Event) {
+	switch e := e.(type) {
+	case *gateway.GuildMemberRemove:
+		bs.AbandonChallenges(ctx, e.GuildID, e.User.ID, time.Now())
+	}
+}
+
+// AbandonChallenges ends the challenges of a member who left a guild, their open challenges
+// are withdrawn and the games they were playing are forfeited to their opponent.
+// Tournament matches are left to their tournament. It returns the number of challenges ended
+func (bs *BotServer) AbandonChallenges(ctx context.Context, guildID, userID string, now time.Time) int {
+	ctx, span := tracing.Start(ctx, "gateway.AbandonChallenges")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+
+	// the challenges they issued or claimed in the guild
+	involved, err := bs.store().ListChallenges(ctx, memory.ChallengeQuery{
+		ChallengeFilter: memory.ChallengeFilter{GuildID: guildID},
+		UserID:          userID,
+		Statuses:        []challenge.Status{challenge.Open, challenge.Claimed},
+	})
+	if err != nil {
+		span.RecordError(err)
+		logger.Error("could not list challenges of a member who left", logging.KeyError, err)
+		return 0
+	}
+	abandoned := 0
+	for _, c := range involved.Challenges {
+		if tournamentID, _ := c.Tournament(); tournamentID != "" {
+			continue
+		}
+		id, _ := c.GetChallengeID()
+		var transition memory.Transition
+		switch {
+		case c.Status() == challenge.Open && c.Challenger().ID == userID:
+			transition = func(c *challenge.Challenge) error { return c.Cancel(userID, now) }
+		case c.Status() == challenge.Claimed && (c.Challenger().ID == userID || c.ClaimedBy() == userID):
+			transition = func(c *challenge.Challenge) error { return c.Forfeit(userID, now) }
+		default:
+			continue
+		}
+		wasOpen := c.Status() == challenge.Open
+		c, err := bs.transitionChallenge(ctx, c.Scope(), id, c.Status(), transition)
+		if err != nil {
+			// accepted or resolved since it was listed
+			continue
+		}
+		if err := bs.store().DeleteChallenge(ctx, c.Scope(), id); err != nil {
+			logger.Error("could not delete abandoned challenge", logging.KeyChallengeID, id, logging.KeyError, err)
+		}
+		abandoned++
+		ctx := context.WithoutCancel(logging.With(ctx, logging.KeyChallengeID, id))
+		if wasOpen {
+			go bs.editChallengeMessage(ctx, c, "",
+				fmt.Sprintf("~~%s~~ <@%s> left the server", challengeMessage(c), userID))
+			continue
+		}
+		go bs.postChannelMessage(ctx, c.Scope().ChannelID, interaction.ResponseData{
+			Content: fmt.Sprintf("<@%s> left the server, <@%s> wins by forfeit", userID, c.Result().Winner.ID),
+		})
+	}
+	span.SetAttributes("challenge.abandoned", abandoned)
+	return abandoned
+}
+
+// RunPresence shows the number of open challenges as the presence of the bot,
+// counting them every interval until ctx is done
+func (bs *BotServer) RunPresence(ctx context.Context, gw *gateway.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	shown := -1
+	for {
+		if open, err := bs.store().CountOpen(ctx, memory.ChallengeFilter{}); err != nil {
+			logging.FromContext(ctx).Error("could not count open challenges", logging.KeyError, err)
+		} else if open != shown {
+			if err := gw.UpdatePresence(gateway.Playing(presenceMessage(open))); err != nil {
+				logging.FromContext(ctx).Warn("could not update presence", logging.KeyError, err)
+			} else {
+				shown = open
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func presenceMessage(open int) string {
+	if open == 1 {
+		return "RPS — 1 open challenge"
+	}
+	return fmt.Sprintf("RPS — %d open challenges", open)
+}
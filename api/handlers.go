@@ -2,16 +2,22 @@ package api
 
 import (
 	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log/slog"
 	"net/http"
-	"strings"
 	"time"
 
+	"github.com/ekefan/discord-bot/customid"
 	"github.com/ekefan/discord-bot/domain"
 	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/domain/commitment"
 	"github.com/ekefan/discord-bot/domain/interaction"
+	"github.com/ekefan/discord-bot/domain/ledger"
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/memory"
+	"github.com/ekefan/discord-bot/tracing"
 )
 
 // ComponentTypes
@@ -37,19 +43,23 @@ const (
 	EPHEMERAL = 1 << 6
 )
 
-func (bs *BotServer) HandleDiscordPing(w http.ResponseWriter) {
+func (bs *BotServer) HandleDiscordPing(ctx context.Context, w http.ResponseWriter) {
+	ctx, span := tracing.Start(ctx, "handler.HandleDiscordPing")
+	defer span.End()
 	resp := interaction.InteractionResponse{
 		Type: PONG,
 	}
 	err := json.NewEncoder(w).Encode(resp)
 	if err != nil {
 		http.Error(w, "Server Error", http.StatusInternalServerError)
-		slog.Error("error encoding ping response", "details", err.Error())
+		logging.FromContext(ctx).Error("error encoding ping response", logging.KeyError, err)
 		return
 	}
 }
 
-func (bs *BotServer) HandleTestCmd(w http.ResponseWriter) {
+func (bs *BotServer) HandleTestCmd(ctx context.Context, w http.ResponseWriter) {
+	ctx, span := tracing.Start(ctx, "handler.HandleTestCmd")
+	defer span.End()
 	resp := interaction.InteractionResponse{
 		Type: CHANNEL_MESSAGE_WITH_SOURCE,
 		Data: interaction.ResponseData{
@@ -60,36 +70,112 @@ func (bs *BotServer) HandleTestCmd(w http.ResponseWriter) {
 	err := json.NewEncoder(w).Encode(resp)
 	if err != nil {
 		http.Error(w, "Server Error", http.StatusInternalServerError)
-		slog.Error("error encoding ping response", "details", err.Error())
+		logging.FromContext(ctx).Error("error encoding test command response", logging.KeyError, err)
 		return
 	}
 }
 
-func (bs *BotServer) HandleChanllengeCmd(w http.ResponseWriter, reqData interaction.SlashCommandInteraction) {
+func (bs *BotServer) HandleChanllengeCmd(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+	ctx, span := tracing.Start(ctx, "handler.HandleChanllengeCmd")
+	defer span.End()
 	// get challenge and challenger details from request Data
 	challengeId := reqData.ID
-	challengerId := reqData.Member.User.ID
-	choice := reqData.Data.Options[0].Value
+	ctx = logging.With(ctx, logging.KeyChallengeID, challengeId)
+	challengerId := reqData.InvokingUser().ID
+	cmdData, _ := reqData.CommandData()
+	_, options := cmdData.Subcommand()
+	choice, _ := interaction.OptionValue(options, "object")
+	target, _ := interaction.OptionValue(options, "opponent")
+	wager, _ := interaction.OptionInt(options, "wager")
+	fair, _ := interaction.OptionBool(options, "fair")
+	switch target {
+	case bs.botUserID():
+		if wager > 0 {
+			bs.respondEphemeral(ctx, w, "You can't wager coins against the bot")
+			return
+		}
+		bs.playBot(ctx, w, reqData, domain.RpsChoice(choice), bs.Config.BotStrategy)
+		return
+	case challengerId:
+		bs.respondEphemeral(ctx, w, "You can't challenge yourself")
+		return
+	}
+	if wager > 0 {
+		if reqData.GuildID == "" {
+			bs.respondEphemeral(ctx, w, "Coins can only be wagered in a server")
+			return
+		}
+		if !bs.guildSettings(ctx, reqData.GuildID).Economy {
+			bs.respondEphemeral(ctx, w, economyOff)
+			return
+		}
+		balance, err := bs.Ledger.Balance(ledger.UserAccount(reqData.GuildID, challengerId))
+		if err != nil {
+			http.Error(w, "Server Error", http.StatusInternalServerError)
+			logging.FromContext(ctx).Error("could not load balance", logging.KeyError, err)
+			return
+		}
+		if balance < wager {
+			bs.respondEphemeral(ctx, w, fmt.Sprintf("You only have **%d** coins, claim more with `/daily`", balance))
+			return
+		}
+	}
 
 	p1 := &domain.Player{
 		ID:     challengerId,
 		Choice: domain.RpsChoice(choice),
 	}
 	// create a new challenge
-	challenge, err := challenge.NewChallenge(challengeId, p1)
+	newChallenge, err := challenge.NewChallenge(challengeId, scopeOf(reqData), p1)
 	if err != nil {
 		http.Error(w, "Server Error", http.StatusInternalServerError)
-		slog.Error("error encoding ping response", "details", err.Error())
+		logging.FromContext(ctx).Error("could not create challenge", logging.KeyError, err)
 		return
 	}
-	bs.Store.CreateChallenge(challenge) // support for another context is not provided
+	newChallenge.SetTarget(target)
+	newChallenge.SetWager(wager)
+	if fair {
+		nonce, err := commitment.Nonce(rand.Reader)
+		if err != nil {
+			http.Error(w, "Server Error", http.StatusInternalServerError)
+			logging.FromContext(ctx).Error("could not generate nonce", logging.KeyError, err)
+			return
+		}
+		newChallenge.SetCommitment(commitment.Commit(p1.Choice, nonce), nonce)
+	}
+	bs.openChallenge(ctx, w, reqData, newChallenge)
+}
+
+// openChallenge stores a new challenge issued by reqData and posts it with its accept button
+func (bs *BotServer) openChallenge(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction, newChallenge *challenge.Challenge) {
+	challengeId, _ := newChallenge.GetChallengeID()
+	now := time.Now()
+	newChallenge.SetOrigin(challenge.Origin{
+		InteractionToken: reqData.Token,
+		IssuedAt:         now,
+	})
+	if ttl := bs.guildSettings(ctx, newChallenge.Scope().GuildID).ChallengeTTL; ttl > 0 {
+		newChallenge.SetExpiry(now.Add(ttl))
+	}
+	if err := bs.store().CreateChallenge(ctx, newChallenge); err != nil {
+		var limitErr *memory.LimitError
+		if errors.As(err, &limitErr) {
+			bs.respondEphemeral(ctx, w, limitMessage(limitErr))
+			return
+		}
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not store challenge", logging.KeyError, err)
+		return
+	}
+	bs.recordChallengeEvents(ctx, newChallenge, newChallenge.History())
+	bs.saveCommitment(ctx, newChallenge)
 
 	// respond with a message component
 	btnComponent := interaction.BtnComponent{
 		Type:     BUTTON,
 		Label:    "accept",
 		Style:    PRIMARY,
-		CustomId: fmt.Sprintf("accept_button_%s", challengeId),
+		CustomId: bs.IDs.MustEncode(acceptRoute, customid.String(challengeId)),
 	}
 	var components interface{}
 	components = []interaction.BtnComponent{
@@ -102,22 +188,253 @@ func (bs *BotServer) HandleChanllengeCmd(w http.ResponseWriter, reqData interact
 	resp := interaction.InteractionResponse{
 		Type: CHANNEL_MESSAGE_WITH_SOURCE,
 		Data: interaction.ResponseData{
-			Content: fmt.Sprintf("accept challenge from <@%s>", reqData.Member.User.ID),
+			Content: challengeMessage(newChallenge) + commitmentMessage(newChallenge),
 			Components: []interaction.ResponseDataComponent{
 				respCompnent,
 			},
 		},
 	}
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(resp)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(ctx).Error("failed to send interaction response", logging.KeyError, err)
+		return
+	}
+
+	go bs.attachChallengeMessage(context.WithoutCancel(ctx), newChallenge)
 }
 
-func (bs *BotServer) HandleAcceptComponentInteraction(w http.ResponseWriter, cmpInteraction interaction.ComponentInteraction) {
-	challengeId := strings.Replace(cmpInteraction.Data.CustomId, "accept_button_", "", -1)
+// attachChallengeMessage records the id of the message a challenge was posted with,
+// so it can be linked to and edited later
+func (bs *BotServer) attachChallengeMessage(ctx context.Context, c *challenge.Challenge) {
+	logger := logging.FromContext(ctx)
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	endpoint := fmt.Sprintf("webhooks/%v/%v/messages/@original", bs.Config.AppID, c.Origin().InteractionToken)
+	response, err := bs.DiscordRequest(ctx, endpoint, DiscordRequestOption{Method: GET})
+	if err != nil {
+		logger.Error("could not fetch challenge message", logging.KeyError, err)
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		logger.Error("failed to fetch challenge message", "status", response.StatusCode)
+		return
+	}
+	var message interaction.ComponentInteractionMessage
+	if err := json.NewDecoder(response.Body).Decode(&message); err != nil {
+		logger.Error("could not decode challenge message", logging.KeyError, err)
+		return
+	}
 
+	id, _ := c.GetChallengeID()
+	err = bs.updateChallenge(ctx, c.Scope(), id, func(c *challenge.Challenge) {
+		origin := c.Origin()
+		origin.MessageID = message.ID
+		c.SetOrigin(origin)
+	})
+	// a challenge withdrawn in the meantime has no message to attach
+	if err != nil && !errors.Is(err, memory.ErrChallengeNotFound) {
+		logger.Error("could not attach message to challenge", logging.KeyError, err)
+	}
+}
+
+// HandleCancelChallengeCmd withdraws the invoking user's open challenge in the channel
+// and edits the challenge message to show it was withdrawn
+func (bs *BotServer) HandleCancelChallengeCmd(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+	ctx, span := tracing.Start(ctx, "handler.HandleCancelChallengeCmd")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+
+	scope := scopeOf(reqData)
+	userID := reqData.InvokingUser().ID
+	listed, err := bs.store().ListChallenges(ctx, memory.ChallengeQuery{
+		ChallengeFilter: memory.ChallengeFilter{Scope: &scope, ChallengerID: userID},
+		Statuses:        []challenge.Status{challenge.Open},
+	})
+	if err != nil {
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logger.Error("could not list challenges", logging.KeyError, err)
+		return
+	}
+	// tournament matches are ended by cancelling their tournament
+	open := listed.Challenges[:0]
+	for _, c := range listed.Challenges {
+		if tournamentID, _ := c.Tournament(); tournamentID == "" {
+			open = append(open, c)
+		}
+	}
+	if len(open) == 0 {
+		bs.respondEphemeral(ctx, w, "You have no open challenge in this channel")
+		return
+	}
+
+	cancelled := 0
+	for _, c := range open {
+		id, _ := c.GetChallengeID()
+		if _, err := bs.transitionChallenge(ctx, scope, id, challenge.Open, func(c *challenge.Challenge) error {
+			return c.Cancel(userID, time.Now())
+		}); err != nil {
+			// accepted while cancelling, the game goes on
+			continue
+		}
+		if err := bs.store().DeleteChallenge(ctx, scope, id); err != nil {
+			logger.Error("could not delete cancelled challenge", logging.KeyError, err)
+		}
+		cancelled++
+		go bs.editChallengeMessage(context.WithoutCancel(logging.With(ctx, logging.KeyChallengeID, id)), c, reqData.Token,
+			fmt.Sprintf("~~%s~~ challenge withdrawn", challengeMessage(c)))
+	}
+	if cancelled == 0 {
+		bs.respondEphemeral(ctx, w, "Your challenge was already accepted, it can't be withdrawn anymore")
+		return
+	}
+	bs.respondEphemeral(ctx, w, "Your challenge was withdrawn")
+}
+
+// editChallengeMessage replaces the content of a challenge message and removes its buttons,
+// token is used when the original interaction token has expired and may be empty
+func (bs *BotServer) editChallengeMessage(ctx context.Context, c *challenge.Challenge, token, content string) {
+	bs.editMessage(ctx, c.Scope(), c.Origin(), token, map[string]interface{}{
+		"content":    content,
+		"components": []interface{}{},
+	})
+}
+
+// editMessage edits a message posted in scope with body, the original interaction token is
+// preferred, token is used once it expired and may be empty
+func (bs *BotServer) editMessage(ctx context.Context, scope challenge.Scope, origin challenge.Origin, token string, body map[string]interface{}) {
+	logger := logging.FromContext(ctx)
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	var endpoint string
+	switch {
+	case origin.TokenValid(time.Now()):
+		endpoint = fmt.Sprintf("webhooks/%v/%v/messages/@original", bs.Config.AppID, origin.InteractionToken)
+	case origin.MessageID != "" && token != "":
+		endpoint = fmt.Sprintf("webhooks/%v/%v/messages/%v", bs.Config.AppID, token, origin.MessageID)
+	case origin.MessageID != "" && scope.Context == challenge.GuildContext:
+		// without a token only a bot in the guild can edit its message
+		endpoint = fmt.Sprintf("channels/%v/messages/%v", scope.ChannelID, origin.MessageID)
+	default:
+		logger.Warn("message can no longer be edited")
+		return
+	}
+	options := DiscordRequestOption{
+		Method: PATCH,
+		Body:   body,
+	}
+	response, err := bs.DiscordRequest(ctx, endpoint, options)
+	if err != nil {
+		logger.Error("could not edit message", logging.KeyError, err)
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		logger.Error("failed to edit message", "status", response.StatusCode)
+	}
+}
+
+// challengeMessage is the content of the message a challenge is posted with
+func challengeMessage(c *challenge.Challenge) string {
+	var stake string
+	if c.Wager() > 0 {
+		stake = fmt.Sprintf(" for **%d** coins", c.Wager())
+	}
+	kind := "challenge"
+	if _, rematch := c.Series(); rematch > 0 {
+		kind = fmt.Sprintf("rematch #%d", rematch)
+	}
+	if target := c.Target(); target != "" {
+		return fmt.Sprintf("<@%s>, accept %s from <@%s>%s", target, kind, c.Challenger().ID, stake)
+	}
+	return fmt.Sprintf("accept %s from <@%s>%s", kind, c.Challenger().ID, stake)
+}
+
+// limitMessage explains to a user why their challenge was not opened
+func limitMessage(err *memory.LimitError) string {
+	switch err.Limit {
+	case memory.UserLimit:
+		if err.Existing != nil && err.Existing.Origin().MessageID != "" {
+			return fmt.Sprintf("You already have an open challenge here: %s\nUse `/challenge cancel` to withdraw it",
+				messageLink(err.Existing.Scope(), err.Existing.Origin().MessageID))
+		}
+		return "You already have an open challenge in this channel, use `/challenge cancel` to withdraw it"
+	case memory.ChannelLimit:
+		return fmt.Sprintf("This channel already has %d open challenges, accept one of them instead", err.Max)
+	default:
+		return fmt.Sprintf("This server already has %d open challenges, try again once some are played", err.Max)
+	}
+}
+
+// messageLink returns a jump link to a message
+func messageLink(scope challenge.Scope, messageID string) string {
+	guild := scope.GuildID
+	if guild == "" {
+		guild = "@me"
+	}
+	return fmt.Sprintf("https://discord.com/channels/%s/%s/%s", guild, scope.ChannelID, messageID)
+}
+
+func (bs *BotServer) HandleAcceptComponentInteraction(ctx context.Context, w http.ResponseWriter, cmpInteraction *interaction.Interaction, id customid.ID) {
+	ctx, span := tracing.Start(ctx, "handler.HandleAcceptComponentInteraction")
+	defer span.End()
+	challengeId := id.Str(0)
+	ctx = logging.With(ctx, logging.KeyChallengeID, challengeId)
+	logger := logging.FromContext(ctx)
+
+	userID := cmpInteraction.InvokingUser().ID
+	acceptedChallenge, err := bs.acceptChallenge(ctx, scopeOf(cmpInteraction), challengeId, userID, time.Now())
+	if err != nil {
+		bs.respondEphemeral(ctx, w, claimFailedMessage(acceptedChallenge, err))
+		return
+	}
+
+	cmpRespData := bs.choiceSelect(challengeId)
+	resp := interaction.InteractionResponse{
+		Type: CHANNEL_MESSAGE_WITH_SOURCE,
+		Data: cmpRespData,
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Error("failed to send interaction response", logging.KeyError, err)
+		return
+	}
+
+	go func() {
+		// delete the accept message so no other can accept again
+		endpoint := bs.challengeMessageEndpoint(acceptedChallenge, cmpInteraction)
+		options := DiscordRequestOption{
+			Method: DELETE,
+		}
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Minute)
+		defer cancel()
+		response, err := bs.DiscordRequest(ctx, endpoint, options)
+		if err != nil {
+			logger.Error("could not delete accept button compnent", logging.KeyError, err)
+			return
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusNoContent {
+			logger.Error("failed to delete discord message", "status", response.StatusCode)
+			return
+		}
+	}()
+
+}
+
+// choiceSelect is the ephemeral message a player picks their object with
+func (bs *BotServer) choiceSelect(challengeID string) interaction.ResponseData {
+	return objectSelect(bs.IDs.MustEncode(choiceRoute, customid.String(challengeID)))
+}
+
+// objectSelect is the ephemeral message a player picks their object with
+// from the select identified by customID
+func objectSelect(customID string) interaction.ResponseData {
 	strSelect := interaction.StringSelectComponent{
 		Type:     STRING_SELECT,
-		CustomId: fmt.Sprintf("select_choice_%v", challengeId),
+		CustomId: customID,
 		Options: []interaction.StrSelectOption{
 			{
 				Label:       "Rock",
@@ -142,104 +459,201 @@ func (bs *BotServer) HandleAcceptComponentInteraction(w http.ResponseWriter, cmp
 		Type:       ACTION_ROW,
 		Components: components.([]interaction.StringSelectComponent),
 	}
-	cmpRespData := interaction.ResponseData{
+	return interaction.ResponseData{
 		Content: "What is your object of choice?",
 		Flags:   EPHEMERAL,
 		Components: []interaction.ResponseDataComponent{
 			respCompnent,
 		},
 	}
-	resp := interaction.InteractionResponse{
-		Type: CHANNEL_MESSAGE_WITH_SOURCE,
-		Data: cmpRespData,
-	}
-	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
-		slog.Error("failed to send interaction response", "error", err.Error())
-		return
-	}
-
-	go func() {
-		// delete the accept message so no other can accept again
-		endpoint := fmt.Sprintf("webhooks/%v/%v/messages/%v", bs.Config.AppID, cmpInteraction.Token, cmpInteraction.Message.ID)
-		options := DiscordRequestOption{
-			Method: DELETE,
-		}
-		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-		defer cancel()
-		response, err := bs.DiscordRequest(ctx, endpoint, options)
-		if err != nil {
-			slog.Error("could not delete accept button compnent", "details", err.Error())
-			return
-		}
-		if response.StatusCode != http.StatusNoContent {
-			fmt.Printf("Failed to delete Discord message: unexpected status code %v\n", response.StatusCode)
-			return
-		}
-	}()
-
 }
 
-func (bs *BotServer) HandleChoiceSelectionInteraction(w http.ResponseWriter, cmpInteraction interaction.ComponentInteraction) {
-	challengeID := strings.Replace(cmpInteraction.Data.CustomId, "select_choice_", "", -1)
+func (bs *BotServer) HandleChoiceSelectionInteraction(ctx context.Context, w http.ResponseWriter, cmpInteraction *interaction.Interaction, id customid.ID) {
+	ctx, span := tracing.Start(ctx, "handler.HandleChoiceSelectionInteraction")
+	defer span.End()
+	cmpData, _ := cmpInteraction.ComponentData()
+	challengeID := id.Str(0)
+	ctx = logging.With(ctx, logging.KeyChallengeID, challengeID)
+	logger := logging.FromContext(ctx)
 
-	challenge, err := bs.Store.GetChallenge(challengeID)
-	if err != nil {
-		// If game expands use a message interaction as the response
-		http.Error(w, "Challenge not found", http.StatusNotFound)
+	scope := scopeOf(cmpInteraction)
+	opponentId := cmpInteraction.InvokingUser().ID
+	if len(cmpData.Values) == 0 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
 		return
 	}
-	opponentId := cmpInteraction.Member.User.ID
-	choice := cmpInteraction.Data.Values[0]
 	opponent := &domain.Player{
 		ID:     opponentId,
-		Choice: domain.RpsChoice(choice),
+		Choice: domain.RpsChoice(cmpData.Values[0]),
+	}
+	resolved, err := bs.transitionChallenge(ctx, scope, challengeID, challenge.Claimed, func(c *challenge.Challenge) error {
+		return c.MakeChoice(opponent, time.Now())
+	})
+	if err != nil {
+		bs.respondEphemeral(ctx, w, resolveFailedMessage(resolved, err))
+		if !isExpectedStateError(err) {
+			logger.Error("could not determin challenge result", logging.KeyError, err)
+		}
+		return
+	}
+	if resolved.Status() != challenge.Resolved {
+		// the other player of a match has not chosen yet
+		other := resolved.ClaimedBy()
+		if other == opponentId {
+			other = resolved.Challenger().ID
+		}
+		bs.respondEphemeral(ctx, w, fmt.Sprintf("Locked in, waiting for <@%s>", other))
+		go bs.acknowledgeChoice(context.WithoutCancel(ctx), cmpInteraction)
+		return
 	}
-	challenge.SetOpponent(opponent)
-	err = challenge.DetermineChallengeResult()
+	resultStr, err := resolved.GetResultMsg()
 	if err != nil {
 		http.Error(w, "Server Error", http.StatusInternalServerError)
-		slog.Error("could not determin challenge result", "details", err.Error())
+		logger.Error("could not format challenge result", logging.KeyError, err)
 		return
 	}
-	resultStr, err := challenge.GetResultMsg()
-	err = bs.Store.DeleteChallenge(challengeID)
+	err = bs.store().DeleteChallenge(ctx, scope, challengeID)
 	if err != nil {
 		http.Error(w, "Server Error", http.StatusInternalServerError)
-		slog.Error("could not delete a challenge after getting it's result", "details", err.Error())
+		logger.Error("could not delete a challenge after getting it's result", logging.KeyError, err)
 		return
 	}
+	respData := interaction.ResponseData{
+		Content: resultStr + wagerMessage(resolved) + revealMessage(resolved),
+	}
+	if tournamentID, _ := resolved.Tournament(); tournamentID == "" && bs.Config.RematchesEnabled {
+		series, err := bs.recordSeries(ctx, resolved)
+		if err != nil {
+			logger.Error("could not record series", logging.KeyError, err)
+		} else {
+			respData.Content += seriesMessage(resolved, series)
+			respData.Components = []interaction.ResponseDataComponent{bs.rematchButton(series.ID)}
+		}
+	}
 	resp := interaction.InteractionResponse{
 		Type: CHANNEL_MESSAGE_WITH_SOURCE,
-		Data: interaction.ResponseData{
-			Content: resultStr,
-		},
+		Data: respData,
 	}
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(resp)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Error("failed to send interaction response", logging.KeyError, err)
+	}
 
-	go func() {
-		endpoint := fmt.Sprintf("webhooks/%v/%v/messages/%v", bs.Config.AppID, cmpInteraction.Token, cmpInteraction.Message.ID)
-		var body interface{}
-		body = map[string]interface{}{
-			"content":    fmt.Sprintf("Nice choice <@%v>", cmpInteraction.Member.User.ID),
-			"components": nil,
-		}
-		options := DiscordRequestOption{
-			Method: PATCH,
-			Body:   body.(map[string]interface{}),
-		}
+	go bs.acknowledgeChoice(context.WithoutCancel(ctx), cmpInteraction)
+	if tournamentID, _ := resolved.Tournament(); tournamentID != "" {
+		go bs.editChallengeMessage(context.WithoutCancel(ctx), resolved, "", resultStr)
+	}
+}
 
-		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
-		defer cancel()
-		response, err := bs.DiscordRequest(ctx, endpoint, options)
-		if err != nil {
-			slog.Error("could not update the select choice message", "details", err.Error())
-			return
+// acknowledgeChoice replaces the select a player chose their object with
+func (bs *BotServer) acknowledgeChoice(ctx context.Context, cmpInteraction *interaction.Interaction) {
+	logger := logging.FromContext(ctx)
+	endpoint := fmt.Sprintf("webhooks/%v/%v/messages/%v", bs.Config.AppID, cmpInteraction.Token, cmpInteraction.Message.ID)
+	var body interface{}
+	body = map[string]interface{}{
+		"content":    fmt.Sprintf("Nice choice <@%v>", cmpInteraction.InvokingUser().ID),
+		"components": nil,
+	}
+	options := DiscordRequestOption{
+		Method: PATCH,
+		Body:   body.(map[string]interface{}),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	response, err := bs.DiscordRequest(ctx, endpoint, options)
+	if err != nil {
+		logger.Error("could not update the select choice message", logging.KeyError, err)
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		logger.Error("failed to update discord message", "status", response.StatusCode)
+		return
+	}
+}
+
+// claimFailedMessage explains why accepting a challenge failed,
+// current is the challenge as stored when the claim was attempted
+func claimFailedMessage(current *challenge.Challenge, err error) string {
+	var funds *ledger.InsufficientFundsError
+	switch {
+	case errors.As(err, &funds) && current != nil:
+		if funds.Account == ledger.UserAccount(current.Scope().GuildID, current.Challenger().ID) {
+			return fmt.Sprintf("<@%s> no longer has the coins to cover this wager", current.Challenger().ID)
 		}
-		if response.StatusCode != http.StatusOK {
-			fmt.Printf("Failed to update Discord message: unexpected status code %v\n", response.StatusCode)
-			return
+		return fmt.Sprintf("You need **%d** coins to accept this challenge, claim some with `/daily`", current.Wager())
+	case errors.Is(err, challenge.ErrOwnChallenge):
+		return "You can't accept your own challenge"
+	case errors.Is(err, challenge.ErrNotTarget) && current != nil:
+		return fmt.Sprintf("This challenge was issued to <@%s>", current.Target())
+	case errors.Is(err, memory.ErrStateConflict) && current != nil && current.Status() == challenge.Claimed:
+		return fmt.Sprintf("Too slow! <@%s> already accepted this challenge", current.ClaimedBy())
+	default:
+		return "This challenge is no longer available"
+	}
+}
+
+// resolveFailedMessage explains why playing a claimed challenge failed
+func resolveFailedMessage(current *challenge.Challenge, err error) string {
+	switch {
+	case errors.Is(err, challenge.ErrNotClaimant) && current != nil:
+		if tournamentID, _ := current.Tournament(); tournamentID != "" {
+			return fmt.Sprintf("Only <@%s> and <@%s> play this match", current.Challenger().ID, current.ClaimedBy())
 		}
-	}()
+		return fmt.Sprintf("Only <@%s> can play this challenge", current.ClaimedBy())
+	case errors.Is(err, challenge.ErrInvalidPlayer):
+		return "Pick rock, paper or scissors"
+	case errors.Is(err, challenge.ErrChoiceMade):
+		return "You already made your choice"
+	default:
+		return "This challenge is no longer available"
+	}
+}
+
+// isExpectedStateError reports whether err is the result of a user acting on a
+// challenge in the wrong state rather than a server failure
+func isExpectedStateError(err error) bool {
+	return errors.Is(err, memory.ErrStateConflict) ||
+		errors.Is(err, memory.ErrChallengeNotFound) ||
+		errors.Is(err, challenge.ErrIllegalTransition) ||
+		errors.Is(err, challenge.ErrNotClaimant) ||
+		errors.Is(err, challenge.ErrChoiceMade) ||
+		errors.Is(err, challenge.ErrInvalidPlayer)
+}
+
+// scopeOf returns the challenge scope an interaction was issued in
+func scopeOf(i *interaction.Interaction) challenge.Scope {
+	return challenge.Scope{
+		Context:   i.Context,
+		GuildID:   i.GuildID,
+		ChannelID: i.ChannelID,
+	}
+}
+
+// challengeMessageEndpoint returns the webhook endpoint of the message a challenge was issued with.
+//
+// The original interaction token is preferred since it is the only way to reach the message
+// in group DMs and user installed contexts, once it expires the token of the component
+// interaction clicked on the message is used instead
+func (bs *BotServer) challengeMessageEndpoint(c *challenge.Challenge, cmpInteraction *interaction.Interaction) string {
+	if origin := c.Origin(); origin.TokenValid(time.Now()) {
+		return fmt.Sprintf("webhooks/%v/%v/messages/@original", bs.Config.AppID, origin.InteractionToken)
+	}
+	return fmt.Sprintf("webhooks/%v/%v/messages/%v", bs.Config.AppID, cmpInteraction.Token, cmpInteraction.Message.ID)
+}
+
+// respondEphemeral responds to an interaction with a message only the invoking user can see
+func (bs *BotServer) respondEphemeral(ctx context.Context, w http.ResponseWriter, content string) {
+	resp := interaction.InteractionResponse{
+		Type: CHANNEL_MESSAGE_WITH_SOURCE,
+		Data: interaction.ResponseData{
+			Content: content,
+			Flags:   EPHEMERAL,
+		},
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(ctx).Error("failed to send interaction response", logging.KeyError, err)
+	}
 }
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/ekefan/discord-bot/domain/history"
+	"github.com/ekefan/discord-bot/events"
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/memory"
+)
+
+// recordGame rates a finished game and appends it to the game history
+func (bs *BotServer) recordGame(ctx context.Context, e events.Event) {
+	if !finished(e) {
+		return
+	}
+	delta := bs.updateRatings(ctx, e)
+	if err := bs.History.AppendGame(gameRecord(e, delta)); err != nil && !errors.Is(err, memory.ErrGameExists) {
+		logging.FromContext(ctx).Error("could not record game", logging.KeyChallengeID, e.Challenge.ID, logging.KeyError, err)
+	}
+}
+
+// gameRecord builds the history record of a finished game, delta is how much the winner's rating moved
+func gameRecord(e events.Event, delta int) history.Record {
+	snapshot, result := e.Challenge, e.Challenge.Result
+	r := history.Record{
+		ID:           snapshot.ID,
+		GuildID:      snapshot.GuildID,
+		ChannelID:    snapshot.ChannelID,
+		Players:      [2]string{snapshot.ChallengerID, snapshot.OpponentID},
+		Draw:         result.Draw,
+		Forfeit:      result.Forfeit,
+		RuleSet:      history.RuleCasual,
+		Wager:        snapshot.Wager,
+		TournamentID: snapshot.TournamentID,
+		CreatedAt:    snapshot.CreatedAt,
+		ResolvedAt:   e.At,
+	}
+	switch {
+	case snapshot.BotGame:
+		r.RuleSet = history.RuleBot
+	case snapshot.TournamentID != "":
+		r.RuleSet = history.RuleTournament
+	}
+	if !result.Draw {
+		r.WinnerID = result.WinnerID
+	}
+	for i, p := range r.Players {
+		switch p {
+		case result.WinnerID:
+			r.Throws[i] = domain.RpsChoice(result.WinnerChoice)
+			r.RatingDelta[i] = delta
+		case result.LooserID:
+			r.Throws[i] = domain.RpsChoice(result.LooserChoice)
+			r.RatingDelta[i] = -delta
+		}
+	}
+	return r
+}
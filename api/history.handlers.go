@@ -0,0 +1,178 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/ekefan/discord-bot/domain/history"
+	"github.com/ekefan/discord-bot/domain/interaction"
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/tracing"
+)
+
+// historyPages is the name of the history page source, rendered from
+// historyArgs arguments: the user and the opponent games are filtered by
+const (
+	historyPages = "history"
+	historyArgs  = 2
+)
+
+// historyPageSize is the number of games shown per history page
+const historyPageSize = 10
+
+// HandleHistoryCmd shows the first page of the games of a user, optionally only against another user
+func (bs *BotServer) HandleHistoryCmd(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+	ctx, span := tracing.Start(ctx, "handler.HandleHistoryCmd")
+	defer span.End()
+	if reqData.GuildID == "" {
+		bs.respondEphemeral(ctx, w, "History is only kept in servers")
+		return
+	}
+	cmdData, _ := reqData.CommandData()
+	owner := reqData.InvokingUser().ID
+	userID, ok := interaction.OptionValue(cmdData.Options, "user")
+	if !ok {
+		userID = owner
+	}
+	vs, _ := interaction.OptionValue(cmdData.Options, "vs")
+	if vs == userID {
+		bs.respondEphemeral(ctx, w, "Pick two different players")
+		return
+	}
+	bs.respondPaginated(ctx, w, reqData, 0, historyPages, userID, vs)
+}
+
+// HandleHistoryExportCmd uploads every game of the guild as a CSV or JSON file,
+// the command requires the manage guild permission
+func (bs *BotServer) HandleHistoryExportCmd(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+	ctx, span := tracing.Start(ctx, "handler.HandleHistoryExportCmd")
+	defer span.End()
+	if reqData.GuildID == "" {
+		bs.respondEphemeral(ctx, w, "History is only kept in servers")
+		return
+	}
+	cmdData, _ := reqData.CommandData()
+	format, _ := interaction.OptionValue(cmdData.Options, "format")
+
+	records, _, err := bs.History.Games(history.Query{GuildID: reqData.GuildID})
+	if err != nil {
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not load history", logging.KeyError, err)
+		return
+	}
+	var file bytes.Buffer
+	switch format {
+	case "json":
+		err = history.WriteJSON(&file, records)
+	default:
+		format = "csv"
+		err = history.WriteCSV(&file, records)
+	}
+	if err != nil {
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not export history", logging.KeyError, err)
+		return
+	}
+	bs.respondWithFile(ctx, w, interaction.ResponseData{
+		Content: fmt.Sprintf("%d games played in this server", len(records)),
+		Flags:   EPHEMERAL,
+	}, fmt.Sprintf("history-%s.%s", reqData.GuildID, format), file.Bytes())
+}
+
+// historyPage renders a page of the games of a user, Args holds the user and
+// the opponent games are filtered by, which may be empty
+func (bs *BotServer) historyPage(ctx context.Context, req PageRequest) (Page, error) {
+	userID, vs := req.Args[0], req.Args[1]
+	all, total, err := bs.History.Games(history.Query{GuildID: req.GuildID, UserID: userID, OpponentID: vs})
+	if err != nil {
+		return Page{}, err
+	}
+	pages := max(1, (total+historyPageSize-1)/historyPageSize)
+	offset := min(req.Page, pages-1) * historyPageSize
+
+	var b strings.Builder
+	tally := history.TallyOf(userID, all)
+	if vs != "" {
+		fmt.Fprintf(&b, "**<@%s> vs <@%s>**", userID, vs)
+	} else {
+		fmt.Fprintf(&b, "**Games of <@%s>**", userID)
+	}
+	fmt.Fprintf(&b, " %dW %dL %dD\n", tally.Wins, tally.Losses, tally.Draws)
+	if total == 0 {
+		b.WriteString("No games played yet")
+	}
+	for _, r := range all[offset:min(offset+historyPageSize, total)] {
+		b.WriteString(historyLine(r) + "\n")
+	}
+	return Page{Content: strings.TrimSuffix(b.String(), "\n"), Pages: pages}, nil
+}
+
+// historyLine describes a game in one line
+func historyLine(r history.Record) string {
+	var outcome string
+	switch {
+	case r.Draw:
+		outcome = "draw"
+	case r.Forfeit:
+		outcome = fmt.Sprintf("<@%s> won by forfeit", r.WinnerID)
+	default:
+		outcome = fmt.Sprintf("<@%s> won", r.WinnerID)
+	}
+	line := fmt.Sprintf("`%s` <@%s> **%s** vs <@%s> **%s**, %s",
+		r.ResolvedAt.UTC().Format("2006-01-02"), r.Players[0], r.Throws[0], r.Players[1], r.Throws[1], outcome)
+	if i := r.Index(r.WinnerID); i >= 0 && r.RatingDelta[i] != 0 {
+		line += fmt.Sprintf(" (%+d)", r.RatingDelta[i])
+	}
+	if r.RuleSet != history.RuleCasual {
+		line += fmt.Sprintf(" _(%s)_", r.RuleSet)
+	}
+	return line
+}
+
+// respondWithFile responds to an interaction with a message and an uploaded file
+func (bs *BotServer) respondWithFile(ctx context.Context, w http.ResponseWriter, data interaction.ResponseData, filename string, content []byte) {
+	data.Attachments = []interaction.Attachment{{ID: 0, Filename: filename}}
+	contentType, body, err := encodeFileResponse(interaction.InteractionResponse{
+		Type: CHANNEL_MESSAGE_WITH_SOURCE,
+		Data: data,
+	}, filename, content)
+	if err != nil {
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not encode file response", logging.KeyError, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		logging.FromContext(ctx).Error("failed to send interaction response", logging.KeyError, err)
+	}
+}
+
+// encodeFileResponse encodes an interaction response and the file it uploads as multipart form data
+func encodeFileResponse(resp interaction.InteractionResponse, filename string, content []byte) (string, []byte, error) {
+	payload, err := json.Marshal(resp)
+	if err != nil {
+		return "", nil, err
+	}
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	if err := mw.WriteField("payload_json", string(payload)); err != nil {
+		return "", nil, err
+	}
+	part, err := mw.CreateFormFile("files[0]", filename)
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := part.Write(content); err != nil {
+		return "", nil, err
+	}
+	if err := mw.Close(); err != nil {
+		return "", nil, err
+	}
+	return mw.FormDataContentType(), body.Bytes(), nil
+}
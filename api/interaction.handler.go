@@ -1,24 +1,28 @@
 package api
 
 import (
-	"encoding/json"
-	"log/slog"
+	"context"
 	"net/http"
 	"strings"
 
 	"github.com/ekefan/discord-bot/domain/command"
 	"github.com/ekefan/discord-bot/domain/interaction"
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/tracing"
 )
 
 const (
 	// InteractionTypes
-	PING                 = 1
-	APPLICATION_COMMMAND = 2
-	MESSAGE_COMPONENT    = 3
+	PING                             = 1
+	APPLICATION_COMMMAND             = 2
+	MESSAGE_COMPONENT                = 3
+	APPLICATION_COMMAND_AUTOCOMPLETE = 4
 
 	// Interaction Callback Type
-	CHANNEL_MESSAGE_WITH_SOURCE = 4
-	PONG                        = 1
+	CHANNEL_MESSAGE_WITH_SOURCE             = 4
+	UPDATE_MESSAGE                          = 7
+	APPLICATION_COMMAND_AUTOCOMPLETE_RESULT = 8
+	PONG                                    = 1
 
 	userAgent = "DiscordBot (https://github.com/ekefan/discord-bot, 1.0.0)"
 )
@@ -27,60 +31,194 @@ func (bs *BotServer) InteractionsHandler(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", "application/json; charset-UTF-8")
 	w.Header().Set("User-Agent", userAgent)
 
-	var reqPayload map[string]interface{}
-	if err := json.NewDecoder(r.Body).Decode(&reqPayload); err != nil {
+	reqData, err := interaction.Decode(r.Body)
+	if err != nil {
 		http.Error(w, "Bad request", http.StatusBadRequest)
+		logging.FromContext(r.Context()).Warn("received malformed interaction", logging.KeyError, err)
 		return
 	}
+	attrs := interactionLogAttrs(reqData)
+	ctx, span := tracing.Start(r.Context(), "router.dispatch", attrs...)
+	defer span.End()
+	ctx = logging.With(ctx, attrs...)
+	bs.router(ctx, w, reqData)
+}
+
+// InteractionHandler handles a decoded interaction
+type InteractionHandler func(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction)
+
+// RouterMiddleware wraps the handling of decoded interactions
+type RouterMiddleware func(next InteractionHandler) InteractionHandler
+
+// chain wraps h with middlewares, the first middleware runs first
+func chain(h InteractionHandler, middlewares ...RouterMiddleware) InteractionHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// dispatch routes an interaction to the handler of its command or component
+func (bs *BotServer) dispatch(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+	logger := logging.FromContext(ctx)
 
-	if reqPayload["type"].(float64) == PING {
-		bs.HandleDiscordPing(w)
+	if reqData.Type == PING {
+		bs.HandleDiscordPing(ctx, w)
 		return
 	}
 
-	if reqPayload["type"].(float64) == APPLICATION_COMMMAND {
-		var cmdInteraction interaction.SlashCommandInteraction
-		dataBytes, _ := json.Marshal(reqPayload)
-		err := json.Unmarshal(dataBytes, &cmdInteraction)
-		if err != nil {
-			http.Error(w, "Server Error", http.StatusInternalServerError)
-			slog.Error("could not assert the type of the data from discord interaction")
+	if reqData.Type == APPLICATION_COMMMAND {
+		cmdData, _ := reqData.CommandData()
+		if cmdData.Name == command.TestCommand {
+			bs.HandleTestCmd(ctx, w)
+			return
+		}
+		if cmdData.Name == command.PlayBotCommand {
+			bs.HandlePlayBotCmd(ctx, w, reqData)
+			return
+		}
+		if cmdData.Name == command.TournamentCommand {
+			bs.HandleTournamentCmd(ctx, w, reqData)
+			return
+		}
+		if cmdData.Name == command.RoundCommand {
+			bs.HandleRoundCmd(ctx, w, reqData)
+			return
+		}
+		if cmdData.Name == command.DailyCommand {
+			bs.HandleDailyCmd(ctx, w, reqData)
+			return
+		}
+		if cmdData.Name == command.BalanceCommand {
+			bs.HandleBalanceCmd(ctx, w, reqData)
+			return
+		}
+		if cmdData.Name == command.TransferCommand {
+			bs.HandleTransferCmd(ctx, w, reqData)
+			return
+		}
+		if cmdData.Name == command.VerifyCommand {
+			bs.HandleVerifyCmd(ctx, w, reqData)
+			return
+		}
+		if cmdData.Name == command.HistoryCommand {
+			bs.HandleHistoryCmd(ctx, w, reqData)
+			return
+		}
+		if cmdData.Name == command.HistoryExportCommand {
+			bs.HandleHistoryExportCmd(ctx, w, reqData)
+			return
+		}
+		if cmdData.Name == command.AccessCommand {
+			bs.HandleAccessCmd(ctx, w, reqData)
 			return
 		}
-		if cmdInteraction.Data.Name == command.TestCommand {
-			bs.HandleTestCmd(w)
+		if cmdData.Name == command.ConfigCommand {
+			bs.HandleConfigCmd(ctx, w, reqData)
+			return
+		}
+		if cmdData.Name == command.LeaderboardCommand {
+			bs.HandleLeaderboardCmd(ctx, w, reqData)
 			return
 		}
 
-		if cmdInteraction.Data.Name == command.ChallengeCommand {
-			bs.HandleChanllengeCmd(w, cmdInteraction)
+		if cmdData.Name == command.ChallengeCommand {
+			subcommand, _ := cmdData.Subcommand()
+			if subcommand == command.ChallengeCancelSubcommand {
+				bs.HandleCancelChallengeCmd(ctx, w, reqData)
+				return
+			}
+			bs.HandleChanllengeCmd(ctx, w, reqData)
 		} else {
 			http.Error(w, "Bad Request", http.StatusBadRequest)
-			slog.Error("received bad request interaction from discord", "details", "requested command doesn't exist on server")
+			logger.Error("received bad request interaction from discord", logging.KeyError, "requested command doesn't exist on server")
 			return
 		}
 		return
 	}
-	if reqPayload["type"].(float64) == MESSAGE_COMPONENT {
-		var cmpInteraction interaction.ComponentInteraction
-		dataBytes, _ := json.Marshal(reqPayload)
-		err := json.Unmarshal(dataBytes, &cmpInteraction)
+	if reqData.Type == APPLICATION_COMMAND_AUTOCOMPLETE {
+		cmdData, _ := reqData.CommandData()
+		if cmdData.Name == command.ConfigCommand {
+			bs.HandleConfigAutocomplete(ctx, w, reqData)
+			return
+		}
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		logger.Error("received autocomplete for a command without autocomplete options")
+		return
+	}
+	if reqData.Type == MESSAGE_COMPONENT {
+		cmpData, _ := reqData.ComponentData()
+		id, err := bs.IDs.Decode(cmpData.CustomId)
 		if err != nil {
-			http.Error(w, "Server Error", http.StatusInternalServerError)
-			slog.Error("could not assert the type of the data from discord interaction")
+			// stale ids are legitimate after a restart or an upgrade, forged ones are not
+			logger.Warn("rejected component custom id", logging.KeyError, err)
+			bs.respondEphemeral(ctx, w, "This component no longer works")
+			return
+		}
+		if id.Route == acceptRoute {
+			bs.HandleAcceptComponentInteraction(ctx, w, reqData, id)
+			return
+		}
+		if id.Route == choiceRoute {
+			bs.HandleChoiceSelectionInteraction(ctx, w, reqData, id)
+			return
+		}
+		if id.Route == tournamentJoinRoute {
+			bs.HandleTournamentJoinInteraction(ctx, w, reqData, id)
+			return
+		}
+		if id.Route == matchThrowRoute {
+			bs.HandleMatchThrowInteraction(ctx, w, reqData, id)
+			return
+		}
+		if id.Route == rematchRoute {
+			bs.HandleRematchInteraction(ctx, w, reqData, id)
 			return
 		}
-		if strings.HasPrefix(cmpInteraction.Data.CustomId, "accept_button_") {
-			bs.HandleAcceptComponentInteraction(w, cmpInteraction)
+		if id.Route == rematchChoiceRoute {
+			bs.HandleRematchChoiceInteraction(ctx, w, reqData, id)
 			return
 		}
-		if strings.HasPrefix(cmpInteraction.Data.CustomId, "select_choice_") {
-			bs.HandleChoiceSelectionInteraction(w, cmpInteraction)
+		if strings.HasPrefix(id.Route, pageRoutePrefix) {
+			bs.HandlePageInteraction(ctx, w, reqData, id)
 			return
 		}
+		if id.Route == roundRoute {
+			bs.HandleRoundComponentInteraction(ctx, w, reqData, id)
+			return
+		}
+		// a route this build signs ids for but has no handler for, e.g. a feature that was removed
+		logger.Warn("unhandled component route", "route", id.Route)
+		bs.respondEphemeral(ctx, w, "This component no longer works")
+		return
 	} else {
 		http.Error(w, "Bad Request", http.StatusBadRequest)
-		slog.Error("received bad request interaction from discord", "details", "interaction type not supported on this server")
+		logger.Error("received bad request interaction from discord", logging.KeyError, "interaction type not supported on this server")
 		return
 	}
 }
+
+// interactionLogAttrs picks the identifying fields of an interaction
+// to be logged with every record of the request
+func interactionLogAttrs(i *interaction.Interaction) []any {
+	attrs := []any{
+		logging.KeyInteractionID, i.ID,
+		logging.KeyInteractionType, int(i.Type),
+	}
+	if data, ok := i.CommandData(); ok {
+		attrs = append(attrs, logging.KeyCommand, data.Name)
+	}
+	if data, ok := i.ComponentData(); ok {
+		attrs = append(attrs, logging.KeyCustomID, data.CustomId)
+	}
+	if i.GuildID != "" {
+		attrs = append(attrs, logging.KeyGuildID, i.GuildID)
+	}
+	if i.ChannelID != "" {
+		attrs = append(attrs, logging.KeyChannelID, i.ChannelID)
+	}
+	if user := i.InvokingUser(); user.ID != "" {
+		attrs = append(attrs, logging.KeyUserID, user.ID)
+	}
+	return attrs
+}
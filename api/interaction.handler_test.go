@@ -0,0 +1,37 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ekefan/discord-bot/domain/interaction"
+	"github.com/ekefan/discord-bot/memory"
+	"github.com/ekefan/discord-bot/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDispatchUnhandledComponentRoute(t *testing.T) {
+	bs := NewBotServer(&util.EnvConfig{CustomIDSecret: "secret"}, memory.NewChallengeStore(memory.NewInMemory(memory.Limits{})))
+	// a route ids are still signed for while nothing handles it
+	bs.IDs.Register("gone", 1)
+	body, err := json.Marshal(map[string]any{
+		"id":       "i1",
+		"type":     MESSAGE_COMPONENT,
+		"guild_id": "g1",
+		"member":   map[string]any{"user": map[string]any{"id": "u1"}},
+		"data":     map[string]any{"custom_id": bs.IDs.MustEncode("gone"), "component_type": 2},
+	})
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	bs.InteractionsHandler(recorder, httptest.NewRequest(http.MethodPost, "/interactions", strings.NewReader(string(body))))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	var response interaction.InteractionResponse
+	require.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+	require.Equal(t, "This component no longer works", response.Data.Content)
+	require.Equal(t, EPHEMERAL, response.Data.Flags)
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ekefan/discord-bot/domain/interaction"
+	"github.com/ekefan/discord-bot/domain/settings"
+	"github.com/ekefan/discord-bot/tracing"
+)
+
+// leaderboardPages is the name of the leaderboard page source, it takes no arguments
+const leaderboardPages = "leaderboard"
+
+// leaderboardPageSize is the number of players shown per leaderboard page
+const leaderboardPageSize = 10
+
+// HandleLeaderboardCmd shows the best rated players of a guild, to the channel
+// or only to the member depending on the leaderboard setting of the guild
+func (bs *BotServer) HandleLeaderboardCmd(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+	ctx, span := tracing.Start(ctx, "handler.HandleLeaderboardCmd")
+	defer span.End()
+	if reqData.GuildID == "" {
+		bs.respondEphemeral(ctx, w, "Ratings are only kept in servers")
+		return
+	}
+	switch bs.guildSettings(ctx, reqData.GuildID).Leaderboard {
+	case settings.LeaderboardOff:
+		bs.respondEphemeral(ctx, w, "The leaderboard is turned off in this server")
+	case settings.LeaderboardPrivate:
+		bs.respondPaginated(ctx, w, reqData, EPHEMERAL, leaderboardPages)
+	default:
+		bs.respondPaginated(ctx, w, reqData, 0, leaderboardPages)
+	}
+}
+
+// leaderboardPage renders a page of the standings of a guild
+func (bs *BotServer) leaderboardPage(ctx context.Context, req PageRequest) (Page, error) {
+	standings, err := bs.Ratings.Standings(req.GuildID)
+	if err != nil {
+		return Page{}, err
+	}
+	if len(standings) == 0 {
+		return Page{Content: "Nobody played a rated game yet", Pages: 1}, nil
+	}
+	pages := (len(standings) + leaderboardPageSize - 1) / leaderboardPageSize
+	start := min(req.Page, pages-1) * leaderboardPageSize
+	end := min(start+leaderboardPageSize, len(standings))
+
+	var b strings.Builder
+	b.WriteString("**Leaderboard**")
+	for i, s := range standings[start:end] {
+		fmt.Fprintf(&b, "\n%d. <@%s> **%d**", start+i+1, s.UserID, s.Rating)
+	}
+	return Page{Content: b.String(), Pages: pages}, nil
+}
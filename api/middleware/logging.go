@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/ekefan/discord-bot/logging"
+)
+
+// RequestIDHeader is the header used to accept and echo correlation IDs
+const RequestIDHeader = "X-Request-ID"
+
+// WithRequestLogger attaches a request-scoped logger carrying a correlation ID
+// to the request context. An incoming X-Request-ID is reused when present
+func WithRequestLogger(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" || len(requestID) > 64 {
+			requestID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		ctx := logging.With(r.Context(), logging.KeyRequestID, requestID)
+		f(w, r.WithContext(ctx))
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
@@ -6,14 +6,15 @@ import (
 	"encoding/hex"
 	"errors"
 	"io"
-	"log/slog"
 	"net/http"
 
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/tracing"
 	"github.com/ekefan/discord-bot/util"
 )
 
 var (
-	ErrVerifySignature      = errors.New("signature, could not be verified")
+	ErrVerifySignature       = errors.New("signature, could not be verified")
 	ErrDecodingSignature     = errors.New("error decoding the hex signature")
 	ErrDecodingPubKey        = errors.New("error decoding the hex public key")
 	ErrInvalidPublicKey      = errors.New("environment config, public key is incorrect")
@@ -23,10 +24,14 @@ var (
 
 func VerifyDiscordSignature(f http.HandlerFunc, config *util.EnvConfig) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		_, span := tracing.Start(r.Context(), "middleware.VerifyDiscordSignature")
 		if err := verifySignature(w, r, config); err != nil {
-			slog.Error("couldn't verify discord signature", "details", err.Error())
+			span.RecordError(err)
+			span.End()
+			logging.FromContext(r.Context()).Warn("couldn't verify discord signature", logging.KeyError, err)
 			return
 		}
+		span.End()
 		f(w, r)
 	}
 }
@@ -34,6 +39,7 @@ func VerifyDiscordSignature(f http.HandlerFunc, config *util.EnvConfig) http.Han
 // verifySignature reads a signature and timestamp from the request
 // header and verifies it based on a the body of the request
 func verifySignature(w http.ResponseWriter, r *http.Request, config *util.EnvConfig) error {
+	logger := logging.FromContext(r.Context())
 	// read discords security headers
 	signature := r.Header.Get("X-Signature-Ed25519")
 	timestamp := r.Header.Get("X-Signature-Timestamp")
@@ -60,18 +66,18 @@ func verifySignature(w http.ResponseWriter, r *http.Request, config *util.EnvCon
 	pubKeyBytes, err := hex.DecodeString(config.PublicKey)
 	if err != nil {
 		http.Error(w, "Server Error", http.StatusInternalServerError)
-		slog.Error("error decoding public key", "details", err.Error())
+		logger.Error("error decoding public key", logging.KeyError, err)
 		return ErrDecodingPubKey
 	}
 	if len(pubKeyBytes) != ed25519.PublicKeySize {
-		slog.Error("incorrect discord public key size used")
+		logger.Error("incorrect discord public key size used")
 		http.Error(w, "Server Error", http.StatusInternalServerError)
 		return ErrInvalidPublicKey
 	}
 	sigBytes, err := hex.DecodeString(signature)
 	if err != nil {
 		http.Error(w, "Invalid signature", http.StatusUnauthorized)
-		slog.Error("error decoding discord signature", "details", err.Error())
+		logger.Debug("error decoding discord signature", logging.KeyError, err)
 		return ErrDecodingSignature
 	}
 
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/tracing"
+)
+
+// WithTracing starts the root span of an incoming request, continuing a trace
+// from the traceparent header when present. The trace id is added to the
+// request logger so logs and spans can be correlated
+func WithTracing(f http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := tracing.Extract(r.Context(), r.Header)
+		ctx, span := tracing.Start(ctx, r.Method+" "+r.URL.Path,
+			"http.method", r.Method,
+			"http.target", r.URL.Path,
+		)
+		defer span.End()
+		ctx = logging.With(ctx, "trace_id", span.SpanContext().TraceID.String())
+
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		f(sw, r.WithContext(ctx))
+		span.SetAttributes("http.status_code", sw.status)
+		if sw.status >= http.StatusInternalServerError {
+			span.SetStatus(tracing.StatusError, http.StatusText(sw.status))
+		}
+	}
+}
+
+// statusWriter records the status code written by a handler
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(status int) {
+	sw.status = status
+	sw.ResponseWriter.WriteHeader(status)
+}
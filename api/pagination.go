@@ -0,0 +1,272 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ekefan/discord-bot/customid"
+	"github.com/ekefan/discord-bot/domain/interaction"
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/tracing"
+)
+
+var ErrUnknownPages = errors.New("no page source registered under this name")
+
+// Page navigation actions
+const (
+	pageFirst = "first"
+	pagePrev  = "prev"
+	pageNext  = "next"
+	pageLast  = "last"
+)
+
+// pageRoutePrefix starts the component route of every page source
+const pageRoutePrefix = "page_"
+
+// pageParams is the number of cursor parameters before the arguments of a page source
+const pageParams = 4
+
+// DefaultPageTTL is how long page buttons work after a paginated message was posted
+const DefaultPageTTL = 10 * time.Minute
+
+// PageRequest asks a page source for one page, Page starts at zero
+type PageRequest struct {
+	GuildID string
+	Owner   string   // the user who asked for the paginated message
+	Args    []string // the arguments the message was created with
+	Page    int
+}
+
+// Page is one page rendered by a page source and the number of pages there are
+type Page struct {
+	Content string
+	Pages   int
+}
+
+// PageSource renders a page of a paginated message. It is called again for every
+// click so it must render from the request alone, a page past the end is clamped
+type PageSource func(ctx context.Context, req PageRequest) (Page, error)
+
+// Paginator renders paginated messages with first, prev, next and last buttons.
+//
+// Page sources are registered under a name, the buttons carry the page shown, the owner,
+// when the message was issued and the arguments of the source in the signed custom_id
+// of the page route of the source, so a click can render the next page without server
+// side state. Only the owner can turn pages and the buttons expire after TTL
+type Paginator struct {
+	TTL     time.Duration
+	ids     *customid.Codec
+	mu      sync.RWMutex
+	sources map[string]PageSource
+}
+
+func NewPaginator(ttl time.Duration, ids *customid.Codec) *Paginator {
+	return &Paginator{
+		TTL:     ttl,
+		ids:     ids,
+		sources: make(map[string]PageSource),
+	}
+}
+
+// Register adds the page source called name, rendered from args string arguments.
+// Like http.HandleFunc it panics when name is already registered
+func (p *Paginator) Register(name string, args int, source PageSource) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.sources[name]; ok {
+		panic(fmt.Sprintf("pagination: page source %q registered twice", name))
+	}
+	kinds := []customid.Kind{customid.KindInt, customid.KindString, customid.KindString, customid.KindInt}
+	for range args {
+		kinds = append(kinds, customid.KindString)
+	}
+	p.ids.Register(pageRoutePrefix+name, 1, kinds...)
+	p.sources[name] = source
+}
+
+// pageCursor is the state carried by a page button
+type pageCursor struct {
+	name     string
+	page     int
+	action   string
+	owner    string
+	issuedAt int64
+	args     []string
+}
+
+// customID encodes the cursor as a signed custom_id of the page route of its source
+func (c pageCursor) customID(ids *customid.Codec) (string, error) {
+	params := []customid.Param{
+		customid.Int(int64(c.page)),
+		customid.String(c.action),
+		customid.String(c.owner),
+		customid.Int(c.issuedAt),
+	}
+	for _, arg := range c.args {
+		params = append(params, customid.String(arg))
+	}
+	return ids.Encode(pageRoutePrefix+c.name, params...)
+}
+
+// parsePageCursor reads the cursor of a decoded page button
+func parsePageCursor(id customid.ID) pageCursor {
+	cursor := pageCursor{
+		name:     strings.TrimPrefix(id.Route, pageRoutePrefix),
+		page:     max(0, int(id.Int(0))),
+		action:   id.Str(1),
+		owner:    id.Str(2),
+		issuedAt: id.Int(3),
+	}
+	for i := pageParams; i < len(id.Params); i++ {
+		cursor.args = append(cursor.args, id.Str(i))
+	}
+	return cursor
+}
+
+// target returns the page the button of the cursor leads to,
+// the last page is past the end so rendering clamps it
+func (c pageCursor) target() int {
+	switch c.action {
+	case pageFirst:
+		return 0
+	case pagePrev:
+		return max(0, c.page-1)
+	case pageNext:
+		return c.page + 1
+	case pageLast:
+		return math.MaxInt32
+	default:
+		return c.page
+	}
+}
+
+// render renders the page of cursor, the buttons are disabled once expired
+func (p *Paginator) render(ctx context.Context, cursor pageCursor, guildID string, expired bool) (interaction.ResponseData, error) {
+	p.mu.RLock()
+	source, ok := p.sources[cursor.name]
+	p.mu.RUnlock()
+	if !ok {
+		return interaction.ResponseData{}, ErrUnknownPages
+	}
+	req := PageRequest{GuildID: guildID, Owner: cursor.owner, Args: cursor.args, Page: cursor.page}
+	page, err := source(ctx, req)
+	if err != nil {
+		return interaction.ResponseData{}, err
+	}
+	last := max(0, page.Pages-1)
+	if cursor.page > last {
+		// past the end, or pages were removed since the message was rendered
+		cursor.page = last
+		req.Page = last
+		if page, err = source(ctx, req); err != nil {
+			return interaction.ResponseData{}, err
+		}
+	}
+	data := interaction.ResponseData{Content: page.Content}
+	if page.Pages <= 1 {
+		return data, nil
+	}
+
+	data.Content += fmt.Sprintf("\n-# page %d of %d", cursor.page+1, page.Pages)
+	actions := []struct {
+		label, action string
+		disabled      bool
+	}{
+		{"⏮", pageFirst, cursor.page == 0},
+		{"◀", pagePrev, cursor.page == 0},
+		{"▶", pageNext, cursor.page == last},
+		{"⏭", pageLast, cursor.page == last},
+	}
+	buttons := make([]interaction.BtnComponent, 0, len(actions))
+	for _, a := range actions {
+		c := cursor
+		c.action = a.action
+		customID, err := c.customID(p.ids)
+		if err != nil {
+			return interaction.ResponseData{}, err
+		}
+		buttons = append(buttons, interaction.BtnComponent{
+			Type:     BUTTON,
+			Label:    a.label,
+			Style:    SECONDARY,
+			CustomId: customID,
+			Disabled: expired || a.disabled,
+		})
+	}
+	data.Components = []interaction.ResponseDataComponent{
+		{
+			Type:       ACTION_ROW,
+			Components: buttons,
+		},
+	}
+	return data, nil
+}
+
+// expired reports whether the buttons of cursor no longer work at t
+func (p *Paginator) expired(cursor pageCursor, t time.Time) bool {
+	return p.TTL > 0 && !t.Before(time.Unix(cursor.issuedAt, 0).Add(p.TTL))
+}
+
+// respondPaginated responds to an interaction with the first page of the source called name,
+// flags are the message flags of the response such as EPHEMERAL
+func (bs *BotServer) respondPaginated(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction, flags int, name string, args ...string) {
+	cursor := pageCursor{
+		name:     name,
+		owner:    reqData.InvokingUser().ID,
+		issuedAt: time.Now().Unix(),
+		args:     args,
+	}
+	data, err := bs.Pages.render(ctx, cursor, reqData.GuildID, false)
+	if err != nil {
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not render page", "pages", name, logging.KeyError, err)
+		return
+	}
+	data.Flags = flags
+	resp := interaction.InteractionResponse{
+		Type: CHANNEL_MESSAGE_WITH_SOURCE,
+		Data: data,
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(ctx).Error("failed to send interaction response", logging.KeyError, err)
+	}
+}
+
+// HandlePageInteraction turns the page of a paginated message, once the buttons
+// expired the page shown is rendered again with its buttons disabled
+func (bs *BotServer) HandlePageInteraction(ctx context.Context, w http.ResponseWriter, cmpInteraction *interaction.Interaction, id customid.ID) {
+	ctx, span := tracing.Start(ctx, "handler.HandlePageInteraction")
+	defer span.End()
+	cursor := parsePageCursor(id)
+	if cmpInteraction.InvokingUser().ID != cursor.owner {
+		bs.respondEphemeral(ctx, w, "Only the member who asked for this message can turn its pages")
+		return
+	}
+
+	expired := bs.Pages.expired(cursor, time.Now())
+	if !expired {
+		cursor.page = cursor.target()
+	}
+	data, err := bs.Pages.render(ctx, cursor, cmpInteraction.GuildID, expired)
+	if err != nil {
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not render page", "pages", cursor.name, logging.KeyError, err)
+		return
+	}
+	resp := interaction.InteractionResponse{
+		Type: UPDATE_MESSAGE,
+		Data: data,
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(ctx).Error("failed to send interaction response", logging.KeyError, err)
+	}
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"context"
+
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/domain/rating"
+	"github.com/ekefan/discord-bot/events"
+	"github.com/ekefan/discord-bot/logging"
+)
+
+// finished reports whether e ends a challenge with a result
+func finished(e events.Event) bool {
+	if e.Challenge == nil || e.Challenge.Result == nil {
+		return false
+	}
+	return e.Type == events.ChallengeEventType(challenge.EventResolved) ||
+		e.Type == events.ChallengeEventType(challenge.EventForfeited)
+}
+
+// updateRatings moves the ratings of both players of a finished game and returns how much
+// the winner's rating moved, the looser's moved by as much the other way.
+// Games against the bot only count when BotGamesRanked is set
+func (bs *BotServer) updateRatings(ctx context.Context, e events.Event) int {
+	if !finished(e) || (e.Challenge.BotGame && !bs.Config.BotGamesRanked) {
+		return 0
+	}
+	logger := logging.FromContext(ctx)
+	guildID, result := e.Challenge.GuildID, e.Challenge.Result
+	winner, err := bs.Ratings.Rating(guildID, result.WinnerID)
+	if err != nil {
+		logger.Error("could not load rating", logging.KeyUserID, result.WinnerID, logging.KeyError, err)
+		return 0
+	}
+	looser, err := bs.Ratings.Rating(guildID, result.LooserID)
+	if err != nil {
+		logger.Error("could not load rating", logging.KeyUserID, result.LooserID, logging.KeyError, err)
+		return 0
+	}
+	score := rating.Win
+	if result.Draw {
+		score = rating.Draw
+	}
+	newWinner, newLooser := rating.Update(winner, looser, score)
+	if err := bs.Ratings.SetRating(guildID, result.WinnerID, newWinner); err != nil {
+		logger.Error("could not save rating", logging.KeyUserID, result.WinnerID, logging.KeyError, err)
+	}
+	if err := bs.Ratings.SetRating(guildID, result.LooserID, newLooser); err != nil {
+		logger.Error("could not save rating", logging.KeyUserID, result.LooserID, logging.KeyError, err)
+	}
+	return newWinner - winner
+}
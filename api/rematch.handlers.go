@@ -0,0 +1,151 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ekefan/discord-bot/customid"
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/domain/interaction"
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/memory"
+	"github.com/ekefan/discord-bot/tracing"
+)
+
+// HandleRematchInteraction lets a player of a finished game pick their object for a rematch
+func (bs *BotServer) HandleRematchInteraction(ctx context.Context, w http.ResponseWriter, cmpInteraction *interaction.Interaction, id customid.ID) {
+	ctx, span := tracing.Start(ctx, "handler.HandleRematchInteraction")
+	defer span.End()
+	seriesID := id.Str(0)
+	if _, ok := bs.rematchSeries(ctx, w, cmpInteraction, seriesID); !ok {
+		return
+	}
+	resp := interaction.InteractionResponse{
+		Type: CHANNEL_MESSAGE_WITH_SOURCE,
+		Data: objectSelect(bs.IDs.MustEncode(rematchChoiceRoute, customid.String(seriesID))),
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(ctx).Error("failed to send interaction response", logging.KeyError, err)
+	}
+}
+
+// HandleRematchChoiceInteraction opens the rematch challenge against the other player of the series
+func (bs *BotServer) HandleRematchChoiceInteraction(ctx context.Context, w http.ResponseWriter, cmpInteraction *interaction.Interaction, id customid.ID) {
+	ctx, span := tracing.Start(ctx, "handler.HandleRematchChoiceInteraction")
+	defer span.End()
+	cmpData, _ := cmpInteraction.ComponentData()
+	seriesID := id.Str(0)
+	if len(cmpData.Values) == 0 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	series, ok := bs.rematchSeries(ctx, w, cmpInteraction, seriesID)
+	if !ok {
+		return
+	}
+	challengeID := cmpInteraction.ID
+	ctx = logging.With(ctx, logging.KeyChallengeID, challengeID)
+	userID := cmpInteraction.InvokingUser().ID
+	opponent, _ := series.Opponent(userID)
+
+	rematch, err := challenge.NewChallenge(challengeID, scopeOf(cmpInteraction), &domain.Player{
+		ID:     userID,
+		Choice: domain.RpsChoice(cmpData.Values[0]),
+	})
+	if err != nil {
+		bs.respondEphemeral(ctx, w, "Pick rock, paper or scissors")
+		return
+	}
+	rematch.SetTarget(opponent)
+	rematch.SetSeries(series.ID, series.Games)
+	bs.openChallenge(ctx, w, cmpInteraction, rematch)
+	go bs.acknowledgeChoice(context.WithoutCancel(ctx), cmpInteraction)
+}
+
+// rematchSeries returns the series a rematch is asked for, the user is told
+// when it expired or they did not play in it
+func (bs *BotServer) rematchSeries(ctx context.Context, w http.ResponseWriter, cmpInteraction *interaction.Interaction, seriesID string) (*challenge.Series, bool) {
+	series, err := bs.Series.GetSeries(seriesID)
+	if err != nil {
+		if errors.Is(err, memory.ErrSeriesNotFound) {
+			bs.respondEphemeral(ctx, w, "This rematch offer expired")
+			return nil, false
+		}
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not load series", logging.KeyError, err)
+		return nil, false
+	}
+	if series.ExpiredAt(time.Now()) {
+		bs.respondEphemeral(ctx, w, "This rematch offer expired")
+		return nil, false
+	}
+	if !series.Includes(cmpInteraction.InvokingUser().ID) {
+		bs.respondEphemeral(ctx, w, fmt.Sprintf("Only <@%s> and <@%s> can ask for a rematch", series.Players[0], series.Players[1]))
+		return nil, false
+	}
+	return series, true
+}
+
+// recordSeries adds the result of a casual game to the series of its players,
+// a first game starts a new series identified by the challenge
+func (bs *BotServer) recordSeries(ctx context.Context, c *challenge.Challenge) (*challenge.Series, error) {
+	var expiresAt time.Time
+	if ttl := bs.guildSettings(ctx, c.Scope().GuildID).ChallengeTTL; ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+	record := func(s *challenge.Series) error {
+		if err := s.Record(c.Result()); err != nil {
+			return err
+		}
+		s.ExpiresAt = expiresAt
+		return nil
+	}
+
+	seriesID, _ := c.Series()
+	if seriesID != "" {
+		series, err := bs.Series.UpdateSeries(seriesID, record)
+		if !errors.Is(err, memory.ErrSeriesNotFound) {
+			return series, err
+		}
+		// the series expired while the rematch was played, it starts over
+	} else {
+		seriesID, _ = c.GetChallengeID()
+	}
+	series, err := challenge.NewSeries(seriesID, c.Challenger().ID, c.ClaimedBy())
+	if err != nil {
+		return nil, err
+	}
+	if err := record(series); err != nil {
+		return nil, err
+	}
+	return series, bs.Series.SaveSeries(series)
+}
+
+// rematchButton is the button a player of a finished game asks for a rematch with
+func (bs *BotServer) rematchButton(seriesID string) interaction.ResponseDataComponent {
+	return interaction.ResponseDataComponent{
+		Type: ACTION_ROW,
+		Components: []interaction.BtnComponent{
+			{
+				Type:     BUTTON,
+				Label:    "Rematch",
+				Style:    SECONDARY,
+				CustomId: bs.IDs.MustEncode(rematchRoute, customid.String(seriesID)),
+			},
+		},
+	}
+}
+
+// seriesMessage tells the score of the series a rematch was played in
+func seriesMessage(c *challenge.Challenge, series *challenge.Series) string {
+	if _, rematch := c.Series(); rematch > 0 {
+		return fmt.Sprintf("\n-# Rematch #%d, series %s", rematch, series.Score())
+	}
+	return ""
+}
@@ -0,0 +1,448 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ekefan/discord-bot/customid"
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/domain/interaction"
+	"github.com/ekefan/discord-bot/domain/round"
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/memory"
+	"github.com/ekefan/discord-bot/tracing"
+)
+
+// Round component actions, a round component custom id is round_<action>_<round id>
+const (
+	roundJoin   = "join"
+	roundJoin1  = "join1"
+	roundJoin2  = "join2"
+	roundStart  = "start"
+	roundCancel = "cancel"
+	roundThrow  = "throw"
+	roundSelect = "select"
+)
+
+// HandleRoundCmd opens the lobby of a round of more than two players
+func (bs *BotServer) HandleRoundCmd(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+	ctx, span := tracing.Start(ctx, "handler.HandleRoundCmd")
+	defer span.End()
+	ctx = logging.With(ctx, logging.KeyRoundID, reqData.ID)
+	if !bs.Config.RoundsEnabled {
+		bs.respondEphemeral(ctx, w, "Rounds are turned off on this bot")
+		return
+	}
+
+	cmdData, _ := reqData.CommandData()
+	guild := bs.guildSettings(ctx, reqData.GuildID)
+	rule, ok := interaction.OptionValue(cmdData.Options, "rule")
+	if !ok {
+		rule = guild.RuleSet
+	}
+	teamSize := 0
+	switch mode, _ := interaction.OptionValue(cmdData.Options, "mode"); mode {
+	case "2v2":
+		teamSize = 2
+	case "3v3":
+		teamSize = 3
+	}
+	maxPlayers, _ := interaction.OptionInt(cmdData.Options, "max_players")
+
+	r, err := round.New(reqData.ID, scopeOf(reqData), reqData.InvokingUser().ID, round.Rule(rule), teamSize, maxPlayers)
+	if err != nil {
+		bs.respondEphemeral(ctx, w, "Pick one of the rules and modes")
+		return
+	}
+	now := time.Now()
+	r.SetOrigin(challenge.Origin{
+		InteractionToken: reqData.Token,
+		IssuedAt:         now,
+	})
+	if guild.ChallengeTTL > 0 {
+		r.SetExpiry(now.Add(guild.ChallengeTTL))
+	}
+	if err := bs.Rounds.CreateRound(r); err != nil {
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not store round", logging.KeyError, err)
+		return
+	}
+
+	resp := interaction.InteractionResponse{
+		Type: CHANNEL_MESSAGE_WITH_SOURCE,
+		Data: bs.roundMessage(r),
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(ctx).Error("failed to send interaction response", logging.KeyError, err)
+	}
+}
+
+// HandleRoundComponentInteraction dispatches the buttons and selects of a round
+func (bs *BotServer) HandleRoundComponentInteraction(ctx context.Context, w http.ResponseWriter, cmpInteraction *interaction.Interaction, id customid.ID) {
+	ctx, span := tracing.Start(ctx, "handler.HandleRoundComponentInteraction")
+	defer span.End()
+	action, roundID := id.Str(0), id.Str(1)
+	ctx = logging.With(ctx, logging.KeyRoundID, roundID)
+
+	switch action {
+	case roundJoin:
+		bs.joinRound(ctx, w, cmpInteraction, roundID, 0)
+	case roundJoin1:
+		bs.joinRound(ctx, w, cmpInteraction, roundID, round.Team1)
+	case roundJoin2:
+		bs.joinRound(ctx, w, cmpInteraction, roundID, round.Team2)
+	case roundStart:
+		bs.startRound(ctx, w, cmpInteraction, roundID)
+	case roundCancel:
+		bs.cancelRound(ctx, w, cmpInteraction, roundID)
+	case roundThrow:
+		bs.openRoundThrow(ctx, w, cmpInteraction, roundID)
+	case roundSelect:
+		bs.throwRound(ctx, w, cmpInteraction, roundID)
+	default:
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		logging.FromContext(ctx).Error("received unknown round component", "action", action)
+	}
+}
+
+func (bs *BotServer) joinRound(ctx context.Context, w http.ResponseWriter, cmpInteraction *interaction.Interaction, roundID string, team int) {
+	userID := cmpInteraction.InvokingUser().ID
+	r, err := bs.Rounds.UpdateRound(roundID, func(r *round.Round) error {
+		return r.Join(userID, team)
+	})
+	switch {
+	case err == nil:
+		bs.updateRoundMessage(ctx, w, r)
+	case errors.Is(err, round.ErrAlreadyJoined):
+		bs.respondEphemeral(ctx, w, "You already joined this round")
+	case errors.Is(err, round.ErrLobbyFull):
+		bs.respondEphemeral(ctx, w, "This round is full")
+	case errors.Is(err, round.ErrTeamFull):
+		bs.respondEphemeral(ctx, w, fmt.Sprintf("Team %d is full", team))
+	case errors.Is(err, round.ErrLobbyClosed), errors.Is(err, memory.ErrRoundNotFound):
+		bs.respondEphemeral(ctx, w, "This round is no longer open for players")
+	default:
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not join round", logging.KeyError, err)
+	}
+}
+
+func (bs *BotServer) startRound(ctx context.Context, w http.ResponseWriter, cmpInteraction *interaction.Interaction, roundID string) {
+	userID := cmpInteraction.InvokingUser().ID
+	r, err := bs.Rounds.UpdateRound(roundID, func(r *round.Round) error {
+		return r.Start(userID)
+	})
+	switch {
+	case err == nil:
+		bs.updateRoundMessage(ctx, w, r)
+	case errors.Is(err, round.ErrNotHost):
+		bs.respondEphemeral(ctx, w, fmt.Sprintf("Only <@%s> can start this round", r.Host()))
+	case errors.Is(err, round.ErrNotEnoughPlayers):
+		bs.respondEphemeral(ctx, w, "A round needs at least two players")
+	case errors.Is(err, round.ErrTeamsNotFull):
+		bs.respondEphemeral(ctx, w, fmt.Sprintf("Both teams need %d players to start", r.TeamSize()))
+	case errors.Is(err, round.ErrLobbyClosed), errors.Is(err, memory.ErrRoundNotFound):
+		bs.respondEphemeral(ctx, w, "This round already started")
+	default:
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not start round", logging.KeyError, err)
+	}
+}
+
+func (bs *BotServer) cancelRound(ctx context.Context, w http.ResponseWriter, cmpInteraction *interaction.Interaction, roundID string) {
+	userID := cmpInteraction.InvokingUser().ID
+	r, err := bs.Rounds.UpdateRound(roundID, func(r *round.Round) error {
+		return r.Cancel(userID)
+	})
+	switch {
+	case err == nil:
+	case errors.Is(err, round.ErrNotHost):
+		bs.respondEphemeral(ctx, w, fmt.Sprintf("Only <@%s> can cancel this round", r.Host()))
+		return
+	case errors.Is(err, round.ErrRoundOver), errors.Is(err, memory.ErrRoundNotFound):
+		bs.respondEphemeral(ctx, w, "This round is already over")
+		return
+	default:
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not cancel round", logging.KeyError, err)
+		return
+	}
+	if err := bs.Rounds.DeleteRound(roundID); err != nil {
+		logging.FromContext(ctx).Error("could not delete cancelled round", logging.KeyError, err)
+	}
+	bs.respondEphemeral(ctx, w, "The round was cancelled")
+	go bs.closeRoundMessage(context.WithoutCancel(ctx), r, cmpInteraction.Token,
+		fmt.Sprintf("~~%s~~ round cancelled", roundHeading(r)))
+}
+
+// openRoundThrow lets a playing player of a round pick their object
+func (bs *BotServer) openRoundThrow(ctx context.Context, w http.ResponseWriter, cmpInteraction *interaction.Interaction, roundID string) {
+	r, err := bs.Rounds.GetRound(roundID)
+	if err != nil || r.Status() != round.Throwing {
+		bs.respondEphemeral(ctx, w, "This round is over")
+		return
+	}
+	userID := cmpInteraction.InvokingUser().ID
+	playing := false
+	for _, p := range r.Playing() {
+		if p.ID == userID {
+			playing = true
+		}
+	}
+	if !playing {
+		bs.respondEphemeral(ctx, w, "You are not playing this round")
+		return
+	}
+	resp := interaction.InteractionResponse{
+		Type: CHANNEL_MESSAGE_WITH_SOURCE,
+		Data: objectSelect(bs.IDs.MustEncode(roundRoute, customid.String(roundSelect), customid.String(roundID))),
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(ctx).Error("failed to send interaction response", logging.KeyError, err)
+	}
+}
+
+// throwRound records the secret throw of a player, the last throw of a stage
+// either announces the result or the next stage of an elimination round
+func (bs *BotServer) throwRound(ctx context.Context, w http.ResponseWriter, cmpInteraction *interaction.Interaction, roundID string) {
+	logger := logging.FromContext(ctx)
+	cmpData, _ := cmpInteraction.ComponentData()
+	if len(cmpData.Values) == 0 {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return
+	}
+	userID := cmpInteraction.InvokingUser().ID
+	choice := domain.RpsChoice(cmpData.Values[0])
+	var stageOver bool
+	r, err := bs.Rounds.UpdateRound(roundID, func(r *round.Round) error {
+		var err error
+		stageOver, err = r.Throw(userID, choice)
+		return err
+	})
+	switch {
+	case err == nil:
+	case errors.Is(err, round.ErrAlreadyThrown):
+		bs.respondEphemeral(ctx, w, "You already made your choice")
+		return
+	case errors.Is(err, round.ErrInvalidChoice):
+		bs.respondEphemeral(ctx, w, "Pick rock, paper or scissors")
+		return
+	case errors.Is(err, round.ErrNotPlaying):
+		bs.respondEphemeral(ctx, w, "You are not playing this round")
+		return
+	case errors.Is(err, round.ErrNotThrowing), errors.Is(err, memory.ErrRoundNotFound):
+		bs.respondEphemeral(ctx, w, "This round is over")
+		return
+	default:
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logger.Error("could not throw in round", logging.KeyError, err)
+		return
+	}
+	go bs.acknowledgeChoice(context.WithoutCancel(ctx), cmpInteraction)
+	if !stageOver {
+		bs.respondEphemeral(ctx, w, fmt.Sprintf("Locked in, waiting for %d more players", len(r.Waiting())))
+		return
+	}
+
+	var content string
+	if r.Status() == round.Resolved {
+		resultStr, err := r.Result().FormatResult()
+		if err != nil {
+			http.Error(w, "Server Error", http.StatusInternalServerError)
+			logger.Error("could not format round result", logging.KeyError, err)
+			return
+		}
+		content = resultStr
+		if err := bs.Rounds.DeleteRound(roundID); err != nil {
+			logger.Error("could not delete a round after getting it's result", logging.KeyError, err)
+		}
+		go bs.closeRoundMessage(context.WithoutCancel(ctx), r, cmpInteraction.Token, roundHeading(r))
+	} else {
+		content = stageMessage(r.Stages()[len(r.Stages())-1])
+		go bs.editMessage(context.WithoutCancel(ctx), r.Scope(), r.Origin(), cmpInteraction.Token, bs.roundMessageBody(r))
+	}
+	resp := interaction.InteractionResponse{
+		Type: CHANNEL_MESSAGE_WITH_SOURCE,
+		Data: interaction.ResponseData{
+			Content: content,
+		},
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logger.Error("failed to send interaction response", logging.KeyError, err)
+	}
+}
+
+// RunRoundExpiry ends unfinished rounds past their TTL every interval until ctx is done
+func (bs *BotServer) RunRoundExpiry(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			bs.ExpireRounds(ctx, now)
+		}
+	}
+}
+
+// ExpireRounds ends every round past its expiry at now, removes it and edits its message.
+// It returns the number of expired rounds
+func (bs *BotServer) ExpireRounds(ctx context.Context, now time.Time) int {
+	ctx, span := tracing.Start(ctx, "expiry.ExpireRounds")
+	defer span.End()
+	logger := logging.FromContext(ctx)
+
+	rounds, err := bs.Rounds.ListRounds()
+	if err != nil {
+		span.RecordError(err)
+		logger.Error("could not list rounds to expire", logging.KeyError, err)
+		return 0
+	}
+	expired := 0
+	for _, r := range rounds {
+		if !r.ExpiredAt(now) {
+			continue
+		}
+		r, err := bs.Rounds.UpdateRound(r.ID(), func(r *round.Round) error {
+			if !r.ExpiredAt(now) {
+				return round.ErrRoundOver
+			}
+			return r.Expire()
+		})
+		if err != nil {
+			// resolved or cancelled since it was listed
+			continue
+		}
+		if err := bs.Rounds.DeleteRound(r.ID()); err != nil {
+			logger.Error("could not delete expired round", logging.KeyRoundID, r.ID(), logging.KeyError, err)
+		}
+		expired++
+		bs.closeRoundMessage(logging.With(ctx, logging.KeyRoundID, r.ID()), r, "",
+			fmt.Sprintf("~~%s~~ round expired", roundHeading(r)))
+	}
+	span.SetAttributes("round.expired", expired)
+	return expired
+}
+
+// updateRoundMessage responds to a component of the round message by updating it
+func (bs *BotServer) updateRoundMessage(ctx context.Context, w http.ResponseWriter, r *round.Round) {
+	resp := interaction.InteractionResponse{
+		Type: UPDATE_MESSAGE,
+		Data: bs.roundMessage(r),
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(ctx).Error("failed to send interaction response", logging.KeyError, err)
+	}
+}
+
+// closeRoundMessage replaces the content of the round message and removes its buttons
+func (bs *BotServer) closeRoundMessage(ctx context.Context, r *round.Round, token, content string) {
+	bs.editMessage(ctx, r.Scope(), r.Origin(), token, map[string]interface{}{
+		"content":    content,
+		"components": []interface{}{},
+	})
+}
+
+// roundMessageBody is the edit of the round message for its current state
+func (bs *BotServer) roundMessageBody(r *round.Round) map[string]interface{} {
+	data := bs.roundMessage(r)
+	return map[string]interface{}{
+		"content":    data.Content,
+		"components": data.Components,
+	}
+}
+
+// roundMessage is the message a round is posted with, its buttons follow the state of the round
+func (bs *BotServer) roundMessage(r *round.Round) interaction.ResponseData {
+	button := func(label string, style int, action string) interaction.BtnComponent {
+		return interaction.BtnComponent{
+			Type:     BUTTON,
+			Label:    label,
+			Style:    style,
+			CustomId: bs.IDs.MustEncode(roundRoute, customid.String(action), customid.String(r.ID())),
+		}
+	}
+	var buttons []interaction.BtnComponent
+	var b strings.Builder
+	b.WriteString(roundHeading(r))
+	switch r.Status() {
+	case round.Lobby:
+		if r.TeamSize() == 0 {
+			buttons = append(buttons, button("join", PRIMARY, roundJoin))
+		} else {
+			buttons = append(buttons, button("join team 1", PRIMARY, roundJoin1), button("join team 2", PRIMARY, roundJoin2))
+		}
+		buttons = append(buttons, button("start", SUCCESS, roundStart))
+	case round.Throwing:
+		fmt.Fprintf(&b, "\nStage %d, throw with the button", r.Stage())
+		buttons = append(buttons, button("throw", PRIMARY, roundThrow))
+	}
+	buttons = append(buttons, button("cancel", DANGER, roundCancel))
+
+	if r.TeamSize() == 0 {
+		fmt.Fprintf(&b, "\nPlayers: %s", mentions(r.Team(0)))
+	} else {
+		fmt.Fprintf(&b, "\nTeam 1: %s\nTeam 2: %s", mentions(r.Team(round.Team1)), mentions(r.Team(round.Team2)))
+	}
+	return interaction.ResponseData{
+		Content: b.String(),
+		Components: []interaction.ResponseDataComponent{
+			{
+				Type:       ACTION_ROW,
+				Components: buttons,
+			},
+		},
+	}
+}
+
+// roundHeading describes how a round is played
+func roundHeading(r *round.Round) string {
+	mode := "free for all"
+	if r.TeamSize() != 0 {
+		mode = fmt.Sprintf("%dv%d", r.TeamSize(), r.TeamSize())
+	}
+	heading := fmt.Sprintf("<@%s> opened a %s %s round", r.Host(), r.Rule(), mode)
+	if r.TeamSize() == 0 && r.MaxPlayers() > 0 {
+		heading += fmt.Sprintf(" for up to %d players", r.MaxPlayers())
+	}
+	return heading
+}
+
+// stageMessage announces the outcome of an elimination stage that did not end the round
+func stageMessage(s round.Stage) string {
+	if len(s.Eliminated) == 0 {
+		return fmt.Sprintf("Stage %d: %d objects were thrown, nobody is knocked out, throw again", s.Number, len(s.Choices))
+	}
+	return fmt.Sprintf("Stage %d: %s knocked out %s, the others throw again", s.Number, winningChoice(s.Choices), mentions(s.Eliminated))
+}
+
+// winningChoice returns the choice that beats the other of exactly two choices
+func winningChoice(choices []domain.RpsChoice) domain.RpsChoice {
+	if domain.Counter(choices[0]) == choices[1] {
+		return choices[1]
+	}
+	return choices[0]
+}
+
+// mentions formats user ids as a comma separated list of mentions
+func mentions(ids []string) string {
+	if len(ids) == 0 {
+		return "-"
+	}
+	formatted := make([]string, len(ids))
+	for i, id := range ids {
+		formatted[i] = fmt.Sprintf("<@%s>", id)
+	}
+	return strings.Join(formatted, ", ")
+}
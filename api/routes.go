@@ -0,0 +1,43 @@
+package api
+
+import (
+	"crypto/rand"
+
+	"github.com/ekefan/discord-bot/customid"
+	"github.com/ekefan/discord-bot/util"
+)
+
+// Component routes, a custom_id names the route of the handler it is dispatched to.
+// Bump the version of a route whenever its parameters change
+const (
+	acceptRoute         = "accept"
+	choiceRoute         = "choice"
+	tournamentJoinRoute = "tournament_join"
+	matchThrowRoute     = "match_throw"
+	rematchRoute        = "rematch"
+	rematchChoiceRoute  = "rematch_choice"
+	roundRoute          = "round"
+)
+
+// registerRoutes registers the component routes and their parameters,
+// page routes are registered with their page source
+func registerRoutes(ids *customid.Codec) {
+	ids.Register(acceptRoute, 1, customid.KindString)
+	ids.Register(choiceRoute, 1, customid.KindString)
+	ids.Register(tournamentJoinRoute, 1, customid.KindString)
+	ids.Register(matchThrowRoute, 1, customid.KindString)
+	ids.Register(rematchRoute, 1, customid.KindString)
+	ids.Register(rematchChoiceRoute, 1, customid.KindString)
+	ids.Register(roundRoute, 1, customid.KindString, customid.KindString)
+}
+
+// customIDKey returns the key custom_ids are signed with, without a configured
+// secret a random key is used and components stop working when the bot restarts
+func customIDKey(config *util.EnvConfig) []byte {
+	if config != nil && config.CustomIDSecret != "" {
+		return []byte(config.CustomIDSecret)
+	}
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
@@ -7,25 +7,80 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log/slog"
 	"net/http"
 	"time"
 
+	"github.com/ekefan/discord-bot/customid"
 	"github.com/ekefan/discord-bot/domain/command"
+	"github.com/ekefan/discord-bot/domain/throttle"
+	"github.com/ekefan/discord-bot/events"
+	"github.com/ekefan/discord-bot/logging"
 	"github.com/ekefan/discord-bot/memory"
+	"github.com/ekefan/discord-bot/tracing"
 	"github.com/ekefan/discord-bot/util"
 )
 
 type BotServer struct {
-	Config *util.EnvConfig
-	Store  memory.ChallangeRespository
+	Config      *util.EnvConfig
+	Store       memory.ChallengeStore
+	Throws      memory.ThrowRepository
+	Tournaments memory.TournamentRepository
+	Rounds      memory.RoundRepository
+	Ratings     memory.RatingRepository
+	Ledger      memory.LedgerRepository
+	Series      memory.SeriesRepository
+	Commitments memory.CommitmentRepository
+	History     memory.HistoryRepository
+	IDs         *customid.Codec
+	Pages       *Paginator
+	Access      memory.AccessRepository
+	Settings    memory.SettingsRepository
+	Throttle    memory.ThrottleRepository
+	Events      *events.Bus
+
+	// Limits are how fast users may use commands and components, the zero Limits throttle nothing
+	Limits throttle.Limits
+
+	// router handles decoded interactions, dispatch wrapped in the router middlewares
+	router InteractionHandler
+	// commandPermissions is the permission bit set each command requires, zero for open commands
+	commandPermissions map[string]uint64
 }
 
-func NewBotServer(config *util.EnvConfig, store memory.ChallangeRespository) *BotServer {
-	return &BotServer{
-		Config: config,
-		Store:  store,
+// throwHistorySize is the number of throws per user the computer player learns from
+const throwHistorySize = 200
+
+func NewBotServer(config *util.EnvConfig, store memory.ChallengeStore) *BotServer {
+	bs := &BotServer{
+		Config:      config,
+		Store:       store,
+		Throws:      memory.NewInMemoryThrows(throwHistorySize),
+		Tournaments: memory.NewInMemoryTournaments(),
+		Rounds:      memory.NewInMemoryRounds(),
+		Ratings:     memory.NewInMemoryRatings(),
+		Ledger:      memory.NewInMemoryLedger(),
+		Series:      memory.NewInMemorySeries(),
+		Commitments: memory.NewInMemoryCommitments(),
+		History:     memory.NewInMemoryHistory(),
+		IDs:         customid.NewCodec(customIDKey(config)),
+		Access:      memory.NewInMemoryAccess(),
+		Settings:    memory.NewInMemorySettings(),
+		Throttle:    memory.NewInMemoryThrottle(),
+		Events:      events.NewBus(),
+
+		commandPermissions: requiredPermissions(),
 	}
+	bs.router = chain(bs.dispatch, bs.throttle, bs.authorize)
+	bs.Pages = NewPaginator(DefaultPageTTL, bs.IDs)
+	registerRoutes(bs.IDs)
+	bs.Pages.Register(historyPages, historyArgs, bs.historyPage)
+	bs.Pages.Register(leaderboardPages, 0, bs.leaderboardPage)
+	bs.Events.Subscribe(bs.recordThrows)
+	bs.Events.Subscribe(bs.recordGame)
+	bs.Events.Subscribe(bs.advanceTournaments)
+	bs.Events.Subscribe(bs.settleWagers)
+	bs.Events.Subscribe(bs.revealCommitments)
+	return bs
 }
 
 type ReqMethod string
@@ -67,7 +122,13 @@ type DiscordRequestOption struct {
 }
 
 func (bs *BotServer) DiscordRequest(ctx context.Context, endpoint string, options DiscordRequestOption) (*http.Response, error) {
+	ctx, span := tracing.Start(ctx, "discord.request",
+		"http.method", string(options.Method),
+		"discord.endpoint", logging.RedactString(endpoint),
+	)
+	defer span.End()
 	if !options.Method.Valid() {
+		span.RecordError(ErrInvalidReqMethod)
 		return nil, ErrInvalidReqMethod
 	}
 
@@ -96,8 +157,22 @@ func (bs *BotServer) DiscordRequest(ctx context.Context, endpoint string, option
 	request.Header.Add("Authorization", fmt.Sprintf("Bot %v", bs.Config.DiscordToken))
 	request.Header.Add("Content-Type", "application/json; charset=UTF-8")
 	request.Header.Add("User-Agent", "DiscordBot (https://github.com/ekefan/discord-bot, 1.0.0)")
+	tracing.Inject(ctx, request.Header)
 
-	return http.DefaultClient.Do(request)
+	logger := logging.FromContext(ctx).With("method", options.Method, "endpoint", endpoint)
+	start := time.Now()
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		logger.Error("discord request failed", "duration", time.Since(start), logging.KeyError, err)
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttributes("http.status_code", response.StatusCode)
+	if response.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(tracing.StatusError, response.Status)
+	}
+	logger.Debug("discord request completed", "status", response.StatusCode, "duration", time.Since(start))
+	return response, nil
 }
 
 func retryRequest(client *http.Client, request *http.Request, retries int) (*http.Response, error) {
@@ -121,7 +196,7 @@ func (bs *BotServer) InstallGlobalCommands(ctx context.Context, appId, botToken
 	}
 	_, err := bs.DiscordRequest(ctx, url, options)
 	if err != nil {
-		slog.Error("error installing global commands", "details", err)
+		logging.FromContext(ctx).Error("error installing global commands", logging.KeyError, err)
 		return err
 	}
 	return nil
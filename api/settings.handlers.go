@@ -0,0 +1,202 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ekefan/discord-bot/domain/command"
+	"github.com/ekefan/discord-bot/domain/interaction"
+	"github.com/ekefan/discord-bot/domain/round"
+	"github.com/ekefan/discord-bot/domain/settings"
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/tracing"
+)
+
+// maxAutocompleteChoices is the most choices discord shows while an option is typed
+const maxAutocompleteChoices = 25
+
+// economyOff is the reply to economy commands in guilds that turned coins off
+const economyOff = "Coins are turned off in this server"
+
+// defaultSettings are the settings of guilds that override nothing, and of DMs
+func (bs *BotServer) defaultSettings() settings.Settings {
+	return settings.Settings{
+		RuleSet:      string(round.Pairwise),
+		ChallengeTTL: bs.Config.ChallengeTTL,
+		Economy:      bs.Config.EconomyEnabled,
+		Leaderboard:  settings.LeaderboardPublic,
+	}
+}
+
+// guildSettings returns the effective settings of a guild, the defaults
+// when its overrides can't be loaded. Handlers read settings only through it
+func (bs *BotServer) guildSettings(ctx context.Context, guildID string) settings.Settings {
+	defaults := bs.defaultSettings()
+	if guildID == "" {
+		return defaults
+	}
+	overrides, err := bs.Settings.Overrides(guildID)
+	if err != nil {
+		logging.FromContext(ctx).Error("could not load guild settings, using defaults", logging.KeyGuildID, guildID, logging.KeyError, err)
+		return defaults
+	}
+	resolved := settings.Resolve(defaults, overrides)
+	// a guild can't turn coins on when they are off for every guild
+	resolved.Economy = resolved.Economy && bs.Config.EconomyEnabled
+	return resolved
+}
+
+// HandleConfigCmd reads, changes and resets the settings of a guild
+func (bs *BotServer) HandleConfigCmd(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+	ctx, span := tracing.Start(ctx, "handler.HandleConfigCmd")
+	defer span.End()
+	if reqData.GuildID == "" {
+		bs.respondEphemeral(ctx, w, "Settings only apply in servers")
+		return
+	}
+	cmdData, _ := reqData.CommandData()
+	subcommand, options := cmdData.Subcommand()
+	key, _ := interaction.OptionValue(options, "key")
+	if _, ok := settings.Lookup(key); key != "" && !ok {
+		bs.respondEphemeral(ctx, w, fmt.Sprintf("There is no `%s` setting, pick one of %s", key, settingNames()))
+		return
+	}
+
+	var err error
+	switch subcommand {
+	case command.ConfigGetSubcommand:
+	case command.ConfigSetSubcommand:
+		value, _ := interaction.OptionValue(options, "value")
+		if value, err = settings.Parse(key, value); err != nil {
+			bs.respondEphemeral(ctx, w, settingError(err))
+			return
+		}
+		err = bs.Settings.SetOverride(reqData.GuildID, key, value)
+	case command.ConfigResetSubcommand:
+		err = bs.Settings.ResetOverride(reqData.GuildID, key)
+	default:
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		logging.FromContext(ctx).Error("received unknown config sub command", "subcommand", subcommand)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not update guild settings", logging.KeyError, err)
+		return
+	}
+	overrides, err := bs.Settings.Overrides(reqData.GuildID)
+	if err != nil {
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not load guild settings", logging.KeyError, err)
+		return
+	}
+	bs.respondEphemeral(ctx, w, settingsMessage(bs.guildSettings(ctx, reqData.GuildID), overrides, key))
+}
+
+// HandleConfigAutocomplete suggests setting names, and the values of the picked setting
+func (bs *BotServer) HandleConfigAutocomplete(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+	ctx, span := tracing.Start(ctx, "handler.HandleConfigAutocomplete")
+	defer span.End()
+	cmdData, _ := reqData.CommandData()
+	_, options := cmdData.Subcommand()
+	focused, _ := interaction.FocusedOption(options)
+	typed := strings.ToLower(string(focused.Value))
+
+	var suggestions []string
+	switch focused.Name {
+	case "key":
+		for _, key := range settings.Keys {
+			suggestions = append(suggestions, key.Name)
+		}
+	case "value":
+		name, _ := interaction.OptionValue(options, "key")
+		if key, ok := settings.Lookup(name); ok {
+			suggestions = key.Choices
+		}
+	}
+	choices := []interaction.AutocompleteChoice{}
+	for _, s := range suggestions {
+		if strings.Contains(strings.ToLower(s), typed) && len(choices) < maxAutocompleteChoices {
+			choices = append(choices, interaction.AutocompleteChoice{Name: s, Value: s})
+		}
+	}
+	resp := interaction.AutocompleteResponse{
+		Type: APPLICATION_COMMAND_AUTOCOMPLETE_RESULT,
+		Data: interaction.AutocompleteData{Choices: choices},
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(ctx).Error("failed to send interaction response", logging.KeyError, err)
+	}
+}
+
+// settingsMessage lists the effective settings of a guild, only the setting called key when set.
+// Settings the guild did not override are marked as defaults
+func settingsMessage(s settings.Settings, overrides settings.Overrides, key string) string {
+	var b strings.Builder
+	b.WriteString("**Settings**")
+	for _, k := range settings.Keys {
+		if key != "" && k.Name != key {
+			continue
+		}
+		fmt.Fprintf(&b, "\n`%s`: %s", k.Name, settingValue(s, k.Name))
+		if _, ok := overrides[k.Name]; !ok {
+			b.WriteString(" _(default)_")
+		}
+		fmt.Fprintf(&b, "\n-# %s", k.Description)
+	}
+	return b.String()
+}
+
+// settingValue renders the effective value of a setting
+func settingValue(s settings.Settings, key string) string {
+	switch key {
+	case settings.KeyRuleSet:
+		return s.RuleSet
+	case settings.KeyChallengeTTL:
+		if s.ChallengeTTL == 0 {
+			return "never expire"
+		}
+		return s.ChallengeTTL.String()
+	case settings.KeyAllowedChannels:
+		if len(s.AllowedChannels) == 0 {
+			return "every channel"
+		}
+		return channelMentions(s.AllowedChannels)
+	case settings.KeyEconomy:
+		if s.Economy {
+			return "on"
+		}
+		return "off"
+	case settings.KeyLeaderboard:
+		return s.Leaderboard
+	case settings.KeyAnnouncementChannel:
+		if s.AnnouncementChannel == "" {
+			return "where results happen"
+		}
+		return fmt.Sprintf("<#%s>", s.AnnouncementChannel)
+	default:
+		return ""
+	}
+}
+
+func settingNames() string {
+	names := make([]string, len(settings.Keys))
+	for i, k := range settings.Keys {
+		names[i] = fmt.Sprintf("`%s`", k.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// settingError tells an admin why a value was refused
+func settingError(err error) string {
+	if errors.Is(err, settings.ErrInvalidValue) {
+		_, reason, _ := strings.Cut(err.Error(), ": ")
+		return "That value doesn't work, " + reason
+	}
+	return "That setting doesn't exist"
+}
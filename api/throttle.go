@@ -0,0 +1,66 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain/interaction"
+	"github.com/ekefan/discord-bot/domain/throttle"
+	"github.com/ekefan/discord-bot/logging"
+)
+
+// throttle is the router middleware limiting how fast a user uses commands and
+// components, users out of tokens get an ephemeral reply and nothing is dispatched.
+// Pings and autocompletes are never throttled, and the store failing lets interactions through
+func (bs *BotServer) throttle(next InteractionHandler) InteractionHandler {
+	return func(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+		kind, name, ok := bs.throttled(reqData)
+		if !ok {
+			next(ctx, w, reqData)
+			return
+		}
+		limit, ok := bs.Limits.For(kind, name)
+		if !ok {
+			next(ctx, w, reqData)
+			return
+		}
+		key := throttle.Key(reqData.GuildID, reqData.InvokingUser().ID, kind, name)
+		ok, retryAfter, err := bs.Throttle.Take(key, limit, time.Now())
+		if err != nil {
+			logging.FromContext(ctx).Error("could not throttle interaction, letting it through", logging.KeyError, err)
+			next(ctx, w, reqData)
+			return
+		}
+		if !ok {
+			logging.FromContext(ctx).Info("throttled interaction", "kind", kind, "name", name, "retry_after", retryAfter)
+			bs.respondEphemeral(ctx, w, slowDownMessage(retryAfter))
+			return
+		}
+		next(ctx, w, reqData)
+	}
+}
+
+// throttled returns the kind and the command or component route of an interaction,
+// ok is false for interactions that are not throttled
+func (bs *BotServer) throttled(reqData *interaction.Interaction) (kind, name string, ok bool) {
+	switch reqData.Type {
+	case APPLICATION_COMMMAND:
+		cmdData, ok := reqData.CommandData()
+		return throttle.KindCommand, cmdData.Name, ok
+	case MESSAGE_COMPONENT:
+		cmpData, ok := reqData.ComponentData()
+		// unverified routes are fine here, a forged one only picks another bucket of the same user
+		return throttle.KindComponent, bs.IDs.Route(cmpData.CustomId), ok
+	default:
+		return "", "", false
+	}
+}
+
+// slowDownMessage tells a throttled user when to try again, in whole seconds rounded up
+func slowDownMessage(retryAfter time.Duration) string {
+	seconds := max(1, int(math.Ceil(retryAfter.Seconds())))
+	return fmt.Sprintf("Slow down, try again in %ds", seconds)
+}
@@ -0,0 +1,506 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ekefan/discord-bot/customid"
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/domain/command"
+	"github.com/ekefan/discord-bot/domain/interaction"
+	"github.com/ekefan/discord-bot/domain/tournament"
+	"github.com/ekefan/discord-bot/events"
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/memory"
+	"github.com/ekefan/discord-bot/tracing"
+)
+
+// maxMatchReplays is how many times a match that expired unplayed is played again
+// before it is decided for the better seed
+const maxMatchReplays = 2
+
+var (
+	errMatchRescheduled = errors.New("match already has another challenge")
+)
+
+// HandleTournamentCmd dispatches the tournament sub commands
+func (bs *BotServer) HandleTournamentCmd(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+	ctx, span := tracing.Start(ctx, "handler.HandleTournamentCmd")
+	defer span.End()
+	if !bs.Config.TournamentsEnabled {
+		bs.respondEphemeral(ctx, w, "Tournaments are turned off on this bot")
+		return
+	}
+
+	cmdData, _ := reqData.CommandData()
+	subcommand, options := cmdData.Subcommand()
+	switch subcommand {
+	case command.TournamentCreateSubcommand:
+		bs.createTournament(ctx, w, reqData, options)
+	case command.TournamentJoinSubcommand:
+		t, ok := bs.activeTournament(ctx, w, reqData)
+		if ok {
+			bs.joinTournament(ctx, w, reqData, t.ID())
+		}
+	case command.TournamentStartSubcommand:
+		bs.startTournament(ctx, w, reqData)
+	case command.TournamentBracketSubcommand:
+		if t, ok := bs.activeTournament(ctx, w, reqData); ok {
+			bs.respondEphemeral(ctx, w, bracketMessage(t))
+		}
+	case command.TournamentCancelSubcommand:
+		bs.cancelTournament(ctx, w, reqData)
+	default:
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		logging.FromContext(ctx).Error("received unknown tournament sub command", "subcommand", subcommand)
+	}
+}
+
+// HandleTournamentJoinInteraction joins the tournament whose join button was clicked
+func (bs *BotServer) HandleTournamentJoinInteraction(ctx context.Context, w http.ResponseWriter, cmpInteraction *interaction.Interaction, id customid.ID) {
+	ctx, span := tracing.Start(ctx, "handler.HandleTournamentJoinInteraction")
+	defer span.End()
+	bs.joinTournament(ctx, w, cmpInteraction, id.Str(0))
+}
+
+// HandleMatchThrowInteraction lets a player of a tournament match pick their object
+func (bs *BotServer) HandleMatchThrowInteraction(ctx context.Context, w http.ResponseWriter, cmpInteraction *interaction.Interaction, id customid.ID) {
+	ctx, span := tracing.Start(ctx, "handler.HandleMatchThrowInteraction")
+	defer span.End()
+	challengeID := id.Str(0)
+	ctx = logging.With(ctx, logging.KeyChallengeID, challengeID)
+
+	match, err := bs.store().GetChallenge(ctx, scopeOf(cmpInteraction), challengeID)
+	if err != nil || match.Status() != challenge.Claimed {
+		bs.respondEphemeral(ctx, w, "This match is over")
+		return
+	}
+	userID := cmpInteraction.InvokingUser().ID
+	if userID != match.Challenger().ID && userID != match.ClaimedBy() {
+		bs.respondEphemeral(ctx, w, fmt.Sprintf("Only <@%s> and <@%s> play this match", match.Challenger().ID, match.ClaimedBy()))
+		return
+	}
+	if match.Chose(userID) {
+		bs.respondEphemeral(ctx, w, "You already made your choice")
+		return
+	}
+	resp := interaction.InteractionResponse{
+		Type: CHANNEL_MESSAGE_WITH_SOURCE,
+		Data: bs.choiceSelect(challengeID),
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(ctx).Error("failed to send interaction response", logging.KeyError, err)
+	}
+}
+
+func (bs *BotServer) createTournament(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction, options []interaction.InteractionOptions) {
+	name, _ := interaction.OptionValue(options, "name")
+	format, _ := interaction.OptionValue(options, "format")
+	seeding, ok := interaction.OptionValue(options, "seeding")
+	if !ok {
+		seeding = string(tournament.SeedRandom)
+	}
+	maxPlayers, _ := interaction.OptionInt(options, "max_players")
+	host := reqData.InvokingUser().ID
+
+	t, err := tournament.New(reqData.ID, scopeOf(reqData), name, host, tournament.Format(format), tournament.Seeding(seeding), maxPlayers)
+	if err != nil {
+		bs.respondEphemeral(ctx, w, "Give the tournament a name and pick one of the formats")
+		return
+	}
+	if err := bs.Tournaments.CreateTournament(t); err != nil {
+		if errors.Is(err, memory.ErrTournamentExists) {
+			bs.respondEphemeral(ctx, w, "This channel already has a tournament, use `/tournament bracket` to see it")
+			return
+		}
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not store tournament", logging.KeyError, err)
+		return
+	}
+
+	joinButton := interaction.BtnComponent{
+		Type:     BUTTON,
+		Label:    "join",
+		Style:    PRIMARY,
+		CustomId: bs.IDs.MustEncode(tournamentJoinRoute, customid.String(t.ID())),
+	}
+	resp := interaction.InteractionResponse{
+		Type: CHANNEL_MESSAGE_WITH_SOURCE,
+		Data: interaction.ResponseData{
+			Content: fmt.Sprintf("<@%s> opened the **%s** %s tournament, join with the button or `/tournament join`", host, t.Name(), t.Format()),
+			Components: []interaction.ResponseDataComponent{
+				{
+					Type:       ACTION_ROW,
+					Components: []interaction.BtnComponent{joinButton},
+				},
+			},
+		},
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(ctx).Error("failed to send interaction response", logging.KeyError, err)
+	}
+}
+
+func (bs *BotServer) joinTournament(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction, tournamentID string) {
+	ctx = logging.With(ctx, logging.KeyTournamentID, tournamentID)
+	participant := tournament.Participant{
+		ID:   reqData.InvokingUser().ID,
+		Name: displayName(reqData),
+	}
+	t, err := bs.Tournaments.UpdateTournament(tournamentID, func(t *tournament.Tournament) error {
+		return t.Join(participant)
+	})
+	switch {
+	case err == nil:
+		bs.respondEphemeral(ctx, w, fmt.Sprintf("You joined **%s**, %d players so far", t.Name(), len(t.Participants())))
+	case errors.Is(err, tournament.ErrAlreadyJoined):
+		bs.respondEphemeral(ctx, w, fmt.Sprintf("You already joined **%s**", t.Name()))
+	case errors.Is(err, tournament.ErrTournamentFull):
+		bs.respondEphemeral(ctx, w, fmt.Sprintf("**%s** is full", t.Name()))
+	case errors.Is(err, tournament.ErrNotRegistering), errors.Is(err, memory.ErrTournamentNotFound):
+		bs.respondEphemeral(ctx, w, "This tournament is no longer open for registration")
+	default:
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not join tournament", logging.KeyError, err)
+	}
+}
+
+func (bs *BotServer) startTournament(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+	current, ok := bs.activeTournament(ctx, w, reqData)
+	if !ok {
+		return
+	}
+	ctx = logging.With(ctx, logging.KeyTournamentID, current.ID())
+	userID := reqData.InvokingUser().ID
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	t, err := bs.Tournaments.UpdateTournament(current.ID(), func(t *tournament.Tournament) error {
+		return t.Start(userID, bs.tournamentRatings(ctx, t), rng)
+	})
+	switch {
+	case err == nil:
+	case errors.Is(err, tournament.ErrNotHost):
+		bs.respondEphemeral(ctx, w, fmt.Sprintf("Only <@%s> can start **%s**", current.Host(), current.Name()))
+		return
+	case errors.Is(err, tournament.ErrNotEnoughPlayers):
+		bs.respondEphemeral(ctx, w, "A tournament needs at least two players")
+		return
+	case errors.Is(err, tournament.ErrNotRegistering):
+		bs.respondEphemeral(ctx, w, fmt.Sprintf("**%s** already started", current.Name()))
+		return
+	default:
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not start tournament", logging.KeyError, err)
+		return
+	}
+
+	resp := interaction.InteractionResponse{
+		Type: CHANNEL_MESSAGE_WITH_SOURCE,
+		Data: interaction.ResponseData{
+			Content: fmt.Sprintf("**%s** has started!\n%s", t.Name(), bracketMessage(t)),
+		},
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(ctx).Error("failed to send interaction response", logging.KeyError, err)
+	}
+	go bs.scheduleMatches(context.WithoutCancel(ctx), t.ID(), func(m tournament.Match) bool {
+		return m.Challenge == ""
+	})
+}
+
+func (bs *BotServer) cancelTournament(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) {
+	current, ok := bs.activeTournament(ctx, w, reqData)
+	if !ok {
+		return
+	}
+	ctx = logging.With(ctx, logging.KeyTournamentID, current.ID())
+	userID := reqData.InvokingUser().ID
+	t, err := bs.Tournaments.UpdateTournament(current.ID(), func(t *tournament.Tournament) error {
+		return t.Cancel(userID)
+	})
+	switch {
+	case err == nil:
+	case errors.Is(err, tournament.ErrNotHost):
+		bs.respondEphemeral(ctx, w, fmt.Sprintf("Only <@%s> can cancel **%s**", current.Host(), current.Name()))
+		return
+	case errors.Is(err, tournament.ErrNotRunning):
+		bs.respondEphemeral(ctx, w, fmt.Sprintf("**%s** is already over", current.Name()))
+		return
+	default:
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not cancel tournament", logging.KeyError, err)
+		return
+	}
+
+	for _, m := range t.Matches() {
+		if m.Done || m.Challenge == "" {
+			continue
+		}
+		if err := bs.store().DeleteChallenge(ctx, t.Scope(), m.Challenge); err != nil && !errors.Is(err, memory.ErrChallengeNotFound) {
+			logging.FromContext(ctx).Error("could not delete match challenge", logging.KeyChallengeID, m.Challenge, logging.KeyError, err)
+		}
+	}
+	resp := interaction.InteractionResponse{
+		Type: CHANNEL_MESSAGE_WITH_SOURCE,
+		Data: interaction.ResponseData{
+			Content: fmt.Sprintf("**%s** was cancelled", t.Name()),
+		},
+	}
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		logging.FromContext(ctx).Error("failed to send interaction response", logging.KeyError, err)
+	}
+}
+
+// activeTournament returns the tournament of the channel an interaction was issued in,
+// the user is told when there is none
+func (bs *BotServer) activeTournament(ctx context.Context, w http.ResponseWriter, reqData *interaction.Interaction) (*tournament.Tournament, bool) {
+	t, err := bs.Tournaments.ActiveTournament(scopeOf(reqData))
+	if err != nil {
+		if errors.Is(err, memory.ErrTournamentNotFound) {
+			bs.respondEphemeral(ctx, w, "There is no tournament in this channel, create one with `/tournament create`")
+			return nil, false
+		}
+		http.Error(w, "Server Error", http.StatusInternalServerError)
+		logging.FromContext(ctx).Error("could not load tournament", logging.KeyError, err)
+		return nil, false
+	}
+	return t, true
+}
+
+// tournamentRatings returns the rating of every participant in the tournament's guild
+func (bs *BotServer) tournamentRatings(ctx context.Context, t *tournament.Tournament) map[string]int {
+	ratings := map[string]int{}
+	if t.Seeding() != tournament.SeedRating {
+		return ratings
+	}
+	for _, p := range t.Participants() {
+		r, err := bs.Ratings.Rating(t.Scope().GuildID, p.ID)
+		if err != nil {
+			logging.FromContext(ctx).Error("could not load rating", logging.KeyUserID, p.ID, logging.KeyError, err)
+			continue
+		}
+		ratings[p.ID] = r
+	}
+	return ratings
+}
+
+// advanceTournaments reports the results of tournament matches and schedules the
+// matches they unlock, drawn matches and matches that expired unplayed are played again.
+// A match that expired unplayed more than maxMatchReplays times goes to the better seed
+func (bs *BotServer) advanceTournaments(ctx context.Context, e events.Event) {
+	if e.Challenge == nil || e.Challenge.TournamentID == "" {
+		return
+	}
+	expired := e.Type == events.ChallengeEventType(challenge.EventExpired)
+	if !expired && !finished(e) {
+		return
+	}
+	snapshot := e.Challenge
+	ctx = logging.With(context.WithoutCancel(ctx), logging.KeyTournamentID, snapshot.TournamentID)
+	replay := func() {
+		go bs.scheduleMatches(ctx, snapshot.TournamentID, func(m tournament.Match) bool {
+			return m.ID == snapshot.MatchID && m.Challenge == snapshot.ID
+		})
+	}
+	if !expired && snapshot.Result.Draw {
+		replay()
+		return
+	}
+
+	replayed, walkover := false, ""
+	t, err := bs.Tournaments.UpdateTournament(snapshot.TournamentID, func(t *tournament.Tournament) error {
+		replayed, walkover = false, ""
+		m, err := t.Match(snapshot.MatchID)
+		if err != nil {
+			return err
+		}
+		if m.Challenge != snapshot.ID {
+			return errMatchRescheduled
+		}
+		if !expired {
+			return t.Report(snapshot.MatchID, snapshot.Result.WinnerID)
+		}
+		if m.Replays < maxMatchReplays {
+			replayed = true
+			return t.Replay(snapshot.MatchID)
+		}
+		walkover, err = t.Walkover(snapshot.MatchID)
+		return err
+	})
+	if err != nil {
+		logging.FromContext(ctx).Warn("could not report match result", "match", snapshot.MatchID, logging.KeyError, err)
+		return
+	}
+	if replayed {
+		replay()
+		return
+	}
+	if walkover != "" {
+		go bs.postChannelMessage(ctx, t.Scope().ChannelID, interaction.ResponseData{
+			Content: fmt.Sprintf("Match %s was never played, <@%s> advances as the better seed", snapshot.MatchID, walkover),
+		})
+	}
+	if t.Status() == tournament.Finished {
+		// champions are announced in the announcement channel of the guild when it has one
+		channelID := t.Scope().ChannelID
+		if announcements := bs.guildSettings(ctx, t.Scope().GuildID).AnnouncementChannel; announcements != "" {
+			channelID = announcements
+		}
+		go bs.postChannelMessage(ctx, channelID, interaction.ResponseData{
+			Content: fmt.Sprintf("<@%s> wins **%s**!\n%s", t.Champion(), t.Name(), bracketMessage(t)),
+		})
+		return
+	}
+	go bs.scheduleMatches(ctx, t.ID(), func(m tournament.Match) bool {
+		return m.Challenge == ""
+	})
+}
+
+// scheduleMatches creates a challenge for every playable match picked by pick
+// and posts it to the tournament channel
+func (bs *BotServer) scheduleMatches(ctx context.Context, tournamentID string, pick func(m tournament.Match) bool) {
+	logger := logging.FromContext(ctx)
+	var scheduled []tournament.Match
+	t, err := bs.Tournaments.UpdateTournament(tournamentID, func(t *tournament.Tournament) error {
+		scheduled = scheduled[:0]
+		for _, m := range t.Playable() {
+			if !pick(m) {
+				continue
+			}
+			m.Challenge = fmt.Sprintf("%s-%s-%d", t.ID(), m.ID, time.Now().UnixNano())
+			if err := t.SetChallenge(m.ID, m.Challenge); err != nil {
+				return err
+			}
+			scheduled = append(scheduled, m)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error("could not schedule matches", logging.KeyError, err)
+		return
+	}
+	for _, m := range scheduled {
+		bs.startMatch(ctx, t, m)
+	}
+}
+
+// startMatch creates the challenge of a match and posts it with a button both players throw with
+func (bs *BotServer) startMatch(ctx context.Context, t *tournament.Tournament, m tournament.Match) {
+	ctx = logging.With(ctx, logging.KeyChallengeID, m.Challenge)
+	logger := logging.FromContext(ctx)
+	c, err := challenge.NewMatch(m.Challenge, t.Scope(), m.Players[0], m.Players[1])
+	if err != nil {
+		logger.Error("could not create match challenge", logging.KeyError, err)
+		return
+	}
+	c.SetTournament(t.ID(), m.ID)
+	now := time.Now()
+	c.SetOrigin(challenge.Origin{IssuedAt: now})
+	// a match nobody finishes in time is forfeited or played again, see ExpireChallenges
+	if ttl := bs.guildSettings(ctx, t.Scope().GuildID).ChallengeTTL; ttl > 0 {
+		c.SetExpiry(now.Add(ttl))
+	}
+	if err := bs.store().CreateChallenge(ctx, c); err != nil {
+		logger.Error("could not store match challenge", logging.KeyError, err)
+		return
+	}
+	bs.recordChallengeEvents(ctx, c, c.History())
+
+	throwButton := interaction.BtnComponent{
+		Type:     BUTTON,
+		Label:    "throw",
+		Style:    PRIMARY,
+		CustomId: bs.IDs.MustEncode(matchThrowRoute, customid.String(m.Challenge)),
+	}
+	messageID, ok := bs.postChannelMessage(ctx, t.Scope().ChannelID, interaction.ResponseData{
+		Content: matchMessage(t, m),
+		Components: []interaction.ResponseDataComponent{
+			{
+				Type:       ACTION_ROW,
+				Components: []interaction.BtnComponent{throwButton},
+			},
+		},
+	})
+	if !ok {
+		return
+	}
+	err = bs.updateChallenge(ctx, c.Scope(), m.Challenge, func(c *challenge.Challenge) {
+		origin := c.Origin()
+		origin.MessageID = messageID
+		c.SetOrigin(origin)
+	})
+	// a match played before the message id was known has no message to attach
+	if err != nil && !errors.Is(err, memory.ErrChallengeNotFound) {
+		logger.Error("could not attach message to match challenge", logging.KeyError, err)
+	}
+}
+
+// postChannelMessage posts a message as the bot and returns its id
+func (bs *BotServer) postChannelMessage(ctx context.Context, channelID string, data interaction.ResponseData) (string, bool) {
+	logger := logging.FromContext(ctx)
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	response, err := bs.DiscordRequest(ctx, fmt.Sprintf("channels/%v/messages", channelID), DiscordRequestOption{
+		Method: POST,
+		Body:   data,
+	})
+	if err != nil {
+		logger.Error("could not post channel message", logging.KeyError, err)
+		return "", false
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		logger.Error("failed to post channel message", "status", response.StatusCode)
+		return "", false
+	}
+	var message interaction.ComponentInteractionMessage
+	if err := json.NewDecoder(response.Body).Decode(&message); err != nil {
+		logger.Error("could not decode channel message", logging.KeyError, err)
+		return "", false
+	}
+	return message.ID, true
+}
+
+// matchMessage is the content of the message a match is posted with
+func matchMessage(t *tournament.Tournament, m tournament.Match) string {
+	var stage string
+	switch m.Bracket {
+	case tournament.GrandFinal:
+		stage = "grand final"
+		if m.Round > 1 {
+			stage = "grand final reset"
+		}
+	case "":
+		stage = fmt.Sprintf("round %d", m.Round)
+	default:
+		stage = fmt.Sprintf("%s round %d", m.Bracket, m.Round)
+	}
+	return fmt.Sprintf("**%s** %s: <@%s> vs <@%s>, both players throw with the button", t.Name(), stage, m.Players[0], m.Players[1])
+}
+
+// bracketMessage renders a tournament in a code block cut to fit in a message
+func bracketMessage(t *tournament.Tournament) string {
+	const limit = 1900
+	rendered := t.Render()
+	if len(rendered) > limit {
+		rendered = rendered[:strings.LastIndex(rendered[:limit], "\n")+1] + "...\n"
+	}
+	return "```\n" + rendered + "```"
+}
+
+// displayName returns the name a user goes by where the interaction happened
+func displayName(i *interaction.Interaction) string {
+	if i.Member != nil && i.Member.Nick != "" {
+		return i.Member.Nick
+	}
+	return i.InvokingUser().Username
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/memory"
+	"github.com/ekefan/discord-bot/tracing"
+)
+
+// tracedStore records a span for every store operation as a child of the
+// span carried by the context of the call
+type tracedStore struct {
+	repo memory.ChallengeStore
+}
+
+// store returns the challenge store traced
+func (bs *BotServer) store() tracedStore {
+	return tracedStore{repo: bs.Store}
+}
+
+func (ts tracedStore) CreateChallenge(ctx context.Context, c *challenge.Challenge) error {
+	ctx, span := tracing.Start(ctx, "store.CreateChallenge")
+	defer span.End()
+	err := ts.repo.CreateChallenge(ctx, c)
+	span.RecordError(err)
+	return err
+}
+
+func (ts tracedStore) GetChallenge(ctx context.Context, scope challenge.Scope, id string) (*challenge.Challenge, error) {
+	ctx, span := tracing.Start(ctx, "store.GetChallenge", "challenge.id", id, "challenge.scope", scope.Key())
+	defer span.End()
+	c, err := ts.repo.GetChallenge(ctx, scope, id)
+	span.RecordError(err)
+	return c, err
+}
+
+func (ts tracedStore) UpdateChallenge(ctx context.Context, c *challenge.Challenge) error {
+	ctx, span := tracing.Start(ctx, "store.UpdateChallenge", "challenge.version", c.Version())
+	defer span.End()
+	err := ts.repo.UpdateChallenge(ctx, c)
+	span.RecordError(err)
+	return err
+}
+
+func (ts tracedStore) ListChallenges(ctx context.Context, q memory.ChallengeQuery) (memory.ChallengePage, error) {
+	ctx, span := tracing.Start(ctx, "store.ListChallenges")
+	defer span.End()
+	page, err := ts.repo.ListChallenges(ctx, q)
+	span.RecordError(err)
+	span.SetAttributes("challenge.count", len(page.Challenges))
+	return page, err
+}
+
+func (ts tracedStore) CountOpen(ctx context.Context, filter memory.ChallengeFilter) (int, error) {
+	ctx, span := tracing.Start(ctx, "store.CountOpen")
+	defer span.End()
+	open, err := ts.repo.CountOpen(ctx, filter)
+	span.RecordError(err)
+	span.SetAttributes("challenge.count", open)
+	return open, err
+}
+
+func (ts tracedStore) TransitionChallenge(ctx context.Context, scope challenge.Scope, id string, from challenge.Status, transition memory.Transition) (*challenge.Challenge, error) {
+	ctx, span := tracing.Start(ctx, "store.TransitionChallenge", "challenge.id", id, "challenge.from", from.String())
+	defer span.End()
+	c, err := ts.repo.TransitionChallenge(ctx, scope, id, from, transition)
+	span.RecordError(err)
+	return c, err
+}
+
+func (ts tracedStore) DeleteChallenge(ctx context.Context, scope challenge.Scope, id string) error {
+	ctx, span := tracing.Start(ctx, "store.DeleteChallenge", "challenge.id", id, "challenge.scope", scope.Key())
+	defer span.End()
+	err := ts.repo.DeleteChallenge(ctx, scope, id)
+	span.RecordError(err)
+	return err
+}
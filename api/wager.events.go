@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/domain/ledger"
+	"github.com/ekefan/discord-bot/events"
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/memory"
+)
+
+// acceptChallenge claims an open challenge for userID and escrows the wager of both players.
+// A transition may run more than once when a swap is retried, so the stakes are only posted
+// once the claim is stored, and the claim is reversed when they can't be covered
+func (bs *BotServer) acceptChallenge(ctx context.Context, scope challenge.Scope, id, userID string, at time.Time) (*challenge.Challenge, error) {
+	var before *challenge.Challenge
+	accepted, err := bs.store().TransitionChallenge(ctx, scope, id, challenge.Open, func(c *challenge.Challenge) error {
+		before = challenge.Restore(c.Snapshot())
+		return c.Accept(userID, at)
+	})
+	if err != nil {
+		return accepted, err
+	}
+	if err := bs.escrowWager(accepted, at); err != nil {
+		bs.reverseAccept(ctx, accepted, before)
+		return accepted, err
+	}
+	bs.recordChallengeEvents(ctx, accepted, accepted.History()[len(before.History()):])
+	return accepted, nil
+}
+
+// reverseAccept puts back the open challenge an accept replaced, unless it changed since
+func (bs *BotServer) reverseAccept(ctx context.Context, accepted, before *challenge.Challenge) {
+	id, _ := accepted.GetChallengeID()
+	_, err := bs.store().TransitionChallenge(ctx, accepted.Scope(), id, challenge.Claimed, func(c *challenge.Challenge) error {
+		if c.Version() != accepted.Version() {
+			return memory.ErrVersionConflict
+		}
+		open := challenge.Restore(before.Snapshot())
+		open.SetVersion(c.Version())
+		*c = *open
+		return nil
+	})
+	if err != nil {
+		logging.FromContext(ctx).Error("could not reopen challenge after a failed escrow", logging.KeyError, err)
+	}
+}
+
+// escrowWager moves the stakes of both players of an accepted challenge into escrow,
+// a challenge is escrowed once so a retried post is not charged twice
+func (bs *BotServer) escrowWager(c *challenge.Challenge, at time.Time) error {
+	if c.Wager() == 0 {
+		return nil
+	}
+	id, _ := c.GetChallengeID()
+	tx, err := ledger.Escrow(fmt.Sprintf("escrow:%s", id), c.Scope().GuildID, id, c.Challenger().ID, c.ClaimedBy(), c.Wager(), at)
+	if err != nil {
+		return err
+	}
+	if err := bs.Ledger.Post(tx); err != nil && !errors.Is(err, memory.ErrTransactionExists) {
+		return err
+	}
+	return nil
+}
+
+// settleWagers pays the escrowed stakes of a finished challenge to the winner,
+// they are refunded on a draw and when the challenge expires or is cancelled
+func (bs *BotServer) settleWagers(ctx context.Context, e events.Event) {
+	snapshot := e.Challenge
+	if snapshot == nil || snapshot.Wager == 0 {
+		return
+	}
+	refund := false
+	switch e.Type {
+	case events.ChallengeEventType(challenge.EventResolved), events.ChallengeEventType(challenge.EventForfeited):
+		if snapshot.Result == nil {
+			return
+		}
+		refund = snapshot.Result.Draw
+	case events.ChallengeEventType(challenge.EventExpired), events.ChallengeEventType(challenge.EventCancelled):
+		refund = true
+	default:
+		return
+	}
+	logger := logging.FromContext(ctx)
+	escrowed, err := bs.Ledger.Balance(ledger.EscrowAccount(snapshot.ID))
+	if err != nil {
+		logger.Error("could not load escrow balance", logging.KeyError, err)
+		return
+	}
+	if escrowed == 0 {
+		// never accepted, nothing was staked
+		return
+	}
+
+	// a challenge is settled once, either paid out or refunded
+	id := fmt.Sprintf("settle:%s", snapshot.ID)
+	var tx ledger.Transaction
+	if refund {
+		tx, err = ledger.Refund(id, snapshot.GuildID, snapshot.ID, snapshot.ChallengerID, snapshot.OpponentID, snapshot.Wager, e.At)
+	} else {
+		tx, err = ledger.Transfer(id, ledger.KindPayout, ledger.EscrowAccount(snapshot.ID), ledger.UserAccount(snapshot.GuildID, snapshot.Result.WinnerID), escrowed, e.At)
+	}
+	if err == nil {
+		err = bs.Ledger.Post(tx)
+	}
+	if err != nil && !errors.Is(err, memory.ErrTransactionExists) {
+		logger.Error("could not settle wager", logging.KeyChallengeID, snapshot.ID, logging.KeyError, err)
+	}
+}
+
+// wagerMessage tells how the stakes of a finished challenge were settled
+func wagerMessage(c *challenge.Challenge) string {
+	if c.Wager() == 0 || c.Result() == nil {
+		return ""
+	}
+	if c.Result().OutcomeDraw {
+		return fmt.Sprintf("\n-# both stakes of **%d** coins were refunded", c.Wager())
+	}
+	return fmt.Sprintf("\n-# <@%s> takes the pot of **%d** coins", c.Result().Winner.ID, 2*c.Wager())
+}
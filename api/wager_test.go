@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/domain/ledger"
+	"github.com/ekefan/discord-bot/memory"
+	"github.com/ekefan/discord-bot/resp"
+	"github.com/ekefan/discord-bot/resp/resptest"
+	"github.com/ekefan/discord-bot/sqlite"
+	"github.com/ekefan/discord-bot/util"
+	"github.com/stretchr/testify/require"
+)
+
+// sharedStores open a challenge store shared by every instance of the bot
+var sharedStores = map[string]func(t *testing.T) func() memory.ChallengeStore{
+	"redis": func(t *testing.T) func() memory.ChallengeStore {
+		server := resptest.NewServer(t)
+		return func() memory.ChallengeStore {
+			client := resp.NewClient(resp.Config{Addr: server.Addr()})
+			t.Cleanup(func() { client.Close() })
+			return memory.NewChallengeStore(memory.NewRedisChallenges(client, "rps:", memory.Limits{}))
+		}
+	},
+	"sqlite": func(t *testing.T) func() memory.ChallengeStore {
+		path := filepath.Join(t.TempDir(), "bot.db")
+		migrations, err := sqlite.Migrations()
+		require.NoError(t, err)
+		return func() memory.ChallengeStore {
+			db, err := sqlite.Open(path)
+			require.NoError(t, err)
+			t.Cleanup(func() { db.Close() })
+			_, err = sqlite.NewMigrator(db, migrations).Up(context.Background())
+			require.NoError(t, err)
+			return memory.NewChallengeStore(sqlite.NewChallenges(db, memory.Limits{}))
+		}
+	},
+}
+
+// newSharedInstances returns n bot servers sharing a challenge store and a ledger,
+// like instances of the bot behind one load balancer
+func newSharedInstances(t *testing.T, n int, open func() memory.ChallengeStore) ([]*BotServer, memory.LedgerRepository) {
+	shared := memory.NewInMemoryLedger()
+	instances := make([]*BotServer, n)
+	for i := range instances {
+		instances[i] = NewBotServer(&util.EnvConfig{CustomIDSecret: "secret"}, open())
+		instances[i].Ledger = shared
+	}
+	return instances, shared
+}
+
+func fund(t *testing.T, l memory.LedgerRepository, guildID, userID string, amount int) {
+	tx, err := ledger.Transfer("fund:"+userID, ledger.KindDaily, ledger.MintAccount(guildID), ledger.UserAccount(guildID, userID), amount, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, l.Post(tx))
+}
+
+func newWager(t *testing.T, bs *BotServer, id string, wager int) *challenge.Challenge {
+	c, err := challenge.NewChallenge(id, challenge.Scope{GuildID: "g1", ChannelID: "c1"}, &domain.Player{ID: "challenger", Choice: domain.Rock})
+	require.NoError(t, err)
+	c.SetWager(wager)
+	require.NoError(t, bs.store().CreateChallenge(context.Background(), c))
+	return c
+}
+
+func balance(t *testing.T, l memory.LedgerRepository, account string) int {
+	b, err := l.Balance(account)
+	require.NoError(t, err)
+	return b
+}
+
+func TestAcceptChallengeEscrowsOnce(t *testing.T) {
+	for name, store := range sharedStores {
+		t.Run(name, func(t *testing.T) {
+			testAcceptChallengeEscrowsOnce(t, store(t))
+		})
+	}
+}
+
+func testAcceptChallengeEscrowsOnce(t *testing.T, open func() memory.ChallengeStore) {
+	instances, shared := newSharedInstances(t, 2, open)
+	const challenges, wager = 20, 10
+	for _, user := range []string{"challenger", "a", "b"} {
+		fund(t, shared, "g1", user, challenges*wager)
+	}
+
+	ctx := context.Background()
+	for i := 0; i < challenges; i++ {
+		id := fmt.Sprint(i)
+		c := newWager(t, instances[0], id, wager)
+
+		// two instances race to accept the same challenge for two users
+		var wg sync.WaitGroup
+		errs := make([]error, len(instances))
+		for n, bs := range instances {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, errs[n] = bs.acceptChallenge(ctx, c.Scope(), id, []string{"a", "b"}[n], time.Now())
+			}()
+		}
+		wg.Wait()
+		if errs[0] == nil {
+			require.ErrorIs(t, errs[1], memory.ErrStateConflict)
+		} else {
+			require.ErrorIs(t, errs[0], memory.ErrStateConflict)
+			require.NoError(t, errs[1])
+		}
+		require.Equal(t, 2*wager, balance(t, shared, ledger.EscrowAccount(id)))
+	}
+
+	require.Equal(t, 0, balance(t, shared, ledger.UserAccount("g1", "challenger")))
+	paid := balance(t, shared, ledger.UserAccount("g1", "a")) + balance(t, shared, ledger.UserAccount("g1", "b"))
+	require.Equal(t, challenges*wager, paid)
+}
+
+func TestAcceptChallengeReopensWhenStakeIsShort(t *testing.T) {
+	for name, store := range sharedStores {
+		t.Run(name, func(t *testing.T) {
+			testAcceptChallengeReopensWhenStakeIsShort(t, store(t))
+		})
+	}
+}
+
+func testAcceptChallengeReopensWhenStakeIsShort(t *testing.T, open func() memory.ChallengeStore) {
+	instances, shared := newSharedInstances(t, 1, open)
+	bs := instances[0]
+	fund(t, shared, "g1", "challenger", 10)
+	fund(t, shared, "g1", "a", 5)
+	c := newWager(t, bs, "1", 10)
+
+	ctx := context.Background()
+	_, err := bs.acceptChallenge(ctx, c.Scope(), "1", "a", time.Now())
+	var funds *ledger.InsufficientFundsError
+	require.ErrorAs(t, err, &funds)
+	require.Equal(t, ledger.UserAccount("g1", "a"), funds.Account)
+
+	got, err := bs.store().GetChallenge(ctx, c.Scope(), "1")
+	require.NoError(t, err)
+	require.Equal(t, challenge.Open, got.Status())
+	require.Empty(t, got.ClaimedBy())
+	require.Equal(t, 0, balance(t, shared, ledger.EscrowAccount("1")))
+
+	// the reopened challenge can be accepted by someone who covers the stake
+	fund(t, shared, "g1", "b", 10)
+	accepted, err := bs.acceptChallenge(ctx, c.Scope(), "1", "b", time.Now())
+	require.NoError(t, err)
+	require.Equal(t, "b", accepted.ClaimedBy())
+	require.Equal(t, 20, balance(t, shared, ledger.EscrowAccount("1")))
+}
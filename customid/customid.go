@@ -0,0 +1,350 @@
+// customid package encodes component state into signed, compact custom_ids
+//
+// A custom_id is the route name in clear followed by a dot and the base64url
+// encoding of the route version, the typed parameters and a truncated HMAC of
+// the route and everything before it:
+//
+//	<route>.<base64url(version | params | hmac)>
+//
+// Routes are registered with their version and parameter kinds, decoding rejects
+// forged ids, ids of unknown routes and ids issued for another version of a route
+// before they reach a handler
+package customid
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MaxLength is the longest custom_id discord accepts
+const MaxLength = 100
+
+// macSize is the number of HMAC-SHA256 bytes kept in a custom_id
+const macSize = 8
+
+// Codec Errors
+var (
+	ErrMalformed    = errors.New("custom id is malformed")
+	ErrForged       = errors.New("custom id signature does not match")
+	ErrUnknownRoute = errors.New("custom id route is not registered")
+	ErrStale        = errors.New("custom id was issued for another version of its route")
+	ErrParams       = errors.New("custom id parameters don't match its route")
+	ErrTooLong      = errors.New("custom id is longer than discord allows")
+)
+
+// Kind is the type of a parameter
+type Kind uint8
+
+const (
+	KindString Kind = iota + 1
+	KindInt
+	KindBool
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindString:
+		return "string"
+	case KindInt:
+		return "int"
+	case KindBool:
+		return "bool"
+	default:
+		return "kind(" + strconv.Itoa(int(k)) + ")"
+	}
+}
+
+// Param is a typed parameter of a custom_id
+type Param struct {
+	kind Kind
+	str  string
+	num  int64
+}
+
+func String(s string) Param {
+	return Param{kind: KindString, str: s}
+}
+
+func Int(n int64) Param {
+	return Param{kind: KindInt, num: n}
+}
+
+func Bool(b bool) Param {
+	p := Param{kind: KindBool}
+	if b {
+		p.num = 1
+	}
+	return p
+}
+
+func (p Param) Kind() Kind {
+	return p.kind
+}
+
+// ID is a decoded custom_id
+type ID struct {
+	Route   string
+	Version uint8
+	Params  []Param
+}
+
+// Str returns the string parameter at i, the empty string when there is none
+func (id ID) Str(i int) string {
+	if i < 0 || i >= len(id.Params) {
+		return ""
+	}
+	return id.Params[i].str
+}
+
+// Int returns the integer parameter at i, zero when there is none
+func (id ID) Int(i int) int64 {
+	if i < 0 || i >= len(id.Params) || id.Params[i].kind != KindInt {
+		return 0
+	}
+	return id.Params[i].num
+}
+
+// Bool returns the boolean parameter at i, false when there is none
+func (id ID) Bool(i int) bool {
+	if i < 0 || i >= len(id.Params) || id.Params[i].kind != KindBool {
+		return false
+	}
+	return id.Params[i].num == 1
+}
+
+// route is the registered version and parameter kinds of a route
+type route struct {
+	version uint8
+	kinds   []Kind
+}
+
+// Codec encodes and decodes the custom_ids of registered routes
+type Codec struct {
+	key    []byte
+	mu     sync.RWMutex
+	routes map[string]route
+}
+
+func NewCodec(key []byte) *Codec {
+	return &Codec{
+		key:    key,
+		routes: make(map[string]route),
+	}
+}
+
+// Register adds a route with its version and parameter kinds. Route names are
+// lower case letters, digits and underscores. Bump the version whenever the
+// parameters of a route change so ids issued before are rejected as stale.
+// Like http.HandleFunc it panics when the route is invalid or already registered
+func (c *Codec) Register(name string, version uint8, kinds ...Kind) {
+	if !validRoute(name) {
+		panic(fmt.Sprintf("customid: invalid route name %q", name))
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.routes[name]; ok {
+		panic(fmt.Sprintf("customid: route %q registered twice", name))
+	}
+	c.routes[name] = route{version: version, kinds: kinds}
+}
+
+func validRoute(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '_' {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *Codec) lookup(name string) (route, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	r, ok := c.routes[name]
+	return r, ok
+}
+
+// Encode returns the signed custom_id of a registered route with params
+func (c *Codec) Encode(name string, params ...Param) (string, error) {
+	r, ok := c.lookup(name)
+	if !ok {
+		return "", ErrUnknownRoute
+	}
+	if !r.matches(params) {
+		return "", ErrParams
+	}
+	payload := []byte{r.version}
+	for _, p := range params {
+		payload = appendParam(payload, p)
+	}
+	payload = append(payload, c.sign(name, payload)...)
+	id := name + "." + base64.RawURLEncoding.EncodeToString(payload)
+	if len(id) > MaxLength {
+		return "", ErrTooLong
+	}
+	return id, nil
+}
+
+// MustEncode is Encode for ids that fit by construction, it panics on error
+func (c *Codec) MustEncode(name string, params ...Param) string {
+	id, err := c.Encode(name, params...)
+	if err != nil {
+		panic(fmt.Sprintf("customid: encode %q: %v", name, err))
+	}
+	return id
+}
+
+// Route returns the registered route a custom_id claims without verifying it,
+// "" when it names no registered route. Only use it where a forged route does no harm
+func (c *Codec) Route(customID string) string {
+	name, _, ok := strings.Cut(customID, ".")
+	if !ok {
+		return ""
+	}
+	if _, ok := c.lookup(name); !ok {
+		return ""
+	}
+	return name
+}
+
+// Decode verifies and decodes a custom_id
+func (c *Codec) Decode(customID string) (ID, error) {
+	if len(customID) > MaxLength {
+		return ID{}, ErrMalformed
+	}
+	name, encoded, ok := strings.Cut(customID, ".")
+	if !ok || !validRoute(name) {
+		return ID{}, ErrMalformed
+	}
+	payload, err := base64.RawURLEncoding.Strict().DecodeString(encoded)
+	if err != nil || len(payload) < 1+macSize {
+		return ID{}, ErrMalformed
+	}
+	body, mac := payload[:len(payload)-macSize], payload[len(payload)-macSize:]
+	if !hmac.Equal(mac, c.sign(name, body)) {
+		return ID{}, ErrForged
+	}
+
+	r, ok := c.lookup(name)
+	if !ok {
+		return ID{}, ErrUnknownRoute
+	}
+	id := ID{Route: name, Version: body[0]}
+	if id.Version != r.version {
+		return ID{}, ErrStale
+	}
+	for rest := body[1:]; len(rest) > 0; {
+		var p Param
+		if p, rest, err = readParam(rest); err != nil {
+			return ID{}, err
+		}
+		id.Params = append(id.Params, p)
+	}
+	if !r.matches(id.Params) {
+		return ID{}, ErrParams
+	}
+	return id, nil
+}
+
+func (r route) matches(params []Param) bool {
+	if len(params) != len(r.kinds) {
+		return false
+	}
+	for i, p := range params {
+		if p.kind != r.kinds[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sign returns the truncated HMAC of a route and its payload
+func (c *Codec) sign(name string, payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write([]byte(name))
+	mac.Write([]byte{'.'})
+	mac.Write(payload)
+	return mac.Sum(nil)[:macSize]
+}
+
+// Wire tags of parameters, decimal strings such as snowflakes are packed as varints
+const (
+	tagString byte = iota
+	tagDigits
+	tagInt
+	tagFalse
+	tagTrue
+)
+
+func appendParam(b []byte, p Param) []byte {
+	switch p.kind {
+	case KindString:
+		if n, ok := digits(p.str); ok {
+			return binary.AppendUvarint(append(b, tagDigits), n)
+		}
+		b = binary.AppendUvarint(append(b, tagString), uint64(len(p.str)))
+		return append(b, p.str...)
+	case KindInt:
+		return binary.AppendVarint(append(b, tagInt), p.num)
+	default:
+		if p.num == 1 {
+			return append(b, tagTrue)
+		}
+		return append(b, tagFalse)
+	}
+}
+
+// digits reports whether s is the canonical decimal form of an uint64
+func digits(s string) (uint64, bool) {
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || strconv.FormatUint(n, 10) != s {
+		return 0, false
+	}
+	return n, true
+}
+
+func readParam(b []byte) (Param, []byte, error) {
+	tag, b := b[0], b[1:]
+	switch tag {
+	case tagString:
+		size, n := binary.Uvarint(b)
+		if n <= 0 || size > uint64(len(b)-n) {
+			return Param{}, nil, ErrMalformed
+		}
+		b = b[n:]
+		if _, ok := digits(string(b[:size])); ok {
+			// decimal strings are always packed
+			return Param{}, nil, ErrMalformed
+		}
+		return String(string(b[:size])), b[size:], nil
+	case tagDigits:
+		v, n := binary.Uvarint(b)
+		if n <= 0 || !bytes.Equal(binary.AppendUvarint(nil, v), b[:n]) {
+			return Param{}, nil, ErrMalformed
+		}
+		return String(strconv.FormatUint(v, 10)), b[n:], nil
+	case tagInt:
+		v, n := binary.Varint(b)
+		if n <= 0 || !bytes.Equal(binary.AppendVarint(nil, v), b[:n]) {
+			return Param{}, nil, ErrMalformed
+		}
+		return Int(v), b[n:], nil
+	case tagFalse:
+		return Bool(false), b, nil
+	case tagTrue:
+		return Bool(true), b, nil
+	default:
+		return Param{}, nil, ErrMalformed
+	}
+}
@@ -0,0 +1,189 @@
+package customid
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testCodec(key string) *Codec {
+	c := NewCodec([]byte(key))
+	c.Register("accept", 1, KindString)
+	c.Register("page_history", 2, KindInt, KindString, KindString, KindInt, KindString, KindString)
+	c.Register("mixed", 1, KindString, KindInt, KindBool)
+	return c
+}
+
+func TestRoundTrip(t *testing.T) {
+	c := testCodec("secret")
+	testCases := []struct {
+		name   string
+		route  string
+		params []Param
+	}{
+		{
+			name:   "snowflake",
+			route:  "accept",
+			params: []Param{String("1234567890123456789")},
+		}, {
+			name:   "composite string",
+			route:  "accept",
+			params: []Param{String("1234567890123456789-r1m2-1700000000000000000")},
+		}, {
+			name:   "leading zero is not packed",
+			route:  "accept",
+			params: []Param{String("007")},
+		}, {
+			name:   "empty string",
+			route:  "accept",
+			params: []Param{String("")},
+		}, {
+			name:  "page button",
+			route: "page_history",
+			params: []Param{
+				Int(12), String("next"), String("1234567890123456789"),
+				Int(1700000000), String("1234567890123456789"), String("1234567890123456780"),
+			},
+		}, {
+			name:   "typed params",
+			route:  "mixed",
+			params: []Param{String("héllo_wörld"), Int(-42), Bool(true)},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			customID, err := c.Encode(tc.route, tc.params...)
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(customID), MaxLength)
+			require.True(t, strings.HasPrefix(customID, tc.route+"."))
+
+			id, err := c.Decode(customID)
+			require.NoError(t, err)
+			require.Equal(t, tc.route, id.Route)
+			require.Equal(t, tc.params, id.Params)
+		})
+	}
+}
+
+func TestAccessors(t *testing.T) {
+	c := testCodec("secret")
+	id, err := c.Decode(c.MustEncode("mixed", String("a"), Int(7), Bool(true)))
+	require.NoError(t, err)
+	require.Equal(t, "a", id.Str(0))
+	require.Equal(t, int64(7), id.Int(1))
+	require.True(t, id.Bool(2))
+	require.Zero(t, id.Int(0))
+	require.Empty(t, id.Str(5))
+	require.False(t, id.Bool(-1))
+}
+
+func TestDecodeRejects(t *testing.T) {
+	c := testCodec("secret")
+	valid := c.MustEncode("accept", String("1234567890123456789"))
+
+	other := NewCodec([]byte("secret"))
+	other.Register("accept", 2, KindString)
+	other.Register("unknown", 1, KindString)
+	other.Register("mixed", 1, KindString)
+
+	testCases := []struct {
+		name     string
+		customID string
+		err      error
+	}{
+		{name: "legacy id", customID: "accept_button_1234567890123456789", err: ErrMalformed},
+		{name: "bad base64", customID: "accept.!!!", err: ErrMalformed},
+		{name: "truncated", customID: valid[:len(valid)-4], err: ErrMalformed},
+		{name: "bad route", customID: "Accept" + valid[len("accept"):], err: ErrMalformed},
+		{name: "too long", customID: valid + strings.Repeat("A", MaxLength), err: ErrMalformed},
+		{name: "tampered", customID: tamper(valid), err: ErrForged},
+		{name: "route swapped", customID: "mixed" + valid[len("accept"):], err: ErrForged},
+		{name: "other key", customID: testCodec("another").MustEncode("accept", String("1")), err: ErrForged},
+		{name: "unknown route", customID: other.MustEncode("unknown", String("1")), err: ErrUnknownRoute},
+		{name: "stale version", customID: other.MustEncode("accept", String("1")), err: ErrStale},
+		{name: "wrong params", customID: other.MustEncode("mixed", String("1")), err: ErrParams},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := c.Decode(tc.customID)
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestEncodeRejects(t *testing.T) {
+	c := testCodec("secret")
+	_, err := c.Encode("unknown", String("1"))
+	require.ErrorIs(t, err, ErrUnknownRoute)
+	_, err = c.Encode("accept", Int(1))
+	require.ErrorIs(t, err, ErrParams)
+	_, err = c.Encode("accept")
+	require.ErrorIs(t, err, ErrParams)
+	_, err = c.Encode("accept", String(strings.Repeat("x", MaxLength)))
+	require.ErrorIs(t, err, ErrTooLong)
+	require.Panics(t, func() { c.MustEncode("unknown") })
+}
+
+func TestRegister(t *testing.T) {
+	c := testCodec("secret")
+	require.Panics(t, func() { c.Register("accept", 3) })
+	require.Panics(t, func() { c.Register("with.dot", 1) })
+	require.Panics(t, func() { c.Register("", 1) })
+}
+
+func TestRoute(t *testing.T) {
+	c := testCodec("secret")
+	require.Equal(t, "accept", c.Route(c.MustEncode("accept", String("1"))))
+	require.Equal(t, "accept", c.Route(tamper(c.MustEncode("accept", String("1")))))
+	require.Equal(t, "", c.Route("unknown.AAAA"))
+	require.Equal(t, "", c.Route("accept"))
+}
+
+// tamper flips a bit of the first payload byte of a custom id
+func tamper(customID string) string {
+	name, encoded, _ := strings.Cut(customID, ".")
+	alphabet := "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	i := strings.IndexByte(alphabet, encoded[0])
+	return name + "." + string(alphabet[i^1]) + encoded[1:]
+}
+
+func FuzzRoundTrip(f *testing.F) {
+	f.Add("1234567890123456789", int64(0), false)
+	f.Add("", int64(-1), true)
+	f.Add("007", int64(1<<62), false)
+	f.Add("héllo.wörld_", int64(-1<<63), true)
+	c := testCodec("secret")
+	f.Fuzz(func(t *testing.T, s string, n int64, b bool) {
+		params := []Param{String(s), Int(n), Bool(b)}
+		customID, err := c.Encode("mixed", params...)
+		if err != nil {
+			require.ErrorIs(t, err, ErrTooLong)
+			return
+		}
+		id, err := c.Decode(customID)
+		require.NoError(t, err)
+		require.Equal(t, params, id.Params)
+	})
+}
+
+func FuzzDecode(f *testing.F) {
+	c := testCodec("secret")
+	f.Add(c.MustEncode("accept", String("1234567890123456789")))
+	f.Add(c.MustEncode("mixed", String("x"), Int(3), Bool(false)))
+	f.Add("accept_button_1")
+	f.Add("accept.")
+	f.Add("page_history.AAAAAAAAAAAAAAAA")
+	f.Fuzz(func(t *testing.T, customID string) {
+		id, err := c.Decode(customID)
+		if err != nil {
+			return
+		}
+		// only ids the codec issued decode, and they encode back to themselves
+		reencoded, err := c.Encode(id.Route, id.Params...)
+		require.NoError(t, err)
+		require.Equal(t, customID, reencoded)
+	})
+}
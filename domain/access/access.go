@@ -0,0 +1,177 @@
+// access package decides who may use a command where
+//
+// A command may require member permissions, which discord also uses to hide it
+// by default. On top of that the admins of a guild keep a policy of allow and
+// deny lists of channels and roles, per command or for every command. Members
+// who can manage the guild are never restricted by its policy so they cannot
+// lock themselves out
+package access
+
+import (
+	"errors"
+	"slices"
+)
+
+// Access Errors
+var (
+	ErrDenied      = errors.New("access denied")
+	ErrInvalidRule = errors.New("rule must name a channel or a role")
+)
+
+// AllCommands is the command name of the rule applying to every command
+const AllCommands = "*"
+
+// Denial reasons
+const (
+	ReasonPermission = "permission"
+	ReasonChannel    = "channel"
+	ReasonRole       = "role"
+)
+
+// DeniedError tells why a request was denied
+type DeniedError struct {
+	Command string
+	Reason  string
+	// Permissions is the permission bit set the member lacks, set for ReasonPermission
+	Permissions uint64
+	// Channels lists the channels the command is allowed in, set for ReasonChannel
+	Channels []string
+}
+
+func (e *DeniedError) Error() string {
+	return ErrDenied.Error() + ": " + e.Command + " by " + e.Reason
+}
+
+func (e *DeniedError) Is(target error) bool {
+	return target == ErrDenied
+}
+
+// Rule restricts where and by whom a command can be used in a guild.
+// Deny lists win over allow lists, an empty allow list allows everything
+type Rule struct {
+	AllowChannels []string `json:"allow_channels,omitempty"`
+	DenyChannels  []string `json:"deny_channels,omitempty"`
+	AllowRoles    []string `json:"allow_roles,omitempty"`
+	DenyRoles     []string `json:"deny_roles,omitempty"`
+}
+
+// Empty reports whether the rule restricts nothing
+func (r Rule) Empty() bool {
+	return len(r.AllowChannels)+len(r.DenyChannels)+len(r.AllowRoles)+len(r.DenyRoles) == 0
+}
+
+// Allow adds a channel and a role to the allow lists, either may be empty
+func (r *Rule) Allow(channelID, roleID string) error {
+	if channelID == "" && roleID == "" {
+		return ErrInvalidRule
+	}
+	r.AllowChannels = add(r.AllowChannels, channelID)
+	r.AllowRoles = add(r.AllowRoles, roleID)
+	r.DenyChannels = remove(r.DenyChannels, channelID)
+	r.DenyRoles = remove(r.DenyRoles, roleID)
+	return nil
+}
+
+// Deny adds a channel and a role to the deny lists, either may be empty
+func (r *Rule) Deny(channelID, roleID string) error {
+	if channelID == "" && roleID == "" {
+		return ErrInvalidRule
+	}
+	r.DenyChannels = add(r.DenyChannels, channelID)
+	r.DenyRoles = add(r.DenyRoles, roleID)
+	r.AllowChannels = remove(r.AllowChannels, channelID)
+	r.AllowRoles = remove(r.AllowRoles, roleID)
+	return nil
+}
+
+func add(ids []string, id string) []string {
+	if id == "" || slices.Contains(ids, id) {
+		return ids
+	}
+	return append(ids, id)
+}
+
+func remove(ids []string, id string) []string {
+	return slices.DeleteFunc(ids, func(v string) bool { return v == id })
+}
+
+// check returns the reason the rule denies a channel and roles, empty when it allows them
+func (r Rule) check(channelID string, roles []string) (string, []string) {
+	if slices.Contains(r.DenyChannels, channelID) {
+		return ReasonChannel, r.AllowChannels
+	}
+	if len(r.AllowChannels) > 0 && !slices.Contains(r.AllowChannels, channelID) {
+		return ReasonChannel, r.AllowChannels
+	}
+	for _, role := range roles {
+		if slices.Contains(r.DenyRoles, role) {
+			return ReasonRole, nil
+		}
+	}
+	if len(r.AllowRoles) > 0 && !slices.ContainsFunc(roles, func(role string) bool {
+		return slices.Contains(r.AllowRoles, role)
+	}) {
+		return ReasonRole, nil
+	}
+	return "", nil
+}
+
+// Policy is the rules of a guild keyed by command name, the AllCommands rule
+// applies to every command along with the rule of the command
+type Policy struct {
+	GuildID string          `json:"guild_id"`
+	Rules   map[string]Rule `json:"rules,omitempty"`
+}
+
+// Clone returns a copy of the policy sharing no lists with it
+func (p Policy) Clone() Policy {
+	clone := Policy{GuildID: p.GuildID}
+	for command, r := range p.Rules {
+		clone.SetRule(command, Rule{
+			AllowChannels: slices.Clone(r.AllowChannels),
+			DenyChannels:  slices.Clone(r.DenyChannels),
+			AllowRoles:    slices.Clone(r.AllowRoles),
+			DenyRoles:     slices.Clone(r.DenyRoles),
+		})
+	}
+	return clone
+}
+
+// Rule returns the rule of a command, AllCommands for the guild wide rule
+func (p Policy) Rule(command string) Rule {
+	return p.Rules[command]
+}
+
+// SetRule replaces the rule of a command, an empty rule removes it
+func (p *Policy) SetRule(command string, r Rule) {
+	if r.Empty() {
+		delete(p.Rules, command)
+		return
+	}
+	if p.Rules == nil {
+		p.Rules = make(map[string]Rule)
+	}
+	p.Rules[command] = r
+}
+
+// Request is a member using a command in a guild
+type Request struct {
+	Command   string
+	ChannelID string
+	Roles     []string
+	// Manager is set for members who can manage the guild
+	Manager bool
+}
+
+// Check returns a *DeniedError when the policy denies the request
+func (p Policy) Check(req Request) error {
+	if req.Manager {
+		return nil
+	}
+	for _, name := range []string{AllCommands, req.Command} {
+		if reason, channels := p.Rule(name).check(req.ChannelID, req.Roles); reason != "" {
+			return &DeniedError{Command: req.Command, Reason: reason, Channels: channels}
+		}
+	}
+	return nil
+}
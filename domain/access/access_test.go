@@ -0,0 +1,82 @@
+package access
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleAllowDeny(t *testing.T) {
+	var r Rule
+	require.True(t, r.Empty())
+	require.ErrorIs(t, r.Allow("", ""), ErrInvalidRule)
+
+	require.NoError(t, r.Allow("games", "players"))
+	require.NoError(t, r.Allow("games", ""))
+	require.Equal(t, []string{"games"}, r.AllowChannels)
+	require.Equal(t, []string{"players"}, r.AllowRoles)
+
+	// denying moves an id from the allow list to the deny list
+	require.NoError(t, r.Deny("", "players"))
+	require.Empty(t, r.AllowRoles)
+	require.Equal(t, []string{"players"}, r.DenyRoles)
+	require.False(t, r.Empty())
+}
+
+func TestPolicyCheck(t *testing.T) {
+	var p Policy
+	require.NoError(t, p.Check(Request{Command: "challenge", ChannelID: "general"}))
+
+	p.SetRule(AllCommands, Rule{DenyRoles: []string{"muted"}})
+	p.SetRule("challenge", Rule{AllowChannels: []string{"games"}, AllowRoles: []string{"players"}})
+
+	testCases := []struct {
+		name   string
+		req    Request
+		reason string
+	}{
+		{
+			name: "allowed",
+			req:  Request{Command: "challenge", ChannelID: "games", Roles: []string{"players"}},
+		}, {
+			name:   "wrong channel",
+			req:    Request{Command: "challenge", ChannelID: "general", Roles: []string{"players"}},
+			reason: ReasonChannel,
+		}, {
+			name:   "missing role",
+			req:    Request{Command: "challenge", ChannelID: "games"},
+			reason: ReasonRole,
+		}, {
+			name:   "denied role on every command",
+			req:    Request{Command: "daily", ChannelID: "general", Roles: []string{"muted"}},
+			reason: ReasonRole,
+		}, {
+			name: "unrestricted command",
+			req:  Request{Command: "daily", ChannelID: "general"},
+		}, {
+			name: "managers are never restricted",
+			req:  Request{Command: "challenge", ChannelID: "general", Roles: []string{"muted"}, Manager: true},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := p.Check(tc.req)
+			if tc.reason == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrDenied)
+			var denied *DeniedError
+			require.ErrorAs(t, err, &denied)
+			require.Equal(t, tc.reason, denied.Reason)
+		})
+	}
+
+	var denied *DeniedError
+	require.ErrorAs(t, p.Check(Request{Command: "challenge", ChannelID: "general"}), &denied)
+	require.Equal(t, []string{"games"}, denied.Channels)
+
+	p.SetRule("challenge", Rule{})
+	require.NotContains(t, p.Rules, "challenge")
+}
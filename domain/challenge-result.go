@@ -3,6 +3,7 @@ package domain
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 var (
@@ -13,6 +14,35 @@ type ChallengeResult struct {
 	Winner      *Player
 	Looser      *Player
 	OutcomeDraw bool
+	Forfeit     bool // the looser gave up before playing
+	// Placements ranks every player of a round of more than two players,
+	// Winner and Looser are not set for such rounds
+	Placements []Placement
+}
+
+// Placement is where a player finished in a round of more than two players
+type Placement struct {
+	Player *Player
+	Team   int // zero when everyone plays for themselves
+	Points int
+	Rank   int // tied players share a rank, 1 is the best
+}
+
+// Winners returns the players who won, none for a draw
+func (cr *ChallengeResult) Winners() []*Player {
+	if cr.OutcomeDraw {
+		return nil
+	}
+	if len(cr.Placements) == 0 {
+		return []*Player{cr.Winner}
+	}
+	winners := []*Player{}
+	for _, p := range cr.Placements {
+		if p.Rank == 1 {
+			winners = append(winners, p.Player)
+		}
+	}
+	return winners
 }
 
 //TODO: write test for formatResultMsg
@@ -20,12 +50,43 @@ type ChallengeResult struct {
 // FormatResultMsg returns a formatted msg detailing the result of a challenge
 // when no winner or looser has been set an invalid challenge result is returned
 func (cr *ChallengeResult) FormatResult() (string, error) {
+	if len(cr.Placements) > 0 {
+		return cr.formatPlacements(), nil
+	}
 	if cr.Winner == nil || cr.Looser == nil {
 		return "", ErrInvalidChallengeResult
 	}
 
+	if cr.Forfeit {
+		return fmt.Sprintf("<@%v> wins the challenge, <@%v> forfeited", cr.Winner.ID, cr.Looser.ID), nil
+	}
 	if cr.OutcomeDraw {
 		return fmt.Sprintf("<@%v> and <@%v> draw with **%v**", cr.Winner.ID, cr.Looser.ID, cr.Looser.Choice), nil
 	}
 	return fmt.Sprintf("<@%v> wins the challenge with **%v** beating <@%s>'s **%v**", cr.Winner.ID, cr.Winner.Choice, cr.Looser.ID, cr.Looser.Choice), nil
 }
+
+// formatPlacements lists the players of a round from first to last
+func (cr *ChallengeResult) formatPlacements() string {
+	var b strings.Builder
+	switch first := cr.Placements[0]; {
+	case cr.OutcomeDraw:
+		b.WriteString("It's a draw!\n")
+	case first.Team != 0:
+		fmt.Fprintf(&b, "Team %d wins!\n", first.Team)
+	default:
+		mentions := []string{}
+		for _, p := range cr.Winners() {
+			mentions = append(mentions, fmt.Sprintf("<@%v>", p.ID))
+		}
+		fmt.Fprintf(&b, "%s won!\n", strings.Join(mentions, ", "))
+	}
+	for _, p := range cr.Placements {
+		fmt.Fprintf(&b, "%d. <@%v> **%v** %d pts", p.Rank, p.Player.ID, p.Player.Choice, p.Points)
+		if p.Team != 0 {
+			fmt.Fprintf(&b, " (team %d)", p.Team)
+		}
+		b.WriteString("\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -5,6 +5,7 @@ package challenge
 
 import (
 	"errors"
+	"time"
 
 	"github.com/ekefan/discord-bot/domain"
 )
@@ -20,25 +21,170 @@ var (
 // Challenge is an instance of a Rock Paper Scissor Challenge
 type Challenge struct {
 	id         string
+	scope      Scope
+	origin     Origin
 	challenger *domain.Player
 	opponent   *domain.Player
 	result     *domain.ChallengeResult
+	status     Status
+	claimedBy  string
+	expiresAt  time.Time
+	events     []Event
+	target     string // only this player may accept when set
+	botGame    bool
+	tournament string // tournament and match the challenge was played for
+	match      string
+	wager      int    // coins each player stakes, escrowed once accepted
+	series     string // series the challenge is a rematch in and its number
+	rematch    int
+	commitment string // hash of the challenger's choice and nonce in fair mode
+	nonce      string
+	version    int // set by the store, grows with every stored change
 }
 
-// TODO: Write tests for these functions
-
 // NewChallenge Factory create new Challenges
-func NewChallenge(challengeId string, challenger *domain.Player) (*Challenge, error) {
+func NewChallenge(challengeId string, scope Scope, challenger *domain.Player) (*Challenge, error) {
 	if challengeId == "" {
 		return nil, ErrInvalidChallengeID
 	}
 	if challenger == nil || !challenger.Valid() {
 		return nil, ErrInvalidPlayer
 	}
-	return &Challenge{
+	c := &Challenge{
 		id:         challengeId,
+		scope:      scope,
 		challenger: challenger,
-	}, nil
+	}
+	c.record(EventCreated, challenger.ID, time.Now())
+	return c, nil
+}
+
+// NewMatch creates a challenge between two preset players where neither has chosen yet,
+// the challenge starts claimed by playerB and resolves once both players made a choice
+func NewMatch(challengeId string, scope Scope, playerA, playerB string) (*Challenge, error) {
+	if challengeId == "" {
+		return nil, ErrInvalidChallengeID
+	}
+	if playerA == "" || playerB == "" || playerA == playerB {
+		return nil, ErrInvalidPlayer
+	}
+	c := &Challenge{
+		id:         challengeId,
+		scope:      scope,
+		challenger: &domain.Player{ID: playerA},
+		target:     playerB,
+	}
+	at := time.Now()
+	c.record(EventCreated, SystemActor, at)
+	c.status = Claimed
+	c.claimedBy = playerB
+	c.record(EventAccepted, playerB, at)
+	return c, nil
+}
+
+// Scope returns where the challenge was issued
+func (c *Challenge) Scope() Scope {
+	return c.scope
+}
+
+// Origin returns the interaction that created the challenge
+func (c *Challenge) Origin() Origin {
+	return c.origin
+}
+
+// SetOrigin records the interaction that created the challenge
+func (c *Challenge) SetOrigin(origin Origin) {
+	c.origin = origin
+}
+
+// Challenger returns the player who issued the challenge
+func (c *Challenge) Challenger() *domain.Player {
+	return c.challenger
+}
+
+// Target returns the only player allowed to accept the challenge, empty when anyone can
+func (c *Challenge) Target() string {
+	return c.target
+}
+
+// SetTarget restricts who can accept the challenge to userID
+func (c *Challenge) SetTarget(userID string) {
+	c.target = userID
+}
+
+// BotGame reports whether the challenge is played against the computer player
+func (c *Challenge) BotGame() bool {
+	return c.botGame
+}
+
+// SetBotGame marks the challenge as played against the computer player
+func (c *Challenge) SetBotGame(botGame bool) {
+	c.botGame = botGame
+}
+
+// Tournament returns the tournament and match the challenge is played for, empty for casual challenges
+func (c *Challenge) Tournament() (tournamentID, matchID string) {
+	return c.tournament, c.match
+}
+
+// SetTournament marks the challenge as the game played for a tournament match
+func (c *Challenge) SetTournament(tournamentID, matchID string) {
+	c.tournament = tournamentID
+	c.match = matchID
+}
+
+// Wager returns the coins each player stakes on the challenge, zero when nothing is at stake
+func (c *Challenge) Wager() int {
+	return c.wager
+}
+
+// SetWager sets the coins each player stakes on the challenge
+func (c *Challenge) SetWager(coins int) {
+	c.wager = coins
+}
+
+// Version is the version of the stored challenge c was read as, zero before it was stored
+func (c *Challenge) Version() int {
+	return c.version
+}
+
+// SetVersion is called by stores when they save c, callers never set it
+func (c *Challenge) SetVersion(version int) {
+	c.version = version
+}
+
+// Series returns the series the challenge is a rematch in and the number of the
+// rematch, empty for a first game
+func (c *Challenge) Series() (seriesID string, rematch int) {
+	return c.series, c.rematch
+}
+
+// SetSeries marks the challenge as the rematch number rematch of a series
+func (c *Challenge) SetSeries(seriesID string, rematch int) {
+	c.series = seriesID
+	c.rematch = rematch
+}
+
+// Commitment returns the published hash of the challenger's choice and the nonce
+// revealed once the game is over, both are empty unless the challenge is played fair
+func (c *Challenge) Commitment() (commitment, nonce string) {
+	return c.commitment, c.nonce
+}
+
+// SetCommitment plays the challenge fair with commitment made for the challenger's choice and nonce
+func (c *Challenge) SetCommitment(commitment, nonce string) {
+	c.commitment = commitment
+	c.nonce = nonce
+}
+
+// Opponent returns the player who played against the challenger, nil until a choice is made
+func (c *Challenge) Opponent() *domain.Player {
+	return c.opponent
+}
+
+// Result returns the result of a resolved or forfeited challenge, nil otherwise
+func (c *Challenge) Result() *domain.ChallengeResult {
+	return c.result
 }
 
 func (c *Challenge) GetChallengeID() (string, error) {
@@ -0,0 +1,234 @@
+package challenge
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestChallenge(t *testing.T, choice domain.RpsChoice) *Challenge {
+	c, err := NewChallenge("1", Scope{GuildID: "g1", ChannelID: "c1"}, &domain.Player{ID: "challenger", Choice: choice})
+	require.NoError(t, err)
+	return c
+}
+
+func eventTypes(c *Challenge) []EventType {
+	types := []EventType{}
+	for _, e := range c.History() {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestNewChallenge(t *testing.T) {
+	_, err := NewChallenge("", Scope{}, &domain.Player{ID: "p1", Choice: domain.Rock})
+	require.ErrorIs(t, err, ErrInvalidChallengeID)
+	_, err = NewChallenge("1", Scope{}, &domain.Player{ID: "p1", Choice: "lizard"})
+	require.ErrorIs(t, err, ErrInvalidPlayer)
+
+	c := newTestChallenge(t, domain.Rock)
+	require.Equal(t, Open, c.Status())
+	require.Equal(t, []EventType{EventCreated}, eventTypes(c))
+	require.Equal(t, "challenger", c.History()[0].Actor)
+}
+
+func TestChallengeLifecycle(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := newTestChallenge(t, domain.Rock)
+
+	require.ErrorIs(t, c.Accept("challenger", at), ErrOwnChallenge)
+	require.NoError(t, c.Accept("opponent", at))
+	require.Equal(t, Claimed, c.Status())
+	require.Equal(t, "opponent", c.ClaimedBy())
+
+	require.ErrorIs(t, c.MakeChoice(&domain.Player{ID: "intruder", Choice: domain.Paper}, at), ErrNotClaimant)
+	require.ErrorIs(t, c.MakeChoice(&domain.Player{ID: "opponent", Choice: "lizard"}, at), ErrInvalidPlayer)
+	require.NoError(t, c.MakeChoice(&domain.Player{ID: "opponent", Choice: domain.Paper}, at.Add(time.Second)))
+	require.Equal(t, Resolved, c.Status())
+
+	msg, err := c.GetResultMsg()
+	require.NoError(t, err)
+	require.Equal(t, "<@opponent> wins the challenge with **paper** beating <@challenger>'s **rock**", msg)
+
+	require.Equal(t, []EventType{EventCreated, EventAccepted, EventChoiceMade, EventResolved}, eventTypes(c))
+	history := c.History()
+	require.Equal(t, domain.Paper, history[2].Choice)
+	require.Equal(t, at.Add(time.Second), history[3].At)
+	require.Equal(t, SystemActor, history[3].Actor)
+}
+
+func TestIllegalTransitions(t *testing.T) {
+	at := time.Now()
+	testCases := []struct {
+		name  string
+		setup func(c *Challenge)
+		apply func(c *Challenge) error
+		from  Status
+		event EventType
+	}{
+		{
+			name:  "choice on open challenge",
+			setup: func(c *Challenge) {},
+			apply: func(c *Challenge) error {
+				return c.MakeChoice(&domain.Player{ID: "opponent", Choice: domain.Rock}, at)
+			},
+			from:  Open,
+			event: EventChoiceMade,
+		}, {
+			name:  "accept claimed challenge",
+			setup: func(c *Challenge) { c.Accept("opponent", at) },
+			apply: func(c *Challenge) error { return c.Accept("other", at) },
+			from:  Claimed,
+			event: EventAccepted,
+		}, {
+			name:  "cancel claimed challenge",
+			setup: func(c *Challenge) { c.Accept("opponent", at) },
+			apply: func(c *Challenge) error { return c.Cancel("challenger", at) },
+			from:  Claimed,
+			event: EventCancelled,
+		}, {
+			name:  "forfeit open challenge",
+			setup: func(c *Challenge) {},
+			apply: func(c *Challenge) error { return c.Forfeit("challenger", at) },
+			from:  Open,
+			event: EventForfeited,
+		}, {
+			name:  "expire cancelled challenge",
+			setup: func(c *Challenge) { c.Cancel("challenger", at) },
+			apply: func(c *Challenge) error { return c.Expire(at) },
+			from:  Cancelled,
+			event: EventExpired,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestChallenge(t, domain.Rock)
+			tc.setup(c)
+			before := len(c.History())
+			err := tc.apply(c)
+			require.ErrorIs(t, err, ErrIllegalTransition)
+			require.Equal(t, &TransitionError{From: tc.from, Event: tc.event}, err)
+			require.Len(t, c.History(), before)
+		})
+	}
+}
+
+func TestCancelExpireForfeit(t *testing.T) {
+	at := time.Now()
+
+	c := newTestChallenge(t, domain.Rock)
+	require.ErrorIs(t, c.Cancel("someone", at), ErrNotChallenger)
+	require.NoError(t, c.Cancel("challenger", at))
+	require.Equal(t, Cancelled, c.Status())
+
+	c = newTestChallenge(t, domain.Rock)
+	c.SetExpiry(at)
+	require.False(t, c.ExpiredAt(at.Add(-time.Second)))
+	require.True(t, c.ExpiredAt(at))
+	require.NoError(t, c.Expire(at))
+	require.False(t, c.ExpiredAt(at))
+
+	c = newTestChallenge(t, domain.Rock)
+	require.NoError(t, c.Accept("opponent", at))
+	require.ErrorIs(t, c.Forfeit("someone", at), ErrNotParticipant)
+	require.NoError(t, c.Forfeit("opponent", at))
+	require.Equal(t, Forfeited, c.Status())
+	msg, err := c.GetResultMsg()
+	require.NoError(t, err)
+	require.Equal(t, "<@challenger> wins the challenge, <@opponent> forfeited", msg)
+}
+
+func TestHistoryNotShared(t *testing.T) {
+	c := newTestChallenge(t, domain.Rock)
+	first, second := *c, *c
+	require.NoError(t, first.Accept("a", time.Now()))
+	require.NoError(t, second.Cancel("challenger", time.Now()))
+	require.Equal(t, EventAccepted, first.History()[1].Type)
+	require.Equal(t, EventCancelled, second.History()[1].Type)
+}
+
+func TestMatch(t *testing.T) {
+	at := time.Now()
+	_, err := NewMatch("1", Scope{}, "a", "a")
+	require.ErrorIs(t, err, ErrInvalidPlayer)
+
+	c, err := NewMatch("1", Scope{GuildID: "g1", ChannelID: "c1"}, "a", "b")
+	require.NoError(t, err)
+	c.SetTournament("t1", "m1")
+	require.Equal(t, Claimed, c.Status())
+	require.ErrorIs(t, c.Accept("c", at), ErrIllegalTransition)
+
+	require.ErrorIs(t, c.MakeChoice(&domain.Player{ID: "c", Choice: domain.Rock}, at), ErrNotClaimant)
+	require.NoError(t, c.MakeChoice(&domain.Player{ID: "b", Choice: domain.Rock}, at))
+	require.True(t, c.Chose("b"))
+	require.False(t, c.Chose("a"))
+	require.Equal(t, Claimed, c.Status())
+	require.ErrorIs(t, c.MakeChoice(&domain.Player{ID: "b", Choice: domain.Paper}, at), ErrChoiceMade)
+
+	require.NoError(t, c.MakeChoice(&domain.Player{ID: "a", Choice: domain.Paper}, at))
+	require.Equal(t, Resolved, c.Status())
+	require.Equal(t, "a", c.Result().Winner.ID)
+	require.Equal(t, []EventType{EventCreated, EventAccepted, EventChoiceMade, EventChoiceMade, EventResolved}, eventTypes(c))
+	tournamentID, matchID := c.Tournament()
+	require.Equal(t, "t1", tournamentID)
+	require.Equal(t, "m1", matchID)
+}
+
+func TestSeries(t *testing.T) {
+	_, err := NewSeries("s1", "a", "a")
+	require.ErrorIs(t, err, ErrInvalidSeries)
+
+	s, err := NewSeries("s1", "a", "b")
+	require.NoError(t, err)
+	a, b := &domain.Player{ID: "a", Choice: domain.Rock}, &domain.Player{ID: "b", Choice: domain.Scissor}
+	require.NoError(t, s.Record(&domain.ChallengeResult{Winner: a, Looser: b}))
+	require.NoError(t, s.Record(&domain.ChallengeResult{Winner: b, Looser: a}))
+	require.NoError(t, s.Record(&domain.ChallengeResult{Winner: a, Looser: b, OutcomeDraw: true}))
+	require.NoError(t, s.Record(&domain.ChallengeResult{Winner: a, Looser: b}))
+	require.ErrorIs(t, s.Record(&domain.ChallengeResult{Winner: a, Looser: &domain.Player{ID: "c"}}), ErrSeriesMismatch)
+	require.Equal(t, 4, s.Games)
+	require.Equal(t, "<@a> 2-1 <@b>", s.Score())
+
+	opponent, err := s.Opponent("b")
+	require.NoError(t, err)
+	require.Equal(t, "a", opponent)
+	_, err = s.Opponent("c")
+	require.ErrorIs(t, err, ErrNotInSeries)
+
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.False(t, s.ExpiredAt(at))
+	s.ExpiresAt = at
+	require.True(t, s.ExpiredAt(at))
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	at := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := newTestChallenge(t, domain.Rock)
+	c.SetOrigin(Origin{InteractionToken: "token", IssuedAt: at, MessageID: "m1"})
+	c.SetExpiry(at.Add(time.Minute))
+	c.SetWager(10)
+	c.SetSeries("s1", 2)
+	c.SetCommitment("hash", "nonce")
+	c.SetVersion(3)
+	require.NoError(t, c.Accept("opponent", at))
+	require.NoError(t, c.MakeChoice(&domain.Player{ID: "opponent", Choice: domain.Paper}, at))
+
+	data, err := json.Marshal(c.Snapshot())
+	require.NoError(t, err)
+	var s Snapshot
+	require.NoError(t, json.Unmarshal(data, &s))
+	restored := Restore(s)
+	require.Equal(t, eventTypes(c), eventTypes(restored))
+	// the creation time carries a monotonic reading that doesn't survive encoding
+	expected := c.Snapshot()
+	expected.Events, s.Events = nil, nil
+	require.Equal(t, expected, s)
+	require.Equal(t, Resolved, restored.Status())
+	msg, err := restored.GetResultMsg()
+	require.NoError(t, err)
+	require.Equal(t, "<@opponent> wins the challenge with **paper** beating <@challenger>'s **rock**", msg)
+}
@@ -0,0 +1,51 @@
+package challenge
+
+import (
+	"time"
+
+	"github.com/ekefan/discord-bot/domain"
+)
+
+// SystemActor is the actor of events not caused by a player
+const SystemActor = "system"
+
+type EventType string
+
+// Challenge Events
+const (
+	EventCreated    EventType = "created"
+	EventAccepted   EventType = "accepted"
+	EventChoiceMade EventType = "choice_made"
+	EventResolved   EventType = "resolved"
+	EventExpired    EventType = "expired"
+	EventCancelled  EventType = "cancelled"
+	EventForfeited  EventType = "forfeited"
+)
+
+// Event is an entry of a challenge's history
+type Event struct {
+	Type        EventType
+	ChallengeID string
+	At          time.Time
+	Actor       string           // id of the player who caused the event or SystemActor
+	Choice      domain.RpsChoice // set on choice made events
+}
+
+// History returns the events of the challenge in the order they happened
+func (c *Challenge) History() []Event {
+	history := make([]Event, len(c.events))
+	copy(history, c.events)
+	return history
+}
+
+// record appends an event to the history. The history is always reallocated so
+// copies of a challenge never share appended events
+func (c *Challenge) record(eventType EventType, actor string, at time.Time) *Event {
+	c.events = append(c.events[:len(c.events):len(c.events)], Event{
+		Type:        eventType,
+		ChallengeID: c.id,
+		At:          at,
+		Actor:       actor,
+	})
+	return &c.events[len(c.events)-1]
+}
@@ -0,0 +1,53 @@
+package challenge
+
+import (
+	"fmt"
+	"time"
+)
+
+// Interaction contexts a challenge can be issued in
+const (
+	GuildContext          = 0
+	BotDMContext          = 1
+	PrivateChannelContext = 2
+)
+
+// InteractionTokenTTL is how long discord accepts an interaction token for follow ups
+const InteractionTokenTTL = 15 * time.Minute
+
+// Scope is where a challenge was issued,
+// challenges are stored and looked up per scope so a challenge can only be
+// interacted with from the guild channel, DM or group DM it was created in
+type Scope struct {
+	Context   int
+	GuildID   string
+	ChannelID string
+}
+
+// Key returns a string that uniquely identifies the scope
+func (s Scope) Key() string {
+	switch s.Context {
+	case PrivateChannelContext:
+		return fmt.Sprintf("private:%s", s.ChannelID)
+	case BotDMContext:
+		return fmt.Sprintf("dm:%s", s.ChannelID)
+	default:
+		return fmt.Sprintf("guild:%s:%s", s.GuildID, s.ChannelID)
+	}
+}
+
+// Origin references the interaction that created a challenge.
+//
+// In group DMs and for user installed apps the bot is not a member of the
+// channel, so the original message can only be edited through the
+// interaction token, which expires after InteractionTokenTTL
+type Origin struct {
+	InteractionToken string
+	IssuedAt         time.Time
+	MessageID        string // set once the challenge message has been posted
+}
+
+// TokenValid reports whether the origin interaction token can still be used at t
+func (o Origin) TokenValid(t time.Time) bool {
+	return o.InteractionToken != "" && t.Before(o.IssuedAt.Add(InteractionTokenTTL))
+}
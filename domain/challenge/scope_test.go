@@ -0,0 +1,89 @@
+package challenge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopeKey(t *testing.T) {
+	testCases := []struct {
+		name  string
+		scope Scope
+		key   string
+	}{
+		{
+			name:  "guild",
+			scope: Scope{Context: GuildContext, GuildID: "g1", ChannelID: "c1"},
+			key:   "guild:g1:c1",
+		}, {
+			name:  "group dm",
+			scope: Scope{Context: PrivateChannelContext, ChannelID: "c1"},
+			key:   "private:c1",
+		}, {
+			name:  "bot dm",
+			scope: Scope{Context: BotDMContext, ChannelID: "c1"},
+			key:   "dm:c1",
+		}, {
+			// user installed commands run in guilds the bot is not in
+			name:  "group dm with guild",
+			scope: Scope{Context: PrivateChannelContext, GuildID: "g1", ChannelID: "c1"},
+			key:   "private:c1",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.key, tc.scope.Key())
+		})
+	}
+
+	// the same channel id in different contexts are different scopes
+	keys := map[string]bool{}
+	for _, tc := range testCases[:3] {
+		keys[tc.scope.Key()] = true
+	}
+	require.Len(t, keys, 3)
+}
+
+func TestOriginTokenValid(t *testing.T) {
+	issued := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name   string
+		origin Origin
+		at     time.Time
+		valid  bool
+	}{
+		{
+			name:   "fresh token",
+			origin: Origin{InteractionToken: "tkn", IssuedAt: issued},
+			at:     issued.Add(time.Minute),
+			valid:  true,
+		}, {
+			name:   "just before expiry",
+			origin: Origin{InteractionToken: "tkn", IssuedAt: issued},
+			at:     issued.Add(InteractionTokenTTL - time.Second),
+			valid:  true,
+		}, {
+			name:   "at expiry",
+			origin: Origin{InteractionToken: "tkn", IssuedAt: issued},
+			at:     issued.Add(InteractionTokenTTL),
+			valid:  false,
+		}, {
+			name:   "expired",
+			origin: Origin{InteractionToken: "tkn", IssuedAt: issued},
+			at:     issued.Add(time.Hour),
+			valid:  false,
+		}, {
+			name:   "no token",
+			origin: Origin{IssuedAt: issued, MessageID: "m1"},
+			at:     issued,
+			valid:  false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.valid, tc.origin.TokenValid(tc.at))
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package challenge
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain"
+)
+
+// Series Errors
+var (
+	ErrInvalidSeries  = errors.New("series must have an id and two different players")
+	ErrNotInSeries    = errors.New("player is not part of this series")
+	ErrSeriesMismatch = errors.New("result is not between the players of the series")
+)
+
+// Series tallies the games two players played against each other through rematches,
+// it is identified by the first challenge of the series
+type Series struct {
+	ID        string
+	Players   [2]string
+	Wins      [2]int
+	Draws     int
+	Games     int
+	ExpiresAt time.Time // when a rematch can no longer be asked for, zero means never
+}
+
+// NewSeries starts an empty series between two players
+func NewSeries(id, playerA, playerB string) (*Series, error) {
+	if id == "" || playerA == "" || playerB == "" || playerA == playerB {
+		return nil, ErrInvalidSeries
+	}
+	return &Series{ID: id, Players: [2]string{playerA, playerB}}, nil
+}
+
+// Includes reports whether userID plays in the series
+func (s *Series) Includes(userID string) bool {
+	return userID == s.Players[0] || userID == s.Players[1]
+}
+
+// Opponent returns the other player of the series
+func (s *Series) Opponent(userID string) (string, error) {
+	switch userID {
+	case s.Players[0]:
+		return s.Players[1], nil
+	case s.Players[1]:
+		return s.Players[0], nil
+	default:
+		return "", ErrNotInSeries
+	}
+}
+
+// Record adds the result of a game between the players of the series
+func (s *Series) Record(result *domain.ChallengeResult) error {
+	if result == nil || result.Winner == nil || result.Looser == nil ||
+		!s.Includes(result.Winner.ID) || !s.Includes(result.Looser.ID) {
+		return ErrSeriesMismatch
+	}
+	s.Games++
+	switch {
+	case result.OutcomeDraw:
+		s.Draws++
+	case result.Winner.ID == s.Players[0]:
+		s.Wins[0]++
+	default:
+		s.Wins[1]++
+	}
+	return nil
+}
+
+// ExpiredAt reports whether a rematch can no longer be asked for at t
+func (s *Series) ExpiredAt(t time.Time) bool {
+	return !s.ExpiresAt.IsZero() && !t.Before(s.ExpiresAt)
+}
+
+// Score formats the wins of both players
+func (s *Series) Score() string {
+	return fmt.Sprintf("<@%s> %d-%d <@%s>", s.Players[0], s.Wins[0], s.Wins[1], s.Players[1])
+}
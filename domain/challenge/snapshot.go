@@ -0,0 +1,85 @@
+package challenge
+
+import (
+	"time"
+
+	"github.com/ekefan/discord-bot/domain"
+)
+
+// Snapshot is the whole state of a challenge as plain data, stores that keep
+// challenges outside of the process save snapshots and restore them
+type Snapshot struct {
+	ID           string                  `json:"id"`
+	Scope        Scope                   `json:"scope"`
+	Origin       Origin                  `json:"origin"`
+	Challenger   *domain.Player          `json:"challenger"`
+	Opponent     *domain.Player          `json:"opponent,omitempty"`
+	Result       *domain.ChallengeResult `json:"result,omitempty"`
+	Status       Status                  `json:"status"`
+	ClaimedBy    string                  `json:"claimed_by,omitempty"`
+	ExpiresAt    time.Time               `json:"expires_at"`
+	Events       []Event                 `json:"events"`
+	Target       string                  `json:"target,omitempty"`
+	BotGame      bool                    `json:"bot_game,omitempty"`
+	TournamentID string                  `json:"tournament_id,omitempty"`
+	MatchID      string                  `json:"match_id,omitempty"`
+	Wager        int                     `json:"wager,omitempty"`
+	SeriesID     string                  `json:"series_id,omitempty"`
+	Rematch      int                     `json:"rematch,omitempty"`
+	Commitment   string                  `json:"commitment,omitempty"`
+	Nonce        string                  `json:"nonce,omitempty"`
+	Version      int                     `json:"version,omitempty"`
+}
+
+// Snapshot returns the state of c
+func (c *Challenge) Snapshot() Snapshot {
+	return Snapshot{
+		ID:           c.id,
+		Scope:        c.scope,
+		Origin:       c.origin,
+		Challenger:   c.challenger,
+		Opponent:     c.opponent,
+		Result:       c.result,
+		Status:       c.status,
+		ClaimedBy:    c.claimedBy,
+		ExpiresAt:    c.expiresAt,
+		Events:       c.History(),
+		Target:       c.target,
+		BotGame:      c.botGame,
+		TournamentID: c.tournament,
+		MatchID:      c.match,
+		Wager:        c.wager,
+		SeriesID:     c.series,
+		Rematch:      c.rematch,
+		Commitment:   c.commitment,
+		Nonce:        c.nonce,
+		Version:      c.version,
+	}
+}
+
+// Restore returns the challenge s is the state of, it trusts s the way a
+// store trusts what it saved and doesn't validate it
+func Restore(s Snapshot) *Challenge {
+	return &Challenge{
+		id:         s.ID,
+		scope:      s.Scope,
+		origin:     s.Origin,
+		challenger: s.Challenger,
+		opponent:   s.Opponent,
+		result:     s.Result,
+		status:     s.Status,
+		claimedBy:  s.ClaimedBy,
+		expiresAt:  s.ExpiresAt,
+		events:     append([]Event(nil), s.Events...),
+		target:     s.Target,
+		botGame:    s.BotGame,
+		tournament: s.TournamentID,
+		match:      s.MatchID,
+		wager:      s.Wager,
+		series:     s.SeriesID,
+		rematch:    s.Rematch,
+		commitment: s.Commitment,
+		nonce:      s.Nonce,
+		version:    s.Version,
+	}
+}
@@ -0,0 +1,224 @@
+package challenge
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain"
+)
+
+// Status is the lifecycle state of a challenge
+//
+//	Open --Accepted--> Claimed --ChoiceMade, Resolved--> Resolved
+//	Claimed (match) --ChoiceMade--> Claimed --ChoiceMade, Resolved--> Resolved
+//	Open --Cancelled--> Cancelled
+//	Open | Claimed --Expired--> Expired
+//	Claimed --Forfeited--> Forfeited
+type Status int
+
+const (
+	Open Status = iota
+	Claimed
+	Resolved
+	Expired
+	Cancelled
+	Forfeited
+)
+
+// State Errors
+var (
+	ErrIllegalTransition = errors.New("illegal challenge transition")
+	ErrOwnChallenge      = errors.New("a challenger can not accept their own challenge")
+	ErrNotClaimant       = errors.New("challenge was claimed by another player")
+	ErrNotTarget         = errors.New("challenge was issued to another player")
+	ErrNotChallenger     = errors.New("only the challenger can do this")
+	ErrNotParticipant    = errors.New("player is not part of this challenge")
+	ErrChoiceMade        = errors.New("player already made a choice")
+)
+
+// TransitionError is returned when an event is not allowed in the current state
+type TransitionError struct {
+	From  Status
+	Event EventType
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("%v: %s is not allowed when %s", ErrIllegalTransition, e.Event, e.From)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrIllegalTransition
+}
+
+func (s Status) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case Claimed:
+		return "claimed"
+	case Resolved:
+		return "resolved"
+	case Expired:
+		return "expired"
+	case Cancelled:
+		return "cancelled"
+	case Forfeited:
+		return "forfeited"
+	default:
+		return "unknown"
+	}
+}
+
+// Terminal reports whether no further transitions are possible from s
+func (s Status) Terminal() bool {
+	return s == Resolved || s == Expired || s == Cancelled || s == Forfeited
+}
+
+// transitions lists the states each event is allowed in
+var transitions = map[EventType][]Status{
+	EventAccepted:   {Open},
+	EventChoiceMade: {Claimed},
+	EventCancelled:  {Open},
+	EventExpired:    {Open, Claimed},
+	EventForfeited:  {Claimed},
+}
+
+func (c *Challenge) allow(event EventType) error {
+	for _, from := range transitions[event] {
+		if c.status == from {
+			return nil
+		}
+	}
+	return &TransitionError{From: c.status, Event: event}
+}
+
+// Status returns the current state of the challenge
+func (c *Challenge) Status() Status {
+	return c.status
+}
+
+// ClaimedBy returns the id of the player who accepted the challenge
+func (c *Challenge) ClaimedBy() string {
+	return c.claimedBy
+}
+
+// ExpiresAt returns when an unresolved challenge expires, zero means never
+func (c *Challenge) ExpiresAt() time.Time {
+	return c.expiresAt
+}
+
+// SetExpiry sets when an unresolved challenge expires
+func (c *Challenge) SetExpiry(t time.Time) {
+	c.expiresAt = t
+}
+
+// ExpiredAt reports whether an unresolved challenge is past its expiry at t
+func (c *Challenge) ExpiredAt(t time.Time) bool {
+	return !c.status.Terminal() && !c.expiresAt.IsZero() && !t.Before(c.expiresAt)
+}
+
+// Accept reserves an open challenge for userID, only one player can accept a challenge
+// and only the target can accept a targeted challenge
+func (c *Challenge) Accept(userID string, at time.Time) error {
+	if err := c.allow(EventAccepted); err != nil {
+		return err
+	}
+	if userID == c.challenger.ID {
+		return ErrOwnChallenge
+	}
+	if c.target != "" && userID != c.target {
+		return ErrNotTarget
+	}
+	c.status = Claimed
+	c.claimedBy = userID
+	c.record(EventAccepted, userID, at)
+	return nil
+}
+
+// MakeChoice records the choice of a player and resolves the challenge once both players chose.
+// The challenger of a casual challenge chose when creating it, only the player who accepted it
+// can choose, both players of a match choose
+func (c *Challenge) MakeChoice(player *domain.Player, at time.Time) error {
+	if err := c.allow(EventChoiceMade); err != nil {
+		return err
+	}
+	if player == nil || !player.Valid() {
+		return ErrInvalidPlayer
+	}
+	switch player.ID {
+	case c.claimedBy:
+		if err := c.SetOpponent(player); err != nil {
+			return ErrChoiceMade
+		}
+	case c.challenger.ID:
+		if c.challenger.Choice != "" {
+			return ErrChoiceMade
+		}
+		c.challenger = player
+	default:
+		return ErrNotClaimant
+	}
+	c.record(EventChoiceMade, player.ID, at).Choice = player.Choice
+	if c.challenger.Choice == "" || c.opponent == nil {
+		return nil
+	}
+
+	if err := c.DetermineChallengeResult(); err != nil {
+		return err
+	}
+	c.status = Resolved
+	c.record(EventResolved, SystemActor, at)
+	return nil
+}
+
+// Chose reports whether userID already made a choice in the challenge
+func (c *Challenge) Chose(userID string) bool {
+	if userID == c.challenger.ID {
+		return c.challenger.Choice != ""
+	}
+	return c.opponent != nil && c.opponent.ID == userID
+}
+
+// Cancel withdraws an open challenge, only the challenger can cancel
+func (c *Challenge) Cancel(actor string, at time.Time) error {
+	if err := c.allow(EventCancelled); err != nil {
+		return err
+	}
+	if actor != c.challenger.ID {
+		return ErrNotChallenger
+	}
+	c.status = Cancelled
+	c.record(EventCancelled, actor, at)
+	return nil
+}
+
+// Expire ends an unresolved challenge
+func (c *Challenge) Expire(at time.Time) error {
+	if err := c.allow(EventExpired); err != nil {
+		return err
+	}
+	c.status = Expired
+	c.record(EventExpired, SystemActor, at)
+	return nil
+}
+
+// Forfeit ends an accepted challenge with actor giving up, the other player wins
+func (c *Challenge) Forfeit(actor string, at time.Time) error {
+	if err := c.allow(EventForfeited); err != nil {
+		return err
+	}
+	var winner, looser *domain.Player
+	switch actor {
+	case c.challenger.ID:
+		winner, looser = &domain.Player{ID: c.claimedBy}, c.challenger
+	case c.claimedBy:
+		winner, looser = c.challenger, &domain.Player{ID: c.claimedBy}
+	default:
+		return ErrNotParticipant
+	}
+	c.result = &domain.ChallengeResult{Winner: winner, Looser: looser, Forfeit: true}
+	c.status = Forfeited
+	c.record(EventForfeited, actor, at)
+	return nil
+}
@@ -3,6 +3,7 @@ package command
 import (
 	"errors"
 	"fmt"
+	"strconv"
 )
 
 var (
@@ -15,11 +16,52 @@ type CmdOptionType int
 type CmdIntegrationType int
 type CmdContext int
 
-
 // Bot Command
 const (
-	TestCommand      = "test"
-	ChallengeCommand = "challenge"
+	TestCommand          = "test"
+	ChallengeCommand     = "challenge"
+	PlayBotCommand       = "play-bot"
+	TournamentCommand    = "tournament"
+	RoundCommand         = "round"
+	DailyCommand         = "daily"
+	BalanceCommand       = "balance"
+	TransferCommand      = "transfer"
+	VerifyCommand        = "verify"
+	HistoryCommand       = "history"
+	HistoryExportCommand = "history-export"
+	AccessCommand        = "access"
+	ConfigCommand        = "config"
+	LeaderboardCommand   = "leaderboard"
+)
+
+// Challenge Subcommands
+const (
+	ChallengeStartSubcommand  = "start"
+	ChallengeCancelSubcommand = "cancel"
+)
+
+// Access Subcommands
+const (
+	AccessAllowSubcommand = "allow"
+	AccessDenySubcommand  = "deny"
+	AccessClearSubcommand = "clear"
+	AccessShowSubcommand  = "show"
+)
+
+// Config Subcommands
+const (
+	ConfigGetSubcommand   = "get"
+	ConfigSetSubcommand   = "set"
+	ConfigResetSubcommand = "reset"
+)
+
+// Tournament Subcommands
+const (
+	TournamentCreateSubcommand  = "create"
+	TournamentJoinSubcommand    = "join"
+	TournamentStartSubcommand   = "start"
+	TournamentBracketSubcommand = "bracket"
+	TournamentCancelSubcommand  = "cancel"
 )
 const (
 	// Command Types
@@ -33,6 +75,9 @@ const (
 	INTEGER     CmdOptionType = 4
 	SUB_COMMAND CmdOptionType = 1
 	BOOLEAN     CmdOptionType = 5
+	USER_OPTION CmdOptionType = 6
+	CHANNEL     CmdOptionType = 7
+	ROLE        CmdOptionType = 8
 
 	// Command IntegrationTypes
 	GUILD_INSTALL CmdIntegrationType = 0
@@ -50,8 +95,26 @@ type SlashCommand struct {
 	Description       string               `json:"description"`
 	Type              CmdType              `json:"type"`
 	IntergrationTypes []CmdIntegrationType `json:"integration_types"`
-	Contexts          []CmdContext         `json:"context"`
+	Contexts          []CmdContext         `json:"contexts"`
 	Options           []CommandOption      `json:"options,omitempty"` // Options can be of different types
+	// DefaultMemberPermissions is the permission bit set a member needs to see the command by default
+	DefaultMemberPermissions string `json:"default_member_permissions,omitempty"`
+	Nsfw                     bool   `json:"nsfw,omitempty"`
+}
+
+// Default member permissions
+const (
+	ManageGuildPermission = "32"
+)
+
+// RequiredPermissions returns the permission bit set a member needs to use the command,
+// zero when it is open to everyone
+func (s *SlashCommand) RequiredPermissions() uint64 {
+	perm, err := strconv.ParseUint(s.DefaultMemberPermissions, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return perm
 }
 
 // CommandOptions is a discord sub-model of Slash Command model
@@ -60,7 +123,12 @@ type CommandOption struct {
 	Name        string            `json:"name"`
 	Description string            `json:"description"`
 	Required    bool              `json:"required"`
-	Choices     []CmdOptionChoice `json:"options"`
+	Choices     []CmdOptionChoice `json:"choices,omitempty"`
+	Options     []CommandOption   `json:"options,omitempty"` // sub command options
+	MinValue    int               `json:"min_value,omitempty"`
+	MaxValue    int               `json:"max_value,omitempty"`
+	// Autocomplete asks the bot for choices while the option is typed
+	Autocomplete bool `json:"autocomplete,omitempty"`
 }
 
 type CmdOptionChoice struct {
@@ -71,6 +139,37 @@ type CmdOptionChoice struct {
 // Slash command Configuration
 type SlashCmdConfiguration func(slashCmd *SlashCommand) error
 
+// Configurations configures every command of the bot
+var Configurations = []SlashCmdConfiguration{
+	WithTestCommandConfiguration,
+	WithChallengeCommandConfiguration,
+	WithPlayBotCommandConfiguration,
+	WithTournamentCommandConfiguration,
+	WithRoundCommandConfiguration,
+	WithDailyCommandConfiguration,
+	WithBalanceCommandConfiguration,
+	WithTransferCommandConfiguration,
+	WithVerifyCommandConfiguration,
+	WithHistoryCommandConfiguration,
+	WithHistoryExportCommandConfiguration,
+	WithAccessCommandConfiguration,
+	WithConfigCommandConfiguration,
+	WithLeaderboardCommandConfiguration,
+}
+
+// All creates every command of the bot, ready to be installed
+func All() ([]SlashCommand, error) {
+	commands := make([]SlashCommand, 0, len(Configurations))
+	for _, configure := range Configurations {
+		cmd, err := NewSlashCommand(configure)
+		if err != nil {
+			return nil, err
+		}
+		commands = append(commands, *cmd)
+	}
+	return commands, nil
+}
+
 // NewSlashCOmmand creates a SlashCommand based on the slash command configuration
 func NewSlashCommand(configureCmd SlashCmdConfiguration) (*SlashCommand, error) {
 	var slashCommand SlashCommand
@@ -114,6 +213,71 @@ func WithChallengeCommandConfiguration(slashCmd *SlashCommand) error {
 	slashCmd.Contexts = []CmdContext{
 		GUILD, PRIVATE_CHANNEL,
 	}
+	slashCmd.Options = []CommandOption{
+		{
+			Type:        SUB_COMMAND,
+			Name:        ChallengeStartSubcommand,
+			Description: "Open a challenge anyone in the channel can accept",
+			Options: []CommandOption{
+				{
+					Type:        STRING,
+					Name:        "object",
+					Description: "Pick your object",
+					Required:    true,
+					Choices: []CmdOptionChoice{
+						{
+							Name:  "Rock",
+							Value: "rock",
+						}, {
+							Name:  "Paper",
+							Value: "paper",
+						}, {
+							Name:  "Scissor",
+							Value: "scissors",
+						},
+					},
+				},
+				{
+					Type:        USER_OPTION,
+					Name:        "opponent",
+					Description: "Only this player can accept, pick the bot to play it right away",
+				},
+				{
+					Type:        INTEGER,
+					Name:        "wager",
+					Description: "Coins each player stakes, the winner takes both",
+					MinValue:    1,
+				},
+				{
+					Type:        BOOLEAN,
+					Name:        "fair",
+					Description: "Publish a commitment to your object so anyone can verify it after the game",
+				},
+			},
+		}, {
+			Type:        SUB_COMMAND,
+			Name:        ChallengeCancelSubcommand,
+			Description: "Withdraw your open challenge in this channel",
+		},
+	}
+	return nil
+}
+
+// WithPlayBotCommandConfiguration implements
+// a slash command configuration to configure a game against the bot
+func WithPlayBotCommandConfiguration(slashCmd *SlashCommand) error {
+	if slashCmd == nil {
+		return ErrInvalidSlashCommand
+	}
+	slashCmd.Name = PlayBotCommand
+	slashCmd.Description = "Play rock paper scissors against the bot"
+	slashCmd.Type = CHAT_INPUT
+	slashCmd.IntergrationTypes = []CmdIntegrationType{
+		GUILD_INSTALL, USER_INSTALL,
+	}
+	slashCmd.Contexts = []CmdContext{
+		GUILD, BOT_DM, PRIVATE_CHANNEL,
+	}
 	slashCmd.Options = []CommandOption{
 		{
 			Type:        STRING,
@@ -129,10 +293,471 @@ func WithChallengeCommandConfiguration(slashCmd *SlashCommand) error {
 					Value: "paper",
 				}, {
 					Name:  "Scissor",
-					Value: "scissor",
+					Value: "scissors",
+				},
+			},
+		}, {
+			Type:        STRING,
+			Name:        "strategy",
+			Description: "How the bot picks its object",
+			Choices: []CmdOptionChoice{
+				{
+					Name:  "Random",
+					Value: "random",
+				}, {
+					Name:  "Frequency counter",
+					Value: "frequency",
+				}, {
+					Name:  "Markov chain",
+					Value: "markov",
+				}, {
+					Name:  "Beat your last move",
+					Value: "beat-last",
+				},
+			},
+		},
+	}
+	return nil
+}
+
+// WithTournamentCommandConfiguration implements
+// a slash command configuration to configure tournaments
+func WithTournamentCommandConfiguration(slashCmd *SlashCommand) error {
+	if slashCmd == nil {
+		return ErrInvalidSlashCommand
+	}
+	slashCmd.Name = TournamentCommand
+	slashCmd.Description = "Run a rock paper scissors tournament in this channel"
+	slashCmd.Type = CHAT_INPUT
+	// matches are posted to the channel, which needs the bot in the server
+	slashCmd.IntergrationTypes = []CmdIntegrationType{
+		GUILD_INSTALL,
+	}
+	slashCmd.Contexts = []CmdContext{
+		GUILD,
+	}
+	slashCmd.Options = []CommandOption{
+		{
+			Type:        SUB_COMMAND,
+			Name:        TournamentCreateSubcommand,
+			Description: "Open a tournament for registration",
+			Options: []CommandOption{
+				{
+					Type:        STRING,
+					Name:        "name",
+					Description: "Name of the tournament",
+					Required:    true,
+				}, {
+					Type:        STRING,
+					Name:        "format",
+					Description: "How players are paired",
+					Required:    true,
+					Choices: []CmdOptionChoice{
+						{
+							Name:  "Single elimination",
+							Value: "single-elimination",
+						}, {
+							Name:  "Double elimination",
+							Value: "double-elimination",
+						}, {
+							Name:  "Round robin",
+							Value: "round-robin",
+						}, {
+							Name:  "Swiss",
+							Value: "swiss",
+						},
+					},
+				}, {
+					Type:        STRING,
+					Name:        "seeding",
+					Description: "How players are seeded, random by default",
+					Choices: []CmdOptionChoice{
+						{
+							Name:  "Random",
+							Value: "random",
+						}, {
+							Name:  "Rating",
+							Value: "rating",
+						},
+					},
+				}, {
+					Type:        INTEGER,
+					Name:        "max_players",
+					Description: "How many players can join",
+					MinValue:    2,
+					MaxValue:    64,
 				},
 			},
+		}, {
+			Type:        SUB_COMMAND,
+			Name:        TournamentJoinSubcommand,
+			Description: "Join the tournament of this channel",
+		}, {
+			Type:        SUB_COMMAND,
+			Name:        TournamentStartSubcommand,
+			Description: "Seed the players and start the first matches",
+		}, {
+			Type:        SUB_COMMAND,
+			Name:        TournamentBracketSubcommand,
+			Description: "Show the bracket of the tournament",
+		}, {
+			Type:        SUB_COMMAND,
+			Name:        TournamentCancelSubcommand,
+			Description: "Cancel the tournament of this channel",
 		},
 	}
 	return nil
 }
+
+// WithRoundCommandConfiguration implements
+// a slash command configuration to configure rounds of more than two players
+func WithRoundCommandConfiguration(slashCmd *SlashCommand) error {
+	if slashCmd == nil {
+		return ErrInvalidSlashCommand
+	}
+	slashCmd.Name = RoundCommand
+	slashCmd.Description = "Open a round of rock paper scissors for more than two players"
+	slashCmd.Type = CHAT_INPUT
+	slashCmd.IntergrationTypes = []CmdIntegrationType{
+		GUILD_INSTALL, USER_INSTALL,
+	}
+	slashCmd.Contexts = []CmdContext{
+		GUILD, PRIVATE_CHANNEL,
+	}
+	slashCmd.Options = []CommandOption{
+		{
+			Type:        STRING,
+			Name:        "rule",
+			Description: "How throws are scored, the server's default rule when not picked",
+			Choices: []CmdOptionChoice{
+				{
+					Name:  "Every throw scores against every other throw",
+					Value: "pairwise",
+				}, {
+					Name:  "The losing choice is knocked out when exactly two choices appear",
+					Value: "elimination",
+				},
+			},
+		}, {
+			Type:        STRING,
+			Name:        "mode",
+			Description: "Play for yourself or in teams, free for all by default",
+			Choices: []CmdOptionChoice{
+				{
+					Name:  "Free for all",
+					Value: "ffa",
+				}, {
+					Name:  "2v2",
+					Value: "2v2",
+				}, {
+					Name:  "3v3",
+					Value: "3v3",
+				},
+			},
+		}, {
+			Type:        INTEGER,
+			Name:        "max_players",
+			Description: "How many players can join a free for all",
+			MinValue:    2,
+			MaxValue:    25,
+		},
+	}
+	return nil
+}
+
+// WithDailyCommandConfiguration implements
+// a slash command configuration to claim the daily coins
+func WithDailyCommandConfiguration(slashCmd *SlashCommand) error {
+	if slashCmd == nil {
+		return ErrInvalidSlashCommand
+	}
+	slashCmd.Name = DailyCommand
+	slashCmd.Description = "Claim your daily coins"
+	slashCmd.Type = CHAT_INPUT
+	slashCmd.IntergrationTypes = []CmdIntegrationType{
+		GUILD_INSTALL,
+	}
+	slashCmd.Contexts = []CmdContext{
+		GUILD,
+	}
+	slashCmd.Options = nil
+	return nil
+}
+
+// WithBalanceCommandConfiguration implements
+// a slash command configuration to show a coin balance
+func WithBalanceCommandConfiguration(slashCmd *SlashCommand) error {
+	if slashCmd == nil {
+		return ErrInvalidSlashCommand
+	}
+	slashCmd.Name = BalanceCommand
+	slashCmd.Description = "Show how many coins you or another player have"
+	slashCmd.Type = CHAT_INPUT
+	slashCmd.IntergrationTypes = []CmdIntegrationType{
+		GUILD_INSTALL,
+	}
+	slashCmd.Contexts = []CmdContext{
+		GUILD,
+	}
+	slashCmd.Options = []CommandOption{
+		{
+			Type:        USER_OPTION,
+			Name:        "user",
+			Description: "Whose balance to show, yours by default",
+		},
+	}
+	return nil
+}
+
+// WithTransferCommandConfiguration implements
+// a slash command configuration to give coins to another player
+func WithTransferCommandConfiguration(slashCmd *SlashCommand) error {
+	if slashCmd == nil {
+		return ErrInvalidSlashCommand
+	}
+	slashCmd.Name = TransferCommand
+	slashCmd.Description = "Give some of your coins to another player"
+	slashCmd.Type = CHAT_INPUT
+	slashCmd.IntergrationTypes = []CmdIntegrationType{
+		GUILD_INSTALL,
+	}
+	slashCmd.Contexts = []CmdContext{
+		GUILD,
+	}
+	slashCmd.Options = []CommandOption{
+		{
+			Type:        USER_OPTION,
+			Name:        "user",
+			Description: "Who gets the coins",
+			Required:    true,
+		}, {
+			Type:        INTEGER,
+			Name:        "amount",
+			Description: "How many coins to give",
+			Required:    true,
+			MinValue:    1,
+		},
+	}
+	return nil
+}
+
+// WithVerifyCommandConfiguration implements
+// a slash command configuration to verify the commitment of a fair game
+func WithVerifyCommandConfiguration(slashCmd *SlashCommand) error {
+	if slashCmd == nil {
+		return ErrInvalidSlashCommand
+	}
+	slashCmd.Name = VerifyCommand
+	slashCmd.Description = "Check the commitment of a fair game"
+	slashCmd.Type = CHAT_INPUT
+	slashCmd.IntergrationTypes = []CmdIntegrationType{
+		GUILD_INSTALL, USER_INSTALL,
+	}
+	slashCmd.Contexts = []CmdContext{
+		GUILD, BOT_DM, PRIVATE_CHANNEL,
+	}
+	slashCmd.Options = []CommandOption{
+		{
+			Type:        STRING,
+			Name:        "game_id",
+			Description: "The id shown on the result of the game",
+			Required:    true,
+		},
+	}
+	return nil
+}
+
+// WithHistoryCommandConfiguration implements
+// a slash command configuration to browse the game history
+func WithHistoryCommandConfiguration(slashCmd *SlashCommand) error {
+	if slashCmd == nil {
+		return ErrInvalidSlashCommand
+	}
+	slashCmd.Name = HistoryCommand
+	slashCmd.Description = "Browse the games you or another player played"
+	slashCmd.Type = CHAT_INPUT
+	slashCmd.IntergrationTypes = []CmdIntegrationType{
+		GUILD_INSTALL,
+	}
+	slashCmd.Contexts = []CmdContext{
+		GUILD,
+	}
+	slashCmd.Options = []CommandOption{
+		{
+			Type:        USER_OPTION,
+			Name:        "user",
+			Description: "Whose games to show, yours by default",
+		}, {
+			Type:        USER_OPTION,
+			Name:        "vs",
+			Description: "Only show games against this player",
+		},
+	}
+	return nil
+}
+
+// WithHistoryExportCommandConfiguration implements
+// a slash command configuration to export the game history of a guild
+func WithHistoryExportCommandConfiguration(slashCmd *SlashCommand) error {
+	if slashCmd == nil {
+		return ErrInvalidSlashCommand
+	}
+	slashCmd.Name = HistoryExportCommand
+	slashCmd.Description = "Export every game played in this server"
+	slashCmd.Type = CHAT_INPUT
+	slashCmd.IntergrationTypes = []CmdIntegrationType{
+		GUILD_INSTALL,
+	}
+	slashCmd.Contexts = []CmdContext{
+		GUILD,
+	}
+	// only members who can manage the server see the command
+	slashCmd.DefaultMemberPermissions = ManageGuildPermission
+	slashCmd.Options = []CommandOption{
+		{
+			Type:        STRING,
+			Name:        "format",
+			Description: "File format of the export",
+			Required:    true,
+			Choices: []CmdOptionChoice{
+				{
+					Name:  "CSV",
+					Value: "csv",
+				}, {
+					Name:  "JSON",
+					Value: "json",
+				},
+			},
+		},
+	}
+	return nil
+}
+
+// WithAccessCommandConfiguration implements
+// a slash command configuration to restrict where and by whom commands are used
+func WithAccessCommandConfiguration(slashCmd *SlashCommand) error {
+	if slashCmd == nil {
+		return ErrInvalidSlashCommand
+	}
+	target := []CommandOption{
+		{
+			Type:        STRING,
+			Name:        "command",
+			Description: "Command the rule applies to, every command by default",
+		}, {
+			Type:        CHANNEL,
+			Name:        "channel",
+			Description: "Channel the command is allowed or denied in",
+		}, {
+			Type:        ROLE,
+			Name:        "role",
+			Description: "Role the command is allowed or denied to",
+		},
+	}
+	slashCmd.Name = AccessCommand
+	slashCmd.Description = "Restrict where and by whom commands can be used"
+	slashCmd.Type = CHAT_INPUT
+	slashCmd.IntergrationTypes = []CmdIntegrationType{
+		GUILD_INSTALL,
+	}
+	slashCmd.Contexts = []CmdContext{
+		GUILD,
+	}
+	slashCmd.DefaultMemberPermissions = ManageGuildPermission
+	slashCmd.Options = []CommandOption{
+		{
+			Type:        SUB_COMMAND,
+			Name:        AccessAllowSubcommand,
+			Description: "Only allow a command in a channel or to a role",
+			Options:     target,
+		}, {
+			Type:        SUB_COMMAND,
+			Name:        AccessDenySubcommand,
+			Description: "Deny a command in a channel or to a role",
+			Options:     target,
+		}, {
+			Type:        SUB_COMMAND,
+			Name:        AccessClearSubcommand,
+			Description: "Remove the restrictions of a command",
+			Options:     target[:1],
+		}, {
+			Type:        SUB_COMMAND,
+			Name:        AccessShowSubcommand,
+			Description: "Show the restrictions of this server",
+		},
+	}
+	return nil
+}
+
+// WithConfigCommandConfiguration implements
+// a slash command configuration to change the settings of a server
+func WithConfigCommandConfiguration(slashCmd *SlashCommand) error {
+	if slashCmd == nil {
+		return ErrInvalidSlashCommand
+	}
+	key := CommandOption{
+		Type:         STRING,
+		Name:         "key",
+		Description:  "Setting to read or change",
+		Autocomplete: true,
+	}
+	requiredKey := key
+	requiredKey.Required = true
+	slashCmd.Name = ConfigCommand
+	slashCmd.Description = "Read and change the settings of this server"
+	slashCmd.Type = CHAT_INPUT
+	slashCmd.IntergrationTypes = []CmdIntegrationType{
+		GUILD_INSTALL,
+	}
+	slashCmd.Contexts = []CmdContext{
+		GUILD,
+	}
+	slashCmd.DefaultMemberPermissions = ManageGuildPermission
+	slashCmd.Options = []CommandOption{
+		{
+			Type:        SUB_COMMAND,
+			Name:        ConfigGetSubcommand,
+			Description: "Show a setting, every setting by default",
+			Options:     []CommandOption{key},
+		}, {
+			Type:        SUB_COMMAND,
+			Name:        ConfigSetSubcommand,
+			Description: "Change a setting",
+			Options: []CommandOption{
+				requiredKey,
+				{
+					Type:         STRING,
+					Name:         "value",
+					Description:  "New value of the setting",
+					Required:     true,
+					Autocomplete: true,
+				},
+			},
+		}, {
+			Type:        SUB_COMMAND,
+			Name:        ConfigResetSubcommand,
+			Description: "Restore the default of a setting, of every setting by default",
+			Options:     []CommandOption{key},
+		},
+	}
+	return nil
+}
+
+// WithLeaderboardCommandConfiguration implements
+// a slash command configuration to show the best rated players of a server
+func WithLeaderboardCommandConfiguration(slashCmd *SlashCommand) error {
+	if slashCmd == nil {
+		return ErrInvalidSlashCommand
+	}
+	slashCmd.Name = LeaderboardCommand
+	slashCmd.Description = "Show the best rated players of this server"
+	slashCmd.Type = CHAT_INPUT
+	slashCmd.IntergrationTypes = []CmdIntegrationType{
+		GUILD_INSTALL,
+	}
+	slashCmd.Contexts = []CmdContext{
+		GUILD,
+	}
+	slashCmd.Options = nil
+	return nil
+}
@@ -0,0 +1,59 @@
+// commitment package implements the commit-reveal scheme of provably fair challenges
+//
+// When a fair challenge is created the bot publishes the SHA-256 hash of the
+// challenger's choice and a random nonce. The nonce is revealed once the game is
+// over so anyone can recompute the hash and check the choice was not changed
+package commitment
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"io"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain"
+)
+
+// NonceSize is the number of random bytes in a nonce
+const NonceSize = 16
+
+// Nonce reads a random hex encoded nonce from r, which should be crypto/rand.Reader
+func Nonce(r io.Reader) (string, error) {
+	b := make([]byte, NonceSize)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Commit returns the hex encoded SHA-256 hash of a choice and a nonce
+func Commit(choice domain.RpsChoice, nonce string) string {
+	sum := sha256.Sum256([]byte(string(choice) + ":" + nonce))
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify reports whether commitment was made for choice with nonce
+func Verify(commitment string, choice domain.RpsChoice, nonce string) bool {
+	return subtle.ConstantTimeCompare([]byte(commitment), []byte(Commit(choice, nonce))) == 1
+}
+
+// Record is the commitment of a game, the choice and nonce are only
+// published once it is revealed
+type Record struct {
+	GameID     string
+	Commitment string
+	Choice     domain.RpsChoice
+	Nonce      string
+	RevealedAt time.Time // zero until the game is over
+}
+
+// Revealed reports whether the choice and nonce of the record can be published
+func (r Record) Revealed() bool {
+	return !r.RevealedAt.IsZero()
+}
+
+// Verify reports whether the revealed choice and nonce match the commitment
+func (r Record) Verify() bool {
+	return r.Revealed() && Verify(r.Commitment, r.Choice, r.Nonce)
+}
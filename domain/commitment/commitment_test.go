@@ -0,0 +1,45 @@
+package commitment
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNonce(t *testing.T) {
+	a, err := Nonce(rand.Reader)
+	require.NoError(t, err)
+	require.Len(t, a, 2*NonceSize)
+	b, err := Nonce(rand.Reader)
+	require.NoError(t, err)
+	require.NotEqual(t, a, b)
+
+	_, err = Nonce(bytes.NewReader([]byte{1, 2, 3}))
+	require.Error(t, err)
+}
+
+func TestCommit(t *testing.T) {
+	// sha256("rock:00")
+	require.Equal(t, "6e94db230149ef0fbf9168b083d7268fdd140fb31e221a4733b01c34411cafee", Commit(domain.Rock, "00"))
+	require.NotEqual(t, Commit(domain.Rock, "00"), Commit(domain.Paper, "00"))
+	require.NotEqual(t, Commit(domain.Rock, "00"), Commit(domain.Rock, "01"))
+
+	c := Commit(domain.Scissor, "abcd")
+	require.True(t, Verify(c, domain.Scissor, "abcd"))
+	require.False(t, Verify(c, domain.Rock, "abcd"))
+	require.False(t, Verify(c, domain.Scissor, "abce"))
+}
+
+func TestRecord(t *testing.T) {
+	r := Record{GameID: "g1", Commitment: Commit(domain.Paper, "ff"), Choice: domain.Paper, Nonce: "ff"}
+	require.False(t, r.Revealed())
+	require.False(t, r.Verify())
+	r.RevealedAt = time.Now()
+	require.True(t, r.Verify())
+	r.Choice = domain.Rock
+	require.False(t, r.Verify())
+}
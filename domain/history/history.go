@@ -0,0 +1,158 @@
+// history package holds the records of finished games
+//
+// Records are append only, they are written once a game is over and never change
+package history
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain"
+)
+
+// History Errors
+var (
+	ErrInvalidRecord = errors.New("game record must have an id and two players")
+)
+
+// Rule sets a game can be played under
+const (
+	RuleCasual     = "casual"
+	RuleBot        = "bot"
+	RuleTournament = "tournament"
+)
+
+// Record is a finished game between two players
+type Record struct {
+	ID           string              `json:"id"`
+	GuildID      string              `json:"guild_id,omitempty"`
+	ChannelID    string              `json:"channel_id"`
+	Players      [2]string           `json:"players"` // the challenger first
+	Throws       [2]domain.RpsChoice `json:"throws"`
+	WinnerID     string              `json:"winner_id,omitempty"` // empty on a draw
+	Draw         bool                `json:"draw"`
+	Forfeit      bool                `json:"forfeit"`
+	RuleSet      string              `json:"rule_set"`
+	Wager        int                 `json:"wager,omitempty"`
+	TournamentID string              `json:"tournament_id,omitempty"`
+	RatingDelta  [2]int              `json:"rating_delta"` // change of each player's rating
+	CreatedAt    time.Time           `json:"created_at"`
+	ResolvedAt   time.Time           `json:"resolved_at"`
+}
+
+// Validate checks the record identifies the game and its players
+func (r Record) Validate() error {
+	if r.ID == "" || r.Players[0] == "" || r.Players[1] == "" {
+		return ErrInvalidRecord
+	}
+	return nil
+}
+
+// Index returns the position of userID in Players, -1 when they did not play
+func (r Record) Index(userID string) int {
+	for i, p := range r.Players {
+		if p == userID {
+			return i
+		}
+	}
+	return -1
+}
+
+// Query selects game records, empty fields match every record
+type Query struct {
+	GuildID    string
+	UserID     string
+	OpponentID string    // only games between UserID and OpponentID
+	From       time.Time // games resolved at or after From
+	To         time.Time // games resolved before To
+	Offset     int
+	Limit      int // zero returns every record past Offset
+}
+
+// Match reports whether r is selected by the query, ignoring Offset and Limit
+func (q Query) Match(r Record) bool {
+	if q.GuildID != "" && r.GuildID != q.GuildID {
+		return false
+	}
+	if q.UserID != "" && r.Index(q.UserID) < 0 {
+		return false
+	}
+	if q.OpponentID != "" && r.Index(q.OpponentID) < 0 {
+		return false
+	}
+	if !q.From.IsZero() && r.ResolvedAt.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !r.ResolvedAt.Before(q.To) {
+		return false
+	}
+	return true
+}
+
+// Tally is the record of a player over a set of games
+type Tally struct {
+	Wins   int
+	Losses int
+	Draws  int
+}
+
+// TallyOf counts the wins, losses and draws of userID in records
+func TallyOf(userID string, records []Record) Tally {
+	var t Tally
+	for _, r := range records {
+		switch {
+		case r.Index(userID) < 0:
+		case r.Draw:
+			t.Draws++
+		case r.WinnerID == userID:
+			t.Wins++
+		default:
+			t.Losses++
+		}
+	}
+	return t
+}
+
+// csvHeader is the first row of a CSV export
+var csvHeader = []string{
+	"id", "guild_id", "channel_id", "player_1", "player_2", "throw_1", "throw_2", "winner_id",
+	"draw", "forfeit", "rule_set", "wager", "tournament_id", "rating_delta_1", "rating_delta_2",
+	"created_at", "resolved_at",
+}
+
+// WriteCSV writes records as CSV with a header row
+func WriteCSV(w io.Writer, records []Record) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, r := range records {
+		row := []string{
+			r.ID, r.GuildID, r.ChannelID, r.Players[0], r.Players[1],
+			string(r.Throws[0]), string(r.Throws[1]), r.WinnerID,
+			strconv.FormatBool(r.Draw), strconv.FormatBool(r.Forfeit), r.RuleSet,
+			strconv.Itoa(r.Wager), r.TournamentID,
+			strconv.Itoa(r.RatingDelta[0]), strconv.Itoa(r.RatingDelta[1]),
+			r.CreatedAt.UTC().Format(time.RFC3339), r.ResolvedAt.UTC().Format(time.RFC3339),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes records as a JSON array
+func WriteJSON(w io.Writer, records []Record) error {
+	if records == nil {
+		records = []Record{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
@@ -0,0 +1,68 @@
+package history
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func testRecords() []Record {
+	return []Record{
+		{ID: "1", GuildID: "g1", Players: [2]string{"a", "b"}, WinnerID: "a", ResolvedAt: start},
+		{ID: "2", GuildID: "g1", Players: [2]string{"b", "a"}, WinnerID: "b", ResolvedAt: start.Add(time.Hour)},
+		{ID: "3", GuildID: "g1", Players: [2]string{"a", "c"}, Draw: true, ResolvedAt: start.Add(2 * time.Hour)},
+		{ID: "4", GuildID: "g2", Players: [2]string{"a", "b"}, WinnerID: "a", ResolvedAt: start.Add(3 * time.Hour)},
+	}
+}
+
+func matching(q Query) []string {
+	ids := []string{}
+	for _, r := range testRecords() {
+		if q.Match(r) {
+			ids = append(ids, r.ID)
+		}
+	}
+	return ids
+}
+
+func TestQuery(t *testing.T) {
+	require.Equal(t, []string{"1", "2", "3", "4"}, matching(Query{UserID: "a"}))
+	require.Equal(t, []string{"1", "2", "3"}, matching(Query{GuildID: "g1"}))
+	require.Equal(t, []string{"1", "2"}, matching(Query{GuildID: "g1", UserID: "a", OpponentID: "b"}))
+	require.Equal(t, []string{"2", "3"}, matching(Query{From: start.Add(time.Hour), To: start.Add(3 * time.Hour)}))
+}
+
+func TestTally(t *testing.T) {
+	require.Equal(t, Tally{Wins: 2, Losses: 1, Draws: 1}, TallyOf("a", testRecords()))
+	require.Equal(t, Tally{Wins: 1, Losses: 2}, TallyOf("b", testRecords()))
+	require.Equal(t, Tally{}, TallyOf("d", testRecords()))
+}
+
+func TestExport(t *testing.T) {
+	records := testRecords()[:1]
+	records[0].Throws = [2]domain.RpsChoice{domain.Rock, domain.Scissor}
+	records[0].RatingDelta = [2]int{16, -16}
+
+	var csvOut bytes.Buffer
+	require.NoError(t, WriteCSV(&csvOut, records))
+	lines := strings.Split(strings.TrimSpace(csvOut.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, "1,g1,,a,b,rock,scissors,a,false,false,,0,,16,-16,0001-01-01T00:00:00Z,2024-01-01T00:00:00Z", lines[1])
+
+	var jsonOut bytes.Buffer
+	require.NoError(t, WriteJSON(&jsonOut, records))
+	var decoded []Record
+	require.NoError(t, json.Unmarshal(jsonOut.Bytes(), &decoded))
+	require.Equal(t, records, decoded)
+
+	jsonOut.Reset()
+	require.NoError(t, WriteJSON(&jsonOut, nil))
+	require.Equal(t, "[]\n", jsonOut.String())
+}
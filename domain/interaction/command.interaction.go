@@ -1,14 +1,11 @@
 // interaction is a the object received from discord when a user interacts with the bot
 package interaction
 
-type SlashCommandInteraction struct {
-	Type    int                `json:"type"` // Create Type for this
-	Token   string             `json:"token"`
-	Member  SlashCommandMember `json:"member"`
-	ID      string             `json:"id"`
-	Data    InteractionData    `json:"data"`
-	Context int                `json:"context"`
-}
+import (
+	"encoding/json"
+	"strconv"
+)
+
 type InteractionData struct {
 	ID      string               `json:"id"`
 	Name    string               `json:"name"`
@@ -17,14 +14,115 @@ type InteractionData struct {
 }
 
 type InteractionOptions struct {
-	Type  int    `json:"type"` // Create Type for this
-	Name  string `json:"name"`
-	Value string `json:"value"`
+	Type    int                  `json:"type"` // Create Type for this
+	Name    string               `json:"name"`
+	Value   OptionRaw            `json:"value"`
+	Options []InteractionOptions `json:"options,omitempty"` // set for sub commands
+	Focused bool                 `json:"focused,omitempty"` // set on the option being autocompleted
+}
+
+// OptionRaw is the value of an option, discord sends strings, numbers and
+// booleans depending on the option type, non string values keep their JSON text
+type OptionRaw string
+
+func (v *OptionRaw) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*v = OptionRaw(s)
+		return nil
+	}
+	var raw json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*v = OptionRaw(raw)
+	return nil
+}
+
+// sub command option type
+const subCommandOption = 1
+
+// Subcommand returns the name and options of the invoked sub command,
+// name is empty when the command has no sub commands
+func (d InteractionData) Subcommand() (name string, options []InteractionOptions) {
+	for _, opt := range d.Options {
+		if opt.Type == subCommandOption {
+			return opt.Name, opt.Options
+		}
+	}
+	return "", d.Options
+}
+
+// OptionValue returns the value of the option called name
+func OptionValue(options []InteractionOptions, name string) (string, bool) {
+	for _, opt := range options {
+		if opt.Name == name {
+			return string(opt.Value), true
+		}
+	}
+	return "", false
+}
+
+// FocusedOption returns the option being typed in an autocomplete interaction
+func FocusedOption(options []InteractionOptions) (InteractionOptions, bool) {
+	for _, opt := range options {
+		if opt.Focused {
+			return opt, true
+		}
+	}
+	return InteractionOptions{}, false
+}
+
+// OptionInt returns the value of the integer option called name
+func OptionInt(options []InteractionOptions, name string) (int, bool) {
+	value, ok := OptionValue(options, name)
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// OptionBool returns the value of the boolean option called name
+func OptionBool(options []InteractionOptions, name string) (bool, bool) {
+	value, ok := OptionValue(options, name)
+	if !ok {
+		return false, false
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, false
+	}
+	return b, true
+}
+
+// Member permission bits
+const (
+	PermissionAdministrator uint64 = 1 << 3
+	PermissionManageGuild   uint64 = 1 << 5
+)
+
+// HasPermission reports whether the member has every permission bit in perm,
+// administrators have every permission
+func (m *SlashCommandMember) HasPermission(perm uint64) bool {
+	if m == nil {
+		return false
+	}
+	granted, err := strconv.ParseUint(m.Permissions, 10, 64)
+	if err != nil {
+		return false
+	}
+	return granted&PermissionAdministrator != 0 || granted&perm == perm
 }
 
 type SlashCommandMember struct {
-	User  MemberUser `json:"user"`
-	Roles []string   `json:"roles"`
+	User        MemberUser `json:"user"`
+	Roles       []string   `json:"roles"`
+	Nick        string     `json:"nick,omitempty"`
+	Permissions string     `json:"permissions,omitempty"`
 	// still contains more fields but not required
 }
 
@@ -1,19 +1,9 @@
 package interaction
 
-type ComponentInteraction struct {
-	Type    int                         `json:"type"`
-	Token   string                      `json:"token"`
-	ID      string                      `json:"id"`
-	Data    ComponentData               `json:"data"`
-	Member  SlashCommandMember          `json:"member"`
-	Message ComponentInteractionMessage `json:"message"`
-	Context int                         `json:"context"`
-}
-
 type ComponentData struct {
-	CustomId      string               `json:"custom_id"`
-	ComponentType int                  `json:"component_type"`
-	Values       []CmpInteractionValue `json:"values"`
+	CustomId      string                `json:"custom_id"`
+	ComponentType int                   `json:"component_type"`
+	Values        []CmpInteractionValue `json:"values"`
 }
 
 type ComponentInteractionMessage struct {
@@ -21,4 +11,4 @@ type ComponentInteractionMessage struct {
 	ID   string `json:"id"`
 }
 
-type CmpInteractionValue string
\ No newline at end of file
+type CmpInteractionValue string
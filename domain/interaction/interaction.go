@@ -0,0 +1,134 @@
+package interaction
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	ErrDecodeInteraction = errors.New("could not decode discord interaction")
+)
+
+type InteractionType int
+
+// Interaction Types
+const (
+	PING                             InteractionType = 1
+	APPLICATION_COMMAND              InteractionType = 2
+	MESSAGE_COMPONENT                InteractionType = 3
+	APPLICATION_COMMAND_AUTOCOMPLETE InteractionType = 4
+	MODAL_SUBMIT                     InteractionType = 5
+)
+
+// Data is the type specific payload of an interaction,
+// it is either InteractionData for commands or ComponentData for message components
+type Data interface {
+	interactionType() InteractionType
+}
+
+func (InteractionData) interactionType() InteractionType { return APPLICATION_COMMAND }
+func (ComponentData) interactionType() InteractionType   { return MESSAGE_COMPONENT }
+
+// Interaction is the envelope of every interaction received from discord
+type Interaction struct {
+	ID                           string                       `json:"id"`
+	ApplicationID                string                       `json:"application_id"`
+	Type                         InteractionType              `json:"type"`
+	Data                         Data                         `json:"-"`
+	GuildID                      string                       `json:"guild_id,omitempty"`
+	ChannelID                    string                       `json:"channel_id,omitempty"`
+	Member                       *SlashCommandMember          `json:"member,omitempty"` // sent in guilds
+	User                         *MemberUser                  `json:"user,omitempty"`   // sent in DMs
+	Token                        string                       `json:"token"`
+	Version                      int                          `json:"version"`
+	Message                      *ComponentInteractionMessage `json:"message,omitempty"`
+	AppPermissions               string                       `json:"app_permissions,omitempty"`
+	Locale                       string                       `json:"locale,omitempty"`
+	GuildLocale                  string                       `json:"guild_locale,omitempty"`
+	Entitlements                 []Entitlement                `json:"entitlements,omitempty"`
+	AuthorizingIntegrationOwners map[string]string            `json:"authorizing_integration_owners,omitempty"`
+	Context                      int                          `json:"context"`
+}
+
+// Entitlement represents a premium offering the invoking user or guild has access to
+type Entitlement struct {
+	ID            string `json:"id"`
+	SkuID         string `json:"sku_id"`
+	ApplicationID string `json:"application_id"`
+	UserID        string `json:"user_id,omitempty"`
+	GuildID       string `json:"guild_id,omitempty"`
+	Type          int    `json:"type"`
+	Deleted       bool   `json:"deleted"`
+	StartsAt      string `json:"starts_at,omitempty"`
+	EndsAt        string `json:"ends_at,omitempty"`
+	Consumed      bool   `json:"consumed,omitempty"`
+}
+
+// UnmarshalJSON decodes the envelope and the data payload matching its type
+func (i *Interaction) UnmarshalJSON(b []byte) error {
+	type envelope Interaction
+	var raw struct {
+		envelope
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*i = Interaction(raw.envelope)
+	if len(raw.Data) == 0 || string(raw.Data) == "null" {
+		return nil
+	}
+
+	switch i.Type {
+	case APPLICATION_COMMAND, APPLICATION_COMMAND_AUTOCOMPLETE:
+		var data InteractionData
+		if err := json.Unmarshal(raw.Data, &data); err != nil {
+			return err
+		}
+		i.Data = data
+	case MESSAGE_COMPONENT:
+		var data ComponentData
+		if err := json.Unmarshal(raw.Data, &data); err != nil {
+			return err
+		}
+		i.Data = data
+	}
+	return nil
+}
+
+// Decode reads a single interaction from r
+func Decode(r io.Reader) (*Interaction, error) {
+	var i Interaction
+	if err := json.NewDecoder(r).Decode(&i); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecodeInteraction, err)
+	}
+	return &i, nil
+}
+
+// InvokingUser returns the user who triggered the interaction,
+// from member in guilds and from user in DMs
+func (i *Interaction) InvokingUser() MemberUser {
+	if i.Member != nil && i.Member.User.ID != "" {
+		return i.Member.User
+	}
+	if i.User != nil {
+		return *i.User
+	}
+	return MemberUser{}
+}
+
+// CommandData returns the application command payload,
+// ok is false when the interaction is not a command
+func (i *Interaction) CommandData() (data InteractionData, ok bool) {
+	data, ok = i.Data.(InteractionData)
+	return data, ok
+}
+
+// ComponentData returns the message component payload,
+// ok is false when the interaction is not a message component
+func (i *Interaction) ComponentData() (data ComponentData, ok bool) {
+	data, ok = i.Data.(ComponentData)
+	return data, ok
+}
@@ -0,0 +1,193 @@
+package interaction
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecode(t *testing.T) {
+	testCases := []struct {
+		name         string
+		payload      string
+		expectedType InteractionType
+		expectedUser string
+		check        func(t *testing.T, i *Interaction)
+	}{
+		{
+			name: "guild command",
+			payload: `{"id":"1","type":2,"guild_id":"10","channel_id":"20","token":"tkn",
+				"member":{"user":{"id":"100"},"roles":["r1"],"permissions":"8"},
+				"locale":"en-US","guild_locale":"fr","app_permissions":"2048","version":1,
+				"authorizing_integration_owners":{"0":"10"},
+				"data":{"id":"5","name":"challenge","type":1,"options":[{"type":3,"name":"object","value":"rock"}]}}`,
+			expectedType: APPLICATION_COMMAND,
+			expectedUser: "100",
+			check: func(t *testing.T, i *Interaction) {
+				data, ok := i.CommandData()
+				require.True(t, ok)
+				require.Equal(t, "challenge", data.Name)
+				require.Equal(t, OptionRaw("rock"), data.Options[0].Value)
+				_, ok = i.ComponentData()
+				require.False(t, ok)
+				require.Equal(t, "10", i.GuildID)
+				require.Equal(t, "20", i.ChannelID)
+				require.Equal(t, "fr", i.GuildLocale)
+				require.Equal(t, "2048", i.AppPermissions)
+				require.Equal(t, "10", i.AuthorizingIntegrationOwners["0"])
+				require.True(t, i.Member.HasPermission(PermissionManageGuild))
+			},
+		}, {
+			name: "dm command",
+			payload: `{"id":"2","type":2,"channel_id":"30","context":1,"user":{"id":"200"},
+				"entitlements":[{"id":"e1","sku_id":"s1"}],
+				"data":{"name":"test","type":1}}`,
+			expectedType: APPLICATION_COMMAND,
+			expectedUser: "200",
+			check: func(t *testing.T, i *Interaction) {
+				require.Nil(t, i.Member)
+				require.Len(t, i.Entitlements, 1)
+			},
+		}, {
+			name: "component",
+			payload: `{"id":"3","type":3,"member":{"user":{"id":"300"}},"message":{"id":"m1","type":0},
+				"data":{"custom_id":"accept_button_1","component_type":2}}`,
+			expectedType: MESSAGE_COMPONENT,
+			expectedUser: "300",
+			check: func(t *testing.T, i *Interaction) {
+				data, ok := i.ComponentData()
+				require.True(t, ok)
+				require.Equal(t, "accept_button_1", data.CustomId)
+				require.Equal(t, "m1", i.Message.ID)
+			},
+		}, {
+			name: "sub command with integer option",
+			payload: `{"id":"5","type":2,"user":{"id":"500"},
+				"data":{"name":"tournament","type":1,"options":[{"type":1,"name":"create","options":[
+					{"type":3,"name":"name","value":"cup"},{"type":4,"name":"max_players","value":8}]}]}}`,
+			expectedType: APPLICATION_COMMAND,
+			expectedUser: "500",
+			check: func(t *testing.T, i *Interaction) {
+				data, ok := i.CommandData()
+				require.True(t, ok)
+				name, options := data.Subcommand()
+				require.Equal(t, "create", name)
+				value, ok := OptionValue(options, "name")
+				require.True(t, ok)
+				require.Equal(t, "cup", value)
+				n, ok := OptionInt(options, "max_players")
+				require.True(t, ok)
+				require.Equal(t, 8, n)
+			},
+		}, {
+			name: "sub command with boolean option",
+			payload: `{"id":"6","type":2,"user":{"id":"600"},
+				"data":{"name":"challenge","type":1,"options":[{"type":1,"name":"start","options":[
+					{"type":3,"name":"object","value":"rock"},{"type":5,"name":"fair","value":true}]}]}}`,
+			expectedType: APPLICATION_COMMAND,
+			expectedUser: "600",
+			check: func(t *testing.T, i *Interaction) {
+				data, ok := i.CommandData()
+				require.True(t, ok)
+				_, options := data.Subcommand()
+				fair, ok := OptionBool(options, "fair")
+				require.True(t, ok)
+				require.True(t, fair)
+				_, ok = OptionBool(options, "object")
+				require.False(t, ok)
+			},
+		}, {
+			name: "autocomplete",
+			payload: `{"id":"7","type":4,"guild_id":"10","member":{"user":{"id":"700"}},
+				"data":{"name":"config","type":1,"options":[{"type":1,"name":"set","options":[
+					{"type":3,"name":"key","value":"economy"},{"type":3,"name":"value","value":"o","focused":true}]}]}}`,
+			expectedType: APPLICATION_COMMAND_AUTOCOMPLETE,
+			expectedUser: "700",
+			check: func(t *testing.T, i *Interaction) {
+				data, ok := i.CommandData()
+				require.True(t, ok)
+				_, options := data.Subcommand()
+				focused, ok := FocusedOption(options)
+				require.True(t, ok)
+				require.Equal(t, "value", focused.Name)
+				require.Equal(t, OptionRaw("o"), focused.Value)
+			},
+		}, {
+			name:         "ping",
+			payload:      `{"id":"4","type":1}`,
+			expectedType: PING,
+			check: func(t *testing.T, i *Interaction) {
+				require.Nil(t, i.Data)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			i, err := Decode(strings.NewReader(tc.payload))
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedType, i.Type)
+			require.Equal(t, tc.expectedUser, i.InvokingUser().ID)
+			tc.check(t, i)
+		})
+	}
+}
+
+func TestDecodeMalformed(t *testing.T) {
+	_, err := Decode(strings.NewReader(`{"type":2,"data":"not an object"}`))
+	require.ErrorIs(t, err, ErrDecodeInteraction)
+}
+
+func TestHasPermission(t *testing.T) {
+	var nobody *SlashCommandMember
+	require.False(t, nobody.HasPermission(PermissionManageGuild))
+	require.True(t, (&SlashCommandMember{Permissions: "32"}).HasPermission(PermissionManageGuild))
+	require.False(t, (&SlashCommandMember{Permissions: "16"}).HasPermission(PermissionManageGuild))
+	require.False(t, (&SlashCommandMember{Permissions: "not a number"}).HasPermission(PermissionManageGuild))
+
+	// every bit is required, administrators bypass the check
+	manageAndKick := PermissionManageGuild | 1<<1
+	require.False(t, (&SlashCommandMember{Permissions: "32"}).HasPermission(manageAndKick))
+	require.True(t, (&SlashCommandMember{Permissions: "34"}).HasPermission(manageAndKick))
+	require.True(t, (&SlashCommandMember{Permissions: "8"}).HasPermission(manageAndKick))
+}
+
+func TestInvokingUser(t *testing.T) {
+	testCases := []struct {
+		name        string
+		interaction Interaction
+		userID      string
+	}{
+		{
+			name:        "guild member",
+			interaction: Interaction{GuildID: "g1", Member: &SlashCommandMember{User: MemberUser{ID: "100"}}},
+			userID:      "100",
+		}, {
+			name:        "bot dm user",
+			interaction: Interaction{Context: 1, User: &MemberUser{ID: "200"}},
+			userID:      "200",
+		}, {
+			name:        "group dm user",
+			interaction: Interaction{Context: 2, User: &MemberUser{ID: "300"}},
+			userID:      "300",
+		}, {
+			name:        "member is preferred",
+			interaction: Interaction{Member: &SlashCommandMember{User: MemberUser{ID: "100"}}, User: &MemberUser{ID: "200"}},
+			userID:      "100",
+		}, {
+			name:        "member without user",
+			interaction: Interaction{Member: &SlashCommandMember{}, User: &MemberUser{ID: "200"}},
+			userID:      "200",
+		}, {
+			name:        "nobody",
+			interaction: Interaction{},
+			userID:      "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.userID, tc.interaction.InvokingUser().ID)
+		})
+	}
+}
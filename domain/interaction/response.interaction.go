@@ -6,11 +6,35 @@ type InteractionResponse struct {
 	Data ResponseData `json:"data"`
 }
 
+// AutocompleteResponse suggests the choices of the option being typed
+type AutocompleteResponse struct {
+	Type int              `json:"type"`
+	Data AutocompleteData `json:"data"`
+}
+
+// AutocompleteData holds at most 25 choices
+type AutocompleteData struct {
+	Choices []AutocompleteChoice `json:"choices"`
+}
+
+type AutocompleteChoice struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
 // ResponseData is a sub field holding the data of the Interaction Response
 type ResponseData struct {
-	Content    string                  `json:"content"`
-	Flags      int                     `json:"flags,omitempty"` //optional
-	Components []ResponseDataComponent `json:"components,omitempty"` //optional
+	Content     string                  `json:"content"`
+	Flags       int                     `json:"flags,omitempty"`       //optional
+	Components  []ResponseDataComponent `json:"components,omitempty"`  //optional
+	Attachments []Attachment            `json:"attachments,omitempty"` //optional, uploaded with the response
+}
+
+// Attachment describes a file uploaded along with a response,
+// ID is the index of the file in the multipart upload
+type Attachment struct {
+	ID       int    `json:"id"`
+	Filename string `json:"filename"`
 }
 
 // ResponseDataComponent is a sub field of the Response Data of an Interaction Response
@@ -19,7 +43,7 @@ type ResponseData struct {
 // Where components can either be an slice of Button Componets or String Select Components.
 // Support for other types of components are not necessary in this version of the bot.
 type ResponseDataComponent struct {
-	Type       int   `json:"type"` // create Type for this
+	Type       int         `json:"type"` // create Type for this
 	Components interface{} `json:"components"`
 }
 
@@ -28,6 +52,7 @@ type BtnComponent struct {
 	Label    string `json:"label"`
 	Style    int    `json:"style"`
 	CustomId string `json:"custom_id"`
+	Disabled bool   `json:"disabled,omitempty"`
 }
 
 type StringSelectComponent struct {
@@ -0,0 +1,148 @@
+// ledger package holds the double-entry ledger of the coin economy
+//
+// Every movement of coins is a transaction of entries summing to zero, so coins
+// are never created or lost. Coins enter the economy from the mint account of
+// a guild, which is the only account allowed to go below zero
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Ledger Errors
+var (
+	ErrInvalidTransaction = errors.New("transaction must have an id and at least two entries")
+	ErrInvalidAmount      = errors.New("amount must be positive")
+	ErrUnbalanced         = errors.New("transaction entries must sum to zero")
+	ErrInsufficientFunds  = errors.New("account does not have enough coins")
+)
+
+// Transaction kinds
+const (
+	KindDaily    = "daily"
+	KindTransfer = "transfer"
+	KindEscrow   = "escrow"
+	KindPayout   = "payout"
+	KindRefund   = "refund"
+)
+
+// InsufficientFundsError is returned when a transaction would overdraw an account
+type InsufficientFundsError struct {
+	Account string
+	Balance int
+	Needed  int
+}
+
+func (e *InsufficientFundsError) Error() string {
+	return fmt.Sprintf("%v: %s has %d, needs %d", ErrInsufficientFunds, e.Account, e.Balance, e.Needed)
+}
+
+func (e *InsufficientFundsError) Is(target error) bool {
+	return target == ErrInsufficientFunds
+}
+
+// UserAccount returns the account holding the coins of a user in a guild
+func UserAccount(guildID, userID string) string {
+	return fmt.Sprintf("user:%s:%s", guildID, userID)
+}
+
+// EscrowAccount returns the account holding the stakes of a challenge until it is settled
+func EscrowAccount(challengeID string) string {
+	return "escrow:" + challengeID
+}
+
+// MintAccount returns the account coins are granted from in a guild
+func MintAccount(guildID string) string {
+	return "mint:" + guildID
+}
+
+// Overdraftable reports whether account may have a negative balance
+func Overdraftable(account string) bool {
+	return strings.HasPrefix(account, "mint:")
+}
+
+// Entry credits Amount coins to Account, a negative amount is a debit
+type Entry struct {
+	Account string
+	Amount  int
+}
+
+// Transaction is a set of entries applied atomically.
+// The ID makes posting idempotent, a transaction is applied at most once
+type Transaction struct {
+	ID      string
+	Kind    string
+	At      time.Time
+	Entries []Entry
+}
+
+// Validate checks the transaction is balanced and every entry moves coins
+func (t Transaction) Validate() error {
+	if t.ID == "" || len(t.Entries) < 2 {
+		return ErrInvalidTransaction
+	}
+	sum := 0
+	for _, e := range t.Entries {
+		if e.Account == "" || e.Amount == 0 {
+			return ErrInvalidTransaction
+		}
+		sum += e.Amount
+	}
+	if sum != 0 {
+		return ErrUnbalanced
+	}
+	return nil
+}
+
+// Transfer moves amount coins from one account to another
+func Transfer(id, kind, from, to string, amount int, at time.Time) (Transaction, error) {
+	if amount <= 0 {
+		return Transaction{}, ErrInvalidAmount
+	}
+	return Transaction{
+		ID:   id,
+		Kind: kind,
+		At:   at,
+		Entries: []Entry{
+			{Account: from, Amount: -amount},
+			{Account: to, Amount: amount},
+		},
+	}, nil
+}
+
+// Escrow moves the stake of both players of a challenge into its escrow account
+func Escrow(id, guildID, challengeID, playerA, playerB string, stake int, at time.Time) (Transaction, error) {
+	if stake <= 0 {
+		return Transaction{}, ErrInvalidAmount
+	}
+	return Transaction{
+		ID:   id,
+		Kind: KindEscrow,
+		At:   at,
+		Entries: []Entry{
+			{Account: UserAccount(guildID, playerA), Amount: -stake},
+			{Account: UserAccount(guildID, playerB), Amount: -stake},
+			{Account: EscrowAccount(challengeID), Amount: 2 * stake},
+		},
+	}, nil
+}
+
+// Refund returns the stake of both players of a challenge from its escrow account
+func Refund(id, guildID, challengeID, playerA, playerB string, stake int, at time.Time) (Transaction, error) {
+	if stake <= 0 {
+		return Transaction{}, ErrInvalidAmount
+	}
+	return Transaction{
+		ID:   id,
+		Kind: KindRefund,
+		At:   at,
+		Entries: []Entry{
+			{Account: EscrowAccount(challengeID), Amount: -2 * stake},
+			{Account: UserAccount(guildID, playerA), Amount: stake},
+			{Account: UserAccount(guildID, playerB), Amount: stake},
+		},
+	}, nil
+}
@@ -0,0 +1,51 @@
+package ledger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	now := time.Now()
+	tx, err := Transfer("t1", KindTransfer, UserAccount("g1", "a"), UserAccount("g1", "b"), 50, now)
+	require.NoError(t, err)
+	require.NoError(t, tx.Validate())
+
+	_, err = Transfer("t1", KindTransfer, UserAccount("g1", "a"), UserAccount("g1", "b"), 0, now)
+	require.ErrorIs(t, err, ErrInvalidAmount)
+
+	tx.Entries[1].Amount = 40
+	require.ErrorIs(t, tx.Validate(), ErrUnbalanced)
+	require.ErrorIs(t, Transaction{ID: "t2", Entries: tx.Entries[:1]}.Validate(), ErrInvalidTransaction)
+	require.ErrorIs(t, Transaction{Entries: tx.Entries}.Validate(), ErrInvalidTransaction)
+}
+
+func TestEscrowAndRefund(t *testing.T) {
+	now := time.Now()
+	escrow, err := Escrow("e1", "g1", "c1", "a", "b", 25, now)
+	require.NoError(t, err)
+	require.NoError(t, escrow.Validate())
+	require.Equal(t, Entry{Account: EscrowAccount("c1"), Amount: 50}, escrow.Entries[2])
+
+	refund, err := Refund("r1", "g1", "c1", "a", "b", 25, now)
+	require.NoError(t, err)
+	require.NoError(t, refund.Validate())
+
+	balances := map[string]int{}
+	for _, tx := range []Transaction{escrow, refund} {
+		for _, e := range tx.Entries {
+			balances[e.Account] += e.Amount
+		}
+	}
+	for account, balance := range balances {
+		require.Zero(t, balance, account)
+	}
+}
+
+func TestOverdraftable(t *testing.T) {
+	require.True(t, Overdraftable(MintAccount("g1")))
+	require.False(t, Overdraftable(UserAccount("g1", "a")))
+	require.False(t, Overdraftable(EscrowAccount("c1")))
+}
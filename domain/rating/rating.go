@@ -0,0 +1,50 @@
+// rating package computes Elo ratings from game results
+package rating
+
+import (
+	"cmp"
+	"math"
+	"slices"
+)
+
+const (
+	// Initial is the rating of a player who has not played yet
+	Initial = 1000
+	// K is how much a single game moves a rating
+	K = 32
+)
+
+// Score of a game from a player's point of view
+const (
+	Loss = 0.0
+	Draw = 0.5
+	Win  = 1.0
+)
+
+// Expected returns the expected score of a player rated a against a player rated b
+func Expected(a, b int) float64 {
+	return 1 / (1 + math.Pow(10, float64(b-a)/400))
+}
+
+// Update returns the new ratings of two players after a game where
+// the first player scored scoreA
+func Update(a, b int, scoreA float64) (newA, newB int) {
+	delta := int(math.Round(K * (scoreA - Expected(a, b))))
+	return a + delta, b - delta
+}
+
+// Standing is the rating of a player in a guild
+type Standing struct {
+	UserID string
+	Rating int
+}
+
+// SortStandings orders standings from the highest rating, ties by user id
+func SortStandings(standings []Standing) {
+	slices.SortFunc(standings, func(a, b Standing) int {
+		if c := cmp.Compare(b.Rating, a.Rating); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.UserID, b.UserID)
+	})
+}
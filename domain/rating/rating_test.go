@@ -0,0 +1,35 @@
+package rating
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		a, b      int
+		score     float64
+		expectedA int
+		expectedB int
+	}{
+		{name: "equal ratings win", a: Initial, b: Initial, score: Win, expectedA: 1016, expectedB: 984},
+		{name: "equal ratings draw", a: Initial, b: Initial, score: Draw, expectedA: 1000, expectedB: 1000},
+		{name: "upset", a: 1000, b: 1400, score: Win, expectedA: 1029, expectedB: 1371},
+		{name: "expected win", a: 1400, b: 1000, score: Win, expectedA: 1403, expectedB: 997},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			a, b := Update(tc.a, tc.b, tc.score)
+			require.Equal(t, tc.expectedA, a)
+			require.Equal(t, tc.expectedB, b)
+		})
+	}
+}
+
+func TestSortStandings(t *testing.T) {
+	standings := []Standing{{"b", 1000}, {"c", 1040}, {"a", 1000}}
+	SortStandings(standings)
+	require.Equal(t, []Standing{{"c", 1040}, {"a", 1000}, {"b", 1000}}, standings)
+}
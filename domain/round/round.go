@@ -0,0 +1,497 @@
+// round package holds the multi player round entity
+//
+// A round is opened by a host as a lobby, players join it, then every player
+// throws secretly and the round is scored by its rule. Players either play for
+// themselves or in two teams of the same size
+package round
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/ekefan/discord-bot/domain/challenge"
+)
+
+// Round Errors
+var (
+	ErrInvalidRound     = errors.New("round must have an id and a host")
+	ErrUnknownRule      = errors.New("unknown round rule")
+	ErrInvalidTeamSize  = errors.New("teams must have two or three players")
+	ErrNotHost          = errors.New("only the host can do this")
+	ErrLobbyClosed      = errors.New("round is no longer open for players")
+	ErrAlreadyJoined    = errors.New("player already joined the round")
+	ErrLobbyFull        = errors.New("round is full")
+	ErrInvalidTeam      = errors.New("team does not exist in this round")
+	ErrTeamFull         = errors.New("team is full")
+	ErrNotEnoughPlayers = errors.New("a round needs at least two players")
+	ErrTeamsNotFull     = errors.New("every team must be full to start")
+	ErrNotThrowing      = errors.New("round is not waiting for throws")
+	ErrNotPlaying       = errors.New("player is not playing this round")
+	ErrAlreadyThrown    = errors.New("player already threw this stage")
+	ErrInvalidChoice    = errors.New("choice must be rock, paper or scissors")
+	ErrRoundOver        = errors.New("round is over")
+)
+
+// Rule is how the throws of a round are scored
+type Rule string
+
+// Round Rules
+const (
+	// Pairwise scores a point for every throw of an opponent a throw beats
+	Pairwise Rule = "pairwise"
+	// Elimination knocks out the players who threw the losing choice when exactly
+	// two choices appear, the others throw again until one player or team is left
+	Elimination Rule = "elimination"
+)
+
+// MaxStages caps the stages of an elimination round, players left after it share the win
+const MaxStages = 10
+
+// Teams play against each other in team rounds
+const (
+	Team1 = 1
+	Team2 = 2
+)
+
+// Status is the lifecycle state of a round
+//
+//	Lobby --Start--> Throwing --last throw--> Resolved
+//	Lobby | Throwing --Cancel--> Cancelled
+//	Lobby | Throwing --Expire--> Expired
+type Status int
+
+const (
+	Lobby Status = iota
+	Throwing
+	Resolved
+	Cancelled
+	Expired
+)
+
+func (s Status) String() string {
+	switch s {
+	case Lobby:
+		return "lobby"
+	case Throwing:
+		return "throwing"
+	case Resolved:
+		return "resolved"
+	case Cancelled:
+		return "cancelled"
+	case Expired:
+		return "expired"
+	default:
+		return "unknown"
+	}
+}
+
+// Terminal reports whether the round is over
+func (s Status) Terminal() bool {
+	return s == Resolved || s == Cancelled || s == Expired
+}
+
+// Player is a player who joined a round
+type Player struct {
+	ID     string
+	Team   int              // zero when everyone plays for themselves
+	Choice domain.RpsChoice // the choice of the current stage, kept when knocked out
+	OutAt  int              // the stage the player was knocked out in, zero while playing
+}
+
+// Stage is the outcome of one throw of every active player
+type Stage struct {
+	Number     int
+	Choices    []domain.RpsChoice // distinct choices thrown
+	Eliminated []string           // players knocked out in the stage
+}
+
+// Round is a game of rock paper scissors between more than two players
+type Round struct {
+	id         string
+	scope      challenge.Scope
+	origin     challenge.Origin
+	host       string
+	rule       Rule
+	teamSize   int
+	maxPlayers int
+	status     Status
+	stage      int
+	players    []Player
+	stages     []Stage
+	result     *domain.ChallengeResult
+	expiresAt  time.Time
+}
+
+// New opens the lobby of a round, a teamSize of zero lets everyone play for
+// themselves with at most maxPlayers players, zero meaning no limit
+func New(id string, scope challenge.Scope, host string, rule Rule, teamSize, maxPlayers int) (*Round, error) {
+	if id == "" || host == "" {
+		return nil, ErrInvalidRound
+	}
+	if rule != Pairwise && rule != Elimination {
+		return nil, ErrUnknownRule
+	}
+	if teamSize != 0 && teamSize != 2 && teamSize != 3 {
+		return nil, ErrInvalidTeamSize
+	}
+	if teamSize != 0 {
+		maxPlayers = 2 * teamSize
+	}
+	return &Round{
+		id:         id,
+		scope:      scope,
+		host:       host,
+		rule:       rule,
+		teamSize:   teamSize,
+		maxPlayers: maxPlayers,
+	}, nil
+}
+
+// ID returns the id of the round
+func (r *Round) ID() string {
+	return r.id
+}
+
+// Scope returns where the round is played
+func (r *Round) Scope() challenge.Scope {
+	return r.scope
+}
+
+// Origin returns the interaction that opened the round
+func (r *Round) Origin() challenge.Origin {
+	return r.origin
+}
+
+// SetOrigin records the interaction that opened the round
+func (r *Round) SetOrigin(origin challenge.Origin) {
+	r.origin = origin
+}
+
+// Host returns the id of the player who opened the round
+func (r *Round) Host() string {
+	return r.host
+}
+
+// Rule returns how the round is scored
+func (r *Round) Rule() Rule {
+	return r.rule
+}
+
+// TeamSize returns the number of players per team, zero when everyone plays for themselves
+func (r *Round) TeamSize() int {
+	return r.teamSize
+}
+
+// MaxPlayers returns how many players can join, zero means no limit
+func (r *Round) MaxPlayers() int {
+	return r.maxPlayers
+}
+
+// Status returns the current state of the round
+func (r *Round) Status() Status {
+	return r.status
+}
+
+// Stage returns the number of the stage being thrown
+func (r *Round) Stage() int {
+	return r.stage
+}
+
+// Stages returns the outcome of every stage thrown so far
+func (r *Round) Stages() []Stage {
+	return append([]Stage(nil), r.stages...)
+}
+
+// Players returns the players in the order they joined
+func (r *Round) Players() []Player {
+	return append([]Player(nil), r.players...)
+}
+
+// Result returns the result of a resolved round, nil otherwise
+func (r *Round) Result() *domain.ChallengeResult {
+	return r.result
+}
+
+// ExpiresAt returns when an unfinished round expires, zero means never
+func (r *Round) ExpiresAt() time.Time {
+	return r.expiresAt
+}
+
+// SetExpiry sets when an unfinished round expires
+func (r *Round) SetExpiry(t time.Time) {
+	r.expiresAt = t
+}
+
+// ExpiredAt reports whether an unfinished round is past its expiry at t
+func (r *Round) ExpiredAt(t time.Time) bool {
+	return !r.status.Terminal() && !r.expiresAt.IsZero() && !t.Before(r.expiresAt)
+}
+
+// Clone returns a deep copy of the round
+func (r *Round) Clone() *Round {
+	clone := *r
+	clone.players = append([]Player(nil), r.players...)
+	clone.stages = append([]Stage(nil), r.stages...)
+	return &clone
+}
+
+// Join adds a player to the lobby, team must be Team1 or Team2 in team rounds and zero otherwise
+func (r *Round) Join(userID string, team int) error {
+	if r.status != Lobby {
+		return ErrLobbyClosed
+	}
+	if userID == "" {
+		return ErrNotPlaying
+	}
+	if r.player(userID) != nil {
+		return ErrAlreadyJoined
+	}
+	if r.teamSize == 0 && team != 0 || r.teamSize != 0 && team != Team1 && team != Team2 {
+		return ErrInvalidTeam
+	}
+	if r.maxPlayers > 0 && len(r.players) >= r.maxPlayers {
+		return ErrLobbyFull
+	}
+	if r.teamSize != 0 && len(r.Team(team)) >= r.teamSize {
+		return ErrTeamFull
+	}
+	r.players = append(r.players, Player{ID: userID, Team: team})
+	return nil
+}
+
+// Team returns the ids of the players of a team
+func (r *Round) Team(team int) []string {
+	members := []string{}
+	for _, p := range r.players {
+		if p.Team == team {
+			members = append(members, p.ID)
+		}
+	}
+	return members
+}
+
+// Start closes the lobby and waits for the throws of the first stage
+func (r *Round) Start(actor string) error {
+	if actor != r.host {
+		return ErrNotHost
+	}
+	if r.status != Lobby {
+		return ErrLobbyClosed
+	}
+	if r.teamSize != 0 {
+		if len(r.Team(Team1)) < r.teamSize || len(r.Team(Team2)) < r.teamSize {
+			return ErrTeamsNotFull
+		}
+	} else if len(r.players) < 2 {
+		return ErrNotEnoughPlayers
+	}
+	r.status = Throwing
+	r.stage = 1
+	return nil
+}
+
+// Cancel ends an unfinished round
+func (r *Round) Cancel(actor string) error {
+	if actor != r.host {
+		return ErrNotHost
+	}
+	if r.status.Terminal() {
+		return ErrRoundOver
+	}
+	r.status = Cancelled
+	return nil
+}
+
+// Expire ends an unfinished round
+func (r *Round) Expire() error {
+	if r.status.Terminal() {
+		return ErrRoundOver
+	}
+	r.status = Expired
+	return nil
+}
+
+// Playing returns the players who were not knocked out
+func (r *Round) Playing() []Player {
+	playing := []Player{}
+	for _, p := range r.players {
+		if p.OutAt == 0 {
+			playing = append(playing, p)
+		}
+	}
+	return playing
+}
+
+// Waiting returns the ids of the playing players who did not throw this stage
+func (r *Round) Waiting() []string {
+	waiting := []string{}
+	for _, p := range r.Playing() {
+		if p.Choice == "" {
+			waiting = append(waiting, p.ID)
+		}
+	}
+	return waiting
+}
+
+// Throw records the secret choice of a playing player, stageOver reports whether
+// it was the last throw of the stage and the stage was scored
+func (r *Round) Throw(userID string, choice domain.RpsChoice) (stageOver bool, err error) {
+	if r.status != Throwing {
+		return false, ErrNotThrowing
+	}
+	if !(&domain.Player{ID: userID, Choice: choice}).Valid() {
+		return false, ErrInvalidChoice
+	}
+	p := r.player(userID)
+	if p == nil || p.OutAt != 0 {
+		return false, ErrNotPlaying
+	}
+	if p.Choice != "" {
+		return false, ErrAlreadyThrown
+	}
+	p.Choice = choice
+	if len(r.Waiting()) > 0 {
+		return false, nil
+	}
+	if r.rule == Pairwise {
+		r.scorePairwise()
+	} else {
+		r.scoreElimination()
+	}
+	return true, nil
+}
+
+func (r *Round) player(userID string) *Player {
+	for i := range r.players {
+		if r.players[i].ID == userID {
+			return &r.players[i]
+		}
+	}
+	return nil
+}
+
+// distinct returns the distinct choices of the playing players
+func (r *Round) distinct() []domain.RpsChoice {
+	choices := []domain.RpsChoice{}
+	for _, c := range domain.Choices {
+		for _, p := range r.Playing() {
+			if p.Choice == c {
+				choices = append(choices, c)
+				break
+			}
+		}
+	}
+	return choices
+}
+
+func (r *Round) scorePairwise() {
+	r.stages = append(r.stages, Stage{Number: r.stage, Choices: r.distinct()})
+	points := make([]int, len(r.players))
+	for i, p := range r.players {
+		for _, o := range r.players {
+			if p.Team != 0 && p.Team == o.Team {
+				continue
+			}
+			if domain.Counter(o.Choice) == p.Choice {
+				points[i]++
+			}
+		}
+	}
+	r.resolve(points)
+}
+
+func (r *Round) scoreElimination() {
+	stage := Stage{Number: r.stage, Choices: r.distinct()}
+	if len(stage.Choices) == 2 {
+		losing := stage.Choices[0]
+		if domain.Counter(stage.Choices[0]) != stage.Choices[1] {
+			losing = stage.Choices[1]
+		}
+		for i := range r.players {
+			if r.players[i].OutAt == 0 && r.players[i].Choice == losing {
+				r.players[i].OutAt = r.stage
+				stage.Eliminated = append(stage.Eliminated, r.players[i].ID)
+			}
+		}
+	}
+	r.stages = append(r.stages, stage)
+
+	teams := map[int]bool{}
+	for _, p := range r.Playing() {
+		teams[p.Team] = true
+	}
+	over := r.stage == MaxStages || len(r.Playing()) == 1 || r.teamSize != 0 && len(teams) == 1
+	if !over {
+		r.stage++
+		for i := range r.players {
+			if r.players[i].OutAt == 0 {
+				r.players[i].Choice = ""
+			}
+		}
+		return
+	}
+	// players score the stages they survived
+	points := make([]int, len(r.players))
+	for i, p := range r.players {
+		points[i] = r.stage
+		if p.OutAt != 0 {
+			points[i] = p.OutAt - 1
+		}
+	}
+	r.resolve(points)
+}
+
+// resolve ranks players by points, in team rounds teams are ranked by the sum of their points
+func (r *Round) resolve(points []int) {
+	placements := make([]domain.Placement, len(r.players))
+	score := make([]int, len(r.players))
+	teamPoints := map[int]int{}
+	for i, p := range r.players {
+		teamPoints[p.Team] += points[i]
+	}
+	for i, p := range r.players {
+		placements[i] = domain.Placement{
+			Player: &domain.Player{ID: p.ID, Choice: p.Choice},
+			Team:   p.Team,
+			Points: points[i],
+		}
+		score[i] = points[i]
+		if r.teamSize != 0 {
+			score[i] = teamPoints[p.Team]
+			if r.rule == Elimination {
+				// the team with a player left wins
+				score[i] = 0
+				for _, o := range r.Playing() {
+					if o.Team == p.Team {
+						score[i] = 1
+					}
+				}
+			}
+		}
+	}
+	for i := range placements {
+		placements[i].Rank = 1
+		for j := range placements {
+			if score[j] > score[i] {
+				placements[i].Rank++
+			}
+		}
+	}
+	sort.SliceStable(placements, func(i, j int) bool {
+		if placements[i].Rank != placements[j].Rank {
+			return placements[i].Rank < placements[j].Rank
+		}
+		return placements[i].Points > placements[j].Points
+	})
+
+	draw := true
+	for _, p := range placements {
+		if p.Rank != 1 {
+			draw = false
+		}
+	}
+	r.result = &domain.ChallengeResult{Placements: placements, OutcomeDraw: draw}
+	r.status = Resolved
+}
@@ -0,0 +1,133 @@
+package round
+
+import (
+	"testing"
+
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRound(t *testing.T, rule Rule, teamSize int, players ...string) *Round {
+	r, err := New("r1", challenge.Scope{GuildID: "g1", ChannelID: "c1"}, "host", rule, teamSize, 0)
+	require.NoError(t, err)
+	for i, p := range players {
+		team := 0
+		if teamSize != 0 {
+			team = Team1 + i%2
+		}
+		require.NoError(t, r.Join(p, team))
+	}
+	require.NoError(t, r.Start("host"))
+	return r
+}
+
+func throwAll(t *testing.T, r *Round, choices map[string]domain.RpsChoice) {
+	for i, p := range r.Playing() {
+		over, err := r.Throw(p.ID, choices[p.ID])
+		require.NoError(t, err)
+		require.Equal(t, i == len(choices)-1, over)
+	}
+}
+
+func placements(r *Round) map[string][2]int {
+	ranks := map[string][2]int{}
+	for _, p := range r.Result().Placements {
+		ranks[p.Player.ID] = [2]int{p.Rank, p.Points}
+	}
+	return ranks
+}
+
+func TestPairwise(t *testing.T) {
+	r := newTestRound(t, Pairwise, 0, "a", "b", "c", "d")
+	throwAll(t, r, map[string]domain.RpsChoice{"a": domain.Rock, "b": domain.Scissor, "c": domain.Scissor, "d": domain.Paper})
+	require.Equal(t, Resolved, r.Status())
+	require.Equal(t, map[string][2]int{"a": {1, 2}, "b": {2, 1}, "c": {2, 1}, "d": {2, 1}}, placements(r))
+	require.Len(t, r.Result().Winners(), 1)
+
+	msg, err := r.Result().FormatResult()
+	require.NoError(t, err)
+	require.Equal(t, "<@a> won!\n1. <@a> **rock** 2 pts\n2. <@b> **scissors** 1 pts\n2. <@c> **scissors** 1 pts\n2. <@d> **paper** 1 pts", msg)
+}
+
+func TestPairwiseDraw(t *testing.T) {
+	r := newTestRound(t, Pairwise, 0, "a", "b", "c")
+	throwAll(t, r, map[string]domain.RpsChoice{"a": domain.Rock, "b": domain.Scissor, "c": domain.Paper})
+	require.True(t, r.Result().OutcomeDraw)
+	require.Empty(t, r.Result().Winners())
+}
+
+func TestPairwiseTeams(t *testing.T) {
+	// a and c play for team 1, b and d for team 2
+	r := newTestRound(t, Pairwise, 2, "a", "b", "c", "d")
+	throwAll(t, r, map[string]domain.RpsChoice{"a": domain.Rock, "b": domain.Scissor, "c": domain.Paper, "d": domain.Rock})
+	// a beats b and c beats d for team 1, b beats c for team 2
+	require.Equal(t, map[string][2]int{"a": {1, 1}, "c": {1, 1}, "b": {3, 1}, "d": {3, 0}}, placements(r))
+	msg, err := r.Result().FormatResult()
+	require.NoError(t, err)
+	require.Contains(t, msg, "Team 1 wins!")
+}
+
+func TestElimination(t *testing.T) {
+	r := newTestRound(t, Elimination, 0, "a", "b", "c", "d")
+
+	// three choices, everyone throws again
+	throwAll(t, r, map[string]domain.RpsChoice{"a": domain.Rock, "b": domain.Scissor, "c": domain.Paper, "d": domain.Rock})
+	require.Equal(t, Throwing, r.Status())
+	require.Equal(t, 2, r.Stage())
+	require.Empty(t, r.Stages()[0].Eliminated)
+
+	throwAll(t, r, map[string]domain.RpsChoice{"a": domain.Rock, "b": domain.Scissor, "c": domain.Rock, "d": domain.Scissor})
+	require.Equal(t, []string{"b", "d"}, r.Stages()[1].Eliminated)
+	_, err := r.Throw("b", domain.Rock)
+	require.ErrorIs(t, err, ErrNotPlaying)
+
+	throwAll(t, r, map[string]domain.RpsChoice{"a": domain.Paper, "c": domain.Scissor})
+	require.Equal(t, Resolved, r.Status())
+	require.Equal(t, map[string][2]int{"c": {1, 3}, "a": {2, 2}, "b": {3, 1}, "d": {3, 1}}, placements(r))
+}
+
+func TestEliminationTeams(t *testing.T) {
+	r := newTestRound(t, Elimination, 2, "a", "b", "c", "d")
+	throwAll(t, r, map[string]domain.RpsChoice{"a": domain.Rock, "b": domain.Scissor, "c": domain.Scissor, "d": domain.Scissor})
+	// only a survived, and with them team 1
+	require.Equal(t, Resolved, r.Status())
+	winners := []string{}
+	for _, p := range r.Result().Winners() {
+		winners = append(winners, p.ID)
+	}
+	require.ElementsMatch(t, []string{"a", "c"}, winners)
+}
+
+func TestLobby(t *testing.T) {
+	_, err := New("r1", challenge.Scope{}, "host", "chaos", 0, 0)
+	require.ErrorIs(t, err, ErrUnknownRule)
+	_, err = New("r1", challenge.Scope{}, "host", Pairwise, 4, 0)
+	require.ErrorIs(t, err, ErrInvalidTeamSize)
+
+	r, err := New("r1", challenge.Scope{}, "host", Pairwise, 2, 0)
+	require.NoError(t, err)
+	require.ErrorIs(t, r.Join("a", 0), ErrInvalidTeam)
+	require.NoError(t, r.Join("a", Team1))
+	require.ErrorIs(t, r.Join("a", Team2), ErrAlreadyJoined)
+	require.NoError(t, r.Join("b", Team1))
+	require.ErrorIs(t, r.Join("c", Team1), ErrTeamFull)
+	require.NoError(t, r.Join("c", Team2))
+	require.ErrorIs(t, r.Start("host"), ErrTeamsNotFull)
+	require.NoError(t, r.Join("d", Team2))
+	require.ErrorIs(t, r.Join("e", Team2), ErrLobbyFull)
+	require.ErrorIs(t, r.Start("a"), ErrNotHost)
+	require.NoError(t, r.Start("host"))
+	require.ErrorIs(t, r.Join("e", Team1), ErrLobbyClosed)
+
+	_, err = r.Throw("a", "lizard")
+	require.ErrorIs(t, err, ErrInvalidChoice)
+	_, err = r.Throw("a", domain.Rock)
+	require.NoError(t, err)
+	_, err = r.Throw("a", domain.Rock)
+	require.ErrorIs(t, err, ErrAlreadyThrown)
+	require.ElementsMatch(t, []string{"b", "c", "d"}, r.Waiting())
+	require.NoError(t, r.Cancel("host"))
+	_, err = r.Throw("b", domain.Rock)
+	require.ErrorIs(t, err, ErrNotThrowing)
+}
@@ -8,3 +8,18 @@ const (
 	Paper   RpsChoice = "paper"
 	Scissor RpsChoice = "scissors"
 )
+
+// Choices lists every valid choice
+var Choices = []RpsChoice{Rock, Paper, Scissor}
+
+// Counter returns the choice that beats c
+func Counter(c RpsChoice) RpsChoice {
+	switch c {
+	case Rock:
+		return Paper
+	case Paper:
+		return Scissor
+	default:
+		return Rock
+	}
+}
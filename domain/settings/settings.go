@@ -0,0 +1,198 @@
+// settings package holds the settings admins can change per guild
+//
+// A guild only stores the values it overrides, as validated text keyed by setting
+// name. The effective settings of a guild are its overrides applied over the
+// defaults of the bot
+package settings
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain/round"
+)
+
+// Settings Errors
+var (
+	ErrUnknownKey   = errors.New("unknown setting")
+	ErrInvalidValue = errors.New("invalid setting value")
+)
+
+// Setting keys
+const (
+	KeyRuleSet             = "rule_set"
+	KeyChallengeTTL        = "challenge_ttl"
+	KeyAllowedChannels     = "allowed_channels"
+	KeyEconomy             = "economy"
+	KeyLeaderboard         = "leaderboard"
+	KeyAnnouncementChannel = "announcement_channel"
+)
+
+// Leaderboard visibilities
+const (
+	LeaderboardPublic  = "public"
+	LeaderboardPrivate = "private"
+	LeaderboardOff     = "off"
+)
+
+// MaxChallengeTTL is the longest a guild can keep challenges open
+const MaxChallengeTTL = 24 * time.Hour
+
+// Settings are the effective settings of a guild
+type Settings struct {
+	// RuleSet is the rule rounds are played under when none is picked
+	RuleSet string
+	// ChallengeTTL is how long challenges and rounds stay playable, zero disables expiry
+	ChallengeTTL time.Duration
+	// AllowedChannels restricts the bot to these channels when not empty
+	AllowedChannels []string
+	Economy         bool
+	Leaderboard     string
+	// AnnouncementChannel is where results of the guild are announced, empty to announce them where they were played
+	AnnouncementChannel string
+}
+
+// Overrides are the values a guild set, keyed by setting name
+type Overrides map[string]string
+
+// Key describes a setting
+type Key struct {
+	Name        string
+	Description string
+	// Choices lists the values of settings with a fixed set of values
+	Choices []string
+	// parse validates a value and returns its canonical text
+	parse func(value string) (string, error)
+	// apply sets the canonical value on settings
+	apply func(s *Settings, value string)
+}
+
+// Keys lists every setting
+var Keys = []Key{
+	{
+		Name:        KeyRuleSet,
+		Description: "rule rounds are played under when none is picked",
+		Choices:     []string{string(round.Pairwise), string(round.Elimination)},
+		apply:       func(s *Settings, v string) { s.RuleSet = v },
+	}, {
+		Name:        KeyChallengeTTL,
+		Description: "how long challenges stay open, e.g. 10m, 0 never expires",
+		parse:       parseTTL,
+		apply: func(s *Settings, v string) {
+			s.ChallengeTTL, _ = time.ParseDuration(v)
+		},
+	}, {
+		Name:        KeyAllowedChannels,
+		Description: "channels the bot can be used in, all channels when empty",
+		parse:       parseChannels,
+		apply: func(s *Settings, v string) {
+			s.AllowedChannels = nil
+			if v != "" {
+				s.AllowedChannels = strings.Split(v, ",")
+			}
+		},
+	}, {
+		Name:        KeyEconomy,
+		Description: "whether coins can be claimed, sent and wagered",
+		Choices:     []string{"on", "off"},
+		apply:       func(s *Settings, v string) { s.Economy = v == "on" },
+	}, {
+		Name:        KeyLeaderboard,
+		Description: "who sees the leaderboard",
+		Choices:     []string{LeaderboardPublic, LeaderboardPrivate, LeaderboardOff},
+		apply:       func(s *Settings, v string) { s.Leaderboard = v },
+	}, {
+		Name:        KeyAnnouncementChannel,
+		Description: "channel results are announced in",
+		parse:       parseChannel,
+		apply:       func(s *Settings, v string) { s.AnnouncementChannel = v },
+	},
+}
+
+// Lookup returns the setting called name
+func Lookup(name string) (Key, bool) {
+	i := slices.IndexFunc(Keys, func(k Key) bool { return k.Name == name })
+	if i < 0 {
+		return Key{}, false
+	}
+	return Keys[i], true
+}
+
+// Parse validates a value of the setting called name and returns its canonical text
+func Parse(name, value string) (string, error) {
+	key, ok := Lookup(name)
+	if !ok {
+		return "", ErrUnknownKey
+	}
+	value = strings.TrimSpace(value)
+	if key.parse != nil {
+		return key.parse(value)
+	}
+	for _, choice := range key.Choices {
+		if strings.EqualFold(choice, value) {
+			return choice, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s is one of %s", ErrInvalidValue, name, strings.Join(key.Choices, ", "))
+}
+
+// Resolve applies the overrides of a guild over defaults, invalid overrides are ignored
+func Resolve(defaults Settings, overrides Overrides) Settings {
+	s := defaults
+	s.AllowedChannels = slices.Clone(defaults.AllowedChannels)
+	for _, key := range Keys {
+		value, ok := overrides[key.Name]
+		if !ok {
+			continue
+		}
+		if value, err := Parse(key.Name, value); err == nil {
+			key.apply(&s, value)
+		}
+	}
+	return s
+}
+
+func parseTTL(value string) (string, error) {
+	if value == "0" {
+		return "0s", nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl < 0 || ttl > MaxChallengeTTL {
+		return "", fmt.Errorf("%w: %s is a duration such as 10m, at most %s", ErrInvalidValue, KeyChallengeTTL, MaxChallengeTTL)
+	}
+	return ttl.String(), nil
+}
+
+// parseChannel accepts a channel mention or id
+func parseChannel(value string) (string, error) {
+	id := strings.TrimSuffix(strings.TrimPrefix(value, "<#"), ">")
+	if id == "" || strings.Trim(id, "0123456789") != "" {
+		return "", fmt.Errorf("%w: %q is not a channel", ErrInvalidValue, value)
+	}
+	return id, nil
+}
+
+// parseChannels accepts channel mentions or ids separated by commas or spaces,
+// "none" clears the list
+func parseChannels(value string) (string, error) {
+	if strings.EqualFold(value, "none") {
+		return "", nil
+	}
+	var ids []string
+	for _, field := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ' ' }) {
+		id, err := parseChannel(field)
+		if err != nil {
+			return "", err
+		}
+		if !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return "", fmt.Errorf("%w: %s lists channels, or none", ErrInvalidValue, KeyAllowedChannels)
+	}
+	return strings.Join(ids, ","), nil
+}
@@ -0,0 +1,65 @@
+package settings
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		key, value, expected string
+		err                  error
+	}{
+		{key: KeyRuleSet, value: "Elimination", expected: "elimination"},
+		{key: KeyRuleSet, value: "chaos", err: ErrInvalidValue},
+		{key: KeyChallengeTTL, value: "90s", expected: "1m30s"},
+		{key: KeyChallengeTTL, value: "0", expected: "0s"},
+		{key: KeyChallengeTTL, value: "48h", err: ErrInvalidValue},
+		{key: KeyChallengeTTL, value: "-1m", err: ErrInvalidValue},
+		{key: KeyAllowedChannels, value: "<#1>, 2 <#1>", expected: "1,2"},
+		{key: KeyAllowedChannels, value: "none", expected: ""},
+		{key: KeyAllowedChannels, value: "#games", err: ErrInvalidValue},
+		{key: KeyEconomy, value: "off", expected: "off"},
+		{key: KeyLeaderboard, value: "private", expected: LeaderboardPrivate},
+		{key: KeyAnnouncementChannel, value: "<#42>", expected: "42"},
+		{key: "colour", value: "blue", err: ErrUnknownKey},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.key+"="+tc.value, func(t *testing.T) {
+			value, err := Parse(tc.key, tc.value)
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, value)
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	defaults := Settings{
+		RuleSet:      "pairwise",
+		ChallengeTTL: 10 * time.Minute,
+		Economy:      true,
+		Leaderboard:  LeaderboardPublic,
+	}
+	require.Equal(t, defaults, Resolve(defaults, nil))
+
+	s := Resolve(defaults, Overrides{
+		KeyChallengeTTL:        "0s",
+		KeyAllowedChannels:     "1,2",
+		KeyEconomy:             "off",
+		KeyAnnouncementChannel: "3",
+		KeyLeaderboard:         "sideways", // invalid overrides are ignored
+	})
+	require.Zero(t, s.ChallengeTTL)
+	require.Equal(t, []string{"1", "2"}, s.AllowedChannels)
+	require.False(t, s.Economy)
+	require.Equal(t, "3", s.AnnouncementChannel)
+	require.Equal(t, LeaderboardPublic, s.Leaderboard)
+	require.Equal(t, "pairwise", s.RuleSet)
+}
@@ -0,0 +1,148 @@
+// strategy package holds the strategies of the computer player
+//
+// Every strategy predicts a human's next throw from the throws they made in
+// previous games and plays the choice beating it
+package strategy
+
+import (
+	"errors"
+	"math/rand"
+	"sort"
+
+	"github.com/ekefan/discord-bot/domain"
+)
+
+var (
+	ErrUnknownStrategy = errors.New("unknown strategy")
+)
+
+// Strategy names
+const (
+	Uniform   = "random"
+	Frequency = "frequency"
+	Markov    = "markov"
+	BeatLast  = "beat-last"
+)
+
+// Strategy picks the computer player's choice,
+// history holds the opponent's previous throws from oldest to newest
+type Strategy interface {
+	Name() string
+	Next(history []domain.RpsChoice) domain.RpsChoice
+}
+
+// Names lists the available strategies
+func Names() []string {
+	return []string{Uniform, Frequency, Markov, BeatLast}
+}
+
+// New creates the strategy called name, seed makes its random choices deterministic
+func New(name string, seed int64) (Strategy, error) {
+	rng := rand.New(rand.NewSource(seed))
+	switch name {
+	case Uniform:
+		return &uniform{rng: rng}, nil
+	case Frequency:
+		return &frequency{rng: rng}, nil
+	case Markov:
+		return &markov{rng: rng}, nil
+	case BeatLast:
+		return &beatLast{rng: rng}, nil
+	default:
+		return nil, ErrUnknownStrategy
+	}
+}
+
+func random(rng *rand.Rand) domain.RpsChoice {
+	return domain.Choices[rng.Intn(len(domain.Choices))]
+}
+
+// mostLikely returns the choice with the highest count, ties are broken randomly.
+// ok is false when every count is zero
+func mostLikely(rng *rand.Rand, counts map[domain.RpsChoice]int) (choice domain.RpsChoice, ok bool) {
+	best := 0
+	candidates := []domain.RpsChoice{}
+	for _, c := range domain.Choices {
+		switch n := counts[c]; {
+		case n > best:
+			best = n
+			candidates = []domain.RpsChoice{c}
+		case n == best && n > 0:
+			candidates = append(candidates, c)
+		}
+	}
+	if best == 0 {
+		return "", false
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i] < candidates[j] })
+	return candidates[rng.Intn(len(candidates))], true
+}
+
+// uniform ignores history and picks uniformly at random
+type uniform struct {
+	rng *rand.Rand
+}
+
+func (s *uniform) Name() string { return Uniform }
+
+func (s *uniform) Next(history []domain.RpsChoice) domain.RpsChoice {
+	return random(s.rng)
+}
+
+// frequency expects the opponent's most frequent throw
+type frequency struct {
+	rng *rand.Rand
+}
+
+func (s *frequency) Name() string { return Frequency }
+
+func (s *frequency) Next(history []domain.RpsChoice) domain.RpsChoice {
+	counts := map[domain.RpsChoice]int{}
+	for _, c := range history {
+		counts[c]++
+	}
+	predicted, ok := mostLikely(s.rng, counts)
+	if !ok {
+		return random(s.rng)
+	}
+	return domain.Counter(predicted)
+}
+
+// markov expects the throw that most often followed the opponent's last throw
+type markov struct {
+	rng *rand.Rand
+}
+
+func (s *markov) Name() string { return Markov }
+
+func (s *markov) Next(history []domain.RpsChoice) domain.RpsChoice {
+	if len(history) < 2 {
+		return random(s.rng)
+	}
+	last := history[len(history)-1]
+	counts := map[domain.RpsChoice]int{}
+	for i := 0; i+1 < len(history); i++ {
+		if history[i] == last {
+			counts[history[i+1]]++
+		}
+	}
+	predicted, ok := mostLikely(s.rng, counts)
+	if !ok {
+		return random(s.rng)
+	}
+	return domain.Counter(predicted)
+}
+
+// beatLast expects the opponent to repeat their last throw
+type beatLast struct {
+	rng *rand.Rand
+}
+
+func (s *beatLast) Name() string { return BeatLast }
+
+func (s *beatLast) Next(history []domain.RpsChoice) domain.RpsChoice {
+	if len(history) == 0 {
+		return random(s.rng)
+	}
+	return domain.Counter(history[len(history)-1])
+}
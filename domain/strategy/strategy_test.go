@@ -0,0 +1,58 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	for _, name := range Names() {
+		s, err := New(name, 1)
+		require.NoError(t, err)
+		require.Equal(t, name, s.Name())
+	}
+	_, err := New("psychic", 1)
+	require.ErrorIs(t, err, ErrUnknownStrategy)
+}
+
+func TestDeterministicSeed(t *testing.T) {
+	for _, name := range Names() {
+		a, _ := New(name, 42)
+		b, _ := New(name, 42)
+		for i := 0; i < 20; i++ {
+			require.Equal(t, a.Next(nil), b.Next(nil))
+		}
+	}
+}
+
+func TestStrategies(t *testing.T) {
+	r, p, s := domain.Rock, domain.Paper, domain.Scissor
+	testCases := []struct {
+		name     string
+		strategy string
+		history  []domain.RpsChoice
+		expected domain.RpsChoice
+	}{
+		{name: "frequency counters favourite", strategy: Frequency, history: []domain.RpsChoice{r, p, r, s, r}, expected: p},
+		{name: "beat last", strategy: BeatLast, history: []domain.RpsChoice{r, p, s}, expected: r},
+		// after rock the player always threw scissors
+		{name: "markov follows transitions", strategy: Markov, history: []domain.RpsChoice{r, s, p, r, s, p, r}, expected: r},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			strategy, err := New(tc.strategy, 7)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, strategy.Next(tc.history))
+		})
+	}
+}
+
+func TestNoHistoryIsValid(t *testing.T) {
+	for _, name := range Names() {
+		strategy, _ := New(name, 3)
+		choice := strategy.Next(nil)
+		require.Contains(t, domain.Choices, choice)
+	}
+}
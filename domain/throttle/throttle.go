@@ -0,0 +1,140 @@
+// throttle package implements the token buckets limiting how fast users use the bot
+//
+// Every user gets a bucket per guild and per command or component route. A bucket
+// holds up to Burst tokens, each use takes one and one token is refilled every
+// Every. Buckets are plain values so any store can keep them
+package throttle
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidLimit = errors.New("limit must be written as burst/every, such as 5/10s")
+
+// Limit allows Burst uses at once and one more every Every
+type Limit struct {
+	Burst int
+	Every time.Duration
+}
+
+// ParseLimit reads a limit written as burst/every, such as 5/10s
+func ParseLimit(s string) (Limit, error) {
+	burst, every, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, ErrInvalidLimit
+	}
+	n, err := strconv.Atoi(burst)
+	if err != nil || n <= 0 {
+		return Limit{}, ErrInvalidLimit
+	}
+	d, err := time.ParseDuration(every)
+	if err != nil || d <= 0 {
+		return Limit{}, ErrInvalidLimit
+	}
+	return Limit{Burst: n, Every: d}, nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Burst, l.Every)
+}
+
+// Bucket is the tokens left at a time, the zero bucket is full
+type Bucket struct {
+	Tokens float64
+	At     time.Time
+}
+
+// refill returns the tokens of b at now
+func (l Limit) refill(b Bucket, now time.Time) float64 {
+	if b.At.IsZero() {
+		return float64(l.Burst)
+	}
+	elapsed := now.Sub(b.At)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(float64(l.Burst), b.Tokens+float64(elapsed)/float64(l.Every))
+}
+
+// Take refills b at now and takes a token from it. When the bucket is empty
+// ok is false, b is returned refilled and retryAfter is how long until the next token
+func (l Limit) Take(b Bucket, now time.Time) (next Bucket, ok bool, retryAfter time.Duration) {
+	tokens := l.refill(b, now)
+	if tokens < 1 {
+		return Bucket{Tokens: tokens, At: now}, false, time.Duration((1 - tokens) * float64(l.Every))
+	}
+	return Bucket{Tokens: tokens - 1, At: now}, true, 0
+}
+
+// Full reports whether b refilled completely at now, a full bucket need not be kept
+func (l Limit) Full(b Bucket, now time.Time) bool {
+	return l.refill(b, now) >= float64(l.Burst)
+}
+
+// Kinds of throttled interactions
+const (
+	KindCommand   = "command"
+	KindComponent = "component"
+)
+
+// Limits are the limits of commands and components, Named overrides
+// them for the commands and component routes it names
+type Limits struct {
+	Commands   Limit
+	Components Limit
+	Named      map[string]Limit
+}
+
+// ParseLimits reads comma separated name=limit pairs, the commands and components names
+// set the limits of every command and component, other names are commands or routes:
+//
+//	commands=5/10s,components=10/10s,challenge=3/30s
+//
+// Interactions of a kind without a limit are not throttled
+func ParseLimits(s string) (Limits, error) {
+	limits := Limits{Named: make(map[string]Limit)}
+	for _, pair := range strings.Split(s, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return Limits{}, fmt.Errorf("%w: %q has no name", ErrInvalidLimit, pair)
+		}
+		limit, err := ParseLimit(value)
+		if err != nil {
+			return Limits{}, fmt.Errorf("%w: %q", err, pair)
+		}
+		switch name = strings.TrimSpace(name); name {
+		case "commands":
+			limits.Commands = limit
+		case "components":
+			limits.Components = limit
+		default:
+			limits.Named[name] = limit
+		}
+	}
+	return limits, nil
+}
+
+// For returns the limit of a command or component route, ok is false when it is not throttled
+func (ls Limits) For(kind, name string) (limit Limit, ok bool) {
+	if limit, ok := ls.Named[name]; ok {
+		return limit, true
+	}
+	limit = ls.Commands
+	if kind == KindComponent {
+		limit = ls.Components
+	}
+	return limit, limit.Burst > 0
+}
+
+// Key identifies the bucket of a user in a guild for a command or component route
+func Key(guildID, userID, kind, name string) string {
+	return strings.Join([]string{guildID, userID, kind, name}, ":")
+}
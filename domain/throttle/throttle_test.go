@@ -0,0 +1,59 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTake(t *testing.T) {
+	limit := Limit{Burst: 2, Every: 10 * time.Second}
+	now := time.Now()
+	var b Bucket
+	require.True(t, limit.Full(b, now))
+
+	b, ok, _ := limit.Take(b, now)
+	require.True(t, ok)
+	b, ok, _ = limit.Take(b, now)
+	require.True(t, ok)
+
+	b, ok, retry := limit.Take(b, now)
+	require.False(t, ok)
+	require.Equal(t, 10*time.Second, retry)
+
+	// half a token refilled
+	b, ok, retry = limit.Take(b, now.Add(5*time.Second))
+	require.False(t, ok)
+	require.Equal(t, 5*time.Second, retry)
+
+	b, ok, _ = limit.Take(b, now.Add(10*time.Second))
+	require.True(t, ok)
+	require.False(t, limit.Full(b, now.Add(10*time.Second)))
+	require.True(t, limit.Full(b, now.Add(30*time.Second)))
+}
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits("commands=5/10s, components=10/10s,challenge=3/30s")
+	require.NoError(t, err)
+
+	limit, ok := limits.For(KindCommand, "challenge")
+	require.True(t, ok)
+	require.Equal(t, Limit{Burst: 3, Every: 30 * time.Second}, limit)
+	limit, ok = limits.For(KindCommand, "daily")
+	require.True(t, ok)
+	require.Equal(t, "5/10s", limit.String())
+	limit, ok = limits.For(KindComponent, "accept")
+	require.True(t, ok)
+	require.Equal(t, 10, limit.Burst)
+
+	limits, err = ParseLimits("challenge=1/1m")
+	require.NoError(t, err)
+	_, ok = limits.For(KindCommand, "daily")
+	require.False(t, ok)
+
+	for _, invalid := range []string{"commands", "commands=5", "commands=0/1s", "commands=5/-1s", "commands=x/1s"} {
+		_, err := ParseLimits(invalid)
+		require.ErrorIs(t, err, ErrInvalidLimit, invalid)
+	}
+}
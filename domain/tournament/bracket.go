@@ -0,0 +1,258 @@
+package tournament
+
+// Bracket is the part of an elimination tournament a match belongs to
+type Bracket string
+
+// Brackets
+const (
+	Winners    Bracket = "winners"
+	Losers     Bracket = "losers"
+	GrandFinal Bracket = "final"
+)
+
+// slot points at a side of a match, a zero match points nowhere
+type slot struct {
+	match int // index of the match plus one
+	side  int
+}
+
+// Match pairs two players of the tournament
+type Match struct {
+	ID        string
+	Bracket   Bracket // empty for round robin and swiss matches
+	Round     int
+	Players   [2]string // empty while unknown or when the side is a bye
+	Winner    string
+	Loser     string
+	Bye       bool // decided without being played
+	Done      bool
+	Challenge string // id of the challenge played for the match
+	Replays   int    // times the match was scheduled again after expiring unplayed
+
+	filled   [2]bool // a side is known, a filled side without a player is a bye
+	winnerTo slot
+	loserTo  slot
+	resetTo  int // index plus one of the reset of a grand final
+}
+
+func (m Match) playable() bool {
+	return !m.Done && m.Players[0] != "" && m.Players[1] != ""
+}
+
+// seedOrder returns the seeds in bracket order so the best seeds meet last,
+// e.g 1 8 4 5 2 7 3 6 for a bracket of eight
+func seedOrder(size int) []int {
+	order := []int{1}
+	for n := 2; n <= size; n *= 2 {
+		next := make([]int, 0, n)
+		for _, seed := range order {
+			next = append(next, seed, n+1-seed)
+		}
+		order = next
+	}
+	return order
+}
+
+// buildElimination builds the winners bracket and with double elimination the losers
+// bracket and grand final. Missing players in the first round are byes for the best seeds
+func (t *Tournament) buildElimination(double bool) {
+	size, rounds := 2, 1
+	for size < len(t.participants) {
+		size, rounds = size*2, rounds+1
+	}
+
+	// winners[r][i] is the index of the i-th match of round r+1
+	winners := make([][]int, rounds)
+	for r := range rounds {
+		for range size >> (r + 1) {
+			winners[r] = append(winners[r], t.add(Match{Bracket: Winners, Round: r + 1}))
+		}
+	}
+	order := seedOrder(size)
+	for i, seed := range order {
+		player := ""
+		if seed <= len(t.participants) {
+			player = t.participants[seed-1].ID
+		}
+		t.fill(slot{match: winners[0][i/2] + 1, side: i % 2}, player)
+	}
+	for r := 0; r < rounds-1; r++ {
+		for i, m := range winners[r] {
+			t.matches[m].winnerTo = slot{match: winners[r+1][i/2] + 1, side: i % 2}
+		}
+	}
+	if !double {
+		return
+	}
+
+	if rounds == 1 {
+		final := t.addGrandFinal()
+		t.matches[winners[0][0]].winnerTo = slot{match: final + 1, side: 0}
+		t.matches[winners[0][0]].loserTo = slot{match: final + 1, side: 1}
+		return
+	}
+
+	// the losers bracket alternates between rounds where the losers of a winners round
+	// drop in and rounds where the remaining losers bracket players meet each other
+	var previous []int
+	for i := range size / 4 {
+		m := t.add(Match{Bracket: Losers, Round: 1})
+		t.matches[winners[0][2*i]].loserTo = slot{match: m + 1, side: 0}
+		t.matches[winners[0][2*i+1]].loserTo = slot{match: m + 1, side: 1}
+		previous = append(previous, m)
+	}
+	round := 1
+	for r := 1; r < rounds; r++ {
+		round++
+		dropIn := winners[r]
+		current := []int{}
+		for i, p := range previous {
+			m := t.add(Match{Bracket: Losers, Round: round})
+			t.matches[p].winnerTo = slot{match: m + 1, side: 0}
+			// losers drop in reversed so players do not meet again straight away
+			t.matches[dropIn[len(dropIn)-1-i]].loserTo = slot{match: m + 1, side: 1}
+			current = append(current, m)
+		}
+		previous = current
+		if len(previous) == 1 {
+			break
+		}
+		round++
+		current = []int{}
+		for i := 0; i < len(previous); i += 2 {
+			m := t.add(Match{Bracket: Losers, Round: round})
+			t.matches[previous[i]].winnerTo = slot{match: m + 1, side: 0}
+			t.matches[previous[i+1]].winnerTo = slot{match: m + 1, side: 1}
+			current = append(current, m)
+		}
+		previous = current
+	}
+
+	final := t.addGrandFinal()
+	t.matches[winners[rounds-1][0]].winnerTo = slot{match: final + 1, side: 0}
+	t.matches[previous[0]].winnerTo = slot{match: final + 1, side: 1}
+}
+
+// addGrandFinal adds the grand final between the winners of both brackets and its reset,
+// played only when the losers bracket player wins the grand final since both players then
+// lost once. The winners bracket player plays on side 0
+func (t *Tournament) addGrandFinal() int {
+	final := t.add(Match{Bracket: GrandFinal, Round: 1})
+	reset := t.add(Match{Bracket: GrandFinal, Round: 2})
+	t.matches[final].resetTo = reset + 1
+	return final
+}
+
+// decideReset fills the reset of a grand final won by the losers bracket player,
+// the reset is decided without a winner when the winners bracket player won
+func (t *Tournament) decideReset(final Match) {
+	if final.resetTo == 0 {
+		return
+	}
+	if final.Players[1] == "" || final.Winner == final.Players[0] {
+		t.decide(final.resetTo-1, "", "")
+		return
+	}
+	t.fill(slot{match: final.resetTo, side: 0}, final.Players[0])
+	t.fill(slot{match: final.resetTo, side: 1}, final.Players[1])
+}
+
+// buildRoundRobin schedules every pairing with the circle method, one round at a time.
+// With an odd number of players one player sits out each round
+func (t *Tournament) buildRoundRobin() {
+	players := make([]string, 0, len(t.participants)+1)
+	for _, p := range t.participants {
+		players = append(players, p.ID)
+	}
+	if len(players)%2 == 1 {
+		players = append(players, "")
+	}
+	n := len(players)
+	t.rounds, t.round = n-1, 1
+	for r := 1; r <= t.rounds; r++ {
+		for i := range n / 2 {
+			a, b := players[i], players[n-1-i]
+			if a == "" || b == "" {
+				continue
+			}
+			t.add(Match{Round: r, Players: [2]string{a, b}, filled: [2]bool{true, true}})
+		}
+		// keep the first player in place and rotate the others
+		last := players[n-1]
+		copy(players[2:], players[1:n-1])
+		players[1] = last
+	}
+}
+
+// startSwiss plays enough rounds to leave a single unbeaten player
+func (t *Tournament) startSwiss() {
+	t.rounds, t.round = 0, 1
+	for n := 1; n < len(t.participants); n *= 2 {
+		t.rounds++
+	}
+	t.pairSwiss()
+}
+
+// pairSwiss pairs players with the same record for the current round avoiding rematches,
+// with an odd number of players the lowest ranked player without a bye gets one
+func (t *Tournament) pairSwiss() {
+	played := map[[2]string]bool{}
+	byes := map[string]bool{}
+	for _, m := range t.matches {
+		if m.Bye {
+			byes[m.Winner] = true
+			continue
+		}
+		played[[2]string{m.Players[0], m.Players[1]}] = true
+		played[[2]string{m.Players[1], m.Players[0]}] = true
+	}
+
+	standings := t.Standings()
+	unpaired := make([]string, 0, len(standings))
+	for _, s := range standings {
+		unpaired = append(unpaired, s.Participant.ID)
+	}
+	if len(unpaired)%2 == 1 {
+		bye := len(unpaired) - 1
+		for i := len(unpaired) - 1; i >= 0; i-- {
+			if !byes[unpaired[i]] {
+				bye = i
+				break
+			}
+		}
+		t.add(Match{Round: t.round, Players: [2]string{unpaired[bye], ""}, filled: [2]bool{true, true}})
+		unpaired = append(unpaired[:bye], unpaired[bye+1:]...)
+	}
+	pairs, ok := pairWithoutRematch(unpaired, played)
+	if !ok {
+		// every pairing has a rematch, pair neighbours in the standings
+		pairs = pairs[:0]
+		for i := 0; i < len(unpaired); i += 2 {
+			pairs = append(pairs, [2]string{unpaired[i], unpaired[i+1]})
+		}
+	}
+	for _, pair := range pairs {
+		t.add(Match{Round: t.round, Players: pair, filled: [2]bool{true, true}})
+	}
+}
+
+// pairWithoutRematch pairs each player with the closest player in the standings they
+// did not play yet, backtracking when the remaining players can not be paired
+func pairWithoutRematch(unpaired []string, played map[[2]string]bool) ([][2]string, bool) {
+	if len(unpaired) == 0 {
+		return [][2]string{}, true
+	}
+	a := unpaired[0]
+	for i := 1; i < len(unpaired); i++ {
+		if played[[2]string{a, unpaired[i]}] {
+			continue
+		}
+		rest := make([]string, 0, len(unpaired)-2)
+		rest = append(rest, unpaired[1:i]...)
+		rest = append(rest, unpaired[i+1:]...)
+		if pairs, ok := pairWithoutRematch(rest, played); ok {
+			return append([][2]string{{a, unpaired[i]}}, pairs...), true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,100 @@
+package tournament
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Render draws the tournament as plain text meant for a code block
+func (t *Tournament) Render() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s | %s | %s\n", t.name, t.format, t.status)
+	if t.status == Registering {
+		fmt.Fprintf(&b, "\nPlayers (%d", len(t.participants))
+		if t.maxPlayers > 0 {
+			fmt.Fprintf(&b, "/%d", t.maxPlayers)
+		}
+		b.WriteString(")\n")
+		for _, p := range t.participants {
+			fmt.Fprintf(&b, "  %s\n", p.Name)
+		}
+		return b.String()
+	}
+
+	width := 4
+	for _, p := range t.participants {
+		width = max(width, len(p.Name)+len(fmt.Sprint(p.Seed))+3)
+	}
+	var bracket Bracket
+	round := -1
+	for _, m := range t.matches {
+		if m.Bracket == GrandFinal && m.Round > 1 && m.Done && m.Winner == "" {
+			// the grand final needed no reset
+			continue
+		}
+		if m.Bracket != bracket || m.Round != round {
+			bracket, round = m.Bracket, m.Round
+			b.WriteString("\n")
+			switch bracket {
+			case "":
+				fmt.Fprintf(&b, "Round %d\n", round)
+			case GrandFinal:
+				if round > 1 {
+					b.WriteString("Grand final reset\n")
+				} else {
+					b.WriteString("Grand final\n")
+				}
+			default:
+				fmt.Fprintf(&b, "%s round %d\n", strings.ToUpper(string(bracket[:1]))+string(bracket[1:]), round)
+			}
+		}
+		if m.Done && m.Winner == "" {
+			continue
+		}
+		line := fmt.Sprintf("  %-4s %-*s vs %-*s", m.ID, width, t.side(m, 0), width, t.side(m, 1))
+		switch {
+		case m.Done:
+			line += " -> " + t.label(m.Winner)
+		case m.playable():
+			line += " .."
+		}
+		b.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+
+	if t.rounds > 0 {
+		fmt.Fprintf(&b, "\nStandings, %d of %d rounds played\n", t.completedRounds(), t.rounds)
+		for i, s := range t.Standings() {
+			fmt.Fprintf(&b, "  %2d. %-*s %d pts (%d-%d)\n", i+1, width, t.label(s.Participant.ID), s.Points(), s.Wins, s.Losses)
+		}
+	}
+	if t.status == Finished {
+		fmt.Fprintf(&b, "\nChampion: %s\n", t.label(t.champion))
+	}
+	return b.String()
+}
+
+func (t *Tournament) completedRounds() int {
+	if t.status == Finished {
+		return t.rounds
+	}
+	return t.round - 1
+}
+
+func (t *Tournament) side(m Match, side int) string {
+	switch {
+	case m.Players[side] != "":
+		return t.label(m.Players[side])
+	case m.filled[side]:
+		return "bye"
+	default:
+		return "TBD"
+	}
+}
+
+func (t *Tournament) label(userID string) string {
+	p, ok := t.Participant(userID)
+	if !ok {
+		return userID
+	}
+	return fmt.Sprintf("%s (%d)", p.Name, p.Seed)
+}
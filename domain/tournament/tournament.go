@@ -0,0 +1,524 @@
+// tournament package holds the tournament entity
+//
+// A tournament seeds its participants into a bracket and advances it as the
+// results of its matches are reported. Every match is played as a challenge
+// between the two players the bracket paired
+package tournament
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain/challenge"
+)
+
+// Tournament Errors
+var (
+	ErrInvalidTournament  = errors.New("tournament must have an id and a name")
+	ErrUnknownFormat      = errors.New("unknown tournament format")
+	ErrUnknownSeeding     = errors.New("unknown tournament seeding")
+	ErrNotHost            = errors.New("only the host can do this")
+	ErrNotRegistering     = errors.New("tournament is not open for registration")
+	ErrNotRunning         = errors.New("tournament is not running")
+	ErrAlreadyJoined      = errors.New("player already joined the tournament")
+	ErrTournamentFull     = errors.New("tournament is full")
+	ErrNotEnoughPlayers   = errors.New("a tournament needs at least two players")
+	ErrUnknownMatch       = errors.New("match is not part of the tournament")
+	ErrMatchNotPlayable   = errors.New("match can not be played")
+	ErrNotInMatch         = errors.New("player is not part of the match")
+	ErrInvalidParticipant = errors.New("participant must have an id")
+)
+
+// Format is how participants are paired
+type Format string
+
+// Tournament Formats
+const (
+	SingleElimination Format = "single-elimination"
+	DoubleElimination Format = "double-elimination"
+	RoundRobin        Format = "round-robin"
+	Swiss             Format = "swiss"
+)
+
+// Formats lists every format
+var Formats = []Format{SingleElimination, DoubleElimination, RoundRobin, Swiss}
+
+// Seeding is how participants are ordered when the tournament starts
+type Seeding string
+
+// Tournament Seedings
+const (
+	SeedRandom Seeding = "random"
+	SeedRating Seeding = "rating"
+)
+
+// Status is the lifecycle state of a tournament
+//
+//	Registering --Start--> Running --last match--> Finished
+//	Registering | Running --Cancel--> Cancelled
+type Status int
+
+const (
+	Registering Status = iota
+	Running
+	Finished
+	Cancelled
+)
+
+func (s Status) String() string {
+	switch s {
+	case Registering:
+		return "registering"
+	case Running:
+		return "running"
+	case Finished:
+		return "finished"
+	case Cancelled:
+		return "cancelled"
+	default:
+		return "unknown"
+	}
+}
+
+// Participant is a player who joined the tournament
+type Participant struct {
+	ID     string
+	Name   string
+	Seed   int // 1 is the best seed, set when the tournament starts
+	Rating int
+}
+
+// Tournament is a competition between the players who joined it
+type Tournament struct {
+	id           string
+	scope        challenge.Scope
+	name         string
+	host         string
+	format       Format
+	seeding      Seeding
+	maxPlayers   int
+	status       Status
+	participants []Participant
+	matches      []Match
+	round        int // round being played, only used by round robin and swiss
+	rounds       int
+	champion     string
+	createdAt    time.Time
+}
+
+// New creates a tournament open for registration, a maxPlayers of zero means no limit
+func New(id string, scope challenge.Scope, name, host string, format Format, seeding Seeding, maxPlayers int) (*Tournament, error) {
+	if id == "" || name == "" {
+		return nil, ErrInvalidTournament
+	}
+	switch format {
+	case SingleElimination, DoubleElimination, RoundRobin, Swiss:
+	default:
+		return nil, ErrUnknownFormat
+	}
+	switch seeding {
+	case SeedRandom, SeedRating:
+	default:
+		return nil, ErrUnknownSeeding
+	}
+	return &Tournament{
+		id:         id,
+		scope:      scope,
+		name:       name,
+		host:       host,
+		format:     format,
+		seeding:    seeding,
+		maxPlayers: maxPlayers,
+		createdAt:  time.Now(),
+	}, nil
+}
+
+// ID returns the id of the tournament
+func (t *Tournament) ID() string {
+	return t.id
+}
+
+// Scope returns the channel the tournament is played in
+func (t *Tournament) Scope() challenge.Scope {
+	return t.scope
+}
+
+// Name returns the name the host gave the tournament
+func (t *Tournament) Name() string {
+	return t.name
+}
+
+// Host returns the id of the player who created the tournament
+func (t *Tournament) Host() string {
+	return t.host
+}
+
+// Format returns how participants are paired
+func (t *Tournament) Format() Format {
+	return t.format
+}
+
+// Seeding returns how participants are ordered when the tournament starts
+func (t *Tournament) Seeding() Seeding {
+	return t.seeding
+}
+
+// MaxPlayers returns how many players can join, zero means no limit
+func (t *Tournament) MaxPlayers() int {
+	return t.maxPlayers
+}
+
+// Status returns the current state of the tournament
+func (t *Tournament) Status() Status {
+	return t.status
+}
+
+// CreatedAt returns when the tournament was created
+func (t *Tournament) CreatedAt() time.Time {
+	return t.createdAt
+}
+
+// Participants returns the players who joined, in seed order once the tournament started
+func (t *Tournament) Participants() []Participant {
+	return append([]Participant(nil), t.participants...)
+}
+
+// Champion returns the id of the winner of a finished tournament
+func (t *Tournament) Champion() string {
+	return t.champion
+}
+
+// Round returns the round being played and the number of rounds of a
+// round robin or swiss tournament
+func (t *Tournament) Round() (round, rounds int) {
+	return t.round, t.rounds
+}
+
+// Clone returns a deep copy of the tournament
+func (t *Tournament) Clone() *Tournament {
+	clone := *t
+	clone.participants = append([]Participant(nil), t.participants...)
+	clone.matches = append([]Match(nil), t.matches...)
+	return &clone
+}
+
+// Participant returns the participant with the given id
+func (t *Tournament) Participant(userID string) (Participant, bool) {
+	for _, p := range t.participants {
+		if p.ID == userID {
+			return p, true
+		}
+	}
+	return Participant{}, false
+}
+
+// Join registers a participant while the tournament is open for registration
+func (t *Tournament) Join(p Participant) error {
+	if p.ID == "" {
+		return ErrInvalidParticipant
+	}
+	if t.status != Registering {
+		return ErrNotRegistering
+	}
+	if _, ok := t.Participant(p.ID); ok {
+		return ErrAlreadyJoined
+	}
+	if t.maxPlayers > 0 && len(t.participants) >= t.maxPlayers {
+		return ErrTournamentFull
+	}
+	t.participants = append(t.participants, p)
+	return nil
+}
+
+// Start seeds the participants and builds the bracket, ratings are used to seed
+// by rating and rng to seed randomly and break rating ties
+func (t *Tournament) Start(actor string, ratings map[string]int, rng *rand.Rand) error {
+	if actor != t.host {
+		return ErrNotHost
+	}
+	if t.status != Registering {
+		return ErrNotRegistering
+	}
+	if len(t.participants) < 2 {
+		return ErrNotEnoughPlayers
+	}
+	rng.Shuffle(len(t.participants), func(i, j int) {
+		t.participants[i], t.participants[j] = t.participants[j], t.participants[i]
+	})
+	if t.seeding == SeedRating {
+		for i := range t.participants {
+			t.participants[i].Rating = ratings[t.participants[i].ID]
+		}
+		sort.SliceStable(t.participants, func(i, j int) bool {
+			return t.participants[i].Rating > t.participants[j].Rating
+		})
+	}
+	for i := range t.participants {
+		t.participants[i].Seed = i + 1
+	}
+
+	t.status = Running
+	switch t.format {
+	case SingleElimination:
+		t.buildElimination(false)
+	case DoubleElimination:
+		t.buildElimination(true)
+	case RoundRobin:
+		t.buildRoundRobin()
+	case Swiss:
+		t.startSwiss()
+	}
+	t.settle()
+	return nil
+}
+
+// Cancel ends the tournament before it finished
+func (t *Tournament) Cancel(actor string) error {
+	if actor != t.host {
+		return ErrNotHost
+	}
+	if t.status != Registering && t.status != Running {
+		return ErrNotRunning
+	}
+	t.status = Cancelled
+	return nil
+}
+
+// Playable returns the matches waiting for a game between their two players
+func (t *Tournament) Playable() []Match {
+	playable := []Match{}
+	if t.status != Running {
+		return playable
+	}
+	for _, m := range t.matches {
+		if m.playable() && (t.rounds == 0 || m.Round == t.round) {
+			playable = append(playable, m)
+		}
+	}
+	return playable
+}
+
+// Match returns the match with the given id
+func (t *Tournament) Match(matchID string) (Match, error) {
+	i, err := t.matchIndex(matchID)
+	if err != nil {
+		return Match{}, err
+	}
+	return t.matches[i], nil
+}
+
+// Matches returns every match of the tournament in the order they were created
+func (t *Tournament) Matches() []Match {
+	return append([]Match(nil), t.matches...)
+}
+
+// SetChallenge records the challenge being played for a match
+func (t *Tournament) SetChallenge(matchID, challengeID string) error {
+	i, err := t.matchIndex(matchID)
+	if err != nil {
+		return err
+	}
+	if t.status != Running || !t.matches[i].playable() {
+		return ErrMatchNotPlayable
+	}
+	t.matches[i].Challenge = challengeID
+	return nil
+}
+
+// Report records the winner of a match and advances the tournament
+func (t *Tournament) Report(matchID, winnerID string) error {
+	i, err := t.matchIndex(matchID)
+	if err != nil {
+		return err
+	}
+	if t.status != Running || !t.matches[i].playable() {
+		return ErrMatchNotPlayable
+	}
+	m := &t.matches[i]
+	switch winnerID {
+	case m.Players[0]:
+		t.decide(i, m.Players[0], m.Players[1])
+	case m.Players[1]:
+		t.decide(i, m.Players[1], m.Players[0])
+	default:
+		return ErrNotInMatch
+	}
+	t.settle()
+	return nil
+}
+
+// Replay records that a match is played again because its challenge expired unplayed
+func (t *Tournament) Replay(matchID string) error {
+	i, err := t.matchIndex(matchID)
+	if err != nil {
+		return err
+	}
+	if t.status != Running || !t.matches[i].playable() {
+		return ErrMatchNotPlayable
+	}
+	t.matches[i].Replays++
+	return nil
+}
+
+// Walkover decides a match that was never played for the better seed of its
+// players and advances the tournament, it returns the winner
+func (t *Tournament) Walkover(matchID string) (string, error) {
+	i, err := t.matchIndex(matchID)
+	if err != nil {
+		return "", err
+	}
+	if t.status != Running || !t.matches[i].playable() {
+		return "", ErrMatchNotPlayable
+	}
+	m := t.matches[i]
+	first, _ := t.Participant(m.Players[0])
+	second, _ := t.Participant(m.Players[1])
+	winner, loser := m.Players[0], m.Players[1]
+	if second.Seed < first.Seed {
+		winner, loser = loser, winner
+	}
+	t.decide(i, winner, loser)
+	t.settle()
+	return winner, nil
+}
+
+func (t *Tournament) matchIndex(matchID string) (int, error) {
+	for i := range t.matches {
+		if t.matches[i].ID == matchID {
+			return i, nil
+		}
+	}
+	return 0, ErrUnknownMatch
+}
+
+func (t *Tournament) add(m Match) int {
+	m.ID = fmt.Sprintf("M%d", len(t.matches)+1)
+	t.matches = append(t.matches, m)
+	return len(t.matches) - 1
+}
+
+// decide ends a match and moves its players to the matches they feed,
+// an empty loser marks the slot it feeds as a bye
+func (t *Tournament) decide(i int, winner, loser string) {
+	m := &t.matches[i]
+	m.Winner, m.Loser, m.Done = winner, loser, true
+	t.fill(m.winnerTo, winner)
+	t.fill(m.loserTo, loser)
+	t.decideReset(*m)
+}
+
+func (t *Tournament) fill(to slot, player string) {
+	if to.match == 0 {
+		return
+	}
+	m := &t.matches[to.match-1]
+	m.Players[to.side] = player
+	m.filled[to.side] = true
+}
+
+// settle decides every match that can not be played because a side is a bye
+// until none is left, then moves the tournament to its next round or finishes it
+func (t *Tournament) settle() {
+	for changed := true; changed; {
+		changed = false
+		for i := range t.matches {
+			m := &t.matches[i]
+			if m.Done || !m.filled[0] || !m.filled[1] {
+				continue
+			}
+			switch {
+			case m.Players[0] == "" && m.Players[1] == "":
+				t.decide(i, "", "")
+			case m.Players[1] == "":
+				m.Bye = true
+				t.decide(i, m.Players[0], "")
+			case m.Players[0] == "":
+				m.Bye = true
+				t.decide(i, m.Players[1], "")
+			default:
+				continue
+			}
+			changed = true
+		}
+	}
+
+	if t.rounds == 0 {
+		if final := t.final(); final.Done {
+			t.finish(final.Winner)
+		}
+		return
+	}
+	for _, m := range t.matches {
+		if m.Round == t.round && !m.Done {
+			return
+		}
+	}
+	if t.round == t.rounds {
+		t.finish(t.Standings()[0].Participant.ID)
+		return
+	}
+	t.round++
+	if t.format == Swiss {
+		t.pairSwiss()
+		t.settle()
+	}
+}
+
+// final returns the match the champion of an elimination tournament wins, the last
+// match unless it is the reset of a grand final that did not need one
+func (t *Tournament) final() Match {
+	last := t.matches[len(t.matches)-1]
+	if last.Bracket == GrandFinal && last.Round > 1 && last.Done && last.Winner == "" {
+		return t.matches[len(t.matches)-2]
+	}
+	return last
+}
+
+func (t *Tournament) finish(champion string) {
+	t.champion = champion
+	t.status = Finished
+}
+
+// Standing is the record of a participant
+type Standing struct {
+	Participant Participant
+	Wins        int
+	Losses      int
+	Byes        int
+}
+
+// Points are the wins of the participant, byes count as wins
+func (s Standing) Points() int {
+	return s.Wins + s.Byes
+}
+
+// Standings returns the record of every participant ordered by points then seed
+func (t *Tournament) Standings() []Standing {
+	standings := make([]Standing, len(t.participants))
+	index := map[string]int{}
+	for i, p := range t.participants {
+		standings[i].Participant = p
+		index[p.ID] = i
+	}
+	for _, m := range t.matches {
+		if !m.Done || m.Winner == "" {
+			continue
+		}
+		if m.Bye {
+			standings[index[m.Winner]].Byes++
+			continue
+		}
+		standings[index[m.Winner]].Wins++
+		standings[index[m.Loser]].Losses++
+	}
+	sort.SliceStable(standings, func(i, j int) bool {
+		if standings[i].Points() != standings[j].Points() {
+			return standings[i].Points() > standings[j].Points()
+		}
+		return standings[i].Participant.Seed < standings[j].Participant.Seed
+	})
+	return standings
+}
@@ -0,0 +1,256 @@
+package tournament
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTournament(t *testing.T, format Format, seeding Seeding, players int) *Tournament {
+	tournament, err := New("t1", challenge.Scope{GuildID: "g1", ChannelID: "c1"}, "cup", "host", format, seeding, 0)
+	require.NoError(t, err)
+	for i := 1; i <= players; i++ {
+		require.NoError(t, tournament.Join(Participant{ID: fmt.Sprintf("p%d", i), Name: fmt.Sprintf("player%d", i)}))
+	}
+	return tournament
+}
+
+// play reports every playable match until the tournament finishes, the better seed always wins
+func play(t *testing.T, tournament *Tournament) {
+	for range 1000 {
+		if tournament.Status() == Finished {
+			return
+		}
+		playable := tournament.Playable()
+		require.NotEmpty(t, playable)
+		for _, m := range playable {
+			a, _ := tournament.Participant(m.Players[0])
+			b, _ := tournament.Participant(m.Players[1])
+			winner := a
+			if b.Seed < a.Seed {
+				winner = b
+			}
+			require.NoError(t, tournament.SetChallenge(m.ID, "c-"+m.ID))
+			require.NoError(t, tournament.Report(m.ID, winner.ID))
+		}
+	}
+	t.Fatal("tournament did not finish")
+}
+
+func seedOf(t *testing.T, tournament *Tournament, userID string) int {
+	p, ok := tournament.Participant(userID)
+	require.True(t, ok)
+	return p.Seed
+}
+
+func TestFormats(t *testing.T) {
+	for _, format := range Formats {
+		for players := 2; players <= 17; players++ {
+			t.Run(fmt.Sprintf("%s %d players", format, players), func(t *testing.T) {
+				tournament := newTestTournament(t, format, SeedRandom, players)
+				require.NoError(t, tournament.Start("host", nil, rand.New(rand.NewSource(int64(players)))))
+				play(t, tournament)
+				require.Equal(t, 1, seedOf(t, tournament, tournament.Champion()))
+
+				games := map[string]int{}
+				pairs := map[[2]string]int{}
+				for _, m := range tournament.Matches() {
+					require.True(t, m.Done)
+					if m.Bye || m.Winner == "" {
+						continue
+					}
+					games[m.Players[0]]++
+					games[m.Players[1]]++
+					pairs[[2]string{min(m.Players[0], m.Players[1]), max(m.Players[0], m.Players[1])}]++
+				}
+				switch format {
+				case RoundRobin:
+					require.Len(t, pairs, players*(players-1)/2)
+					for _, n := range games {
+						require.Equal(t, players-1, n)
+					}
+				case Swiss:
+					for _, n := range pairs {
+						require.Equal(t, 1, n)
+					}
+				case SingleElimination:
+					require.Len(t, pairs, players-1)
+				case DoubleElimination:
+					losses := map[string]int{}
+					for _, m := range tournament.Matches() {
+						if !m.Bye && m.Loser != "" {
+							losses[m.Loser]++
+						}
+					}
+					require.Len(t, losses, players-1)
+					for _, n := range losses {
+						require.LessOrEqual(t, n, 2)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestSingleEliminationByes(t *testing.T) {
+	tournament := newTestTournament(t, SingleElimination, SeedRandom, 5)
+	require.NoError(t, tournament.Start("host", nil, rand.New(rand.NewSource(1))))
+
+	byes := []int{}
+	for _, m := range tournament.Matches() {
+		if m.Bye {
+			byes = append(byes, seedOf(t, tournament, m.Winner))
+		}
+	}
+	require.Equal(t, []int{1, 2, 3}, byes)
+	// the byes of seeds 2 and 3 already meet in the second round
+	playable := tournament.Playable()
+	require.Len(t, playable, 2)
+	require.ElementsMatch(t, []int{4, 5}, []int{seedOf(t, tournament, playable[0].Players[0]), seedOf(t, tournament, playable[0].Players[1])})
+	require.ElementsMatch(t, []int{2, 3}, []int{seedOf(t, tournament, playable[1].Players[0]), seedOf(t, tournament, playable[1].Players[1])})
+}
+
+func TestDoubleEliminationGrandFinalReset(t *testing.T) {
+	for _, resetWinner := range []int{0, 1} {
+		t.Run(fmt.Sprintf("reset won by side %d", resetWinner), func(t *testing.T) {
+			tournament := newTestTournament(t, DoubleElimination, SeedRating, 4)
+			ratings := map[string]int{"p1": 1300, "p2": 1200, "p3": 1100, "p4": 1000}
+			require.NoError(t, tournament.Start("host", ratings, rand.New(rand.NewSource(1))))
+
+			// the better seed wins until the grand final
+			var final Match
+			for final.ID == "" {
+				playable := tournament.Playable()
+				require.NotEmpty(t, playable)
+				for _, m := range playable {
+					if m.Bracket == GrandFinal {
+						final = m
+						break
+					}
+					winner := m.Players[0]
+					if seedOf(t, tournament, m.Players[1]) < seedOf(t, tournament, winner) {
+						winner = m.Players[1]
+					}
+					require.NoError(t, tournament.Report(m.ID, winner))
+				}
+			}
+			require.Equal(t, [2]string{"p1", "p2"}, final.Players)
+
+			// the losers bracket player wins, both players lost once so the final is reset
+			require.NoError(t, tournament.Report(final.ID, "p2"))
+			require.Equal(t, Running, tournament.Status())
+			playable := tournament.Playable()
+			require.Len(t, playable, 1)
+			reset := playable[0]
+			require.Equal(t, GrandFinal, reset.Bracket)
+			require.Equal(t, 2, reset.Round)
+			require.Equal(t, [2]string{"p1", "p2"}, reset.Players)
+			require.Contains(t, tournament.Render(), "Grand final reset")
+
+			require.NoError(t, tournament.Report(reset.ID, reset.Players[resetWinner]))
+			require.Equal(t, Finished, tournament.Status())
+			require.Equal(t, reset.Players[resetWinner], tournament.Champion())
+		})
+	}
+}
+
+func TestDoubleEliminationNoResetWhenWinnersPlayerWins(t *testing.T) {
+	tournament := newTestTournament(t, DoubleElimination, SeedRandom, 4)
+	require.NoError(t, tournament.Start("host", nil, rand.New(rand.NewSource(1))))
+	play(t, tournament)
+
+	matches := tournament.Matches()
+	reset := matches[len(matches)-1]
+	require.Equal(t, GrandFinal, reset.Bracket)
+	require.True(t, reset.Done)
+	require.Empty(t, reset.Winner)
+	require.Equal(t, matches[len(matches)-2].Winner, tournament.Champion())
+	require.NotContains(t, tournament.Render(), "Grand final reset")
+}
+
+func TestWalkoverGoesToBetterSeed(t *testing.T) {
+	tournament := newTestTournament(t, SingleElimination, SeedRandom, 4)
+	require.NoError(t, tournament.Start("host", nil, rand.New(rand.NewSource(1))))
+	for tournament.Status() != Finished {
+		m := tournament.Playable()[0]
+		require.NoError(t, tournament.Replay(m.ID))
+		replayed, err := tournament.Match(m.ID)
+		require.NoError(t, err)
+		require.Equal(t, 1, replayed.Replays)
+
+		winner, err := tournament.Walkover(m.ID)
+		require.NoError(t, err)
+		require.Equal(t, min(seedOf(t, tournament, m.Players[0]), seedOf(t, tournament, m.Players[1])), seedOf(t, tournament, winner))
+		_, err = tournament.Walkover(m.ID)
+		require.ErrorIs(t, err, ErrMatchNotPlayable)
+	}
+	require.Equal(t, 1, seedOf(t, tournament, tournament.Champion()))
+}
+
+func TestSeedByRating(t *testing.T) {
+	tournament := newTestTournament(t, SingleElimination, SeedRating, 4)
+	ratings := map[string]int{"p1": 900, "p2": 1200, "p3": 1000, "p4": 1100}
+	require.NoError(t, tournament.Start("host", ratings, rand.New(rand.NewSource(1))))
+	seeds := []string{}
+	for _, p := range tournament.Participants() {
+		seeds = append(seeds, p.ID)
+	}
+	require.Equal(t, []string{"p2", "p4", "p3", "p1"}, seeds)
+}
+
+func TestTournamentErrors(t *testing.T) {
+	_, err := New("t1", challenge.Scope{}, "cup", "host", "ladder", SeedRandom, 0)
+	require.ErrorIs(t, err, ErrUnknownFormat)
+
+	tournament, err := New("t1", challenge.Scope{}, "cup", "host", SingleElimination, SeedRandom, 2)
+	require.NoError(t, err)
+	require.ErrorIs(t, tournament.Start("host", nil, rand.New(rand.NewSource(1))), ErrNotEnoughPlayers)
+	require.NoError(t, tournament.Join(Participant{ID: "p1"}))
+	require.ErrorIs(t, tournament.Join(Participant{ID: "p1"}), ErrAlreadyJoined)
+	require.NoError(t, tournament.Join(Participant{ID: "p2"}))
+	require.ErrorIs(t, tournament.Join(Participant{ID: "p3"}), ErrTournamentFull)
+	require.ErrorIs(t, tournament.Start("p1", nil, rand.New(rand.NewSource(1))), ErrNotHost)
+	require.NoError(t, tournament.Start("host", nil, rand.New(rand.NewSource(1))))
+	require.ErrorIs(t, tournament.Join(Participant{ID: "p3"}), ErrNotRegistering)
+
+	m := tournament.Playable()[0]
+	require.ErrorIs(t, tournament.Report(m.ID, "p3"), ErrNotInMatch)
+	require.ErrorIs(t, tournament.Report("M9", "p1"), ErrUnknownMatch)
+	require.NoError(t, tournament.Report(m.ID, "p1"))
+	require.ErrorIs(t, tournament.Report(m.ID, "p1"), ErrMatchNotPlayable)
+	require.Equal(t, Finished, tournament.Status())
+	require.Equal(t, "p1", tournament.Champion())
+	require.ErrorIs(t, tournament.Cancel("host"), ErrNotRunning)
+}
+
+func TestCloneDoesNotShareMatches(t *testing.T) {
+	tournament := newTestTournament(t, RoundRobin, SeedRandom, 4)
+	require.NoError(t, tournament.Start("host", nil, rand.New(rand.NewSource(1))))
+	clone := tournament.Clone()
+	m := clone.Playable()[0]
+	require.NoError(t, clone.Report(m.ID, m.Players[0]))
+	original, err := tournament.Match(m.ID)
+	require.NoError(t, err)
+	require.False(t, original.Done)
+}
+
+func TestRender(t *testing.T) {
+	tournament := newTestTournament(t, SingleElimination, SeedRating, 3)
+	ratings := map[string]int{"p1": 1100, "p2": 1000, "p3": 900}
+	require.NoError(t, tournament.Start("host", ratings, rand.New(rand.NewSource(1))))
+	play(t, tournament)
+	require.Equal(t, `cup | single-elimination | finished
+
+Winners round 1
+  M1   player1 (1) vs bye         -> player1 (1)
+  M2   player2 (2) vs player3 (3) -> player2 (2)
+
+Winners round 2
+  M3   player1 (1) vs player2 (2) -> player1 (1)
+
+Champion: player1 (1)
+`, tournament.Render())
+}
@@ -0,0 +1,140 @@
+// events package is an in process publish/subscribe bus for domain events
+// and the sinks that forward them outside of the bot
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain/challenge"
+)
+
+// Event is the envelope published on the bus and delivered to sinks
+type Event struct {
+	ID        string             `json:"id"`
+	Type      string             `json:"type"`
+	At        time.Time          `json:"at"`
+	Actor     string             `json:"actor"`
+	Challenge *ChallengeSnapshot `json:"challenge,omitempty"`
+}
+
+// ChallengeSnapshot is the public view of a challenge at the time of an event
+type ChallengeSnapshot struct {
+	ID           string    `json:"id"`
+	GuildID      string    `json:"guild_id,omitempty"`
+	ChannelID    string    `json:"channel_id"`
+	Status       string    `json:"status"`
+	ChallengerID string    `json:"challenger_id"`
+	OpponentID   string    `json:"opponent_id,omitempty"`
+	BotGame      bool      `json:"bot_game"`
+	TournamentID string    `json:"tournament_id,omitempty"`
+	MatchID      string    `json:"match_id,omitempty"`
+	Wager        int       `json:"wager,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	Result       *Result   `json:"result,omitempty"`
+}
+
+// Result is the outcome of a finished challenge, choices are only revealed once it is over
+type Result struct {
+	WinnerID     string `json:"winner_id"`
+	LooserID     string `json:"looser_id"`
+	WinnerChoice string `json:"winner_choice,omitempty"`
+	LooserChoice string `json:"looser_choice,omitempty"`
+	Draw         bool   `json:"draw"`
+	Forfeit      bool   `json:"forfeit"`
+}
+
+// ChallengeEventType returns the bus event type of a challenge event
+func ChallengeEventType(t challenge.EventType) string {
+	return "challenge." + string(t)
+}
+
+// FromChallenge builds a bus event from a challenge event and the challenge it happened to
+func FromChallenge(e challenge.Event, c *challenge.Challenge) Event {
+	id, _ := c.GetChallengeID()
+	snapshot := &ChallengeSnapshot{
+		ID:           id,
+		GuildID:      c.Scope().GuildID,
+		ChannelID:    c.Scope().ChannelID,
+		Status:       c.Status().String(),
+		ChallengerID: c.Challenger().ID,
+		OpponentID:   c.ClaimedBy(),
+		BotGame:      c.BotGame(),
+		Wager:        c.Wager(),
+	}
+	if history := c.History(); len(history) > 0 {
+		snapshot.CreatedAt = history[0].At
+	}
+	snapshot.TournamentID, snapshot.MatchID = c.Tournament()
+	if result := c.Result(); result != nil && c.Status().Terminal() {
+		snapshot.Result = &Result{
+			WinnerID:     result.Winner.ID,
+			LooserID:     result.Looser.ID,
+			WinnerChoice: string(result.Winner.Choice),
+			LooserChoice: string(result.Looser.Choice),
+			Draw:         result.OutcomeDraw,
+			Forfeit:      result.Forfeit,
+		}
+	}
+	return Event{
+		ID:        fmt.Sprintf("%s:%d:%s", id, e.At.UnixNano(), e.Type),
+		Type:      ChallengeEventType(e.Type),
+		At:        e.At,
+		Actor:     e.Actor,
+		Challenge: snapshot,
+	}
+}
+
+// Handler receives published events, it is called synchronously by Publish so
+// slow work must be handed off to another goroutine
+type Handler func(ctx context.Context, e Event)
+
+// Bus fans out published events to every subscriber
+type Bus struct {
+	mu     sync.RWMutex
+	nextID int
+	subs   []subscription
+}
+
+type subscription struct {
+	id      int
+	handler Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe registers h for every published event and returns a function removing it
+func (b *Bus) Subscribe(h Handler) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id := b.nextID
+	b.nextID++
+	b.subs = append(b.subs, subscription{id: id, handler: h})
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		for i, sub := range b.subs {
+			if sub.id == id {
+				b.subs = append(b.subs[:i:i], b.subs[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Publish delivers e to every subscriber in the order they subscribed
+func (b *Bus) Publish(ctx context.Context, e Event) {
+	b.mu.RLock()
+	handlers := make([]Handler, 0, len(b.subs))
+	for _, sub := range b.subs {
+		handlers = append(handlers, sub.handler)
+	}
+	b.mu.RUnlock()
+	for _, h := range handlers {
+		h(ctx, e)
+	}
+}
@@ -0,0 +1,203 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/stretchr/testify/require"
+)
+
+func resolvedChallenge(t *testing.T) *challenge.Challenge {
+	c, err := challenge.NewChallenge("1", challenge.Scope{GuildID: "g1", ChannelID: "c1"}, &domain.Player{ID: "p1", Choice: domain.Rock})
+	require.NoError(t, err)
+	require.NoError(t, c.Accept("p2", time.Now()))
+	require.NoError(t, c.MakeChoice(&domain.Player{ID: "p2", Choice: domain.Scissor}, time.Now()))
+	return c
+}
+
+func TestBus(t *testing.T) {
+	bus := NewBus()
+	var received []string
+	unsubscribe := bus.Subscribe(func(ctx context.Context, e Event) { received = append(received, "first:"+e.Type) })
+	bus.Subscribe(func(ctx context.Context, e Event) { received = append(received, "second:"+e.Type) })
+
+	c := resolvedChallenge(t)
+	history := c.History()
+	bus.Publish(context.Background(), FromChallenge(history[len(history)-1], c))
+	unsubscribe()
+	bus.Publish(context.Background(), Event{Type: "other"})
+
+	require.Equal(t, []string{"first:challenge.resolved", "second:challenge.resolved", "second:other"}, received)
+}
+
+func TestFromChallenge(t *testing.T) {
+	c := resolvedChallenge(t)
+	history := c.History()
+
+	accepted := FromChallenge(history[1], c)
+	require.Equal(t, "challenge.accepted", accepted.Type)
+	require.Equal(t, "p2", accepted.Actor)
+
+	resolved := FromChallenge(history[len(history)-1], c)
+	require.Equal(t, "resolved", resolved.Challenge.Status)
+	require.Equal(t, &Result{WinnerID: "p1", LooserID: "p2", WinnerChoice: "rock", LooserChoice: "scissors"}, resolved.Challenge.Result)
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	secret := "shared-secret"
+	var attempts atomic.Int32
+	var mu sync.Mutex
+	var payload Event
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !VerifySignature(secret, r.Header.Get(TimestampHeader), body, r.Header.Get(SignatureHeader)) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		// fail the first attempt to exercise retries
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		json.Unmarshal(body, &payload)
+		require.Equal(t, payload.Type, r.Header.Get(EventHeader))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	sink := NewWebhookSink(WebhookConfig{
+		URLs:    []string{receiver.URL},
+		Secret:  secret,
+		Backoff: time.Millisecond,
+	})
+	c := resolvedChallenge(t)
+	history := c.History()
+	sink.Handle(context.Background(), FromChallenge(history[len(history)-1], c))
+	require.NoError(t, sink.Close(context.Background()))
+
+	require.Equal(t, int32(2), attempts.Load())
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, "challenge.resolved", payload.Type)
+	require.Equal(t, "p1", payload.Challenge.Result.WinnerID)
+}
+
+func TestWebhookDeadLetter(t *testing.T) {
+	testCases := []struct {
+		name             string
+		status           int
+		expectedAttempts int
+	}{
+		{name: "retries exhausted", status: http.StatusInternalServerError, expectedAttempts: 3},
+		{name: "permanent failure", status: http.StatusBadRequest, expectedAttempts: 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var attempts atomic.Int32
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				attempts.Add(1)
+				w.WriteHeader(tc.status)
+			}))
+			defer receiver.Close()
+
+			deadLetters := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+			sink := NewWebhookSink(WebhookConfig{
+				URLs:           []string{receiver.URL},
+				MaxAttempts:    3,
+				Backoff:        time.Millisecond,
+				DeadLetterPath: deadLetters,
+			})
+			sink.Handle(context.Background(), Event{ID: "evt-1", Type: "challenge.created"})
+			require.NoError(t, sink.Close(context.Background()))
+
+			require.Equal(t, int32(tc.expectedAttempts), attempts.Load())
+			file, err := os.Open(deadLetters)
+			require.NoError(t, err)
+			defer file.Close()
+			scanner := bufio.NewScanner(file)
+			require.True(t, scanner.Scan())
+			var dl DeadLetter
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &dl))
+			require.Equal(t, "evt-1", dl.Event.ID)
+			require.Equal(t, receiver.URL, dl.URL)
+			require.Equal(t, tc.expectedAttempts, dl.Attempts)
+			require.False(t, scanner.Scan())
+		})
+	}
+}
+
+func TestWebhookCloseDeadline(t *testing.T) {
+	testCases := []struct {
+		name string
+		hang bool // the receiver never answers, otherwise it asks for a retry
+	}{
+		{name: "hanging receiver", hang: true},
+		{name: "retry backoff"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			release := make(chan struct{})
+			receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tc.hang {
+					<-release
+					return
+				}
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer receiver.Close()
+			defer close(release)
+
+			deadLetters := filepath.Join(t.TempDir(), "dead-letters.jsonl")
+			sink := NewWebhookSink(WebhookConfig{
+				URLs:           []string{receiver.URL},
+				Backoff:        time.Hour,
+				Workers:        1,
+				DeadLetterPath: deadLetters,
+			})
+			sink.Handle(context.Background(), Event{ID: "evt-1", Type: "challenge.created"})
+			sink.Handle(context.Background(), Event{ID: "evt-2", Type: "challenge.created"})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			start := time.Now()
+			require.ErrorIs(t, sink.Close(ctx), context.DeadlineExceeded)
+			require.Less(t, time.Since(start), time.Second)
+
+			file, err := os.Open(deadLetters)
+			require.NoError(t, err)
+			defer file.Close()
+			attempts := map[string]int{}
+			scanner := bufio.NewScanner(file)
+			for scanner.Scan() {
+				var dl DeadLetter
+				require.NoError(t, json.Unmarshal(scanner.Bytes(), &dl))
+				attempts[dl.Event.ID] = dl.Attempts
+			}
+			// the first delivery was attempted once, the second was still queued
+			require.Equal(t, map[string]int{"evt-1": 1, "evt-2": 0}, attempts)
+		})
+	}
+}
+
+func TestSignature(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	signature := Sign("secret", "1700000000", body)
+	require.True(t, VerifySignature("secret", "1700000000", body, signature))
+	require.False(t, VerifySignature("other", "1700000000", body, signature))
+	require.False(t, VerifySignature("secret", "1700000001", body, signature))
+	require.False(t, VerifySignature("secret", "1700000000", []byte(`{"id":"2"}`), signature))
+}
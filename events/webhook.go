@@ -0,0 +1,294 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ekefan/discord-bot/logging"
+)
+
+// Webhook request headers
+const (
+	EventHeader     = "X-RPS-Event"
+	DeliveryHeader  = "X-RPS-Delivery"
+	TimestampHeader = "X-RPS-Timestamp"
+	SignatureHeader = "X-RPS-Signature"
+)
+
+var (
+	ErrWebhookQueueFull = errors.New("webhook delivery queue is full")
+	ErrWebhookClosed    = errors.New("webhook sink is closed")
+)
+
+// WebhookConfig configures outbound webhook delivery
+type WebhookConfig struct {
+	URLs        []string
+	Secret      string
+	MaxAttempts int           // delivery attempts per url before dead lettering, defaults to 5
+	Backoff     time.Duration // delay before the first retry, doubled on each attempt, defaults to 1s
+	MaxBackoff  time.Duration // defaults to 1m
+	QueueSize   int           // pending deliveries, defaults to 256
+	Workers     int           // concurrent deliveries, defaults to 2
+	// DeadLetterPath is a file deliveries that exhausted their attempts are
+	// appended to as JSON lines, they are only logged when empty
+	DeadLetterPath string
+	Client         *http.Client
+}
+
+// WebhookSink POSTs signed JSON events to the configured urls
+type WebhookSink struct {
+	config WebhookConfig
+	queue  chan delivery
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+	closed bool
+	dlMu   sync.Mutex
+	// aborted is cancelled when Close runs out of time, pending requests and
+	// retries stop and the remaining deliveries are dead lettered
+	aborted context.Context
+	abort   context.CancelFunc
+}
+
+type delivery struct {
+	ctx   context.Context
+	url   string
+	event Event
+}
+
+// DeadLetter is a delivery that could not be completed
+type DeadLetter struct {
+	URL      string    `json:"url"`
+	Event    Event     `json:"event"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	At       time.Time `json:"at"`
+}
+
+// NewWebhookSink creates a sink and starts its delivery workers
+func NewWebhookSink(config WebhookConfig) *WebhookSink {
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 5
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = time.Minute
+	}
+	if config.QueueSize <= 0 {
+		config.QueueSize = 256
+	}
+	if config.Workers <= 0 {
+		config.Workers = 2
+	}
+	if config.Client == nil {
+		config.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	s := &WebhookSink{
+		config: config,
+		queue:  make(chan delivery, config.QueueSize),
+	}
+	s.aborted, s.abort = context.WithCancel(context.Background())
+	for i := 0; i < config.Workers; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+	return s
+}
+
+// Handle queues e for delivery to every url, it is meant to be subscribed to a Bus
+func (s *WebhookSink) Handle(ctx context.Context, e Event) {
+	for _, url := range s.config.URLs {
+		if err := s.enqueue(delivery{ctx: context.WithoutCancel(ctx), url: url, event: e}); err != nil {
+			logging.FromContext(ctx).Error("could not queue webhook delivery", "url", url, "event", e.Type, logging.KeyError, err)
+			s.deadLetter(ctx, DeadLetter{URL: url, Event: e, Error: err.Error(), At: time.Now()})
+		}
+	}
+}
+
+func (s *WebhookSink) enqueue(d delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrWebhookClosed
+	}
+	select {
+	case s.queue <- d:
+		return nil
+	default:
+		return ErrWebhookQueueFull
+	}
+}
+
+// Close stops accepting events and waits for queued deliveries to finish until ctx is done,
+// the deliveries still pending then are dead lettered and the error of ctx is returned
+func (s *WebhookSink) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.abort()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (s *WebhookSink) work() {
+	defer s.wg.Done()
+	for d := range s.queue {
+		if s.aborted.Err() != nil {
+			s.deadLetter(d.ctx, DeadLetter{URL: d.url, Event: d.event, Error: ErrWebhookClosed.Error(), At: time.Now()})
+			continue
+		}
+		s.deliver(d)
+	}
+}
+
+// deliver attempts a delivery until it succeeds, fails permanently, runs out of attempts
+// or the sink is aborted
+func (s *WebhookSink) deliver(d delivery) {
+	logger := logging.FromContext(d.ctx).With("url", d.url, "event", d.event.Type, "delivery", d.event.ID)
+	body, err := json.Marshal(d.event)
+	if err != nil {
+		logger.Error("could not encode webhook payload", logging.KeyError, err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(d.ctx)
+	defer cancel()
+	stop := context.AfterFunc(s.aborted, cancel)
+	defer stop()
+
+	var lastErr error
+	attempt := 1
+	for ; attempt <= s.config.MaxAttempts; attempt++ {
+		start := time.Now()
+		status, err := s.post(ctx, d, body)
+		logger.Info("webhook delivery", "attempt", attempt, "status", status, "duration", time.Since(start), logging.KeyError, err)
+		if err == nil {
+			return
+		}
+		lastErr = err
+		if !retryable(status) {
+			break
+		}
+		if attempt < s.config.MaxAttempts && !s.wait(s.backoff(attempt)) {
+			break
+		}
+	}
+	if attempt > s.config.MaxAttempts {
+		attempt = s.config.MaxAttempts
+	}
+	logger.Error("webhook delivery failed", "attempts", attempt, logging.KeyError, lastErr)
+	s.deadLetter(d.ctx, DeadLetter{URL: d.url, Event: d.event, Attempts: attempt, Error: lastErr.Error(), At: time.Now()})
+}
+
+// wait sleeps for delay, it returns false when the sink is aborted first
+func (s *WebhookSink) wait(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-s.aborted.Done():
+		return false
+	}
+}
+
+// post sends a single signed request, the status is zero when no response was received
+func (s *WebhookSink) post(ctx context.Context, d delivery, body []byte) (int, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "DiscordBot (https://github.com/ekefan/discord-bot, 1.0.0)")
+	request.Header.Set(EventHeader, d.event.Type)
+	request.Header.Set(DeliveryHeader, d.event.ID)
+	request.Header.Set(TimestampHeader, timestamp)
+	request.Header.Set(SignatureHeader, Sign(s.config.Secret, timestamp, body))
+
+	response, err := s.config.Client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+	io.Copy(io.Discard, response.Body)
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+// retryable reports whether a delivery that ended with status may succeed later
+func retryable(status int) bool {
+	return status == 0 || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// backoff returns the exponential delay with jitter before the next attempt
+func (s *WebhookSink) backoff(attempt int) time.Duration {
+	delay := s.config.Backoff << (attempt - 1)
+	if delay <= 0 || delay > s.config.MaxBackoff {
+		delay = s.config.MaxBackoff
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay)/4 + 1))
+	return delay - delay/8 + jitter
+}
+
+func (s *WebhookSink) deadLetter(ctx context.Context, dl DeadLetter) {
+	if s.config.DeadLetterPath == "" {
+		return
+	}
+	s.dlMu.Lock()
+	defer s.dlMu.Unlock()
+	file, err := os.OpenFile(s.config.DeadLetterPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		logging.FromContext(ctx).Error("could not open webhook dead letter file", logging.KeyError, err)
+		return
+	}
+	defer file.Close()
+	if err := json.NewEncoder(file).Encode(dl); err != nil {
+		logging.FromContext(ctx).Error("could not write webhook dead letter", logging.KeyError, err)
+	}
+}
+
+// Sign returns the signature header value of a webhook body,
+// an HMAC-SHA256 of the timestamp and body joined by a dot
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature reports whether signature was produced by Sign for the timestamp and body
+func VerifySignature(secret, timestamp string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
@@ -0,0 +1,172 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Intents are the groups of events the gateway sends, see
+// https://discord.com/developers/docs/events/gateway#gateway-intents
+type Intents uint64
+
+const (
+	IntentGuilds         Intents = 1 << 0
+	IntentGuildMembers   Intents = 1 << 1 // privileged
+	IntentGuildPresences Intents = 1 << 8 // privileged
+	IntentGuildMessages  Intents = 1 << 9
+	IntentDirectMessages Intents = 1 << 12
+	IntentMessageContent Intents = 1 << 15 // privileged
+)
+
+var ErrUnknownIntent = errors.New("unknown gateway intent")
+
+// intentNames are the names ParseIntents accepts
+var intentNames = map[string]Intents{
+	"guilds":          IntentGuilds,
+	"guild_members":   IntentGuildMembers,
+	"guild_presences": IntentGuildPresences,
+	"guild_messages":  IntentGuildMessages,
+	"direct_messages": IntentDirectMessages,
+	"message_content": IntentMessageContent,
+}
+
+// ParseIntents reads comma separated intent names, such as guilds,guild_members
+func ParseIntents(s string) (Intents, error) {
+	var intents Intents
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		intent, ok := intentNames[name]
+		if !ok {
+			return 0, fmt.Errorf("%w: %q", ErrUnknownIntent, name)
+		}
+		intents |= intent
+	}
+	return intents, nil
+}
+
+// Activity types
+const (
+	ActivityPlaying = 0
+	ActivityCustom  = 4
+)
+
+// Presence is the status and activities the bot shows
+type Presence struct {
+	Since      *int64     `json:"since"`
+	Activities []Activity `json:"activities"`
+	Status     string     `json:"status"`
+	AFK        bool       `json:"afk"`
+}
+
+type Activity struct {
+	Name  string `json:"name"`
+	Type  int    `json:"type"`
+	State string `json:"state,omitempty"`
+}
+
+// Playing is the online presence playing name
+func Playing(name string) Presence {
+	return Presence{
+		Activities: []Activity{{Name: name, Type: ActivityPlaying}},
+		Status:     "online",
+	}
+}
+
+// Event is a dispatch event received from the gateway, handlers switch on its
+// concrete type. Events without a type of their own are delivered as *Unknown
+type Event interface {
+	EventType() string
+}
+
+type User struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Bot      bool   `json:"bot,omitempty"`
+}
+
+// Ready is dispatched once a new session is identified
+type Ready struct {
+	SessionID        string             `json:"session_id"`
+	ResumeGatewayURL string             `json:"resume_gateway_url"`
+	User             User               `json:"user"`
+	Guilds           []UnavailableGuild `json:"guilds"`
+}
+
+type UnavailableGuild struct {
+	ID          string `json:"id"`
+	Unavailable bool   `json:"unavailable"`
+}
+
+// Resumed is dispatched once a session is resumed, the events missed are replayed before it
+type Resumed struct{}
+
+// GuildCreate is dispatched when a guild becomes available or the bot joins one
+type GuildCreate struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	MemberCount int    `json:"member_count"`
+}
+
+// GuildDelete is dispatched when a guild becomes unavailable or the bot leaves one
+type GuildDelete struct {
+	ID          string `json:"id"`
+	Unavailable bool   `json:"unavailable"`
+}
+
+// GuildMemberRemove is dispatched when a member leaves or is removed from a guild,
+// it needs the guild members intent
+type GuildMemberRemove struct {
+	GuildID string `json:"guild_id"`
+	User    User   `json:"user"`
+}
+
+// MessageCreate is dispatched for new messages, the content needs the message content intent
+type MessageCreate struct {
+	ID        string `json:"id"`
+	ChannelID string `json:"channel_id"`
+	GuildID   string `json:"guild_id,omitempty"`
+	Author    User   `json:"author"`
+	Content   string `json:"content"`
+}
+
+// Unknown is a dispatch event without a type of its own
+type Unknown struct {
+	Type string
+	Data json.RawMessage
+}
+
+func (*Ready) EventType() string             { return "READY" }
+func (*Resumed) EventType() string           { return "RESUMED" }
+func (*GuildCreate) EventType() string       { return "GUILD_CREATE" }
+func (*GuildDelete) EventType() string       { return "GUILD_DELETE" }
+func (*GuildMemberRemove) EventType() string { return "GUILD_MEMBER_REMOVE" }
+func (*MessageCreate) EventType() string     { return "MESSAGE_CREATE" }
+func (e *Unknown) EventType() string         { return e.Type }
+
+// eventTypes creates the typed events by dispatch name
+var eventTypes = map[string]func() Event{
+	"READY":               func() Event { return &Ready{} },
+	"RESUMED":             func() Event { return &Resumed{} },
+	"GUILD_CREATE":        func() Event { return &GuildCreate{} },
+	"GUILD_DELETE":        func() Event { return &GuildDelete{} },
+	"GUILD_MEMBER_REMOVE": func() Event { return &GuildMemberRemove{} },
+	"MESSAGE_CREATE":      func() Event { return &MessageCreate{} },
+}
+
+// decodeEvent decodes the data of a dispatch event into its typed event
+func decodeEvent(name string, data json.RawMessage) (Event, error) {
+	newEvent, ok := eventTypes[name]
+	if !ok {
+		return &Unknown{Type: name, Data: data}, nil
+	}
+	e := newEvent()
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, fmt.Errorf("decode %s event: %w", name, err)
+	}
+	return e, nil
+}
@@ -0,0 +1,446 @@
+// gateway package is a client of the Discord gateway, the websocket the bot sets
+// its presence through and receives events that don't come as interactions.
+//
+// The client identifies, keeps the connection alive with heartbeats and resumes its
+// session when the connection drops, dispatch events are decoded to typed events
+// and delivered to the subscribed handlers
+package gateway
+
+import (
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/ekefan/discord-bot/logging"
+)
+
+// DefaultURL is the gateway url used until a session gets its resume url
+const DefaultURL = "wss://gateway.discord.gg"
+
+// apiVersion is the gateway version the client speaks
+const apiVersion = "10"
+
+// Gateway opcodes
+const (
+	opDispatch       = 0
+	opHeartbeat      = 1
+	opIdentify       = 2
+	opPresenceUpdate = 3
+	opResume         = 6
+	opReconnect      = 7
+	opInvalidSession = 9
+	opHello          = 10
+	opHeartbeatACK   = 11
+)
+
+var (
+	ErrReconnect      = errors.New("gateway asked to reconnect")
+	ErrInvalidSession = errors.New("gateway invalidated the session")
+	ErrZombie         = errors.New("gateway stopped acknowledging heartbeats")
+)
+
+// fatalCloseCodes are the close codes reconnecting won't fix
+var fatalCloseCodes = map[int]bool{
+	4004: true, // authentication failed
+	4010: true, // invalid shard
+	4011: true, // sharding required
+	4012: true, // invalid api version
+	4013: true, // invalid intents
+	4014: true, // disallowed intents
+}
+
+// freshCloseCodes are the close codes after which the session can't be resumed
+var freshCloseCodes = map[int]bool{
+	4007: true, // invalid seq
+	4009: true, // session timed out
+}
+
+// Config configures a gateway client
+type Config struct {
+	Token   string
+	Intents Intents
+	URL     string // defaults to DefaultURL
+	// Compress turns on zlib-stream transport compression
+	Compress bool
+	// Presence is shown once identified, UpdatePresence changes it
+	Presence   *Presence
+	Backoff    time.Duration // delay before the first reconnect, doubled on each failure, defaults to 1s
+	MaxBackoff time.Duration // defaults to 1m
+}
+
+// Handler receives dispatch events, it is called synchronously by the connection
+// so slow work must be handed off to another goroutine
+type Handler func(ctx context.Context, e Event)
+
+// Client is a gateway connection that reconnects until its Run context is done
+type Client struct {
+	config Config
+
+	mu        sync.Mutex
+	conn      *conn
+	sessionID string
+	resumeURL string
+	seq       int64
+	presence  *Presence
+
+	hmu      sync.RWMutex
+	handlers []Handler
+}
+
+// payload is a message of the gateway protocol
+type payload struct {
+	Op int             `json:"op"`
+	D  json.RawMessage `json:"d"`
+	S  *int64          `json:"s,omitempty"`
+	T  string          `json:"t,omitempty"`
+}
+
+type identify struct {
+	Token      string            `json:"token"`
+	Intents    Intents           `json:"intents"`
+	Properties map[string]string `json:"properties"`
+	Presence   *Presence         `json:"presence,omitempty"`
+}
+
+type resume struct {
+	Token     string `json:"token"`
+	SessionID string `json:"session_id"`
+	Seq       int64  `json:"seq"`
+}
+
+func NewClient(config Config) *Client {
+	if config.URL == "" {
+		config.URL = DefaultURL
+	}
+	if config.Backoff <= 0 {
+		config.Backoff = time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = time.Minute
+	}
+	return &Client{config: config, presence: config.Presence}
+}
+
+// Subscribe registers h for every dispatch event
+func (c *Client) Subscribe(h Handler) {
+	c.hmu.Lock()
+	defer c.hmu.Unlock()
+	c.handlers = append(c.handlers, h)
+}
+
+func (c *Client) publish(ctx context.Context, e Event) {
+	c.hmu.RLock()
+	handlers := append([]Handler(nil), c.handlers...)
+	c.hmu.RUnlock()
+	for _, h := range handlers {
+		h(ctx, e)
+	}
+}
+
+// UpdatePresence changes the presence of the bot, it is sent right away when
+// connected and with the next identify otherwise
+func (c *Client) UpdatePresence(p Presence) error {
+	c.mu.Lock()
+	c.presence = &p
+	conn := c.conn
+	c.mu.Unlock()
+	if conn == nil {
+		return nil
+	}
+	return send(conn, opPresenceUpdate, p)
+}
+
+// Run connects to the gateway and reconnects with backoff whenever the connection is lost,
+// resuming the session when the gateway allows it. It returns once ctx is done or the
+// gateway closed the connection with a code reconnecting won't fix
+func (c *Client) Run(ctx context.Context) error {
+	logger := logging.FromContext(ctx)
+	backoff := c.config.Backoff
+	for {
+		established, err := c.session(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		var closeErr *CloseError
+		if errors.As(err, &closeErr) {
+			if fatalCloseCodes[closeErr.Code] {
+				return err
+			}
+			if freshCloseCodes[closeErr.Code] {
+				c.resetSession()
+			}
+		}
+		if established {
+			backoff = c.config.Backoff
+		}
+		delay := jitter(backoff)
+		logger.Warn("gateway connection lost, reconnecting", logging.KeyError, err, "delay", delay)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		backoff = min(2*backoff, c.config.MaxBackoff)
+	}
+}
+
+// session runs a single connection until it fails or ctx is done,
+// established is set once the session was identified or resumed
+func (c *Client) session(ctx context.Context) (established bool, err error) {
+	logger := logging.FromContext(ctx)
+	c.mu.Lock()
+	base := c.config.URL
+	if c.sessionID != "" && c.resumeURL != "" {
+		base = c.resumeURL
+	}
+	c.mu.Unlock()
+	gatewayURL, err := c.gatewayURL(base)
+	if err != nil {
+		return false, err
+	}
+	conn, err := dial(ctx, gatewayURL)
+	if err != nil {
+		return false, err
+	}
+	// any code but 1000 and 1001 keeps the session resumable
+	closeCode := 4000
+	done := make(chan struct{})
+	defer func() {
+		c.mu.Lock()
+		c.conn = nil
+		c.mu.Unlock()
+		conn.close(closeCode, "")
+		close(done)
+	}()
+	payloads := make(chan payload)
+	readErr := make(chan error, 1)
+	go c.read(conn, payloads, readErr, done)
+
+	var interval time.Duration
+	select {
+	case <-ctx.Done():
+		closeCode = 1000
+		return false, ctx.Err()
+	case err := <-readErr:
+		return false, err
+	case p := <-payloads:
+		var hello struct {
+			HeartbeatInterval int64 `json:"heartbeat_interval"`
+		}
+		if p.Op != opHello || json.Unmarshal(p.D, &hello) != nil || hello.HeartbeatInterval <= 0 {
+			return false, fmt.Errorf("%w: expected hello, got op %d", ErrProtocol, p.Op)
+		}
+		interval = time.Duration(hello.HeartbeatInterval) * time.Millisecond
+	}
+	if err := c.identifyOrResume(conn); err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	c.conn = conn
+	c.mu.Unlock()
+
+	// the first heartbeat is jittered so reconnecting clients don't beat in step
+	heartbeat := time.NewTimer(time.Duration(rand.Float64() * float64(interval)))
+	defer heartbeat.Stop()
+	acked := true
+	for {
+		select {
+		case <-ctx.Done():
+			closeCode = 1000
+			return established, ctx.Err()
+		case err := <-readErr:
+			return established, err
+		case <-heartbeat.C:
+			if !acked {
+				return established, ErrZombie
+			}
+			if err := c.heartbeat(conn); err != nil {
+				return established, err
+			}
+			acked = false
+			heartbeat.Reset(interval)
+		case p := <-payloads:
+			switch p.Op {
+			case opDispatch:
+				c.mu.Lock()
+				if p.S != nil {
+					c.seq = *p.S
+				}
+				c.mu.Unlock()
+				e, err := decodeEvent(p.T, p.D)
+				if err != nil {
+					logger.Warn("could not decode gateway event", "event", p.T, logging.KeyError, err)
+					continue
+				}
+				switch e := e.(type) {
+				case *Ready:
+					c.mu.Lock()
+					c.sessionID, c.resumeURL = e.SessionID, e.ResumeGatewayURL
+					c.mu.Unlock()
+					established = true
+				case *Resumed:
+					established = true
+				}
+				c.publish(ctx, e)
+			case opHeartbeat:
+				if err := c.heartbeat(conn); err != nil {
+					return established, err
+				}
+			case opHeartbeatACK:
+				acked = true
+			case opReconnect:
+				return established, ErrReconnect
+			case opInvalidSession:
+				var resumable bool
+				json.Unmarshal(p.D, &resumable)
+				if !resumable {
+					c.resetSession()
+				}
+				return established, ErrInvalidSession
+			}
+		}
+	}
+}
+
+// read decodes the payloads of conn until it fails
+func (c *Client) read(conn *conn, payloads chan<- payload, readErr chan<- error, done <-chan struct{}) {
+	next := c.decoder(conn)
+	for {
+		p, err := next()
+		if err != nil {
+			readErr <- err
+			return
+		}
+		select {
+		case payloads <- p:
+		case <-done:
+			return
+		}
+	}
+}
+
+// decoder returns the function reading the next payload of conn, with zlib-stream every
+// message continues the compressed stream of the connection and ends on a flush
+func (c *Client) decoder(conn *conn) func() (payload, error) {
+	if !c.config.Compress {
+		return func() (payload, error) {
+			var p payload
+			_, message, err := conn.readMessage()
+			if err != nil {
+				return p, err
+			}
+			return p, json.Unmarshal(message, &p)
+		}
+	}
+	var dec *json.Decoder
+	return func() (payload, error) {
+		var p payload
+		if dec == nil {
+			zr, err := zlib.NewReader(&messageReader{conn: conn})
+			if err != nil {
+				return p, err
+			}
+			dec = json.NewDecoder(zr)
+		}
+		return p, dec.Decode(&p)
+	}
+}
+
+// messageReader reads the messages of a connection as a single stream
+type messageReader struct {
+	conn *conn
+	buf  []byte
+}
+
+func (r *messageReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		_, message, err := r.conn.readMessage()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = message
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func (c *Client) identifyOrResume(conn *conn) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sessionID != "" {
+		return send(conn, opResume, resume{
+			Token:     c.config.Token,
+			SessionID: c.sessionID,
+			Seq:       c.seq,
+		})
+	}
+	return send(conn, opIdentify, identify{
+		Token:   c.config.Token,
+		Intents: c.config.Intents,
+		Properties: map[string]string{
+			"os":      runtime.GOOS,
+			"browser": "discord-bot",
+			"device":  "discord-bot",
+		},
+		Presence: c.presence,
+	})
+}
+
+// heartbeat sends the last sequence number received, null before any
+func (c *Client) heartbeat(conn *conn) error {
+	c.mu.Lock()
+	var seq *int64
+	if c.seq > 0 {
+		s := c.seq
+		seq = &s
+	}
+	c.mu.Unlock()
+	return send(conn, opHeartbeat, seq)
+}
+
+// resetSession forgets the session so the next connection identifies anew
+func (c *Client) resetSession() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sessionID, c.resumeURL, c.seq = "", "", 0
+}
+
+// gatewayURL adds the version, encoding and compression to a gateway url
+func (c *Client) gatewayURL(base string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("v", apiVersion)
+	q.Set("encoding", "json")
+	if c.config.Compress {
+		q.Set("compress", "zlib-stream")
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func send(conn *conn, op int, d any) error {
+	message, err := json.Marshal(struct {
+		Op int `json:"op"`
+		D  any `json:"d"`
+	}{Op: op, D: d})
+	if err != nil {
+		return err
+	}
+	return conn.writeFrame(opText, message)
+}
+
+// jitter spreads d between half and all of it
+func jitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
@@ -0,0 +1,298 @@
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// standIn is a local gateway, each connection is handed to the next script
+type standIn struct {
+	t       *testing.T
+	server  *httptest.Server
+	mu      sync.Mutex
+	scripts []func(s *session)
+	queries chan string
+}
+
+// session is the server side of a connection to the stand in
+type session struct {
+	t    *testing.T
+	conn *conn
+	zw   *zlib.Writer
+	buf  bytes.Buffer
+}
+
+func newStandIn(t *testing.T, scripts ...func(s *session)) *standIn {
+	si := &standIn{t: t, scripts: scripts, queries: make(chan string, len(scripts))}
+	si.server = httptest.NewServer(http.HandlerFunc(si.upgrade))
+	t.Cleanup(si.server.Close)
+	return si
+}
+
+func (si *standIn) url() string {
+	return "ws" + strings.TrimPrefix(si.server.URL, "http")
+}
+
+func (si *standIn) upgrade(w http.ResponseWriter, r *http.Request) {
+	si.mu.Lock()
+	if len(si.scripts) == 0 {
+		si.mu.Unlock()
+		http.Error(w, "no more connections expected", http.StatusServiceUnavailable)
+		return
+	}
+	script := si.scripts[0]
+	si.scripts = si.scripts[1:]
+	si.mu.Unlock()
+	si.queries <- r.URL.RawQuery
+
+	nc, brw, err := w.(http.Hijacker).Hijack()
+	require.NoError(si.t, err)
+	defer nc.Close()
+	brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n")
+	brw.WriteString("Sec-WebSocket-Accept: " + acceptKey(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+	brw.Flush()
+	s := &session{t: si.t, conn: &conn{nc: nc, br: bufio.NewReader(brw)}}
+	if r.URL.Query().Get("compress") == "zlib-stream" {
+		s.zw = zlib.NewWriter(&s.buf)
+	}
+	script(s)
+}
+
+func (s *session) send(op int, d any, seq int64, name string) {
+	data, err := json.Marshal(d)
+	require.NoError(s.t, err)
+	p := payload{Op: op, D: data, T: name}
+	if seq > 0 {
+		p.S = &seq
+	}
+	message, err := json.Marshal(p)
+	require.NoError(s.t, err)
+	if s.zw == nil {
+		require.NoError(s.t, s.conn.writeFrame(opText, message))
+		return
+	}
+	s.zw.Write(message)
+	s.zw.Flush()
+	require.NoError(s.t, s.conn.writeFrame(opBinary, s.buf.Bytes()))
+	s.buf.Reset()
+}
+
+func (s *session) receive() payload {
+	s.conn.nc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := s.conn.readMessage()
+	require.NoError(s.t, err)
+	var p payload
+	require.NoError(s.t, json.Unmarshal(message, &p))
+	return p
+}
+
+// receiveOp skips payloads until one with op arrives
+func (s *session) receiveOp(op int) payload {
+	for {
+		if p := s.receive(); p.Op == op {
+			return p
+		}
+	}
+}
+
+func (s *session) hello(interval time.Duration) {
+	s.send(opHello, map[string]int64{"heartbeat_interval": interval.Milliseconds()}, 0, "")
+}
+
+// waitClosed reads until the client closes the connection
+func (s *session) waitClosed() {
+	for {
+		s.conn.nc.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, _, err := s.conn.readMessage(); err != nil {
+			return
+		}
+	}
+}
+
+// collect returns a handler sending the events it receives to the returned channel
+func collect() (Handler, chan Event) {
+	events := make(chan Event, 16)
+	return func(ctx context.Context, e Event) { events <- e }, events
+}
+
+func receiveEvent(t *testing.T, events chan Event) Event {
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event received")
+		return nil
+	}
+}
+
+func TestIdentifyHeartbeatAndDispatch(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	si := newStandIn(t, func(s *session) {
+		s.hello(20 * time.Millisecond)
+		p := s.receiveOp(opIdentify)
+		var id identify
+		require.NoError(t, json.Unmarshal(p.D, &id))
+		require.Equal(t, "token", id.Token)
+		require.Equal(t, IntentGuilds|IntentGuildMembers, id.Intents)
+		require.Equal(t, "RPS", id.Presence.Activities[0].Name)
+
+		s.send(opDispatch, Ready{SessionID: "s1", User: User{ID: "bot"}}, 1, "READY")
+		s.send(opDispatch, GuildMemberRemove{GuildID: "g1", User: User{ID: "u1"}}, 2, "GUILD_MEMBER_REMOVE")
+		s.send(opDispatch, map[string]string{"id": "c1"}, 3, "CHANNEL_CREATE")
+		// every heartbeat is acknowledged until the last sequence and the new presence arrived
+		var presence *Presence
+		beatSeq := ""
+		for presence == nil || beatSeq != "3" {
+			switch p := s.receive(); p.Op {
+			case opHeartbeat:
+				beatSeq = string(p.D)
+				s.send(opHeartbeatACK, nil, 0, "")
+			case opPresenceUpdate:
+				require.NoError(t, json.Unmarshal(p.D, &presence))
+			}
+		}
+		require.Equal(t, Playing("RPS with 2 open challenges"), *presence)
+		cancel()
+		s.waitClosed()
+	})
+	presence := Playing("RPS")
+	client := NewClient(Config{Token: "token", Intents: IntentGuilds | IntentGuildMembers, URL: si.url(), Presence: &presence})
+	handler, events := collect()
+	client.Subscribe(handler)
+	client.Subscribe(func(ctx context.Context, e Event) {
+		if _, ok := e.(*Unknown); ok {
+			client.UpdatePresence(Playing("RPS with 2 open challenges"))
+		}
+	})
+
+	runErr := make(chan error)
+	go func() { runErr <- client.Run(ctx) }()
+	require.Equal(t, "s1", receiveEvent(t, events).(*Ready).SessionID)
+	require.Equal(t, &GuildMemberRemove{GuildID: "g1", User: User{ID: "u1"}}, receiveEvent(t, events))
+	unknown := receiveEvent(t, events).(*Unknown)
+	require.Equal(t, "CHANNEL_CREATE", unknown.EventType())
+	require.JSONEq(t, `{"id":"c1"}`, string(unknown.Data))
+	require.ErrorIs(t, <-runErr, context.Canceled)
+	require.Equal(t, "encoding=json&v=10", <-si.queries)
+}
+
+func TestResume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var si *standIn
+	si = newStandIn(t,
+		func(s *session) {
+			s.hello(time.Minute)
+			s.receiveOp(opIdentify)
+			s.send(opDispatch, Ready{SessionID: "s1", ResumeGatewayURL: si.url() + "/resume"}, 1, "READY")
+			s.send(opDispatch, MessageCreate{ID: "m1"}, 2, "MESSAGE_CREATE")
+			s.send(opReconnect, nil, 0, "")
+			s.waitClosed()
+		},
+		func(s *session) {
+			s.hello(time.Minute)
+			p := s.receiveOp(opResume)
+			var r resume
+			require.NoError(t, json.Unmarshal(p.D, &r))
+			require.Equal(t, resume{Token: "token", SessionID: "s1", Seq: 2}, r)
+			s.send(opDispatch, nil, 3, "RESUMED")
+			// a session that can't be resumed identifies again
+			s.send(opInvalidSession, false, 0, "")
+			s.waitClosed()
+		},
+		func(s *session) {
+			s.hello(time.Minute)
+			s.receiveOp(opIdentify)
+			cancel()
+			s.waitClosed()
+		},
+	)
+	client := NewClient(Config{Token: "token", URL: si.url(), Backoff: time.Millisecond})
+	handler, events := collect()
+	client.Subscribe(handler)
+
+	runErr := make(chan error)
+	go func() { runErr <- client.Run(ctx) }()
+	require.IsType(t, &Ready{}, receiveEvent(t, events))
+	require.IsType(t, &MessageCreate{}, receiveEvent(t, events))
+	require.IsType(t, &Resumed{}, receiveEvent(t, events))
+	require.ErrorIs(t, <-runErr, context.Canceled)
+}
+
+func TestZombieConnectionReconnects(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	si := newStandIn(t,
+		func(s *session) {
+			s.hello(10 * time.Millisecond)
+			s.receiveOp(opIdentify)
+			s.send(opDispatch, Ready{SessionID: "s1"}, 1, "READY")
+			// heartbeats are never acknowledged
+			s.waitClosed()
+		},
+		func(s *session) {
+			s.hello(time.Minute)
+			s.receiveOp(opResume)
+			cancel()
+			s.waitClosed()
+		},
+	)
+	client := NewClient(Config{Token: "token", URL: si.url(), Backoff: time.Millisecond})
+	require.ErrorIs(t, client.Run(ctx), context.Canceled)
+}
+
+func TestZlibStream(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	si := newStandIn(t, func(s *session) {
+		s.hello(time.Minute)
+		s.receiveOp(opIdentify)
+		s.send(opDispatch, Ready{SessionID: "s1"}, 1, "READY")
+		s.send(opDispatch, GuildCreate{ID: "g1", Name: strings.Repeat("rps", 100)}, 2, "GUILD_CREATE")
+		s.waitClosed()
+	})
+	client := NewClient(Config{Token: "token", URL: si.url(), Compress: true})
+	handler, events := collect()
+	client.Subscribe(handler)
+
+	runErr := make(chan error)
+	go func() { runErr <- client.Run(ctx) }()
+	require.Equal(t, "s1", receiveEvent(t, events).(*Ready).SessionID)
+	require.Equal(t, "g1", receiveEvent(t, events).(*GuildCreate).ID)
+	cancel()
+	require.ErrorIs(t, <-runErr, context.Canceled)
+	require.Equal(t, "compress=zlib-stream&encoding=json&v=10", <-si.queries)
+}
+
+func TestFatalClose(t *testing.T) {
+	si := newStandIn(t, func(s *session) {
+		s.hello(time.Minute)
+		s.receiveOp(opIdentify)
+		s.conn.close(4004, "Authentication failed.")
+	})
+	client := NewClient(Config{Token: "bad", URL: si.url(), Backoff: time.Millisecond})
+	err := client.Run(context.Background())
+	var closeErr *CloseError
+	require.ErrorAs(t, err, &closeErr)
+	require.Equal(t, 4004, closeErr.Code)
+}
+
+func TestParseIntents(t *testing.T) {
+	intents, err := ParseIntents("guilds, GUILD_MEMBERS,")
+	require.NoError(t, err)
+	require.Equal(t, IntentGuilds|IntentGuildMembers, intents)
+	_, err = ParseIntents("guilds,everything")
+	require.ErrorIs(t, err, ErrUnknownIntent)
+}
@@ -0,0 +1,75 @@
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// pipe returns the client and server ends of an upgraded connection
+func pipe() (client, server *conn) {
+	a, b := net.Pipe()
+	return &conn{nc: a, br: bufio.NewReader(a), client: true}, &conn{nc: b, br: bufio.NewReader(b)}
+}
+
+func TestReadMessage(t *testing.T) {
+	client, server := pipe()
+	defer client.nc.Close()
+	defer server.nc.Close()
+	long := bytes.Repeat([]byte("r"), 70000)
+
+	go func() {
+		server.writeFrame(opPing, []byte("ping"))
+		// a message split in a text frame and a continuation
+		server.nc.Write([]byte{opText, 3, 'r', 'p', 's'})
+		server.writeFrame(opContinuation, []byte("!"))
+		server.writeFrame(opBinary, long)
+	}()
+	go func() {
+		_, pong, _, err := server.readFrame()
+		require.NoError(t, err)
+		require.Equal(t, byte(opPong), pong)
+	}()
+
+	op, message, err := client.readMessage()
+	require.NoError(t, err)
+	require.Equal(t, byte(opText), op)
+	require.Equal(t, "rps!", string(message))
+	op, message, err = client.readMessage()
+	require.NoError(t, err)
+	require.Equal(t, byte(opBinary), op)
+	require.Equal(t, long, message)
+}
+
+func TestWriteFrameMasks(t *testing.T) {
+	client, server := pipe()
+	defer client.nc.Close()
+	defer server.nc.Close()
+	go client.writeFrame(opText, []byte("rock"))
+
+	frame := make([]byte, 10)
+	_, err := io.ReadFull(server.br, frame)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x80 | opText, 0x80 | 4}, frame[:2])
+	mask, payload := frame[2:6], frame[6:]
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	require.Equal(t, "rock", string(payload))
+}
+
+func TestCloseFrame(t *testing.T) {
+	client, server := pipe()
+	defer client.nc.Close()
+	go func() {
+		server.close(4009, "Session timed out")
+	}()
+	_, _, err := client.readMessage()
+	var closeErr *CloseError
+	require.ErrorAs(t, err, &closeErr)
+	require.Equal(t, &CloseError{Code: 4009, Reason: "Session timed out"}, closeErr)
+}
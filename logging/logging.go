@@ -0,0 +1,93 @@
+// logging package configures the bot's structured logger and carries a
+// request-scoped logger through context.Context
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Log attribute keys shared across the bot
+const (
+	KeyRequestID       = "request_id"
+	KeyInteractionID   = "interaction_id"
+	KeyInteractionType = "interaction_type"
+	KeyCommand         = "command"
+	KeyCustomID        = "custom_id"
+	KeyGuildID         = "guild_id"
+	KeyChannelID       = "channel_id"
+	KeyUserID          = "user_id"
+	KeyChallengeID     = "challenge_id"
+	KeyTournamentID    = "tournament_id"
+	KeyRoundID         = "round_id"
+	KeyError           = "error"
+)
+
+// Log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+type ctxKey struct{}
+
+// New creates a logger writing to w at the given level and format,
+// level is one of debug, info, warn or error and format is either text or json.
+// Sensitive values are redacted before they are written
+func New(w io.Writer, level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       ParseLevel(level),
+		ReplaceAttr: redactAttr,
+	}
+	var handler slog.Handler
+	if strings.EqualFold(format, FormatJSON) {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(handler)
+}
+
+// Setup creates a logger writing to stderr and installs it as the slog default
+func Setup(level, format string) *slog.Logger {
+	logger := New(os.Stderr, level, format)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// ParseLevel converts a level name into a slog.Level, defaulting to info
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// WithLogger returns a copy of ctx carrying logger
+func WithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, logger)
+}
+
+// FromContext returns the logger carried by ctx or the default logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if ctx != nil {
+		if logger, ok := ctx.Value(ctxKey{}).(*slog.Logger); ok && logger != nil {
+			return logger
+		}
+	}
+	return slog.Default()
+}
+
+// With returns a copy of ctx whose logger includes args on every record
+func With(ctx context.Context, args ...any) context.Context {
+	return WithLogger(ctx, FromContext(ctx).With(args...))
+}
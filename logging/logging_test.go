@@ -0,0 +1,82 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedaction(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []any
+		key      string
+		expected string
+	}{
+		{
+			name:     "sensitive key",
+			args:     []any{"token", "interaction-token-value"},
+			key:      "token",
+			expected: Redacted,
+		}, {
+			name:     "sensitive key suffix",
+			args:     []any{"interaction_token", "interaction-token-value"},
+			key:      "interaction_token",
+			expected: Redacted,
+		}, {
+			name:     "webhook endpoint",
+			args:     []any{"endpoint", "webhooks/123/aW50ZXJhY3Rpb24.token/messages/456"},
+			key:      "endpoint",
+			expected: "webhooks/123/" + Redacted + "/messages/456",
+		}, {
+			name:     "authorization value in error",
+			args:     []any{KeyError, errors.New("header Bot abc.def-ghi rejected")},
+			key:      KeyError,
+			expected: "header Bot " + Redacted + " rejected",
+		}, {
+			name:     "plain value",
+			args:     []any{KeyUserID, "1234"},
+			key:      KeyUserID,
+			expected: "1234",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := New(&buf, "info", FormatJSON)
+			logger.Info("test", tc.args...)
+
+			var record map[string]any
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+			require.Equal(t, tc.expected, record[tc.key])
+		})
+	}
+}
+
+func TestContextLogger(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := WithLogger(context.Background(), New(&buf, "debug", FormatJSON))
+	ctx = With(ctx, KeyInteractionID, "987")
+	// background work keeps the request logger after the request is done
+	bgCtx := context.WithoutCancel(ctx)
+	FromContext(With(bgCtx, KeyChallengeID, "654")).Debug("background")
+
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	require.Equal(t, "987", record[KeyInteractionID])
+	require.Equal(t, "654", record[KeyChallengeID])
+	require.Equal(t, slog.Default(), FromContext(context.Background()))
+}
+
+func TestParseLevel(t *testing.T) {
+	require.Equal(t, slog.LevelDebug, ParseLevel("DEBUG"))
+	require.Equal(t, slog.LevelWarn, ParseLevel("warn"))
+	require.Equal(t, slog.LevelError, ParseLevel("error"))
+	require.Equal(t, slog.LevelInfo, ParseLevel(""))
+}
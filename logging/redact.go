@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// Redacted replaces sensitive values in log output
+const Redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values are never logged
+var sensitiveKeys = map[string]struct{}{
+	"token":             {},
+	"interaction_token": {},
+	"bot_token":         {},
+	"discord_token":     {},
+	"authorization":     {},
+	"secret":            {},
+	"password":          {},
+}
+
+var (
+	// webhook endpoints embed the interaction token as the second path segment
+	webhookTokenPattern = regexp.MustCompile(`(webhooks/[^/\s]+/)[^/\s?]+`)
+	// authorization header values
+	botTokenPattern = regexp.MustCompile(`(Bot|Bearer) [A-Za-z0-9._\-]+`)
+)
+
+// IsSensitiveKey reports whether values logged under key are redacted
+func IsSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	if _, ok := sensitiveKeys[key]; ok {
+		return true
+	}
+	return strings.HasSuffix(key, "_token") || strings.HasSuffix(key, "_secret")
+}
+
+// RedactString removes tokens embedded in s, such as interaction tokens in
+// webhook endpoints and bot tokens in authorization values
+func RedactString(s string) string {
+	s = webhookTokenPattern.ReplaceAllString(s, "${1}"+Redacted)
+	return botTokenPattern.ReplaceAllString(s, "${1} "+Redacted)
+}
+
+// redactAttr is a slog ReplaceAttr function applied to every attribute
+func redactAttr(_ []string, a slog.Attr) slog.Attr {
+	if IsSensitiveKey(a.Key) {
+		return slog.String(a.Key, Redacted)
+	}
+	switch a.Value.Kind() {
+	case slog.KindString:
+		if s := a.Value.String(); s != RedactString(s) {
+			return slog.String(a.Key, RedactString(s))
+		}
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, RedactString(err.Error()))
+		}
+	}
+	return a
+}
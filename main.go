@@ -1,18 +1,201 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/ekefan/discord-bot/api"
 	"github.com/ekefan/discord-bot/api/middleware"
+	"github.com/ekefan/discord-bot/domain/throttle"
+	"github.com/ekefan/discord-bot/events"
+	"github.com/ekefan/discord-bot/gateway"
+	"github.com/ekefan/discord-bot/logging"
 	"github.com/ekefan/discord-bot/memory"
+	"github.com/ekefan/discord-bot/resp"
+	"github.com/ekefan/discord-bot/sqlite"
+	"github.com/ekefan/discord-bot/tracing"
 	"github.com/ekefan/discord-bot/util"
 )
 
+// shutdownTimeout bounds the wait for the requests being served when the bot stops
+const shutdownTimeout = 10 * time.Second
+
 func main() {
 	config := util.LoadConfig()
-	storage := memory.NewInMemory()
-	bs := api.NewBotServer(config, storage)
-	http.HandleFunc("/interactions", middleware.VerifyDiscordSignature(bs.InteractionsHandler, config))
-	http.ListenAndServe(":8080", nil)
+	logging.Setup(config.LogLevel, config.LogFormat)
+	exporter, err := tracing.NewExporter(config.TraceExporter)
+	if err != nil {
+		slog.Error("could not configure tracing", "exporter", config.TraceExporter, "error", err)
+		os.Exit(1)
+	}
+	tracing.SetExporter(exporter)
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		os.Exit(migrate(config.SQLitePath, os.Args[2:]))
+	}
+	limits := memory.Limits{
+		PerUser:    config.ChallengeLimitPerUser,
+		PerChannel: config.ChallengeLimitPerChannel,
+		PerGuild:   config.ChallengeLimitPerGuild,
+	}
+	storage := memory.NewInMemory(limits)
+	var db *sql.DB
+	if config.SQLitePath != "" {
+		db, err = openDatabase(config.SQLitePath)
+		if err != nil {
+			slog.Error("could not open database", "path", config.SQLitePath, "error", err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		storage = sqlite.NewChallenges(db, limits)
+	}
+	if config.RedisURL != "" {
+		if err := checkSharedConfig(config); err != nil {
+			slog.Error("can't share challenges through redis", "error", err)
+			os.Exit(1)
+		}
+		redisConfig, err := resp.ParseURL(config.RedisURL)
+		if err != nil {
+			slog.Error("could not parse redis url", "error", err)
+			os.Exit(1)
+		}
+		storage = memory.NewRedisChallenges(resp.NewClient(redisConfig), config.RedisPrefix, limits)
+	}
+	bs := api.NewBotServer(config, memory.NewChallengeStore(storage))
+	if config.SettingsFile != "" {
+		guildSettings, err := memory.NewFileSettings(config.SettingsFile)
+		if err != nil {
+			slog.Error("could not load guild settings", "path", config.SettingsFile, "error", err)
+			os.Exit(1)
+		}
+		bs.Settings = guildSettings
+	}
+	if db != nil {
+		bs.History = sqlite.NewHistory(db)
+		bs.Ratings = sqlite.NewRatings(db)
+		bs.Settings = sqlite.NewSettings(db)
+	}
+	throttleLimits, err := throttle.ParseLimits(config.ThrottleLimits)
+	if err != nil {
+		slog.Error("could not parse throttle limits", "limits", config.ThrottleLimits, "error", err)
+		os.Exit(1)
+	}
+	bs.Limits = throttleLimits
+
+	// the bot stops on SIGINT or SIGTERM once the requests being served and the queued webhooks are done
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if config.GatewayEnabled {
+		intents, err := gateway.ParseIntents(config.GatewayIntents)
+		if err != nil {
+			slog.Error("could not parse gateway intents", "intents", config.GatewayIntents, "error", err)
+			os.Exit(1)
+		}
+		gw := gateway.NewClient(gateway.Config{
+			Token:    config.DiscordToken,
+			Intents:  intents,
+			URL:      config.GatewayURL,
+			Compress: config.GatewayCompress,
+		})
+		gw.Subscribe(bs.HandleGatewayEvent)
+		go func() {
+			if err := gw.Run(ctx); err != nil {
+				slog.Error("gateway connection stopped", "error", err)
+			}
+		}()
+		go bs.RunPresence(ctx, gw, time.Minute)
+	}
+	go bs.RunChallengeExpiry(ctx, 30*time.Second)
+	go bs.RunRoundExpiry(ctx, 30*time.Second)
+
+	var sink *events.WebhookSink
+	if urls := util.SplitList(config.WebhookURLs); len(urls) > 0 {
+		if config.WebhookSecret == "" {
+			slog.Error("WEBHOOK_SECRET must be set when WEBHOOK_URLS is set, receivers could not verify deliveries")
+			os.Exit(1)
+		}
+		sink = events.NewWebhookSink(events.WebhookConfig{
+			URLs:           urls,
+			Secret:         config.WebhookSecret,
+			MaxAttempts:    config.WebhookMaxAttempts,
+			DeadLetterPath: config.WebhookDeadLetterFile,
+		})
+		bs.Events.Subscribe(sink.Handle)
+	}
+	http.HandleFunc("/interactions", middleware.WithRequestLogger(middleware.WithTracing(middleware.VerifyDiscordSignature(bs.InteractionsHandler, config))))
+	server := &http.Server{Addr: ":8080"}
+	go func() {
+		if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("http server stopped", "error", err)
+			stop()
+		}
+	}()
+
+	<-ctx.Done()
+	slog.Info("shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		slog.Error("could not finish serving requests", "error", err)
+	}
+	if sink != nil {
+		if err := sink.Close(shutdownCtx); err != nil {
+			slog.Error("could not deliver every webhook before shutting down, the rest were dead lettered", "error", err)
+		}
+	}
+}
+
+// checkSharedConfig reports why instances configured with config can't share challenges
+func checkSharedConfig(config *util.EnvConfig) error {
+	if config.CustomIDSecret == "" {
+		// every instance would sign components with its own random key
+		return errors.New("CUSTOM_ID_SECRET must be set")
+	}
+	perProcess := []struct {
+		name    string
+		enabled bool
+	}{
+		{"ECONOMY_ENABLED", config.EconomyEnabled},
+		{"ROUNDS_ENABLED", config.RoundsEnabled},
+		{"TOURNAMENTS_ENABLED", config.TournamentsEnabled},
+		{"REMATCHES_ENABLED", config.RematchesEnabled},
+	}
+	enabled := []string{}
+	for _, feature := range perProcess {
+		if feature.enabled {
+			enabled = append(enabled, feature.name)
+		}
+	}
+	if len(enabled) > 0 {
+		return fmt.Errorf("%s must be false, their state is not shared between instances", strings.Join(enabled, ", "))
+	}
+	return nil
+}
+
+// openDatabase opens the database at path and applies its pending migrations
+func openDatabase(path string) (*sql.DB, error) {
+	db, err := sqlite.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := sqlite.Migrations()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := sqlite.NewMigrator(db, migrations).Up(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
 }
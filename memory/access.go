@@ -0,0 +1,44 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/ekefan/discord-bot/domain/access"
+)
+
+// InMemoryAccess keeps the access policies of guilds in memory
+type InMemoryAccess struct {
+	policies map[string]access.Policy
+	sync.Mutex
+}
+
+func NewInMemoryAccess() AccessRepository {
+	return &InMemoryAccess{
+		policies: make(map[string]access.Policy),
+	}
+}
+
+func (ia *InMemoryAccess) Policy(guildID string) (access.Policy, error) {
+	ia.Mutex.Lock()
+	defer ia.Mutex.Unlock()
+	p, ok := ia.policies[guildID]
+	if !ok {
+		return access.Policy{GuildID: guildID}, nil
+	}
+	return p.Clone(), nil
+}
+
+func (ia *InMemoryAccess) UpdatePolicy(guildID string, update func(p *access.Policy) error) (access.Policy, error) {
+	ia.Mutex.Lock()
+	defer ia.Mutex.Unlock()
+	p, ok := ia.policies[guildID]
+	if !ok {
+		p = access.Policy{GuildID: guildID}
+	}
+	p = p.Clone()
+	if err := update(&p); err != nil {
+		return access.Policy{}, err
+	}
+	ia.policies[guildID] = p
+	return p.Clone(), nil
+}
@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain/commitment"
+)
+
+// InMemoryCommitments keeps commitments of fair games in memory
+type InMemoryCommitments struct {
+	records map[string]commitment.Record
+	sync.Mutex
+}
+
+func NewInMemoryCommitments() CommitmentRepository {
+	return &InMemoryCommitments{
+		records: make(map[string]commitment.Record),
+	}
+}
+
+func (ic *InMemoryCommitments) SaveCommitment(r commitment.Record) error {
+	if r.GameID == "" || r.Commitment == "" {
+		return ErrInvalidCommitment
+	}
+	ic.Mutex.Lock()
+	defer ic.Mutex.Unlock()
+	ic.records[r.GameID] = r
+	return nil
+}
+
+func (ic *InMemoryCommitments) GetCommitment(gameID string) (commitment.Record, error) {
+	ic.Mutex.Lock()
+	defer ic.Mutex.Unlock()
+	r, ok := ic.records[gameID]
+	if !ok {
+		return commitment.Record{}, ErrCommitmentNotFound
+	}
+	return r, nil
+}
+
+func (ic *InMemoryCommitments) RevealCommitment(gameID string, t time.Time) error {
+	ic.Mutex.Lock()
+	defer ic.Mutex.Unlock()
+	r, ok := ic.records[gameID]
+	if !ok {
+		return ErrCommitmentNotFound
+	}
+	if !r.Revealed() {
+		r.RevealedAt = t
+		ic.records[gameID] = r
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package memory_test
+
+import (
+	"testing"
+
+	"github.com/ekefan/discord-bot/memory"
+	"github.com/ekefan/discord-bot/memory/repotest"
+	"github.com/ekefan/discord-bot/resp"
+	"github.com/ekefan/discord-bot/resp/resptest"
+)
+
+func TestInMemoryConformance(t *testing.T) {
+	repotest.RunChallengeRepositorySuite(t, func(t *testing.T, limits memory.Limits) memory.ChallangeRespository {
+		return memory.NewInMemory(limits)
+	})
+}
+
+func TestRedisConformance(t *testing.T) {
+	repotest.RunChallengeRepositorySuite(t, func(t *testing.T, limits memory.Limits) memory.ChallangeRespository {
+		server := resptest.NewServer(t)
+		client := resp.NewClient(resp.Config{Addr: server.Addr()})
+		t.Cleanup(func() { client.Close() })
+		return memory.NewRedisChallenges(client, "rps:", limits)
+	})
+}
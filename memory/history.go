@@ -0,0 +1,54 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/ekefan/discord-bot/domain/history"
+)
+
+// InMemoryHistory keeps game records in memory in the order they were appended
+type InMemoryHistory struct {
+	records []history.Record
+	ids     map[string]bool
+	sync.Mutex
+}
+
+func NewInMemoryHistory() HistoryRepository {
+	return &InMemoryHistory{
+		ids: make(map[string]bool),
+	}
+}
+
+func (ih *InMemoryHistory) AppendGame(r history.Record) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	ih.Mutex.Lock()
+	defer ih.Mutex.Unlock()
+	if ih.ids[r.ID] {
+		return ErrGameExists
+	}
+	ih.ids[r.ID] = true
+	ih.records = append(ih.records, r)
+	return nil
+}
+
+func (ih *InMemoryHistory) Games(q history.Query) ([]history.Record, int, error) {
+	ih.Mutex.Lock()
+	defer ih.Mutex.Unlock()
+	selected := []history.Record{}
+	for i := len(ih.records) - 1; i >= 0; i-- {
+		if q.Match(ih.records[i]) {
+			selected = append(selected, ih.records[i])
+		}
+	}
+	total := len(selected)
+	if q.Offset >= total {
+		return []history.Record{}, total, nil
+	}
+	selected = selected[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(selected) {
+		selected = selected[:q.Limit]
+	}
+	return selected, total, nil
+}
@@ -0,0 +1,48 @@
+package memory
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain/history"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	repo := NewInMemoryHistory()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, repo.AppendGame(history.Record{
+			ID:         fmt.Sprint(i),
+			GuildID:    "g1",
+			Players:    [2]string{"a", "b"},
+			WinnerID:   "a",
+			ResolvedAt: start.Add(time.Duration(i) * time.Hour),
+		}))
+	}
+	require.ErrorIs(t, repo.AppendGame(history.Record{ID: "0", Players: [2]string{"a", "b"}}), ErrGameExists)
+	require.ErrorIs(t, repo.AppendGame(history.Record{ID: "9"}), history.ErrInvalidRecord)
+
+	ids := func(records []history.Record) []string {
+		out := []string{}
+		for _, r := range records {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+	page, total, err := repo.Games(history.Query{UserID: "a", Offset: 1, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, 5, total)
+	require.Equal(t, []string{"3", "2"}, ids(page))
+
+	page, total, err = repo.Games(history.Query{UserID: "b", From: start.Add(3 * time.Hour)})
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+	require.Equal(t, []string{"4", "3"}, ids(page))
+
+	page, total, err = repo.Games(history.Query{UserID: "c", Offset: 10})
+	require.NoError(t, err)
+	require.Zero(t, total)
+	require.Empty(t, page)
+}
@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/ekefan/discord-bot/domain/ledger"
+)
+
+// InMemoryLedger keeps balances and posted transactions in memory
+type InMemoryLedger struct {
+	balances map[string]int
+	posted   map[string]ledger.Transaction
+	sync.Mutex
+}
+
+func NewInMemoryLedger() LedgerRepository {
+	return &InMemoryLedger{
+		balances: make(map[string]int),
+		posted:   make(map[string]ledger.Transaction),
+	}
+}
+
+func (il *InMemoryLedger) Post(tx ledger.Transaction) error {
+	if err := tx.Validate(); err != nil {
+		return err
+	}
+	il.Mutex.Lock()
+	defer il.Mutex.Unlock()
+	if _, ok := il.posted[tx.ID]; ok {
+		return ErrTransactionExists
+	}
+	next := map[string]int{}
+	for _, e := range tx.Entries {
+		if _, ok := next[e.Account]; !ok {
+			next[e.Account] = il.balances[e.Account]
+		}
+		next[e.Account] += e.Amount
+	}
+	for account, balance := range next {
+		if balance < 0 && !ledger.Overdraftable(account) {
+			current := il.balances[account]
+			return &ledger.InsufficientFundsError{Account: account, Balance: current, Needed: current - balance}
+		}
+	}
+	for account, balance := range next {
+		il.balances[account] = balance
+	}
+	il.posted[tx.ID] = tx
+	return nil
+}
+
+func (il *InMemoryLedger) Balance(account string) (int, error) {
+	il.Mutex.Lock()
+	defer il.Mutex.Unlock()
+	return il.balances[account], nil
+}
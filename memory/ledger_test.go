@@ -0,0 +1,47 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain/ledger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLedger(t *testing.T) {
+	repo := NewInMemoryLedger()
+	now := time.Now()
+	alice, bob := ledger.UserAccount("g1", "alice"), ledger.UserAccount("g1", "bob")
+
+	daily, err := ledger.Transfer("daily-1", ledger.KindDaily, ledger.MintAccount("g1"), alice, 100, now)
+	require.NoError(t, err)
+	require.NoError(t, repo.Post(daily))
+	// posting the same transaction again does not grant coins twice
+	require.ErrorIs(t, repo.Post(daily), ErrTransactionExists)
+
+	// bob can not stake coins he doesn't have, and alice keeps hers
+	escrow, err := ledger.Escrow("escrow-1", "g1", "c1", "alice", "bob", 60, now)
+	require.NoError(t, err)
+	var funds *ledger.InsufficientFundsError
+	require.ErrorAs(t, repo.Post(escrow), &funds)
+	require.Equal(t, bob, funds.Account)
+	require.Equal(t, 60, funds.Needed)
+	balance, err := repo.Balance(alice)
+	require.NoError(t, err)
+	require.Equal(t, 100, balance)
+
+	transfer, err := ledger.Transfer("transfer-1", ledger.KindTransfer, alice, bob, 60, now)
+	require.NoError(t, err)
+	require.NoError(t, repo.Post(transfer))
+	escrow.ID = "escrow-2"
+	require.ErrorIs(t, repo.Post(escrow), ledger.ErrInsufficientFunds)
+
+	escrow, err = ledger.Escrow("escrow-3", "g1", "c1", "alice", "bob", 40, now)
+	require.NoError(t, err)
+	require.NoError(t, repo.Post(escrow))
+	for account, want := range map[string]int{alice: 0, bob: 20, ledger.EscrowAccount("c1"): 80, ledger.MintAccount("g1"): -100} {
+		balance, err := repo.Balance(account)
+		require.NoError(t, err)
+		require.Equal(t, want, balance, account)
+	}
+}
@@ -0,0 +1,88 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ekefan/discord-bot/domain/challenge"
+)
+
+var (
+	ErrChallengeLimitReached = errors.New("open challenge limit reached")
+)
+
+// Limit names reported by LimitError
+const (
+	UserLimit    = "user"
+	ChannelLimit = "channel"
+	GuildLimit   = "guild"
+)
+
+// Limits caps the number of open challenges, a zero value means no limit
+type Limits struct {
+	PerUser    int // open challenges a single user can have in one channel
+	PerChannel int // open challenges in one channel
+	PerGuild   int // open challenges in one guild, or one DM when there is no guild
+}
+
+// LimitError is returned when creating a challenge would exceed a limit
+type LimitError struct {
+	Limit string
+	Max   int
+	// Existing is the challenger's open challenge in the channel when the user limit was reached
+	Existing *challenge.Challenge
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v: at most %d open challenges per %s", ErrChallengeLimitReached, e.Max, e.Limit)
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == ErrChallengeLimitReached
+}
+
+// Check returns a LimitError when c can not be opened next to the open challenges.
+// Tournament matches are scheduled by their tournament and are never limited
+func (l Limits) Check(open []*challenge.Challenge, c *challenge.Challenge) error {
+	if tournamentMatch(c) {
+		return nil
+	}
+	var perUser, perChannel, perGuild int
+	var existing *challenge.Challenge
+	for _, o := range open {
+		if o.Status().Terminal() || tournamentMatch(o) || !sameGuild(o.Scope(), c.Scope()) {
+			continue
+		}
+		perGuild++
+		if o.Scope().Key() != c.Scope().Key() {
+			continue
+		}
+		perChannel++
+		if o.Challenger().ID == c.Challenger().ID {
+			perUser++
+			existing = o
+		}
+	}
+	if l.PerUser > 0 && perUser >= l.PerUser {
+		return &LimitError{Limit: UserLimit, Max: l.PerUser, Existing: existing}
+	}
+	if l.PerChannel > 0 && perChannel >= l.PerChannel {
+		return &LimitError{Limit: ChannelLimit, Max: l.PerChannel}
+	}
+	if l.PerGuild > 0 && perGuild >= l.PerGuild {
+		return &LimitError{Limit: GuildLimit, Max: l.PerGuild}
+	}
+	return nil
+}
+
+func sameGuild(a, b challenge.Scope) bool {
+	if a.GuildID != "" || b.GuildID != "" {
+		return a.GuildID == b.GuildID
+	}
+	return a.Key() == b.Key()
+}
+
+func tournamentMatch(c *challenge.Challenge) bool {
+	tournamentID, _ := c.Tournament()
+	return tournamentID != ""
+}
@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestChallenge(t *testing.T, id, guildID, channelID, userID string) *challenge.Challenge {
+	scope := challenge.Scope{GuildID: guildID, ChannelID: channelID}
+	c, err := challenge.NewChallenge(id, scope, &domain.Player{ID: userID, Choice: domain.Rock})
+	require.NoError(t, err)
+	return c
+}
+
+func TestLimits(t *testing.T) {
+	ctx := context.Background()
+	limits := Limits{PerUser: 1, PerChannel: 2, PerGuild: 3}
+	repo := NewInMemory(limits)
+
+	require.NoError(t, repo.CreateChallenge(ctx, newTestChallenge(t, "1", "g1", "c1", "u1")))
+
+	// same user, same channel
+	err := repo.CreateChallenge(ctx, newTestChallenge(t, "2", "g1", "c1", "u1"))
+	var limitErr *LimitError
+	require.True(t, errors.As(err, &limitErr))
+	require.ErrorIs(t, err, ErrChallengeLimitReached)
+	require.Equal(t, UserLimit, limitErr.Limit)
+	existingID, _ := limitErr.Existing.GetChallengeID()
+	require.Equal(t, "1", existingID)
+
+	// same user in another channel is allowed
+	require.NoError(t, repo.CreateChallenge(ctx, newTestChallenge(t, "3", "g1", "c2", "u1")))
+
+	require.NoError(t, repo.CreateChallenge(ctx, newTestChallenge(t, "4", "g1", "c1", "u2")))
+	err = repo.CreateChallenge(ctx, newTestChallenge(t, "5", "g1", "c1", "u3"))
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, ChannelLimit, limitErr.Limit)
+
+	err = repo.CreateChallenge(ctx, newTestChallenge(t, "6", "g1", "c3", "u3"))
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, GuildLimit, limitErr.Limit)
+
+	// other guilds are unaffected
+	require.NoError(t, repo.CreateChallenge(ctx, newTestChallenge(t, "7", "g2", "c1", "u1")))
+
+	// withdrawing frees the slot
+	require.NoError(t, repo.DeleteChallenge(ctx, challenge.Scope{GuildID: "g1", ChannelID: "c1"}, "1"))
+	require.NoError(t, repo.CreateChallenge(ctx, newTestChallenge(t, "8", "g1", "c1", "u1")))
+}
+
+func TestLimitsAtomic(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemory(Limits{PerUser: 1})
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		go func(i int) {
+			errs <- repo.CreateChallenge(ctx, newTestChallenge(t, fmt.Sprint(i), "g1", "c1", "u1"))
+		}(i)
+	}
+	created := 0
+	for i := 0; i < 20; i++ {
+		if <-errs == nil {
+			created++
+		}
+	}
+	require.Equal(t, 1, created)
+}
+
+func TestLimitsIgnoreTournamentMatches(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemory(Limits{PerUser: 1, PerChannel: 1})
+	require.NoError(t, repo.CreateChallenge(ctx, newTestChallenge(t, "1", "g1", "c1", "u1")))
+
+	match, err := challenge.NewMatch("2", challenge.Scope{GuildID: "g1", ChannelID: "c1"}, "u1", "u2")
+	require.NoError(t, err)
+	match.SetTournament("t1", "M1")
+	require.NoError(t, repo.CreateChallenge(ctx, match))
+}
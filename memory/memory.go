@@ -1,60 +1,150 @@
 package memory
 
 import (
+	"context"
 	"log/slog"
 	"sync"
 
 	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/logging"
 )
 
 type InMemory struct {
 	challenges map[string]challenge.Challenge
+	limits     Limits
 	sync.Mutex
 }
 
-func NewInMemory() ChallangeRespository {
+func NewInMemory(limits Limits) ChallangeRespository {
 	return &InMemory{
 		challenges: make(map[string]challenge.Challenge),
+		limits:     limits,
 	}
 }
 
-func (im *InMemory) CreateChallenge(c *challenge.Challenge) error {
+func (im *InMemory) CreateChallenge(ctx context.Context, c *challenge.Challenge) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if c == nil {
 		return ErrInvalidChallenge
 	}
 	id, err := c.GetChallengeID()
 	if err != nil {
-		slog.Error("error getting challenge id", "details", err.Error())
+		slog.Error("error getting challenge id", logging.KeyError, err)
 		return ErrSavingChallenge
 	}
 	im.Mutex.Lock()
 	defer im.Mutex.Unlock()
-	im.challenges[id] = *c
+	if err := im.limits.Check(im.list(ChallengeFilter{}), c); err != nil {
+		return err
+	}
+	im.challenges[storageKey(c.Scope(), id)] = *c
 	return nil
 }
 
-func (im *InMemory) GetChallenge(id string) (*challenge.Challenge, error) {
+// storageKey keys challenges by the scope they were issued in
+func storageKey(scope challenge.Scope, id string) string {
+	return scope.Key() + "/" + id
+}
+
+func (im *InMemory) GetChallenge(ctx context.Context, scope challenge.Scope, id string) (*challenge.Challenge, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if id == "" {
 		return nil, ErrInvalidChallengeId
 	}
 	im.Mutex.Lock()
 	defer im.Mutex.Unlock()
-	challenge, ok := im.challenges[id]
+	challenge, ok := im.challenges[storageKey(scope, id)]
 	if !ok {
 		return nil, ErrChallengeNotFound
 	}
 	return &challenge, nil
 }
 
-func (im *InMemory) DeleteChallenge(id string) error {
+// UpdateChallenge replaces a stored challenge with c
+func (im *InMemory) UpdateChallenge(ctx context.Context, c *challenge.Challenge) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if c == nil {
+		return ErrInvalidChallenge
+	}
+	id, err := c.GetChallengeID()
+	if err != nil {
+		return ErrInvalidChallengeId
+	}
+	im.Mutex.Lock()
+	defer im.Mutex.Unlock()
+	key := storageKey(c.Scope(), id)
+	if _, ok := im.challenges[key]; !ok {
+		return ErrChallengeNotFound
+	}
+	im.challenges[key] = *c
+	return nil
+}
+
+func (im *InMemory) DeleteChallenge(ctx context.Context, scope challenge.Scope, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	if id == "" {
 		return ErrInvalidChallengeId
 	}
 	im.Mutex.Lock()
 	defer im.Mutex.Unlock()
-	if _, ok := im.challenges[id]; !ok {
+	key := storageKey(scope, id)
+	if _, ok := im.challenges[key]; !ok {
 		return ErrChallengeNotFound
 	}
-	delete(im.challenges, id)
+	delete(im.challenges, key)
 	return nil
 }
+
+func (im *InMemory) TransitionChallenge(ctx context.Context, scope challenge.Scope, id string, from challenge.Status, transition Transition) (*challenge.Challenge, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if id == "" {
+		return nil, ErrInvalidChallengeId
+	}
+	im.Mutex.Lock()
+	defer im.Mutex.Unlock()
+	key := storageKey(scope, id)
+	current, ok := im.challenges[key]
+	if !ok {
+		return nil, ErrChallengeNotFound
+	}
+	if current.Status() != from {
+		return &current, ErrStateConflict
+	}
+	next := current
+	if err := transition(&next); err != nil {
+		return &current, err
+	}
+	im.challenges[key] = next
+	return &next, nil
+}
+
+func (im *InMemory) ListChallenges(ctx context.Context, filter ChallengeFilter) ([]*challenge.Challenge, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	im.Mutex.Lock()
+	defer im.Mutex.Unlock()
+	return im.list(filter), nil
+}
+
+// list returns copies of the challenges matching filter, callers must hold the lock
+func (im *InMemory) list(filter ChallengeFilter) []*challenge.Challenge {
+	challenges := []*challenge.Challenge{}
+	for _, c := range im.challenges {
+		c := c
+		if filter.Match(&c) {
+			challenges = append(challenges, &c)
+		}
+	}
+	return challenges
+}
@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransitionChallengeSingleClaim(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemory(Limits{})
+	c := newTestChallenge(t, "1", "g1", "c1", "challenger")
+	require.NoError(t, repo.CreateChallenge(ctx, c))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	winners := []string{}
+	conflicts := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(userID string) {
+			defer wg.Done()
+			claimed, err := repo.TransitionChallenge(ctx, c.Scope(), "1", challenge.Open, func(c *challenge.Challenge) error {
+				return c.Accept(userID, time.Now())
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				winners = append(winners, claimed.ClaimedBy())
+				return
+			}
+			if errors.Is(err, ErrStateConflict) {
+				conflicts++
+			}
+		}(fmt.Sprintf("user-%d", i))
+	}
+	wg.Wait()
+	require.Len(t, winners, 1)
+	require.Equal(t, 49, conflicts)
+
+	// only the claimant can resolve, and only once
+	other := &domain.Player{ID: "intruder", Choice: domain.Paper}
+	_, err := repo.TransitionChallenge(ctx, c.Scope(), "1", challenge.Claimed, func(c *challenge.Challenge) error {
+		return c.MakeChoice(other, time.Now())
+	})
+	require.ErrorIs(t, err, challenge.ErrNotClaimant)
+
+	opponent := &domain.Player{ID: winners[0], Choice: domain.Paper}
+	resolved, err := repo.TransitionChallenge(ctx, c.Scope(), "1", challenge.Claimed, func(c *challenge.Challenge) error {
+		return c.MakeChoice(opponent, time.Now())
+	})
+	require.NoError(t, err)
+	require.Equal(t, challenge.Resolved, resolved.Status())
+
+	_, err = repo.TransitionChallenge(ctx, c.Scope(), "1", challenge.Claimed, func(c *challenge.Challenge) error {
+		return c.MakeChoice(opponent, time.Now())
+	})
+	require.ErrorIs(t, err, ErrStateConflict)
+}
+
+func TestSameIDInTwoScopes(t *testing.T) {
+	ctx := context.Background()
+	repo := NewInMemory(Limits{})
+	scopes := []challenge.Scope{
+		{Context: challenge.GuildContext, GuildID: "g1", ChannelID: "c1"},
+		{Context: challenge.PrivateChannelContext, ChannelID: "c1"},
+		{Context: challenge.BotDMContext, ChannelID: "c1"},
+	}
+	for i, scope := range scopes {
+		c, err := challenge.NewChallenge("1", scope, &domain.Player{ID: fmt.Sprintf("user-%d", i), Choice: domain.Rock})
+		require.NoError(t, err)
+		require.NoError(t, repo.CreateChallenge(ctx, c))
+	}
+	for i, scope := range scopes {
+		got, err := repo.GetChallenge(ctx, scope, "1")
+		require.NoError(t, err)
+		require.Equal(t, fmt.Sprintf("user-%d", i), got.Challenger().ID)
+		require.Equal(t, scope, got.Scope())
+	}
+
+	// claiming or deleting the challenge of one scope leaves the others alone
+	_, err := repo.TransitionChallenge(ctx, scopes[0], "1", challenge.Open, func(c *challenge.Challenge) error {
+		return c.Accept("opponent", time.Now())
+	})
+	require.NoError(t, err)
+	require.NoError(t, repo.DeleteChallenge(ctx, scopes[1], "1"))
+	_, err = repo.GetChallenge(ctx, scopes[1], "1")
+	require.ErrorIs(t, err, ErrChallengeNotFound)
+	got, err := repo.GetChallenge(ctx, scopes[2], "1")
+	require.NoError(t, err)
+	require.Equal(t, challenge.Open, got.Status())
+}
@@ -1,9 +1,21 @@
 package memory
 
 import (
+	"context"
 	"errors"
+	"time"
 
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/ekefan/discord-bot/domain/access"
 	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/domain/commitment"
+	"github.com/ekefan/discord-bot/domain/history"
+	"github.com/ekefan/discord-bot/domain/ledger"
+	"github.com/ekefan/discord-bot/domain/rating"
+	"github.com/ekefan/discord-bot/domain/round"
+	"github.com/ekefan/discord-bot/domain/settings"
+	"github.com/ekefan/discord-bot/domain/throttle"
+	"github.com/ekefan/discord-bot/domain/tournament"
 )
 
 var (
@@ -11,10 +23,166 @@ var (
 	ErrSavingChallenge    = errors.New("can not create challenge")
 	ErrInvalidChallengeId = errors.New("challenge id is not valid")
 	ErrChallengeNotFound  = errors.New("challenge doesn't exist")
+	ErrStateConflict      = errors.New("challenge is not in the expected state")
+	ErrInvalidPlayerId    = errors.New("player id is not valid")
+	ErrInvalidTournament  = errors.New("tournament is not valid")
+	ErrTournamentNotFound = errors.New("tournament doesn't exist")
+	ErrTournamentExists   = errors.New("a tournament is already active in this channel")
+	ErrInvalidRound       = errors.New("round is not valid")
+	ErrRoundNotFound      = errors.New("round doesn't exist")
+	ErrTransactionExists  = errors.New("transaction was already posted")
+	ErrInvalidSeries      = errors.New("series is not valid")
+	ErrSeriesNotFound     = errors.New("series doesn't exist")
+	ErrInvalidCommitment  = errors.New("commitment is not valid")
+	ErrCommitmentNotFound = errors.New("commitment doesn't exist")
+	ErrGameExists         = errors.New("game was already recorded")
 )
 
+// Transition mutates a challenge during a compare-and-swap, returning an
+// error aborts the swap and leaves the stored challenge unchanged. A swap
+// that loses a race is retried on the challenge read again, so a transition
+// may run more than once and must only change the challenge it is given
+type Transition func(c *challenge.Challenge) error
+
+// ChallengeFilter selects challenges to list, empty fields match every challenge
+type ChallengeFilter struct {
+	GuildID      string
+	Scope        *challenge.Scope
+	ChallengerID string
+}
+
+// Match reports whether c is selected by the filter
+func (f ChallengeFilter) Match(c *challenge.Challenge) bool {
+	if f.GuildID != "" && c.Scope().GuildID != f.GuildID {
+		return false
+	}
+	if f.Scope != nil && c.Scope().Key() != f.Scope.Key() {
+		return false
+	}
+	if f.ChallengerID != "" && c.Challenger().ID != f.ChallengerID {
+		return false
+	}
+	return true
+}
+
+// ChallangeRespository is implemented by the backends keeping challenges, the
+// handlers use them through a ChallengeStore, see NewChallengeStore. Every call
+// gives up with the error of ctx once ctx is done
 type ChallangeRespository interface {
-	CreateChallenge(c *challenge.Challenge) error
-	GetChallenge(id string) (*challenge.Challenge, error)
-	DeleteChallenge(id string) error
+	// CreateChallenge stores c unless it exceeds the open challenge limits of
+	// the repository, in which case a *LimitError is returned
+	CreateChallenge(ctx context.Context, c *challenge.Challenge) error
+	GetChallenge(ctx context.Context, scope challenge.Scope, id string) (*challenge.Challenge, error)
+	UpdateChallenge(ctx context.Context, c *challenge.Challenge) error
+	DeleteChallenge(ctx context.Context, scope challenge.Scope, id string) error
+	// TransitionChallenge atomically applies transition to the stored challenge
+	// if it is still in the from state. ErrStateConflict is returned along with
+	// the current challenge when it is not, so exactly one caller wins a race
+	TransitionChallenge(ctx context.Context, scope challenge.Scope, id string, from challenge.Status, transition Transition) (*challenge.Challenge, error)
+	ListChallenges(ctx context.Context, filter ChallengeFilter) ([]*challenge.Challenge, error)
+}
+
+// ThrowRepository keeps the throws users made in previous games,
+// the computer player's strategies learn from it
+type ThrowRepository interface {
+	RecordThrow(userID string, choice domain.RpsChoice) error
+	// Throws returns the recorded throws of userID from oldest to newest
+	Throws(userID string) ([]domain.RpsChoice, error)
+}
+
+// TournamentRepository keeps tournaments, at most one registering or running tournament per channel
+type TournamentRepository interface {
+	// CreateTournament stores t, ErrTournamentExists is returned when the channel has an active tournament
+	CreateTournament(t *tournament.Tournament) error
+	GetTournament(id string) (*tournament.Tournament, error)
+	// ActiveTournament returns the registering or running tournament of a channel
+	ActiveTournament(scope challenge.Scope) (*tournament.Tournament, error)
+	// UpdateTournament atomically applies update to the stored tournament, returning
+	// an error leaves it unchanged and the stored tournament is returned with the error
+	UpdateTournament(id string, update func(t *tournament.Tournament) error) (*tournament.Tournament, error)
+}
+
+// RoundRepository keeps multi player rounds
+type RoundRepository interface {
+	CreateRound(r *round.Round) error
+	GetRound(id string) (*round.Round, error)
+	// UpdateRound atomically applies update to the stored round, returning
+	// an error leaves it unchanged and the stored round is returned with the error
+	UpdateRound(id string, update func(r *round.Round) error) (*round.Round, error)
+	DeleteRound(id string) error
+	ListRounds() ([]*round.Round, error)
+}
+
+// SeriesRepository keeps the series of rematches between two players
+type SeriesRepository interface {
+	SaveSeries(s *challenge.Series) error
+	GetSeries(id string) (*challenge.Series, error)
+	// UpdateSeries atomically applies update to the stored series, returning
+	// an error leaves it unchanged
+	UpdateSeries(id string, update func(s *challenge.Series) error) (*challenge.Series, error)
+	// DeleteExpiredSeries removes the series expired at now and returns how many were removed
+	DeleteExpiredSeries(now time.Time) (int, error)
+}
+
+// RatingRepository keeps the rating of each player per guild
+type RatingRepository interface {
+	// Rating returns the rating of userID, rating.Initial when they have not played
+	Rating(guildID, userID string) (int, error)
+	SetRating(guildID, userID string, rating int) error
+	// Standings returns the ratings of the players of a guild who played, highest first
+	Standings(guildID string) ([]rating.Standing, error)
+}
+
+// LedgerRepository keeps the coin balances of the economy
+type LedgerRepository interface {
+	// Post atomically applies a balanced transaction. A transaction whose id was
+	// posted before is not applied again and ErrTransactionExists is returned,
+	// a *ledger.InsufficientFundsError is returned when it would overdraw an account
+	Post(tx ledger.Transaction) error
+	// Balance returns the coins held by account, zero for an unknown account
+	Balance(account string) (int, error)
+}
+
+// CommitmentRepository keeps the commitments of fair games so they can be verified after the game
+type CommitmentRepository interface {
+	SaveCommitment(r commitment.Record) error
+	GetCommitment(gameID string) (commitment.Record, error)
+	// RevealCommitment marks the commitment of a game as revealed at t
+	RevealCommitment(gameID string, t time.Time) error
+}
+
+// HistoryRepository is the append only log of finished games
+type HistoryRepository interface {
+	// AppendGame records a finished game, ErrGameExists is returned when it was recorded before
+	AppendGame(r history.Record) error
+	// Games returns the page of games selected by q from newest to oldest
+	// and the number of games selected before paging
+	Games(q history.Query) ([]history.Record, int, error)
+}
+
+// AccessRepository keeps the access policy of each guild
+type AccessRepository interface {
+	// Policy returns the policy of a guild, an empty policy when none was set
+	Policy(guildID string) (access.Policy, error)
+	// UpdatePolicy atomically applies update to the policy of a guild,
+	// returning an error leaves it unchanged
+	UpdatePolicy(guildID string, update func(p *access.Policy) error) (access.Policy, error)
+}
+
+// SettingsRepository keeps the settings each guild overrides
+type SettingsRepository interface {
+	// Overrides returns the values a guild set, empty when it set none
+	Overrides(guildID string) (settings.Overrides, error)
+	// SetOverride stores the validated value of a setting
+	SetOverride(guildID, key, value string) error
+	// ResetOverride removes the value of a setting, of every setting when key is empty
+	ResetOverride(guildID, key string) error
+}
+
+// ThrottleRepository keeps the token buckets of the rate limiter. Take must be
+// atomic per key, instances sharing a repository then share their limits
+type ThrottleRepository interface {
+	// Take takes a token from the bucket of key at now, when there is none ok is
+	// false and retryAfter is how long until the bucket has a token again
+	Take(key string, limit throttle.Limit, now time.Time) (ok bool, retryAfter time.Duration, err error)
 }
@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/ekefan/discord-bot/domain/rating"
+)
+
+// InMemoryRatings keeps ratings in memory
+type InMemoryRatings struct {
+	ratings map[string]int
+	sync.Mutex
+}
+
+func NewInMemoryRatings() RatingRepository {
+	return &InMemoryRatings{
+		ratings: make(map[string]int),
+	}
+}
+
+func (ir *InMemoryRatings) Rating(guildID, userID string) (int, error) {
+	if userID == "" {
+		return 0, ErrInvalidPlayerId
+	}
+	ir.Mutex.Lock()
+	defer ir.Mutex.Unlock()
+	r, ok := ir.ratings[guildID+"/"+userID]
+	if !ok {
+		return rating.Initial, nil
+	}
+	return r, nil
+}
+
+func (ir *InMemoryRatings) SetRating(guildID, userID string, r int) error {
+	if userID == "" {
+		return ErrInvalidPlayerId
+	}
+	ir.Mutex.Lock()
+	defer ir.Mutex.Unlock()
+	ir.ratings[guildID+"/"+userID] = r
+	return nil
+}
+
+func (ir *InMemoryRatings) Standings(guildID string) ([]rating.Standing, error) {
+	ir.Mutex.Lock()
+	defer ir.Mutex.Unlock()
+	standings := []rating.Standing{}
+	for key, r := range ir.ratings {
+		if userID, ok := strings.CutPrefix(key, guildID+"/"); ok {
+			standings = append(standings, rating.Standing{UserID: userID, Rating: r})
+		}
+	}
+	rating.SortStandings(standings)
+	return standings, nil
+}
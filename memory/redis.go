@@ -0,0 +1,386 @@
+package memory
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/resp"
+)
+
+var (
+	ErrLockTimeout = errors.New("timed out waiting for a lock")
+	ErrContention  = errors.New("challenge changed too often to be updated")
+)
+
+const (
+	// redisTimeout bounds every repository call
+	redisTimeout = 5 * time.Second
+	// lockTTL releases the lock of an instance that died holding it
+	lockTTL = 5 * time.Second
+	// lockRetry is the wait between two attempts to take a lock
+	lockRetry = 10 * time.Millisecond
+	// casAttempts is how many times a transition is retried when the challenge changed under it
+	casAttempts = 16
+)
+
+// RedisChallenges keeps challenges in a redis compatible store so every instance of
+// the bot behind a load balancer sees the same challenges.
+//
+// Challenges are saved as JSON snapshots, transitions are compare-and-swaps over
+// WATCH, MULTI and EXEC so exactly one instance wins a claim, and creating a challenge
+// holds a lock on its guild so the open challenge limits hold across instances
+type RedisChallenges struct {
+	client *resp.Client
+	prefix string
+	limits Limits
+}
+
+// NewRedisChallenges keeps challenges under keys starting with prefix
+func NewRedisChallenges(client *resp.Client, prefix string, limits Limits) ChallangeRespository {
+	return &RedisChallenges{
+		client: client,
+		prefix: prefix,
+		limits: limits,
+	}
+}
+
+// challengeKey is the key a challenge is saved at
+func (rc *RedisChallenges) challengeKey(storageKey string) string {
+	return rc.prefix + "challenge:" + storageKey
+}
+
+// indexKey is the set of the storage keys of every saved challenge
+func (rc *RedisChallenges) indexKey() string {
+	return rc.prefix + "challenges"
+}
+
+// scopeIndexKey is the set of the storage keys of the challenges counted over one limit scope,
+// so creating a challenge reads only the challenges of its guild or DM
+func (rc *RedisChallenges) scopeIndexKey(limitScope string) string {
+	return rc.prefix + "challenges:" + limitScope
+}
+
+func (rc *RedisChallenges) CreateChallenge(ctx context.Context, c *challenge.Challenge) error {
+	if c == nil {
+		return ErrInvalidChallenge
+	}
+	id, err := c.GetChallengeID()
+	if err != nil {
+		slog.Error("error getting challenge id", logging.KeyError, err)
+		return ErrSavingChallenge
+	}
+	value, err := json.Marshal(c.Snapshot())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSavingChallenge, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+
+	unlock, err := rc.lock(ctx, limitScope(c.Scope()))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSavingChallenge, err)
+	}
+	defer unlock()
+	open, err := rc.listIndex(ctx, rc.scopeIndexKey(limitScope(c.Scope())), ChallengeFilter{})
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrSavingChallenge, err)
+	}
+	if err := rc.limits.Check(open, c); err != nil {
+		return err
+	}
+	key := storageKey(c.Scope(), id)
+	return rc.transaction(ctx, func(conn *resp.Conn) error {
+		if _, err := conn.Do(ctx, "SET", rc.challengeKey(key), string(value)); err != nil {
+			return err
+		}
+		if _, err := conn.Do(ctx, "SADD", rc.indexKey(), key); err != nil {
+			return err
+		}
+		_, err := conn.Do(ctx, "SADD", rc.scopeIndexKey(limitScope(c.Scope())), key)
+		return err
+	})
+}
+
+// limitScope is what the limits of a challenge are counted over, its guild or its DM
+func limitScope(scope challenge.Scope) string {
+	if scope.GuildID != "" {
+		return "guild:" + scope.GuildID
+	}
+	return scope.Key()
+}
+
+func (rc *RedisChallenges) GetChallenge(ctx context.Context, scope challenge.Scope, id string) (*challenge.Challenge, error) {
+	if id == "" {
+		return nil, ErrInvalidChallengeId
+	}
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	value, err := resp.String(rc.client.Do(ctx, "GET", rc.challengeKey(storageKey(scope, id))))
+	if errors.Is(err, resp.ErrNil) {
+		return nil, ErrChallengeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeChallenge(value)
+}
+
+// UpdateChallenge replaces a stored challenge with c
+func (rc *RedisChallenges) UpdateChallenge(ctx context.Context, c *challenge.Challenge) error {
+	if c == nil {
+		return ErrInvalidChallenge
+	}
+	id, err := c.GetChallengeID()
+	if err != nil {
+		return ErrInvalidChallengeId
+	}
+	value, err := json.Marshal(c.Snapshot())
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	_, err = resp.String(rc.client.Do(ctx, "SET", rc.challengeKey(storageKey(c.Scope(), id)), string(value), "XX"))
+	if errors.Is(err, resp.ErrNil) {
+		return ErrChallengeNotFound
+	}
+	return err
+}
+
+func (rc *RedisChallenges) DeleteChallenge(ctx context.Context, scope challenge.Scope, id string) error {
+	if id == "" {
+		return ErrInvalidChallengeId
+	}
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	key := storageKey(scope, id)
+	var deleted int64
+	err := rc.transaction(ctx, func(conn *resp.Conn) error {
+		if _, err := conn.Do(ctx, "DEL", rc.challengeKey(key)); err != nil {
+			return err
+		}
+		if _, err := conn.Do(ctx, "SREM", rc.indexKey(), key); err != nil {
+			return err
+		}
+		_, err := conn.Do(ctx, "SREM", rc.scopeIndexKey(limitScope(scope)), key)
+		return err
+	}, &deleted)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrChallengeNotFound
+	}
+	return nil
+}
+
+func (rc *RedisChallenges) TransitionChallenge(ctx context.Context, scope challenge.Scope, id string, from challenge.Status, transition Transition) (*challenge.Challenge, error) {
+	if id == "" {
+		return nil, ErrInvalidChallengeId
+	}
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	key := rc.challengeKey(storageKey(scope, id))
+	for attempt := 0; attempt < casAttempts; attempt++ {
+		next, swapped, err := rc.compareAndSwap(ctx, key, from, transition)
+		if swapped || err != nil {
+			return next, err
+		}
+	}
+	return nil, ErrContention
+}
+
+// compareAndSwap applies transition to the challenge saved at key unless it changes meanwhile,
+// swapped is false when it did and the transition must be retried
+func (rc *RedisChallenges) compareAndSwap(ctx context.Context, key string, from challenge.Status, transition Transition) (c *challenge.Challenge, swapped bool, err error) {
+	conn, err := rc.client.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer conn.Close()
+	if _, err := conn.Do(ctx, "WATCH", key); err != nil {
+		return nil, false, err
+	}
+	value, err := resp.String(conn.Do(ctx, "GET", key))
+	if errors.Is(err, resp.ErrNil) {
+		return nil, false, ErrChallengeNotFound
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	current, err := decodeChallenge(value)
+	if err != nil {
+		return nil, false, err
+	}
+	if current.Status() != from {
+		return current, false, ErrStateConflict
+	}
+	next := challenge.Restore(current.Snapshot())
+	if err := transition(next); err != nil {
+		return current, false, err
+	}
+	nextValue, err := json.Marshal(next.Snapshot())
+	if err != nil {
+		return current, false, err
+	}
+	if _, err := conn.Do(ctx, "MULTI"); err != nil {
+		return nil, false, err
+	}
+	if _, err := conn.Do(ctx, "SET", key, string(nextValue)); err != nil {
+		return nil, false, err
+	}
+	_, err = resp.Values(conn.Do(ctx, "EXEC"))
+	if errors.Is(err, resp.ErrNil) {
+		// changed since it was read
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return next, true, nil
+}
+
+func (rc *RedisChallenges) ListChallenges(ctx context.Context, filter ChallengeFilter) ([]*challenge.Challenge, error) {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	return rc.list(ctx, filter)
+}
+
+// list reads the challenges selected by filter from the smallest index holding them all
+func (rc *RedisChallenges) list(ctx context.Context, filter ChallengeFilter) ([]*challenge.Challenge, error) {
+	index := rc.indexKey()
+	switch {
+	case filter.Scope != nil:
+		index = rc.scopeIndexKey(limitScope(*filter.Scope))
+	case filter.GuildID != "":
+		index = rc.scopeIndexKey(limitScope(challenge.Scope{GuildID: filter.GuildID}))
+	}
+	return rc.listIndex(ctx, index, filter)
+}
+
+// listIndex reads the challenges of the index set at key that filter selects
+func (rc *RedisChallenges) listIndex(ctx context.Context, key string, filter ChallengeFilter) ([]*challenge.Challenge, error) {
+	keys, err := resp.Strings(rc.client.Do(ctx, "SMEMBERS", key))
+	if err != nil {
+		return nil, err
+	}
+	challenges := []*challenge.Challenge{}
+	if len(keys) == 0 {
+		return challenges, nil
+	}
+	args := []string{"MGET"}
+	for _, key := range keys {
+		args = append(args, rc.challengeKey(key))
+	}
+	values, err := resp.Strings(rc.client.Do(ctx, args...))
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range values {
+		if value == "" {
+			// deleted between the two reads
+			continue
+		}
+		c, err := decodeChallenge(value)
+		if err != nil {
+			return nil, err
+		}
+		if filter.Match(c) {
+			challenges = append(challenges, c)
+		}
+	}
+	return challenges, nil
+}
+
+// transaction runs queue in MULTI and EXEC, the integer replies of the
+// queued commands are stored in counts in order
+func (rc *RedisChallenges) transaction(ctx context.Context, queue func(conn *resp.Conn) error, counts ...*int64) error {
+	conn, err := rc.client.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.Do(ctx, "MULTI"); err != nil {
+		return err
+	}
+	if err := queue(conn); err != nil {
+		return err
+	}
+	replies, err := resp.Values(conn.Do(ctx, "EXEC"))
+	if err != nil {
+		return err
+	}
+	for i, count := range counts {
+		if i < len(replies) {
+			*count, _ = resp.Int(replies[i], nil)
+		}
+	}
+	for _, reply := range replies {
+		if err, ok := reply.(resp.Error); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// lock takes the lock called name, waiting for it until ctx is done. The lock expires
+// after lockTTL so an instance dying with it doesn't block the others for good
+func (rc *RedisChallenges) lock(ctx context.Context, name string) (unlock func(), err error) {
+	key := rc.prefix + "lock:" + name
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	ttl := strconv.FormatInt(lockTTL.Milliseconds(), 10)
+	for {
+		reply, err := rc.client.Do(ctx, "SET", key, token, "NX", "PX", ttl)
+		if err != nil {
+			return nil, err
+		}
+		if reply != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ErrLockTimeout, ctx.Err())
+		case <-time.After(lockRetry):
+		}
+	}
+	return func() {
+		// only the holder deletes the lock, it may have expired and been taken by another instance
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), redisTimeout)
+		defer cancel()
+		conn, err := rc.client.Conn(ctx)
+		if err != nil {
+			slog.Error("could not release lock", "lock", name, logging.KeyError, err)
+			return
+		}
+		defer conn.Close()
+		conn.Do(ctx, "WATCH", key)
+		if holder, _ := resp.String(conn.Do(ctx, "GET", key)); holder != token {
+			conn.Do(ctx, "UNWATCH")
+			return
+		}
+		conn.Do(ctx, "MULTI")
+		conn.Do(ctx, "DEL", key)
+		if _, err := conn.Do(ctx, "EXEC"); err != nil {
+			slog.Error("could not release lock", "lock", name, logging.KeyError, err)
+		}
+	}, nil
+}
+
+func decodeChallenge(value string) (*challenge.Challenge, error) {
+	var s challenge.Snapshot
+	if err := json.Unmarshal([]byte(value), &s); err != nil {
+		return nil, fmt.Errorf("decode challenge: %w", err)
+	}
+	return challenge.Restore(s), nil
+}
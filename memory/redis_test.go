@@ -0,0 +1,190 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/resp"
+	"github.com/ekefan/discord-bot/resp/resptest"
+	"github.com/stretchr/testify/require"
+)
+
+// newRedisInstances returns n repositories sharing a stand in server, as n bot instances would
+func newRedisInstances(t *testing.T, n int, limits Limits) []ChallangeRespository {
+	server := resptest.NewServer(t)
+	repos := make([]ChallangeRespository, n)
+	for i := range repos {
+		client := resp.NewClient(resp.Config{Addr: server.Addr()})
+		t.Cleanup(func() { client.Close() })
+		repos[i] = NewRedisChallenges(client, "rps:", limits)
+	}
+	return repos
+}
+
+func TestRedisChallenges(t *testing.T) {
+	ctx := context.Background()
+	repos := newRedisInstances(t, 2, Limits{})
+	a, b := repos[0], repos[1]
+	c := newTestChallenge(t, "1", "g1", "c1", "challenger")
+	c.SetWager(5)
+	require.NoError(t, a.CreateChallenge(ctx, c))
+
+	got, err := b.GetChallenge(ctx, c.Scope(), "1")
+	require.NoError(t, err)
+	require.Equal(t, "challenger", got.Challenger().ID)
+	require.Equal(t, 5, got.Wager())
+	_, err = b.GetChallenge(ctx, challenge.Scope{GuildID: "g1", ChannelID: "c2"}, "1")
+	require.ErrorIs(t, err, ErrChallengeNotFound)
+
+	got.SetOrigin(challenge.Origin{MessageID: "m1"})
+	require.NoError(t, b.UpdateChallenge(ctx, got))
+	got, err = a.GetChallenge(ctx, c.Scope(), "1")
+	require.NoError(t, err)
+	require.Equal(t, "m1", got.Origin().MessageID)
+	require.ErrorIs(t, a.UpdateChallenge(ctx, newTestChallenge(t, "2", "g1", "c1", "u1")), ErrChallengeNotFound)
+
+	require.NoError(t, a.CreateChallenge(ctx, newTestChallenge(t, "2", "g2", "c1", "u1")))
+	listed, err := b.ListChallenges(ctx, ChallengeFilter{GuildID: "g1"})
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	listed, err = b.ListChallenges(ctx, ChallengeFilter{})
+	require.NoError(t, err)
+	require.Len(t, listed, 2)
+
+	require.NoError(t, b.DeleteChallenge(ctx, c.Scope(), "1"))
+	require.ErrorIs(t, a.DeleteChallenge(ctx, c.Scope(), "1"), ErrChallengeNotFound)
+	_, err = a.GetChallenge(ctx, c.Scope(), "1")
+	require.ErrorIs(t, err, ErrChallengeNotFound)
+}
+
+func TestRedisSingleClaimAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	repos := newRedisInstances(t, 3, Limits{})
+	c := newTestChallenge(t, "1", "g1", "c1", "challenger")
+	require.NoError(t, repos[0].CreateChallenge(ctx, c))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	winners := []string{}
+	conflicts := 0
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func(repo ChallangeRespository, userID string) {
+			defer wg.Done()
+			claimed, err := repo.TransitionChallenge(ctx, c.Scope(), "1", challenge.Open, func(c *challenge.Challenge) error {
+				return c.Accept(userID, time.Now())
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				winners = append(winners, claimed.ClaimedBy())
+				return
+			}
+			if errors.Is(err, ErrStateConflict) {
+				conflicts++
+			}
+		}(repos[i%len(repos)], fmt.Sprintf("user-%d", i))
+	}
+	wg.Wait()
+	require.Len(t, winners, 1)
+	require.Equal(t, 29, conflicts)
+
+	stored, err := repos[1].GetChallenge(ctx, c.Scope(), "1")
+	require.NoError(t, err)
+	require.Equal(t, winners[0], stored.ClaimedBy())
+
+	// a refused transition leaves the stored challenge unchanged
+	other := &domain.Player{ID: "intruder", Choice: domain.Paper}
+	current, err := repos[2].TransitionChallenge(ctx, c.Scope(), "1", challenge.Claimed, func(c *challenge.Challenge) error {
+		return c.MakeChoice(other, time.Now())
+	})
+	require.ErrorIs(t, err, challenge.ErrNotClaimant)
+	require.Equal(t, challenge.Claimed, current.Status())
+
+	opponent := &domain.Player{ID: winners[0], Choice: domain.Paper}
+	resolved, err := repos[2].TransitionChallenge(ctx, c.Scope(), "1", challenge.Claimed, func(c *challenge.Challenge) error {
+		return c.MakeChoice(opponent, time.Now())
+	})
+	require.NoError(t, err)
+	require.Equal(t, challenge.Resolved, resolved.Status())
+	require.Equal(t, winners[0], resolved.Result().Winner.ID)
+}
+
+func TestRedisLimitsAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	repos := newRedisInstances(t, 4, Limits{PerUser: 1})
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		go func(i int) {
+			errs <- repos[i%len(repos)].CreateChallenge(ctx, newTestChallenge(t, fmt.Sprint(i), "g1", "c1", "u1"))
+		}(i)
+	}
+	created := 0
+	for i := 0; i < 20; i++ {
+		err := <-errs
+		if err == nil {
+			created++
+			continue
+		}
+		require.ErrorIs(t, err, ErrChallengeLimitReached)
+	}
+	require.Equal(t, 1, created)
+}
+
+func TestRedisScopeIndex(t *testing.T) {
+	ctx := context.Background()
+	server := resptest.NewServer(t)
+	client := resp.NewClient(resp.Config{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	repo := NewRedisChallenges(client, "rps:", Limits{PerGuild: 1})
+	members := func(limitScope string) []string {
+		keys, err := resp.Strings(client.Do(context.Background(), "SMEMBERS", "rps:challenges:"+limitScope))
+		require.NoError(t, err)
+		return keys
+	}
+
+	g1 := newTestChallenge(t, "1", "g1", "c1", "u1")
+	require.NoError(t, repo.CreateChallenge(ctx, g1))
+	require.NoError(t, repo.CreateChallenge(ctx, newTestChallenge(t, "2", "g2", "c1", "u1")))
+	dm, err := challenge.NewChallenge("3", challenge.Scope{ChannelID: "d1", Context: challenge.BotDMContext}, &domain.Player{ID: "u1", Choice: domain.Rock})
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateChallenge(ctx, dm))
+
+	// each create only reads the challenges of its own guild or DM
+	require.Equal(t, []string{storageKey(g1.Scope(), "1")}, members("guild:g1"))
+	require.Len(t, members("guild:g2"), 1)
+	require.Equal(t, []string{storageKey(dm.Scope(), "3")}, members(dm.Scope().Key()))
+	require.ErrorIs(t, repo.CreateChallenge(ctx, newTestChallenge(t, "4", "g1", "c2", "u2")), ErrChallengeLimitReached)
+
+	listed, err := repo.ListChallenges(ctx, ChallengeFilter{GuildID: "g1"})
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+
+	require.NoError(t, repo.DeleteChallenge(ctx, g1.Scope(), "1"))
+	require.Empty(t, members("guild:g1"))
+	require.NoError(t, repo.CreateChallenge(ctx, newTestChallenge(t, "4", "g1", "c2", "u2")))
+}
+
+func TestRedisCancelStopsCreateWaitingForLock(t *testing.T) {
+	server := resptest.NewServer(t)
+	client := resp.NewClient(resp.Config{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	repo := NewRedisChallenges(client, "rps:", Limits{})
+	// another instance holds the lock of the guild
+	_, err := client.Do(context.Background(), "SET", "rps:lock:guild:g1", "other", "NX", "PX", "60000")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	err = repo.CreateChallenge(ctx, newTestChallenge(t, "1", "g1", "c1", "u1"))
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorIs(t, err, ErrSavingChallenge)
+	require.Less(t, time.Since(start), time.Second)
+}
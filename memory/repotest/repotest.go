@@ -0,0 +1,402 @@
+// repotest package is a conformance suite for ChallangeRespository implementations,
+// every backend runs it to prove it behaves as the in memory repository does
+package repotest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/memory"
+	"github.com/stretchr/testify/require"
+)
+
+// Factory returns a new empty repository enforcing limits, repositories it
+// returns must not share challenges with each other
+type Factory func(t *testing.T, limits memory.Limits) memory.ChallangeRespository
+
+// RunChallengeRepositorySuite runs the conformance tests against the repositories of factory
+func RunChallengeRepositorySuite(t *testing.T, factory Factory) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, factory Factory)
+	}{
+		{"CreateGetDelete", testCreateGetDelete},
+		{"Errors", testErrors},
+		{"Overwrite", testOverwrite},
+		{"Copies", testCopies},
+		{"List", testList},
+		{"Transition", testTransition},
+		{"SingleClaim", testSingleClaim},
+		{"Expiry", testExpiry},
+		{"Limits", testLimits},
+		{"LimitsAtomic", testLimitsAtomic},
+		{"Concurrent", testConcurrent},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.run(t, factory)
+		})
+	}
+}
+
+func newChallenge(t *testing.T, id, guildID, channelID, userID string) *challenge.Challenge {
+	scope := challenge.Scope{GuildID: guildID, ChannelID: channelID}
+	c, err := challenge.NewChallenge(id, scope, &domain.Player{ID: userID, Choice: domain.Rock})
+	require.NoError(t, err)
+	return c
+}
+
+func ids(challenges []*challenge.Challenge) []string {
+	out := []string{}
+	for _, c := range challenges {
+		id, _ := c.GetChallengeID()
+		out = append(out, id)
+	}
+	return out
+}
+
+func testCreateGetDelete(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	repo := factory(t, memory.Limits{})
+	c := newChallenge(t, "1", "g1", "c1", "challenger")
+	c.SetWager(5)
+	require.NoError(t, repo.CreateChallenge(ctx, c))
+
+	got, err := repo.GetChallenge(ctx, c.Scope(), "1")
+	require.NoError(t, err)
+	require.Equal(t, "challenger", got.Challenger().ID)
+	require.Equal(t, domain.Rock, got.Challenger().Choice)
+	require.Equal(t, challenge.Open, got.Status())
+	require.Equal(t, 5, got.Wager())
+	require.Equal(t, c.Scope(), got.Scope())
+	require.Len(t, got.History(), len(c.History()))
+
+	// challenges are keyed by their scope
+	_, err = repo.GetChallenge(ctx, challenge.Scope{GuildID: "g1", ChannelID: "c2"}, "1")
+	require.ErrorIs(t, err, memory.ErrChallengeNotFound)
+	require.ErrorIs(t, repo.DeleteChallenge(ctx, challenge.Scope{GuildID: "g1", ChannelID: "c2"}, "1"), memory.ErrChallengeNotFound)
+
+	require.NoError(t, repo.DeleteChallenge(ctx, c.Scope(), "1"))
+	_, err = repo.GetChallenge(ctx, c.Scope(), "1")
+	require.ErrorIs(t, err, memory.ErrChallengeNotFound)
+	require.ErrorIs(t, repo.DeleteChallenge(ctx, c.Scope(), "1"), memory.ErrChallengeNotFound)
+}
+
+func testErrors(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	repo := factory(t, memory.Limits{})
+	scope := challenge.Scope{GuildID: "g1", ChannelID: "c1"}
+	accept := func(c *challenge.Challenge) error { return c.Accept("opponent", time.Now()) }
+
+	require.ErrorIs(t, repo.CreateChallenge(ctx, nil), memory.ErrInvalidChallenge)
+	require.ErrorIs(t, repo.UpdateChallenge(ctx, nil), memory.ErrInvalidChallenge)
+
+	_, err := repo.GetChallenge(ctx, scope, "")
+	require.ErrorIs(t, err, memory.ErrInvalidChallengeId)
+	require.ErrorIs(t, repo.DeleteChallenge(ctx, scope, ""), memory.ErrInvalidChallengeId)
+	_, err = repo.TransitionChallenge(ctx, scope, "", challenge.Open, accept)
+	require.ErrorIs(t, err, memory.ErrInvalidChallengeId)
+
+	_, err = repo.GetChallenge(ctx, scope, "missing")
+	require.ErrorIs(t, err, memory.ErrChallengeNotFound)
+	require.ErrorIs(t, repo.DeleteChallenge(ctx, scope, "missing"), memory.ErrChallengeNotFound)
+	require.ErrorIs(t, repo.UpdateChallenge(ctx, newChallenge(t, "missing", "g1", "c1", "u1")), memory.ErrChallengeNotFound)
+	_, err = repo.TransitionChallenge(ctx, scope, "missing", challenge.Open, accept)
+	require.ErrorIs(t, err, memory.ErrChallengeNotFound)
+}
+
+func testOverwrite(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	repo := factory(t, memory.Limits{})
+	first := newChallenge(t, "1", "g1", "c1", "first")
+	require.NoError(t, repo.CreateChallenge(ctx, first))
+
+	// creating a challenge with the id of a stored one replaces it
+	second := newChallenge(t, "1", "g1", "c1", "second")
+	require.NoError(t, repo.CreateChallenge(ctx, second))
+	got, err := repo.GetChallenge(ctx, first.Scope(), "1")
+	require.NoError(t, err)
+	require.Equal(t, "second", got.Challenger().ID)
+	listed, err := repo.ListChallenges(ctx, memory.ChallengeFilter{})
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+
+	got.SetWager(10)
+	require.NoError(t, got.Accept("opponent", time.Now()))
+	require.NoError(t, repo.UpdateChallenge(ctx, got))
+	got, err = repo.GetChallenge(ctx, first.Scope(), "1")
+	require.NoError(t, err)
+	require.Equal(t, 10, got.Wager())
+	require.Equal(t, challenge.Claimed, got.Status())
+	require.Equal(t, "opponent", got.ClaimedBy())
+}
+
+func testCopies(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	repo := factory(t, memory.Limits{})
+	c := newChallenge(t, "1", "g1", "c1", "challenger")
+	require.NoError(t, repo.CreateChallenge(ctx, c))
+
+	// the stored challenge only changes through the repository
+	c.SetWager(1)
+	got, err := repo.GetChallenge(ctx, c.Scope(), "1")
+	require.NoError(t, err)
+	require.Zero(t, got.Wager())
+	got.SetWager(2)
+	require.NoError(t, got.Accept("opponent", time.Now()))
+	listed, err := repo.ListChallenges(ctx, memory.ChallengeFilter{})
+	require.NoError(t, err)
+	listed[0].SetWager(3)
+
+	got, err = repo.GetChallenge(ctx, c.Scope(), "1")
+	require.NoError(t, err)
+	require.Zero(t, got.Wager())
+	require.Equal(t, challenge.Open, got.Status())
+}
+
+func testList(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	repo := factory(t, memory.Limits{})
+	listed, err := repo.ListChallenges(ctx, memory.ChallengeFilter{})
+	require.NoError(t, err)
+	require.NotNil(t, listed)
+	require.Empty(t, listed)
+
+	require.NoError(t, repo.CreateChallenge(ctx, newChallenge(t, "1", "g1", "c1", "u1")))
+	require.NoError(t, repo.CreateChallenge(ctx, newChallenge(t, "2", "g1", "c2", "u1")))
+	require.NoError(t, repo.CreateChallenge(ctx, newChallenge(t, "3", "g1", "c1", "u2")))
+	require.NoError(t, repo.CreateChallenge(ctx, newChallenge(t, "4", "g2", "c3", "u1")))
+
+	scope := challenge.Scope{GuildID: "g1", ChannelID: "c1"}
+	for _, tc := range []struct {
+		filter memory.ChallengeFilter
+		want   []string
+	}{
+		{memory.ChallengeFilter{}, []string{"1", "2", "3", "4"}},
+		{memory.ChallengeFilter{GuildID: "g1"}, []string{"1", "2", "3"}},
+		{memory.ChallengeFilter{Scope: &scope}, []string{"1", "3"}},
+		{memory.ChallengeFilter{ChallengerID: "u1"}, []string{"1", "2", "4"}},
+		{memory.ChallengeFilter{GuildID: "g1", ChallengerID: "u1"}, []string{"1", "2"}},
+		{memory.ChallengeFilter{GuildID: "g3"}, []string{}},
+	} {
+		listed, err := repo.ListChallenges(ctx, tc.filter)
+		require.NoError(t, err)
+		require.ElementsMatch(t, tc.want, ids(listed), "%+v", tc.filter)
+	}
+}
+
+func testTransition(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	repo := factory(t, memory.Limits{})
+	c := newChallenge(t, "1", "g1", "c1", "challenger")
+	require.NoError(t, repo.CreateChallenge(ctx, c))
+
+	// a failed transition leaves the stored challenge unchanged and returns it
+	current, err := repo.TransitionChallenge(ctx, c.Scope(), "1", challenge.Open, func(c *challenge.Challenge) error {
+		c.SetWager(99)
+		return challenge.ErrNotClaimant
+	})
+	require.ErrorIs(t, err, challenge.ErrNotClaimant)
+	require.Equal(t, challenge.Open, current.Status())
+	got, err := repo.GetChallenge(ctx, c.Scope(), "1")
+	require.NoError(t, err)
+	require.Zero(t, got.Wager())
+
+	claimed, err := repo.TransitionChallenge(ctx, c.Scope(), "1", challenge.Open, func(c *challenge.Challenge) error {
+		return c.Accept("opponent", time.Now())
+	})
+	require.NoError(t, err)
+	require.Equal(t, challenge.Claimed, claimed.Status())
+
+	// the wrong from state is a conflict and returns the current challenge
+	current, err = repo.TransitionChallenge(ctx, c.Scope(), "1", challenge.Open, func(c *challenge.Challenge) error {
+		return c.Accept("late", time.Now())
+	})
+	require.ErrorIs(t, err, memory.ErrStateConflict)
+	require.Equal(t, "opponent", current.ClaimedBy())
+
+	opponent := &domain.Player{ID: "opponent", Choice: domain.Paper}
+	resolved, err := repo.TransitionChallenge(ctx, c.Scope(), "1", challenge.Claimed, func(c *challenge.Challenge) error {
+		return c.MakeChoice(opponent, time.Now())
+	})
+	require.NoError(t, err)
+	require.Equal(t, challenge.Resolved, resolved.Status())
+	require.Equal(t, "opponent", resolved.Result().Winner.ID)
+	got, err = repo.GetChallenge(ctx, c.Scope(), "1")
+	require.NoError(t, err)
+	require.Equal(t, challenge.Resolved, got.Status())
+	require.Equal(t, "opponent", got.Result().Winner.ID)
+}
+
+func testSingleClaim(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	repo := factory(t, memory.Limits{})
+	c := newChallenge(t, "1", "g1", "c1", "challenger")
+	require.NoError(t, repo.CreateChallenge(ctx, c))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	winners := []string{}
+	conflicts := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(userID string) {
+			defer wg.Done()
+			claimed, err := repo.TransitionChallenge(ctx, c.Scope(), "1", challenge.Open, func(c *challenge.Challenge) error {
+				return c.Accept(userID, time.Now())
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				winners = append(winners, claimed.ClaimedBy())
+				return
+			}
+			if errors.Is(err, memory.ErrStateConflict) {
+				conflicts++
+			}
+		}(fmt.Sprintf("user-%d", i))
+	}
+	wg.Wait()
+	require.Len(t, winners, 1)
+	require.Equal(t, 19, conflicts)
+	got, err := repo.GetChallenge(ctx, c.Scope(), "1")
+	require.NoError(t, err)
+	require.Equal(t, winners[0], got.ClaimedBy())
+}
+
+func testExpiry(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	repo := factory(t, memory.Limits{PerUser: 1})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := newChallenge(t, "1", "g1", "c1", "challenger")
+	c.SetExpiry(now.Add(10 * time.Minute))
+	require.NoError(t, repo.CreateChallenge(ctx, c))
+
+	got, err := repo.GetChallenge(ctx, c.Scope(), "1")
+	require.NoError(t, err)
+	require.True(t, got.ExpiresAt().Equal(now.Add(10*time.Minute)))
+	require.False(t, got.ExpiredAt(now))
+
+	// repositories keep challenges past their expiry, expiring them is a transition
+	later := now.Add(time.Hour)
+	got, err = repo.GetChallenge(ctx, c.Scope(), "1")
+	require.NoError(t, err)
+	require.True(t, got.ExpiredAt(later))
+	require.ErrorIs(t, repo.CreateChallenge(ctx, newChallenge(t, "2", "g1", "c1", "challenger")), memory.ErrChallengeLimitReached)
+
+	expired, err := repo.TransitionChallenge(ctx, c.Scope(), "1", challenge.Open, func(c *challenge.Challenge) error {
+		return c.Expire(later)
+	})
+	require.NoError(t, err)
+	require.Equal(t, challenge.Expired, expired.Status())
+	_, err = repo.TransitionChallenge(ctx, c.Scope(), "1", challenge.Open, func(c *challenge.Challenge) error {
+		return c.Expire(later)
+	})
+	require.ErrorIs(t, err, memory.ErrStateConflict)
+
+	// an expired challenge no longer counts towards the limits
+	require.NoError(t, repo.CreateChallenge(ctx, newChallenge(t, "2", "g1", "c1", "challenger")))
+}
+
+func testLimits(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	repo := factory(t, memory.Limits{PerUser: 1, PerChannel: 2, PerGuild: 3})
+
+	require.NoError(t, repo.CreateChallenge(ctx, newChallenge(t, "1", "g1", "c1", "u1")))
+	err := repo.CreateChallenge(ctx, newChallenge(t, "2", "g1", "c1", "u1"))
+	var limitErr *memory.LimitError
+	require.True(t, errors.As(err, &limitErr))
+	require.ErrorIs(t, err, memory.ErrChallengeLimitReached)
+	require.Equal(t, memory.UserLimit, limitErr.Limit)
+	require.Equal(t, []string{"1"}, ids([]*challenge.Challenge{limitErr.Existing}))
+
+	require.NoError(t, repo.CreateChallenge(ctx, newChallenge(t, "3", "g1", "c2", "u1")))
+	require.NoError(t, repo.CreateChallenge(ctx, newChallenge(t, "4", "g1", "c1", "u2")))
+	err = repo.CreateChallenge(ctx, newChallenge(t, "5", "g1", "c1", "u3"))
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, memory.ChannelLimit, limitErr.Limit)
+	err = repo.CreateChallenge(ctx, newChallenge(t, "6", "g1", "c3", "u3"))
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, memory.GuildLimit, limitErr.Limit)
+
+	// other guilds are unaffected and withdrawing frees the slot
+	require.NoError(t, repo.CreateChallenge(ctx, newChallenge(t, "7", "g2", "c1", "u1")))
+	require.NoError(t, repo.DeleteChallenge(ctx, challenge.Scope{GuildID: "g1", ChannelID: "c1"}, "1"))
+	require.NoError(t, repo.CreateChallenge(ctx, newChallenge(t, "8", "g1", "c1", "u1")))
+
+	// tournament matches are never limited
+	match, err := challenge.NewMatch("9", challenge.Scope{GuildID: "g1", ChannelID: "c1"}, "u1", "u2")
+	require.NoError(t, err)
+	match.SetTournament("t1", "M1")
+	require.NoError(t, repo.CreateChallenge(ctx, match))
+}
+
+func testLimitsAtomic(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	repo := factory(t, memory.Limits{PerUser: 1})
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		go func(i int) {
+			errs <- repo.CreateChallenge(ctx, newChallenge(t, fmt.Sprint(i), "g1", "c1", "u1"))
+		}(i)
+	}
+	created := 0
+	for i := 0; i < 20; i++ {
+		if err := <-errs; err == nil {
+			created++
+		} else {
+			require.ErrorIs(t, err, memory.ErrChallengeLimitReached)
+		}
+	}
+	require.Equal(t, 1, created)
+}
+
+func testConcurrent(t *testing.T, factory Factory) {
+	ctx := context.Background()
+	repo := factory(t, memory.Limits{})
+	const workers = 8
+	const perWorker = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*perWorker*5)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			channelID := fmt.Sprintf("c%d", w)
+			for i := 0; i < perWorker; i++ {
+				id := fmt.Sprint(i)
+				c := newChallenge(t, id, "g1", channelID, fmt.Sprintf("u%d-%d", w, i))
+				errs <- repo.CreateChallenge(ctx, c)
+				_, err := repo.GetChallenge(ctx, c.Scope(), id)
+				errs <- err
+				_, err = repo.ListChallenges(ctx, memory.ChallengeFilter{GuildID: "g1"})
+				errs <- err
+				_, err = repo.TransitionChallenge(ctx, c.Scope(), id, challenge.Open, func(c *challenge.Challenge) error {
+					return c.Accept("opponent", time.Now())
+				})
+				errs <- err
+				// every other challenge is withdrawn
+				if i%2 == 0 {
+					errs <- repo.DeleteChallenge(ctx, c.Scope(), id)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	listed, err := repo.ListChallenges(ctx, memory.ChallengeFilter{})
+	require.NoError(t, err)
+	require.Len(t, listed, workers*perWorker/2)
+	for _, c := range listed {
+		require.Equal(t, challenge.Claimed, c.Status())
+	}
+}
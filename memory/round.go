@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/ekefan/discord-bot/domain/round"
+)
+
+// InMemoryRounds keeps rounds in memory, stored rounds are cloned so
+// callers never share state with the repository
+type InMemoryRounds struct {
+	rounds map[string]*round.Round
+	sync.Mutex
+}
+
+func NewInMemoryRounds() RoundRepository {
+	return &InMemoryRounds{
+		rounds: make(map[string]*round.Round),
+	}
+}
+
+func (ir *InMemoryRounds) CreateRound(r *round.Round) error {
+	if r == nil || r.ID() == "" {
+		return ErrInvalidRound
+	}
+	ir.Mutex.Lock()
+	defer ir.Mutex.Unlock()
+	ir.rounds[r.ID()] = r.Clone()
+	return nil
+}
+
+func (ir *InMemoryRounds) GetRound(id string) (*round.Round, error) {
+	ir.Mutex.Lock()
+	defer ir.Mutex.Unlock()
+	r, ok := ir.rounds[id]
+	if !ok {
+		return nil, ErrRoundNotFound
+	}
+	return r.Clone(), nil
+}
+
+func (ir *InMemoryRounds) UpdateRound(id string, update func(r *round.Round) error) (*round.Round, error) {
+	ir.Mutex.Lock()
+	defer ir.Mutex.Unlock()
+	stored, ok := ir.rounds[id]
+	if !ok {
+		return nil, ErrRoundNotFound
+	}
+	r := stored.Clone()
+	if err := update(r); err != nil {
+		return stored.Clone(), err
+	}
+	ir.rounds[id] = r.Clone()
+	return r, nil
+}
+
+func (ir *InMemoryRounds) DeleteRound(id string) error {
+	ir.Mutex.Lock()
+	defer ir.Mutex.Unlock()
+	if _, ok := ir.rounds[id]; !ok {
+		return ErrRoundNotFound
+	}
+	delete(ir.rounds, id)
+	return nil
+}
+
+func (ir *InMemoryRounds) ListRounds() ([]*round.Round, error) {
+	ir.Mutex.Lock()
+	defer ir.Mutex.Unlock()
+	rounds := make([]*round.Round, 0, len(ir.rounds))
+	for _, r := range ir.rounds {
+		rounds = append(rounds, r.Clone())
+	}
+	return rounds, nil
+}
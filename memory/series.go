@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain/challenge"
+)
+
+// InMemorySeries keeps rematch series in memory
+type InMemorySeries struct {
+	series map[string]challenge.Series
+	sync.Mutex
+}
+
+func NewInMemorySeries() SeriesRepository {
+	return &InMemorySeries{
+		series: make(map[string]challenge.Series),
+	}
+}
+
+func (is *InMemorySeries) SaveSeries(s *challenge.Series) error {
+	if s == nil || s.ID == "" {
+		return ErrInvalidSeries
+	}
+	is.Mutex.Lock()
+	defer is.Mutex.Unlock()
+	is.series[s.ID] = *s
+	return nil
+}
+
+func (is *InMemorySeries) GetSeries(id string) (*challenge.Series, error) {
+	is.Mutex.Lock()
+	defer is.Mutex.Unlock()
+	s, ok := is.series[id]
+	if !ok {
+		return nil, ErrSeriesNotFound
+	}
+	return &s, nil
+}
+
+func (is *InMemorySeries) UpdateSeries(id string, update func(s *challenge.Series) error) (*challenge.Series, error) {
+	is.Mutex.Lock()
+	defer is.Mutex.Unlock()
+	s, ok := is.series[id]
+	if !ok {
+		return nil, ErrSeriesNotFound
+	}
+	if err := update(&s); err != nil {
+		return nil, err
+	}
+	is.series[id] = s
+	return &s, nil
+}
+
+func (is *InMemorySeries) DeleteExpiredSeries(now time.Time) (int, error) {
+	is.Mutex.Lock()
+	defer is.Mutex.Unlock()
+	deleted := 0
+	for id, s := range is.series {
+		if s.ExpiredAt(now) {
+			delete(is.series, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
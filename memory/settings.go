@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/ekefan/discord-bot/domain/settings"
+)
+
+// InMemorySettings keeps the settings guilds override in memory
+type InMemorySettings struct {
+	overrides map[string]settings.Overrides
+	sync.Mutex
+}
+
+func NewInMemorySettings() SettingsRepository {
+	return &InMemorySettings{
+		overrides: make(map[string]settings.Overrides),
+	}
+}
+
+func (is *InMemorySettings) Overrides(guildID string) (settings.Overrides, error) {
+	is.Mutex.Lock()
+	defer is.Mutex.Unlock()
+	return maps.Clone(is.overrides[guildID]), nil
+}
+
+func (is *InMemorySettings) SetOverride(guildID, key, value string) error {
+	is.Mutex.Lock()
+	defer is.Mutex.Unlock()
+	is.set(guildID, key, value)
+	return nil
+}
+
+func (is *InMemorySettings) ResetOverride(guildID, key string) error {
+	is.Mutex.Lock()
+	defer is.Mutex.Unlock()
+	is.reset(guildID, key)
+	return nil
+}
+
+func (is *InMemorySettings) set(guildID, key, value string) {
+	if is.overrides[guildID] == nil {
+		is.overrides[guildID] = make(settings.Overrides)
+	}
+	is.overrides[guildID][key] = value
+}
+
+func (is *InMemorySettings) reset(guildID, key string) {
+	if key == "" {
+		delete(is.overrides, guildID)
+		return
+	}
+	delete(is.overrides[guildID], key)
+	if len(is.overrides[guildID]) == 0 {
+		delete(is.overrides, guildID)
+	}
+}
+
+// FileSettings keeps the settings guilds override in memory and writes
+// every change to a JSON file, so they survive restarts
+type FileSettings struct {
+	InMemorySettings
+	path string
+}
+
+// NewFileSettings loads the settings stored at path, a missing file holds no settings
+func NewFileSettings(path string) (SettingsRepository, error) {
+	fs := &FileSettings{
+		InMemorySettings: InMemorySettings{overrides: make(map[string]settings.Overrides)},
+		path:             path,
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &fs.overrides); err != nil {
+		return nil, err
+	}
+	if fs.overrides == nil {
+		// the file holds null
+		fs.overrides = make(map[string]settings.Overrides)
+	}
+	return fs, nil
+}
+
+func (fs *FileSettings) SetOverride(guildID, key, value string) error {
+	fs.Mutex.Lock()
+	defer fs.Mutex.Unlock()
+	previous, had := fs.overrides[guildID][key]
+	fs.set(guildID, key, value)
+	if err := fs.save(); err != nil {
+		// keep memory in line with the file
+		if had {
+			fs.set(guildID, key, previous)
+		} else {
+			fs.reset(guildID, key)
+		}
+		return err
+	}
+	return nil
+}
+
+func (fs *FileSettings) ResetOverride(guildID, key string) error {
+	fs.Mutex.Lock()
+	defer fs.Mutex.Unlock()
+	previous := maps.Clone(fs.overrides[guildID])
+	fs.reset(guildID, key)
+	if err := fs.save(); err != nil {
+		if previous != nil {
+			fs.overrides[guildID] = previous
+		}
+		return err
+	}
+	return nil
+}
+
+// save atomically replaces the file with the current settings
+func (fs *FileSettings) save() error {
+	data, err := json.MarshalIndent(fs.overrides, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(fs.path), filepath.Base(fs.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), fs.path)
+}
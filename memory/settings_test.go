@@ -0,0 +1,56 @@
+package memory
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ekefan/discord-bot/domain/settings"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	repo, err := NewFileSettings(path)
+	require.NoError(t, err)
+
+	overrides, err := repo.Overrides("g1")
+	require.NoError(t, err)
+	require.Empty(t, overrides)
+
+	require.NoError(t, repo.SetOverride("g1", settings.KeyEconomy, "off"))
+	require.NoError(t, repo.SetOverride("g1", settings.KeyChallengeTTL, "5m0s"))
+	require.NoError(t, repo.SetOverride("g2", settings.KeyEconomy, "off"))
+	require.NoError(t, repo.ResetOverride("g1", settings.KeyChallengeTTL))
+	require.NoError(t, repo.ResetOverride("g2", ""))
+
+	// the overrides returned are a copy
+	overrides, err = repo.Overrides("g1")
+	require.NoError(t, err)
+	overrides[settings.KeyLeaderboard] = settings.LeaderboardOff
+
+	reloaded, err := NewFileSettings(path)
+	require.NoError(t, err)
+	overrides, err = reloaded.Overrides("g1")
+	require.NoError(t, err)
+	require.Equal(t, settings.Overrides{settings.KeyEconomy: "off"}, overrides)
+	overrides, err = reloaded.Overrides("g2")
+	require.NoError(t, err)
+	require.Empty(t, overrides)
+
+	require.NoError(t, os.WriteFile(path, []byte("not json"), 0o600))
+	_, err = NewFileSettings(path)
+	require.Error(t, err)
+}
+
+func TestFileSettingsNull(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	require.NoError(t, os.WriteFile(path, []byte("null"), 0o600))
+	repo, err := NewFileSettings(path)
+	require.NoError(t, err)
+
+	require.NoError(t, repo.SetOverride("g1", settings.KeyEconomy, "off"))
+	overrides, err := repo.Overrides("g1")
+	require.NoError(t, err)
+	require.Equal(t, settings.Overrides{settings.KeyEconomy: "off"}, overrides)
+}
@@ -0,0 +1,204 @@
+package memory
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/ekefan/discord-bot/domain/challenge"
+)
+
+var (
+	ErrVersionConflict = errors.New("challenge was changed since it was read")
+	ErrInvalidCursor   = errors.New("cursor is not valid")
+)
+
+// ChallengeStore keeps challenges like ChallangeRespository, with versions and paged lists.
+// Stored challenges carry a version that grows with every change, so a challenge read
+// and changed by a caller only replaces the stored one when nobody changed it meanwhile
+type ChallengeStore interface {
+	// CreateChallenge stores c at version 1 unless it exceeds the open challenge
+	// limits of the store, in which case a *LimitError is returned
+	CreateChallenge(ctx context.Context, c *challenge.Challenge) error
+	GetChallenge(ctx context.Context, scope challenge.Scope, id string) (*challenge.Challenge, error)
+	// UpdateChallenge replaces the stored challenge with c when it is still at the
+	// version c was read as and moves c to the next version. ErrVersionConflict is
+	// returned when the stored challenge changed since, c must be read again
+	UpdateChallenge(ctx context.Context, c *challenge.Challenge) error
+	DeleteChallenge(ctx context.Context, scope challenge.Scope, id string) error
+	// TransitionChallenge atomically applies transition to the stored challenge
+	// if it is still in the from state. ErrStateConflict is returned along with
+	// the current challenge when it is not, so exactly one caller wins a race
+	TransitionChallenge(ctx context.Context, scope challenge.Scope, id string, from challenge.Status, transition Transition) (*challenge.Challenge, error)
+	// ListChallenges returns the page of the challenges selected by q, ordered by scope and id
+	ListChallenges(ctx context.Context, q ChallengeQuery) (ChallengePage, error)
+	// CountOpen returns the number of open challenges selected by filter
+	CountOpen(ctx context.Context, filter ChallengeFilter) (int, error)
+}
+
+// ChallengeQuery selects a page of challenges, empty fields match every challenge
+type ChallengeQuery struct {
+	ChallengeFilter
+	UserID   string             // challenges the user issued or claimed
+	Statuses []challenge.Status // challenges in any of the states
+	// Cursor is the Next of the previous page, empty for the first page
+	Cursor string
+	Limit  int // zero returns every challenge past Cursor
+}
+
+// Match reports whether c is selected by the query, ignoring Cursor and Limit
+func (q ChallengeQuery) Match(c *challenge.Challenge) bool {
+	if !q.ChallengeFilter.Match(c) {
+		return false
+	}
+	if q.UserID != "" && c.Challenger().ID != q.UserID && c.ClaimedBy() != q.UserID {
+		return false
+	}
+	if len(q.Statuses) > 0 && !slices.Contains(q.Statuses, c.Status()) {
+		return false
+	}
+	return true
+}
+
+// ChallengePage is a page of challenges
+type ChallengePage struct {
+	Challenges []*challenge.Challenge
+	// Next is the cursor of the next page, empty on the last page
+	Next string
+}
+
+// RepositoryStore adapts a ChallangeRespository to a ChallengeStore. Versions are kept
+// on the challenges themselves, they only hold when every change goes through the store
+type RepositoryStore struct {
+	repo ChallangeRespository
+}
+
+func NewChallengeStore(repo ChallangeRespository) ChallengeStore {
+	return &RepositoryStore{repo: repo}
+}
+
+func (rs *RepositoryStore) CreateChallenge(ctx context.Context, c *challenge.Challenge) error {
+	if c == nil {
+		return ErrInvalidChallenge
+	}
+	c.SetVersion(1)
+	return rs.repo.CreateChallenge(ctx, c)
+}
+
+func (rs *RepositoryStore) GetChallenge(ctx context.Context, scope challenge.Scope, id string) (*challenge.Challenge, error) {
+	return rs.repo.GetChallenge(ctx, scope, id)
+}
+
+func (rs *RepositoryStore) UpdateChallenge(ctx context.Context, c *challenge.Challenge) error {
+	if c == nil {
+		return ErrInvalidChallenge
+	}
+	id, err := c.GetChallengeID()
+	if err != nil {
+		return ErrInvalidChallengeId
+	}
+	current, err := rs.repo.GetChallenge(ctx, c.Scope(), id)
+	if err != nil {
+		return err
+	}
+	if current.Version() != c.Version() {
+		return ErrVersionConflict
+	}
+	next := challenge.Restore(c.Snapshot())
+	next.SetVersion(c.Version() + 1)
+	// the swap only happens while the stored challenge is at the version it was read as
+	_, err = rs.repo.TransitionChallenge(ctx, c.Scope(), id, current.Status(), func(stored *challenge.Challenge) error {
+		if stored.Version() != c.Version() {
+			return ErrVersionConflict
+		}
+		*stored = *next
+		return nil
+	})
+	if errors.Is(err, ErrStateConflict) {
+		return ErrVersionConflict
+	}
+	if err != nil {
+		return err
+	}
+	c.SetVersion(next.Version())
+	return nil
+}
+
+func (rs *RepositoryStore) DeleteChallenge(ctx context.Context, scope challenge.Scope, id string) error {
+	return rs.repo.DeleteChallenge(ctx, scope, id)
+}
+
+func (rs *RepositoryStore) TransitionChallenge(ctx context.Context, scope challenge.Scope, id string, from challenge.Status, transition Transition) (*challenge.Challenge, error) {
+	return rs.repo.TransitionChallenge(ctx, scope, id, from, func(c *challenge.Challenge) error {
+		if err := transition(c); err != nil {
+			return err
+		}
+		c.SetVersion(c.Version() + 1)
+		return nil
+	})
+}
+
+func (rs *RepositoryStore) ListChallenges(ctx context.Context, q ChallengeQuery) (ChallengePage, error) {
+	after, err := decodeCursor(q.Cursor)
+	if err != nil {
+		return ChallengePage{}, err
+	}
+	listed, err := rs.repo.ListChallenges(ctx, q.ChallengeFilter)
+	if err != nil {
+		return ChallengePage{}, err
+	}
+	type keyed struct {
+		key string
+		c   *challenge.Challenge
+	}
+	selected := []keyed{}
+	for _, c := range listed {
+		id, _ := c.GetChallengeID()
+		key := storageKey(c.Scope(), id)
+		if q.Match(c) && (after == "" || key > after) {
+			selected = append(selected, keyed{key, c})
+		}
+	}
+	slices.SortFunc(selected, func(a, b keyed) int { return strings.Compare(a.key, b.key) })
+	page := ChallengePage{Challenges: []*challenge.Challenge{}}
+	if q.Limit > 0 && q.Limit < len(selected) {
+		selected = selected[:q.Limit]
+		page.Next = encodeCursor(selected[len(selected)-1].key)
+	}
+	for _, s := range selected {
+		page.Challenges = append(page.Challenges, s.c)
+	}
+	return page, nil
+}
+
+func (rs *RepositoryStore) CountOpen(ctx context.Context, filter ChallengeFilter) (int, error) {
+	listed, err := rs.repo.ListChallenges(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	open := 0
+	for _, c := range listed {
+		if c.Status() == challenge.Open {
+			open++
+		}
+	}
+	return open, nil
+}
+
+// cursors are the storage key of the last challenge of a page, encoded so callers don't read into them
+func encodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+	key, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(key) == 0 {
+		return "", ErrInvalidCursor
+	}
+	return string(key), nil
+}
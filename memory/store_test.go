@@ -0,0 +1,135 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChallengeStoreVersions(t *testing.T) {
+	store := NewChallengeStore(NewInMemory(Limits{}))
+	ctx := context.Background()
+	c := newTestChallenge(t, "1", "g1", "c1", "challenger")
+	require.NoError(t, store.CreateChallenge(ctx, c))
+	require.Equal(t, 1, c.Version())
+
+	first, err := store.GetChallenge(ctx, c.Scope(), "1")
+	require.NoError(t, err)
+	second, err := store.GetChallenge(ctx, c.Scope(), "1")
+	require.NoError(t, err)
+
+	first.SetWager(5)
+	require.NoError(t, store.UpdateChallenge(ctx, first))
+	require.Equal(t, 2, first.Version())
+
+	// second was read before first was saved, its change would be lost
+	second.SetWager(10)
+	require.ErrorIs(t, store.UpdateChallenge(ctx, second), ErrVersionConflict)
+
+	claimed, err := store.TransitionChallenge(ctx, c.Scope(), "1", challenge.Open, func(c *challenge.Challenge) error {
+		return c.Accept("opponent", time.Now())
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, claimed.Version())
+	require.ErrorIs(t, store.UpdateChallenge(ctx, first), ErrVersionConflict)
+
+	got, err := store.GetChallenge(ctx, c.Scope(), "1")
+	require.NoError(t, err)
+	require.Equal(t, 5, got.Wager())
+	require.Equal(t, challenge.Claimed, got.Status())
+	got.SetWager(20)
+	require.NoError(t, store.UpdateChallenge(ctx, got))
+	got, err = store.GetChallenge(ctx, c.Scope(), "1")
+	require.NoError(t, err)
+	require.Equal(t, 20, got.Wager())
+	require.Equal(t, "opponent", got.ClaimedBy())
+	require.Equal(t, 4, got.Version())
+
+	require.ErrorIs(t, store.UpdateChallenge(ctx, newTestChallenge(t, "2", "g1", "c1", "x")), ErrChallengeNotFound)
+	require.ErrorIs(t, store.UpdateChallenge(ctx, nil), ErrInvalidChallenge)
+}
+
+func TestChallengeStoreList(t *testing.T) {
+	store := NewChallengeStore(NewInMemory(Limits{}))
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		require.NoError(t, store.CreateChallenge(ctx, newTestChallenge(t, fmt.Sprint(i), "g1", "c1", fmt.Sprintf("u%d", i))))
+	}
+	require.NoError(t, store.CreateChallenge(ctx, newTestChallenge(t, "5", "g1", "c2", "u0")))
+	require.NoError(t, store.CreateChallenge(ctx, newTestChallenge(t, "6", "g2", "c3", "u0")))
+	_, err := store.TransitionChallenge(ctx, challenge.Scope{GuildID: "g1", ChannelID: "c1"}, "1", challenge.Open, func(c *challenge.Challenge) error {
+		return c.Accept("u0", time.Now())
+	})
+	require.NoError(t, err)
+
+	ids := func(page ChallengePage) []string {
+		out := []string{}
+		for _, c := range page.Challenges {
+			id, _ := c.GetChallengeID()
+			out = append(out, id)
+		}
+		return out
+	}
+	scope := challenge.Scope{GuildID: "g1", ChannelID: "c1"}
+	for _, tc := range []struct {
+		q    ChallengeQuery
+		want []string
+	}{
+		{ChallengeQuery{}, []string{"0", "1", "2", "3", "4", "5", "6"}},
+		{ChallengeQuery{ChallengeFilter: ChallengeFilter{GuildID: "g1"}}, []string{"0", "1", "2", "3", "4", "5"}},
+		{ChallengeQuery{ChallengeFilter: ChallengeFilter{Scope: &scope}}, []string{"0", "1", "2", "3", "4"}},
+		{ChallengeQuery{UserID: "u0"}, []string{"0", "1", "5", "6"}},
+		{ChallengeQuery{UserID: "u0", Statuses: []challenge.Status{challenge.Open}}, []string{"0", "5", "6"}},
+		{ChallengeQuery{Statuses: []challenge.Status{challenge.Claimed, challenge.Resolved}}, []string{"1"}},
+	} {
+		page, err := store.ListChallenges(ctx, tc.q)
+		require.NoError(t, err)
+		require.Equal(t, tc.want, ids(page), "%+v", tc.q)
+		require.Empty(t, page.Next)
+	}
+
+	// pages follow each other even when challenges are removed in between
+	q := ChallengeQuery{ChallengeFilter: ChallengeFilter{Scope: &scope}, Limit: 2}
+	page, err := store.ListChallenges(ctx, q)
+	require.NoError(t, err)
+	require.Equal(t, []string{"0", "1"}, ids(page))
+	require.NotEmpty(t, page.Next)
+	require.NoError(t, store.DeleteChallenge(ctx, scope, "1"))
+	q.Cursor = page.Next
+	page, err = store.ListChallenges(ctx, q)
+	require.NoError(t, err)
+	require.Equal(t, []string{"2", "3"}, ids(page))
+	q.Cursor = page.Next
+	page, err = store.ListChallenges(ctx, q)
+	require.NoError(t, err)
+	require.Equal(t, []string{"4"}, ids(page))
+	require.Empty(t, page.Next)
+
+	_, err = store.ListChallenges(ctx, ChallengeQuery{Cursor: "not a cursor"})
+	require.ErrorIs(t, err, ErrInvalidCursor)
+
+	open, err := store.CountOpen(ctx, ChallengeFilter{})
+	require.NoError(t, err)
+	require.Equal(t, 6, open)
+	open, err = store.CountOpen(ctx, ChallengeFilter{GuildID: "g2"})
+	require.NoError(t, err)
+	require.Equal(t, 1, open)
+}
+
+func TestChallengeStoreContext(t *testing.T) {
+	store := NewChallengeStore(NewInMemory(Limits{}))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c := newTestChallenge(t, "1", "g1", "c1", "challenger")
+	require.ErrorIs(t, store.CreateChallenge(ctx, c), context.Canceled)
+	_, err := store.GetChallenge(ctx, c.Scope(), "1")
+	require.ErrorIs(t, err, context.Canceled)
+	_, err = store.ListChallenges(ctx, ChallengeQuery{})
+	require.ErrorIs(t, err, context.Canceled)
+	_, err = store.CountOpen(ctx, ChallengeFilter{})
+	require.ErrorIs(t, err, context.Canceled)
+}
@@ -0,0 +1,46 @@
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain/throttle"
+)
+
+// throttleSweepEvery is the number of takes between sweeps of the full buckets
+const throttleSweepEvery = 1024
+
+type throttleEntry struct {
+	bucket throttle.Bucket
+	limit  throttle.Limit
+}
+
+// InMemoryThrottle keeps token buckets in memory, buckets that refilled
+// completely are swept away as they hold nothing a new bucket wouldn't
+type InMemoryThrottle struct {
+	buckets map[string]throttleEntry
+	takes   int
+	sync.Mutex
+}
+
+func NewInMemoryThrottle() ThrottleRepository {
+	return &InMemoryThrottle{
+		buckets: make(map[string]throttleEntry),
+	}
+}
+
+func (it *InMemoryThrottle) Take(key string, limit throttle.Limit, now time.Time) (bool, time.Duration, error) {
+	it.Mutex.Lock()
+	defer it.Mutex.Unlock()
+	it.takes++
+	if it.takes%throttleSweepEvery == 0 {
+		for k, e := range it.buckets {
+			if e.limit.Full(e.bucket, now) {
+				delete(it.buckets, k)
+			}
+		}
+	}
+	bucket, ok, retryAfter := limit.Take(it.buckets[key].bucket, now)
+	it.buckets[key] = throttleEntry{bucket: bucket, limit: limit}
+	return ok, retryAfter, nil
+}
@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain/throttle"
+	"github.com/stretchr/testify/require"
+)
+
+func TestThrottle(t *testing.T) {
+	repo := NewInMemoryThrottle().(*InMemoryThrottle)
+	limit := throttle.Limit{Burst: 1, Every: time.Minute}
+	now := time.Now()
+
+	ok, _, err := repo.Take("g1:u1:command:challenge", limit, now)
+	require.NoError(t, err)
+	require.True(t, ok)
+	ok, retryAfter, err := repo.Take("g1:u1:command:challenge", limit, now.Add(15*time.Second))
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.Equal(t, 45*time.Second, retryAfter)
+
+	// other users and commands have their own buckets
+	ok, _, _ = repo.Take("g1:u2:command:challenge", limit, now)
+	require.True(t, ok)
+	ok, _, _ = repo.Take("g1:u1:command:daily", limit, now)
+	require.True(t, ok)
+
+	// refilled buckets are swept
+	later := now.Add(time.Hour)
+	for i := 0; i < throttleSweepEvery; i++ {
+		repo.Take(fmt.Sprintf("g1:u%d:command:other", i), limit, later)
+	}
+	require.NotContains(t, repo.buckets, "g1:u1:command:challenge")
+}
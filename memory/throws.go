@@ -0,0 +1,47 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/ekefan/discord-bot/domain"
+)
+
+// InMemoryThrows keeps the most recent throws of each user
+type InMemoryThrows struct {
+	limit  int
+	throws map[string][]domain.RpsChoice
+	sync.Mutex
+}
+
+// NewInMemoryThrows creates a throw history keeping at most limit throws per user
+func NewInMemoryThrows(limit int) ThrowRepository {
+	return &InMemoryThrows{
+		limit:  limit,
+		throws: make(map[string][]domain.RpsChoice),
+	}
+}
+
+func (it *InMemoryThrows) RecordThrow(userID string, choice domain.RpsChoice) error {
+	if userID == "" {
+		return ErrInvalidPlayerId
+	}
+	it.Mutex.Lock()
+	defer it.Mutex.Unlock()
+	throws := append(it.throws[userID], choice)
+	if it.limit > 0 && len(throws) > it.limit {
+		throws = throws[len(throws)-it.limit:]
+	}
+	it.throws[userID] = throws
+	return nil
+}
+
+func (it *InMemoryThrows) Throws(userID string) ([]domain.RpsChoice, error) {
+	if userID == "" {
+		return nil, ErrInvalidPlayerId
+	}
+	it.Mutex.Lock()
+	defer it.Mutex.Unlock()
+	throws := make([]domain.RpsChoice, len(it.throws[userID]))
+	copy(throws, it.throws[userID])
+	return throws, nil
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/domain/tournament"
+)
+
+// InMemoryTournaments keeps tournaments in memory, stored tournaments are
+// cloned so callers never share state with the repository
+type InMemoryTournaments struct {
+	tournaments map[string]*tournament.Tournament
+	sync.Mutex
+}
+
+func NewInMemoryTournaments() TournamentRepository {
+	return &InMemoryTournaments{
+		tournaments: make(map[string]*tournament.Tournament),
+	}
+}
+
+func active(t *tournament.Tournament) bool {
+	return t.Status() == tournament.Registering || t.Status() == tournament.Running
+}
+
+func (it *InMemoryTournaments) CreateTournament(t *tournament.Tournament) error {
+	if t == nil || t.ID() == "" {
+		return ErrInvalidTournament
+	}
+	it.Mutex.Lock()
+	defer it.Mutex.Unlock()
+	if _, ok := it.tournaments[t.ID()]; ok {
+		return ErrTournamentExists
+	}
+	if _, err := it.active(t.Scope()); err == nil {
+		return ErrTournamentExists
+	}
+	it.tournaments[t.ID()] = t.Clone()
+	return nil
+}
+
+func (it *InMemoryTournaments) GetTournament(id string) (*tournament.Tournament, error) {
+	it.Mutex.Lock()
+	defer it.Mutex.Unlock()
+	t, ok := it.tournaments[id]
+	if !ok {
+		return nil, ErrTournamentNotFound
+	}
+	return t.Clone(), nil
+}
+
+func (it *InMemoryTournaments) ActiveTournament(scope challenge.Scope) (*tournament.Tournament, error) {
+	it.Mutex.Lock()
+	defer it.Mutex.Unlock()
+	t, err := it.active(scope)
+	if err != nil {
+		return nil, err
+	}
+	return t.Clone(), nil
+}
+
+func (it *InMemoryTournaments) active(scope challenge.Scope) (*tournament.Tournament, error) {
+	for _, t := range it.tournaments {
+		if active(t) && t.Scope().Key() == scope.Key() {
+			return t, nil
+		}
+	}
+	return nil, ErrTournamentNotFound
+}
+
+func (it *InMemoryTournaments) UpdateTournament(id string, update func(t *tournament.Tournament) error) (*tournament.Tournament, error) {
+	it.Mutex.Lock()
+	defer it.Mutex.Unlock()
+	stored, ok := it.tournaments[id]
+	if !ok {
+		return nil, ErrTournamentNotFound
+	}
+	t := stored.Clone()
+	if err := update(t); err != nil {
+		return stored.Clone(), err
+	}
+	it.tournaments[id] = t.Clone()
+	return t, nil
+}
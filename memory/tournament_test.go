@@ -0,0 +1,41 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/domain/tournament"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTournaments(t *testing.T) {
+	repo := NewInMemoryTournaments()
+	scope := challenge.Scope{GuildID: "g1", ChannelID: "c1"}
+	first, err := tournament.New("t1", scope, "cup", "host", tournament.SingleElimination, tournament.SeedRandom, 0)
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateTournament(first))
+
+	second, err := tournament.New("t2", scope, "cup", "host", tournament.Swiss, tournament.SeedRandom, 0)
+	require.NoError(t, err)
+	require.ErrorIs(t, repo.CreateTournament(second), ErrTournamentExists)
+
+	// a failed update leaves the stored tournament unchanged
+	failed := errors.New("failed")
+	_, err = repo.UpdateTournament("t1", func(t *tournament.Tournament) error {
+		t.Join(tournament.Participant{ID: "p1"})
+		return failed
+	})
+	require.ErrorIs(t, err, failed)
+	active, err := repo.ActiveTournament(scope)
+	require.NoError(t, err)
+	require.Empty(t, active.Participants())
+
+	_, err = repo.UpdateTournament("t1", func(t *tournament.Tournament) error {
+		return t.Cancel("host")
+	})
+	require.NoError(t, err)
+	_, err = repo.ActiveTournament(scope)
+	require.ErrorIs(t, err, ErrTournamentNotFound)
+	require.NoError(t, repo.CreateTournament(second))
+}
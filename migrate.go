@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ekefan/discord-bot/sqlite"
+)
+
+const migrateUsage = `usage: bot migrate [command]
+
+commands:
+  up            apply every pending migration, the default
+  down [steps]  revert the last steps migrations, one by default
+  to <version>  migrate up or down to version, 0 reverts every migration
+  status        list the migrations and whether they were applied`
+
+// migrate runs the migrate command on the database at path and returns the exit code
+func migrate(path string, args []string) int {
+	if path == "" {
+		fmt.Fprintln(os.Stderr, "SQLITE_PATH is not set")
+		return 1
+	}
+	db, err := sqlite.Open(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer db.Close()
+	migrations, err := sqlite.Migrations()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	migrator := sqlite.NewMigrator(db, migrations)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	command := "up"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	var ran int
+	switch {
+	case command == "up" && len(args) == 0:
+		ran, err = migrator.Up(ctx)
+	case command == "down" && len(args) <= 1:
+		steps := 1
+		if len(args) == 1 {
+			if steps, err = strconv.Atoi(args[0]); err != nil || steps < 1 {
+				fmt.Fprintln(os.Stderr, migrateUsage)
+				return 2
+			}
+		}
+		ran, err = migrator.Down(ctx, steps)
+	case command == "to" && len(args) == 1:
+		version, convErr := strconv.Atoi(args[0])
+		if convErr != nil {
+			fmt.Fprintln(os.Stderr, migrateUsage)
+			return 2
+		}
+		ran, err = migrator.Migrate(ctx, version)
+	case command == "status" && len(args) == 0:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, s := range statuses {
+			applied := "pending"
+			if s.Applied {
+				applied = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d %-24s %s\n", s.Version, s.Name, applied)
+		}
+		return 0
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	fmt.Printf("%d migrations run\n", ran)
+	return 0
+}
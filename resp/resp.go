@@ -0,0 +1,395 @@
+// resp package is a client of RESP, the protocol of redis and the stores compatible with it.
+//
+// It only covers what the repositories need: commands, pooled connections and
+// optimistic transactions with WATCH, MULTI and EXEC on a single connection
+package resp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNil      = errors.New("resp: nil reply")
+	ErrProtocol = errors.New("resp: protocol error")
+	ErrClosed   = errors.New("resp: client is closed")
+	ErrReply    = errors.New("resp: unexpected reply type")
+)
+
+// Error is an error reply of the server
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Config configures a client
+type Config struct {
+	Addr     string // host:port
+	Password string
+	DB       int
+	TLS      bool
+	// PoolSize is the number of idle connections kept, defaults to 8
+	PoolSize    int
+	DialTimeout time.Duration // defaults to 5s
+	// IOTimeout bounds every command without a context deadline, defaults to 5s
+	IOTimeout time.Duration
+}
+
+// ParseURL reads a redis://[:password@]host[:port][/db] url, rediss:// turns on TLS
+func ParseURL(rawURL string) (Config, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Config{}, err
+	}
+	var config Config
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		config.TLS = true
+	default:
+		return Config{}, fmt.Errorf("unsupported redis url scheme %q", u.Scheme)
+	}
+	config.Addr = u.Host
+	if u.Port() == "" {
+		config.Addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if password, ok := u.User.Password(); ok {
+		config.Password = password
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if config.DB, err = strconv.Atoi(db); err != nil {
+			return Config{}, fmt.Errorf("invalid redis database %q", db)
+		}
+	}
+	return config, nil
+}
+
+// Client is a pool of connections to a server
+type Client struct {
+	config Config
+	mu     sync.Mutex
+	idle   []*Conn
+	closed bool
+}
+
+func NewClient(config Config) *Client {
+	if config.PoolSize <= 0 {
+		config.PoolSize = 8
+	}
+	if config.DialTimeout <= 0 {
+		config.DialTimeout = 5 * time.Second
+	}
+	if config.IOTimeout <= 0 {
+		config.IOTimeout = 5 * time.Second
+	}
+	return &Client{config: config}
+}
+
+// Do runs a command on a pooled connection
+func (c *Client) Do(ctx context.Context, args ...string) (any, error) {
+	conn, err := c.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.Do(ctx, args...)
+}
+
+// Conn returns a connection of the pool, transactions need one to themselves.
+// It must be closed to go back to the pool
+func (c *Client) Conn(ctx context.Context) (*Conn, error) {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil, ErrClosed
+	}
+	if n := len(c.idle); n > 0 {
+		conn := c.idle[n-1]
+		c.idle = c.idle[:n-1]
+		c.mu.Unlock()
+		return conn, nil
+	}
+	c.mu.Unlock()
+	return c.dial(ctx)
+}
+
+func (c *Client) dial(ctx context.Context) (*Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.DialTimeout)
+	defer cancel()
+	var d net.Dialer
+	nc, err := d.DialContext(ctx, "tcp", c.config.Addr)
+	if err != nil {
+		return nil, err
+	}
+	if c.config.TLS {
+		host, _, _ := net.SplitHostPort(c.config.Addr)
+		tc := tls.Client(nc, &tls.Config{ServerName: host})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, err
+		}
+		nc = tc
+	}
+	conn := &Conn{client: c, nc: nc, br: bufio.NewReader(nc)}
+	if c.config.Password != "" {
+		if _, err := conn.Do(ctx, "AUTH", c.config.Password); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	if c.config.DB != 0 {
+		if _, err := conn.Do(ctx, "SELECT", strconv.Itoa(c.config.DB)); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// put returns a connection to the pool
+func (c *Client) put(conn *Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed || len(c.idle) >= c.config.PoolSize {
+		conn.nc.Close()
+		return
+	}
+	c.idle = append(c.idle, conn)
+}
+
+// Close closes the idle connections, connections in use are closed when they are returned
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	for _, conn := range c.idle {
+		conn.nc.Close()
+	}
+	c.idle = nil
+	return nil
+}
+
+// Conn is a connection to the server, it is not safe for concurrent use
+type Conn struct {
+	client *Client
+	nc     net.Conn
+	br     *bufio.Reader
+	// broken connections failed mid command, the others may watch keys or queue a transaction
+	broken   bool
+	watching bool
+	multi    bool
+}
+
+// Do sends a command and reads its reply. Replies are strings for simple and bulk
+// strings, int64 for integers, []any for arrays and nil for nil replies, error
+// replies are returned as Error
+func (cn *Conn) Do(ctx context.Context, args ...string) (any, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%w: empty command", ErrProtocol)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(cn.client.config.IOTimeout)
+	}
+	cn.nc.SetDeadline(deadline)
+	// cancelling ctx interrupts the command by moving the deadline to now
+	stop := context.AfterFunc(ctx, func() { cn.nc.SetDeadline(time.Now()) })
+	defer stop()
+
+	if _, err := cn.nc.Write(appendCommand(nil, args)); err != nil {
+		cn.broken = true
+		return nil, interrupted(ctx, err)
+	}
+	reply, err := readReply(cn.br)
+	if err != nil {
+		cn.broken = true
+		return nil, interrupted(ctx, err)
+	}
+	switch strings.ToUpper(args[0]) {
+	case "WATCH":
+		cn.watching = true
+	case "MULTI":
+		cn.multi = true
+	case "EXEC", "DISCARD", "UNWATCH":
+		cn.watching, cn.multi = false, false
+	}
+	if e, ok := reply.(Error); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+// interrupted returns the error of ctx along with err when ctx ended the command
+func interrupted(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("%w: %v", ctxErr, err)
+	}
+	return err
+}
+
+// Close returns the connection to its pool, a transaction it was left in is discarded first
+func (cn *Conn) Close() error {
+	var err error
+	ctx := context.Background()
+	switch {
+	case cn.broken:
+		return cn.nc.Close()
+	case cn.multi:
+		_, err = cn.Do(ctx, "DISCARD")
+	case cn.watching:
+		_, err = cn.Do(ctx, "UNWATCH")
+	}
+	if err != nil {
+		return cn.nc.Close()
+	}
+	cn.client.put(cn)
+	return nil
+}
+
+// appendCommand encodes a command as an array of bulk strings
+func appendCommand(b []byte, args []string) []byte {
+	b = append(b, '*')
+	b = strconv.AppendInt(b, int64(len(args)), 10)
+	b = append(b, '\r', '\n')
+	for _, arg := range args {
+		b = append(b, '$')
+		b = strconv.AppendInt(b, int64(len(arg)), 10)
+		b = append(b, '\r', '\n')
+		b = append(b, arg...)
+		b = append(b, '\r', '\n')
+	}
+	return b
+}
+
+// readReply reads a RESP2 reply, error replies are returned as an Error value
+func readReply(br *bufio.Reader) (any, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("%w: malformed line %q", ErrProtocol, line)
+	}
+	kind, rest := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return rest, nil
+	case '-':
+		return Error(rest), nil
+	case ':':
+		n, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: bad integer %q", ErrProtocol, rest)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("%w: bad bulk length %q", ErrProtocol, rest)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(rest)
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("%w: bad array length %q", ErrProtocol, rest)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		values := make([]any, n)
+		for i := range values {
+			if values[i], err = readReply(br); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown reply type %q", ErrProtocol, kind)
+	}
+}
+
+// String converts a string reply, ErrNil is returned for a nil reply
+func String(reply any, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	switch reply := reply.(type) {
+	case string:
+		return reply, nil
+	case nil:
+		return "", ErrNil
+	default:
+		return "", fmt.Errorf("%w: %T is not a string", ErrReply, reply)
+	}
+}
+
+// Int converts an integer reply
+func Int(reply any, err error) (int64, error) {
+	if err != nil {
+		return 0, err
+	}
+	switch reply := reply.(type) {
+	case int64:
+		return reply, nil
+	case nil:
+		return 0, ErrNil
+	default:
+		return 0, fmt.Errorf("%w: %T is not an integer", ErrReply, reply)
+	}
+}
+
+// Values converts an array reply, ErrNil is returned for a nil array such as
+// the reply of EXEC when a watched key changed
+func Values(reply any, err error) ([]any, error) {
+	if err != nil {
+		return nil, err
+	}
+	switch reply := reply.(type) {
+	case []any:
+		return reply, nil
+	case nil:
+		return nil, ErrNil
+	default:
+		return nil, fmt.Errorf("%w: %T is not an array", ErrReply, reply)
+	}
+}
+
+// Strings converts an array reply of strings, nil elements are returned as empty strings
+func Strings(reply any, err error) ([]string, error) {
+	values, err := Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	strs := make([]string, len(values))
+	for i, v := range values {
+		switch v := v.(type) {
+		case string:
+			strs[i] = v
+		case nil:
+		default:
+			return nil, fmt.Errorf("%w: %T is not a string", ErrReply, v)
+		}
+	}
+	return strs, nil
+}
@@ -0,0 +1,140 @@
+package resp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ekefan/discord-bot/resp/resptest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseURL(t *testing.T) {
+	config, err := ParseURL("redis://:secret@cache:6380/2")
+	require.NoError(t, err)
+	require.Equal(t, Config{Addr: "cache:6380", Password: "secret", DB: 2}, config)
+
+	config, err = ParseURL("rediss://cache")
+	require.NoError(t, err)
+	require.Equal(t, Config{Addr: "cache:6379", TLS: true}, config)
+
+	_, err = ParseURL("http://cache")
+	require.Error(t, err)
+	_, err = ParseURL("redis://cache/zero")
+	require.Error(t, err)
+}
+
+func TestDo(t *testing.T) {
+	ctx := context.Background()
+	server := resptest.NewServer(t)
+	server.RequirePass("secret")
+	client := NewClient(Config{Addr: server.Addr(), Password: "secret", DB: 1})
+	defer client.Close()
+
+	require.Equal(t, "OK", must(String(client.Do(ctx, "SET", "key", "va\r\nlue"))))
+	require.Equal(t, "va\r\nlue", must(String(client.Do(ctx, "GET", "key"))))
+	_, err := String(client.Do(ctx, "GET", "missing"))
+	require.ErrorIs(t, err, ErrNil)
+	require.Equal(t, int64(2), must(Int(client.Do(ctx, "SADD", "set", "a", "b"))))
+	require.Equal(t, []string{"a", "b"}, must(Strings(client.Do(ctx, "SMEMBERS", "set"))))
+	require.Equal(t, []string{"va\r\nlue", ""}, must(Strings(client.Do(ctx, "MGET", "key", "missing"))))
+
+	var replyErr Error
+	_, err = client.Do(ctx, "GET", "set")
+	require.True(t, errors.As(err, &replyErr))
+	require.Contains(t, err.Error(), "WRONGTYPE")
+
+	unauthenticated := NewClient(Config{Addr: server.Addr()})
+	defer unauthenticated.Close()
+	_, err = unauthenticated.Do(ctx, "GET", "key")
+	require.ErrorContains(t, err, "NOAUTH")
+}
+
+func TestTransactionConflict(t *testing.T) {
+	ctx := context.Background()
+	server := resptest.NewServer(t)
+	a := NewClient(Config{Addr: server.Addr()})
+	b := NewClient(Config{Addr: server.Addr()})
+	defer a.Close()
+	defer b.Close()
+
+	conn, err := a.Conn(ctx)
+	require.NoError(t, err)
+	_, err = conn.Do(ctx, "WATCH", "key")
+	require.NoError(t, err)
+	_, err = b.Do(ctx, "SET", "key", "b")
+	require.NoError(t, err)
+	_, err = conn.Do(ctx, "MULTI")
+	require.NoError(t, err)
+	require.Equal(t, "QUEUED", must(String(conn.Do(ctx, "SET", "key", "a"))))
+	_, err = Values(conn.Do(ctx, "EXEC"))
+	require.ErrorIs(t, err, ErrNil)
+	conn.Close()
+	require.Equal(t, "b", must(String(a.Do(ctx, "GET", "key"))))
+
+	// without a conflicting write the transaction runs
+	conn, err = a.Conn(ctx)
+	require.NoError(t, err)
+	_, err = conn.Do(ctx, "WATCH", "key")
+	require.NoError(t, err)
+	_, err = conn.Do(ctx, "MULTI")
+	require.NoError(t, err)
+	conn.Do(ctx, "SET", "key", "a")
+	conn.Do(ctx, "DEL", "other")
+	replies, err := Values(conn.Do(ctx, "EXEC"))
+	require.NoError(t, err)
+	require.Equal(t, []any{"OK", int64(0)}, replies)
+	conn.Close()
+}
+
+func TestConnLeftInTransactionIsDiscarded(t *testing.T) {
+	ctx := context.Background()
+	server := resptest.NewServer(t)
+	client := NewClient(Config{Addr: server.Addr()})
+	defer client.Close()
+
+	conn, err := client.Conn(ctx)
+	require.NoError(t, err)
+	_, err = conn.Do(ctx, "MULTI")
+	require.NoError(t, err)
+	conn.Close()
+	// the pooled connection would queue the command if the transaction was not discarded
+	require.Equal(t, "OK", must(String(client.Do(ctx, "SET", "key", "value"))))
+}
+
+func TestCancelInterruptsCommand(t *testing.T) {
+	// a server that accepts connections and never replies
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	client := NewClient(Config{Addr: listener.Addr().String()})
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = client.Do(ctx, "GET", "key")
+	require.ErrorIs(t, err, context.Canceled)
+	require.Less(t, time.Since(start), time.Second)
+
+	_, err = client.Do(ctx, "GET", "key")
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
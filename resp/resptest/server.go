@@ -0,0 +1,497 @@
+// resptest package is an in process stand in for a redis server, it speaks enough
+// RESP for the resp client and the repositories built on it to be tested
+package resptest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Server keeps strings and sets in memory, every command runs atomically and
+// WATCH, MULTI and EXEC behave as they do in redis
+type Server struct {
+	ln       net.Listener
+	password string
+
+	mu       sync.Mutex
+	strings  map[string]string
+	sets     map[string]map[string]bool
+	expires  map[string]time.Time
+	versions map[string]uint64
+	commands int
+}
+
+// NewServer starts a server on a local port, it is closed when the test ends
+func NewServer(t testing.TB) *Server {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("resptest: listen: %v", err)
+	}
+	s := &Server{
+		ln:       ln,
+		strings:  make(map[string]string),
+		sets:     make(map[string]map[string]bool),
+		expires:  make(map[string]time.Time),
+		versions: make(map[string]uint64),
+	}
+	go s.serve()
+	t.Cleanup(func() { ln.Close() })
+	return s
+}
+
+// Addr is the host:port the server listens on
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// RequirePass makes connections authenticate with password before any other command
+func (s *Server) RequirePass(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+}
+
+// Commands returns the number of commands run so far
+func (s *Server) Commands() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.commands
+}
+
+// Keys returns the keys currently stored, sorted
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	keys := []string{}
+	for k := range s.strings {
+		if !s.expired(k) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range s.sets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *Server) serve() {
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(nc)
+	}
+}
+
+// client is the state of a connection
+type client struct {
+	authed  bool
+	watched map[string]uint64
+	multi   bool
+	queued  [][]string
+	dirty   bool // a queued command was refused, EXEC aborts
+}
+
+func (s *Server) handle(nc net.Conn) {
+	defer nc.Close()
+	br := bufio.NewReader(nc)
+	bw := bufio.NewWriter(nc)
+	c := &client{}
+	for {
+		args, err := readCommand(br)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				writeReply(bw, errorReply("ERR Protocol error: "+err.Error()))
+				bw.Flush()
+			}
+			return
+		}
+		writeReply(bw, s.dispatch(c, args))
+		if err := bw.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// errorReply is written as a RESP error
+type errorReply string
+
+// queued is the reply of commands queued in a transaction
+const queued = "QUEUED"
+
+func (s *Server) dispatch(c *client, args []string) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands++
+	name := strings.ToUpper(args[0])
+
+	if name == "AUTH" {
+		if len(args) != 2 {
+			return wrongArgs(name)
+		}
+		if s.password == "" || args[1] != s.password {
+			return errorReply("WRONGPASS invalid username-password pair or user is disabled.")
+		}
+		c.authed = true
+		return "OK"
+	}
+	if s.password != "" && !c.authed {
+		return errorReply("NOAUTH Authentication required.")
+	}
+
+	switch name {
+	case "MULTI":
+		if c.multi {
+			return errorReply("ERR MULTI calls can not be nested")
+		}
+		c.multi = true
+		return "OK"
+	case "EXEC":
+		if !c.multi {
+			return errorReply("ERR EXEC without MULTI")
+		}
+		queue, dirty := c.queued, c.dirty
+		changed := false
+		for key, version := range c.watched {
+			s.expired(key)
+			if s.versions[key] != version {
+				changed = true
+			}
+		}
+		*c = client{authed: c.authed}
+		if dirty {
+			return errorReply("EXECABORT Transaction discarded because of previous errors.")
+		}
+		if changed {
+			return nil
+		}
+		replies := make([]any, len(queue))
+		for i, args := range queue {
+			replies[i] = s.run(args)
+		}
+		return replies
+	case "DISCARD":
+		if !c.multi {
+			return errorReply("ERR DISCARD without MULTI")
+		}
+		*c = client{authed: c.authed}
+		return "OK"
+	case "WATCH":
+		if c.multi {
+			return errorReply("ERR WATCH inside MULTI is not allowed")
+		}
+		if len(args) < 2 {
+			return wrongArgs(name)
+		}
+		if c.watched == nil {
+			c.watched = make(map[string]uint64)
+		}
+		for _, key := range args[1:] {
+			s.expired(key)
+			c.watched[key] = s.versions[key]
+		}
+		return "OK"
+	case "UNWATCH":
+		c.watched = nil
+		return "OK"
+	}
+	if c.multi {
+		if _, ok := commands[name]; !ok {
+			c.dirty = true
+			return errorReply(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		}
+		c.queued = append(c.queued, args)
+		return queued
+	}
+	return s.run(args)
+}
+
+// commands are the commands run outside of the connection state, with their arity,
+// negative when it is a minimum
+var commands = map[string]int{
+	"PING":     -1,
+	"SELECT":   2,
+	"GET":      2,
+	"SET":      -3,
+	"DEL":      -2,
+	"EXISTS":   -2,
+	"MGET":     -2,
+	"SADD":     -3,
+	"SREM":     -3,
+	"SMEMBERS": 2,
+	"FLUSHALL": 1,
+}
+
+// run runs a command, callers must hold the lock
+func (s *Server) run(args []string) any {
+	name := strings.ToUpper(args[0])
+	arity, ok := commands[name]
+	if !ok {
+		return errorReply(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+	}
+	if (arity > 0 && len(args) != arity) || (arity < 0 && len(args) < -arity) {
+		return wrongArgs(name)
+	}
+	keys := args[1:min(2, len(args))]
+	if name == "DEL" || name == "EXISTS" || name == "MGET" {
+		keys = args[1:]
+	}
+	for _, key := range keys {
+		s.expired(key)
+	}
+
+	switch name {
+	case "PING":
+		if len(args) > 1 {
+			return args[1]
+		}
+		return "PONG"
+	case "SELECT":
+		return "OK"
+	case "GET":
+		if _, ok := s.sets[args[1]]; ok {
+			return wrongType()
+		}
+		if v, ok := s.strings[args[1]]; ok {
+			return v
+		}
+		return nil
+	case "SET":
+		return s.set(args)
+	case "DEL":
+		var n int64
+		for _, key := range args[1:] {
+			if s.exists(key) {
+				s.remove(key)
+				n++
+			}
+		}
+		return n
+	case "EXISTS":
+		var n int64
+		for _, key := range args[1:] {
+			if s.exists(key) {
+				n++
+			}
+		}
+		return n
+	case "MGET":
+		values := make([]any, len(args)-1)
+		for i, key := range args[1:] {
+			if v, ok := s.strings[key]; ok {
+				values[i] = v
+			}
+		}
+		return values
+	case "SADD":
+		if _, ok := s.strings[args[1]]; ok {
+			return wrongType()
+		}
+		set := s.sets[args[1]]
+		if set == nil {
+			set = make(map[string]bool)
+			s.sets[args[1]] = set
+		}
+		var n int64
+		for _, member := range args[2:] {
+			if !set[member] {
+				set[member] = true
+				n++
+			}
+		}
+		if n > 0 {
+			s.versions[args[1]]++
+		}
+		return n
+	case "SREM":
+		if _, ok := s.strings[args[1]]; ok {
+			return wrongType()
+		}
+		set := s.sets[args[1]]
+		var n int64
+		for _, member := range args[2:] {
+			if set[member] {
+				delete(set, member)
+				n++
+			}
+		}
+		if n > 0 {
+			s.versions[args[1]]++
+			if len(set) == 0 {
+				delete(s.sets, args[1])
+			}
+		}
+		return n
+	case "SMEMBERS":
+		if _, ok := s.strings[args[1]]; ok {
+			return wrongType()
+		}
+		members := make([]string, 0, len(s.sets[args[1]]))
+		for member := range s.sets[args[1]] {
+			members = append(members, member)
+		}
+		sort.Strings(members)
+		values := make([]any, len(members))
+		for i, m := range members {
+			values[i] = m
+		}
+		return values
+	case "FLUSHALL":
+		for key := range s.strings {
+			s.remove(key)
+		}
+		for key := range s.sets {
+			s.remove(key)
+		}
+		return "OK"
+	}
+	return errorReply("ERR unreachable")
+}
+
+// set runs SET key value [NX|XX] [PX ms|EX s]
+func (s *Server) set(args []string) any {
+	key, value := args[1], args[2]
+	var nx, xx bool
+	var ttl time.Duration
+	for i := 3; i < len(args); i++ {
+		switch strings.ToUpper(args[i]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "PX", "EX":
+			if i+1 == len(args) {
+				return errorReply("ERR syntax error")
+			}
+			n, err := strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil || n <= 0 {
+				return errorReply("ERR invalid expire time in 'set' command")
+			}
+			ttl = time.Duration(n) * time.Millisecond
+			if strings.ToUpper(args[i]) == "EX" {
+				ttl = time.Duration(n) * time.Second
+			}
+			i++
+		default:
+			return errorReply("ERR syntax error")
+		}
+	}
+	if nx && xx {
+		return errorReply("ERR syntax error")
+	}
+	if (nx && s.exists(key)) || (xx && !s.exists(key)) {
+		return nil
+	}
+	s.remove(key)
+	s.strings[key] = value
+	s.versions[key]++
+	if ttl > 0 {
+		s.expires[key] = time.Now().Add(ttl)
+	}
+	return "OK"
+}
+
+func (s *Server) exists(key string) bool {
+	_, str := s.strings[key]
+	_, set := s.sets[key]
+	return str || set
+}
+
+// remove deletes a key of any type, touching its version
+func (s *Server) remove(key string) {
+	if s.exists(key) {
+		s.versions[key]++
+	}
+	delete(s.strings, key)
+	delete(s.sets, key)
+	delete(s.expires, key)
+}
+
+// expired removes key when its expiry passed and reports whether it did
+func (s *Server) expired(key string) bool {
+	at, ok := s.expires[key]
+	if !ok || time.Now().Before(at) {
+		return false
+	}
+	s.remove(key)
+	return true
+}
+
+func wrongArgs(name string) errorReply {
+	return errorReply(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+}
+
+func wrongType() errorReply {
+	return "WRONGTYPE Operation against a key holding the wrong kind of value"
+}
+
+// readCommand reads a command sent as an array of bulk strings
+func readCommand(br *bufio.Reader) ([]string, error) {
+	n, err := readHeader(br, '*')
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, fmt.Errorf("bad command length %d", n)
+	}
+	args := make([]string, n)
+	for i := range args {
+		size, err := readHeader(br, '$')
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, fmt.Errorf("bad bulk length %d", size)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func readHeader(br *bufio.Reader, kind byte) (int, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		return 0, err
+	}
+	if len(line) < 3 || line[0] != kind || line[len(line)-2] != '\r' {
+		return 0, fmt.Errorf("expected %q, got %q", kind, line)
+	}
+	return strconv.Atoi(line[1 : len(line)-2])
+}
+
+func writeReply(bw *bufio.Writer, reply any) {
+	switch reply := reply.(type) {
+	case nil:
+		bw.WriteString("$-1\r\n")
+	case errorReply:
+		fmt.Fprintf(bw, "-%s\r\n", reply)
+	case int64:
+		fmt.Fprintf(bw, ":%d\r\n", reply)
+	case string:
+		if reply == "OK" || reply == "PONG" || reply == queued {
+			fmt.Fprintf(bw, "+%s\r\n", reply)
+			return
+		}
+		fmt.Fprintf(bw, "$%d\r\n%s\r\n", len(reply), reply)
+	case []any:
+		fmt.Fprintf(bw, "*%d\r\n", len(reply))
+		for _, r := range reply {
+			writeReply(bw, r)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/logging"
+	"github.com/ekefan/discord-bot/memory"
+)
+
+// Challenges keeps challenges in the challenges table. Every challenge is saved as a
+// JSON snapshot next to the columns it is looked up by, and changes run in transactions
+// that hold the write lock, so limits are checked and claims are won exactly once
+type Challenges struct {
+	db     *sql.DB
+	limits memory.Limits
+}
+
+func NewChallenges(db *sql.DB, limits memory.Limits) memory.ChallangeRespository {
+	return &Challenges{
+		db:     db,
+		limits: limits,
+	}
+}
+
+func (sc *Challenges) CreateChallenge(ctx context.Context, c *challenge.Challenge) error {
+	if c == nil {
+		return memory.ErrInvalidChallenge
+	}
+	id, err := c.GetChallengeID()
+	if err != nil {
+		slog.Error("error getting challenge id", logging.KeyError, err)
+		return memory.ErrSavingChallenge
+	}
+	data, err := json.Marshal(c.Snapshot())
+	if err != nil {
+		return fmt.Errorf("%w: %v", memory.ErrSavingChallenge, err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return WithTx(ctx, sc.db, func(tx *sql.Tx) error {
+		// only the challenges of the same guild, or the same DM, count towards the limits
+		open, err := queryChallenges(ctx, tx,
+			`SELECT data FROM challenges WHERE guild_id = ? AND (guild_id != '' OR scope_key = ?)`,
+			c.Scope().GuildID, c.Scope().Key())
+		if err != nil {
+			return fmt.Errorf("%w: %w", memory.ErrSavingChallenge, err)
+		}
+		if err := sc.limits.Check(open, c); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO challenges (scope_key, id, guild_id, challenger_id, status, data) VALUES (?, ?, ?, ?, ?, ?)
+			ON CONFLICT (scope_key, id) DO UPDATE SET
+				guild_id = excluded.guild_id, challenger_id = excluded.challenger_id,
+				status = excluded.status, data = excluded.data`,
+			c.Scope().Key(), id, c.Scope().GuildID, c.Challenger().ID, int(c.Status()), string(data))
+		return err
+	})
+}
+
+func (sc *Challenges) GetChallenge(ctx context.Context, scope challenge.Scope, id string) (*challenge.Challenge, error) {
+	if id == "" {
+		return nil, memory.ErrInvalidChallengeId
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return getChallenge(ctx, sc.db, scope, id)
+}
+
+// UpdateChallenge replaces a stored challenge with c
+func (sc *Challenges) UpdateChallenge(ctx context.Context, c *challenge.Challenge) error {
+	if c == nil {
+		return memory.ErrInvalidChallenge
+	}
+	id, err := c.GetChallengeID()
+	if err != nil {
+		return memory.ErrInvalidChallengeId
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return WithTx(ctx, sc.db, func(tx *sql.Tx) error {
+		return saveChallenge(ctx, tx, c.Scope(), id, c)
+	})
+}
+
+func (sc *Challenges) DeleteChallenge(ctx context.Context, scope challenge.Scope, id string) error {
+	if id == "" {
+		return memory.ErrInvalidChallengeId
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	result, err := sc.db.ExecContext(ctx, `DELETE FROM challenges WHERE scope_key = ? AND id = ?`, scope.Key(), id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return memory.ErrChallengeNotFound
+	}
+	return nil
+}
+
+// TransitionChallenge runs transition inside the write transaction, which is rolled back
+// when saving the challenge fails, so transition can't post anything the rollback would leave behind
+func (sc *Challenges) TransitionChallenge(ctx context.Context, scope challenge.Scope, id string, from challenge.Status, transition memory.Transition) (*challenge.Challenge, error) {
+	if id == "" {
+		return nil, memory.ErrInvalidChallengeId
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var current, next *challenge.Challenge
+	err := WithTx(ctx, sc.db, func(tx *sql.Tx) error {
+		var err error
+		if current, err = getChallenge(ctx, tx, scope, id); err != nil {
+			return err
+		}
+		if current.Status() != from {
+			return memory.ErrStateConflict
+		}
+		next = challenge.Restore(current.Snapshot())
+		if err := transition(next); err != nil {
+			return err
+		}
+		return saveChallenge(ctx, tx, scope, id, next)
+	})
+	if err != nil {
+		return current, err
+	}
+	return next, nil
+}
+
+func (sc *Challenges) ListChallenges(ctx context.Context, filter memory.ChallengeFilter) ([]*challenge.Challenge, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	query := `SELECT data FROM challenges WHERE 1 = 1`
+	args := []any{}
+	if filter.GuildID != "" {
+		query += ` AND guild_id = ?`
+		args = append(args, filter.GuildID)
+	}
+	if filter.Scope != nil {
+		query += ` AND scope_key = ?`
+		args = append(args, filter.Scope.Key())
+	}
+	if filter.ChallengerID != "" {
+		query += ` AND challenger_id = ?`
+		args = append(args, filter.ChallengerID)
+	}
+	return queryChallenges(ctx, sc.db, query, args...)
+}
+
+// querier is what reads need of a *sql.DB or a *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func getChallenge(ctx context.Context, q querier, scope challenge.Scope, id string) (*challenge.Challenge, error) {
+	var data string
+	err := q.QueryRowContext(ctx, `SELECT data FROM challenges WHERE scope_key = ? AND id = ?`, scope.Key(), id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, memory.ErrChallengeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeChallenge(data)
+}
+
+// saveChallenge replaces the stored challenge with c, ErrChallengeNotFound is returned when there is none
+func saveChallenge(ctx context.Context, tx *sql.Tx, scope challenge.Scope, id string, c *challenge.Challenge) error {
+	data, err := json.Marshal(c.Snapshot())
+	if err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx,
+		`UPDATE challenges SET challenger_id = ?, status = ?, data = ? WHERE scope_key = ? AND id = ?`,
+		c.Challenger().ID, int(c.Status()), string(data), scope.Key(), id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil || n == 0 {
+		return memory.ErrChallengeNotFound
+	}
+	return nil
+}
+
+func queryChallenges(ctx context.Context, q querier, query string, args ...any) ([]*challenge.Challenge, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	challenges := []*challenge.Challenge{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		c, err := decodeChallenge(data)
+		if err != nil {
+			return nil, err
+		}
+		challenges = append(challenges, c)
+	}
+	return challenges, rows.Err()
+}
+
+func decodeChallenge(data string) (*challenge.Challenge, error) {
+	var s challenge.Snapshot
+	if err := json.Unmarshal([]byte(data), &s); err != nil {
+		return nil, fmt.Errorf("decode challenge: %w", err)
+	}
+	return challenge.Restore(s), nil
+}
@@ -0,0 +1,173 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/ekefan/discord-bot/memory"
+	"github.com/ekefan/discord-bot/memory/repotest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChallengesConformance(t *testing.T) {
+	repotest.RunChallengeRepositorySuite(t, func(t *testing.T, limits memory.Limits) memory.ChallangeRespository {
+		return NewChallenges(newTestDB(t), limits)
+	})
+}
+
+func TestChallenges(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "bot.db")
+	repo := NewChallenges(openTestDB(t, path), memory.Limits{})
+	c := newTestChallenge(t, "1", "g1", "c1", "challenger")
+	c.SetWager(5)
+	require.NoError(t, repo.CreateChallenge(ctx, c))
+
+	// challenges survive a restart
+	reopened := NewChallenges(openTestDB(t, path), memory.Limits{})
+	got, err := reopened.GetChallenge(ctx, c.Scope(), "1")
+	require.NoError(t, err)
+	require.Equal(t, "challenger", got.Challenger().ID)
+	require.Equal(t, 5, got.Wager())
+	_, err = reopened.GetChallenge(ctx, challenge.Scope{GuildID: "g1", ChannelID: "c2"}, "1")
+	require.ErrorIs(t, err, memory.ErrChallengeNotFound)
+	_, err = reopened.GetChallenge(ctx, c.Scope(), "")
+	require.ErrorIs(t, err, memory.ErrInvalidChallengeId)
+
+	require.NoError(t, repo.CreateChallenge(ctx, newTestChallenge(t, "2", "g1", "c2", "other")))
+	listed, err := repo.ListChallenges(ctx, memory.ChallengeFilter{ChallengerID: "other"})
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+	scope := c.Scope()
+	listed, err = repo.ListChallenges(ctx, memory.ChallengeFilter{GuildID: "g1", Scope: &scope})
+	require.NoError(t, err)
+	require.Len(t, listed, 1)
+
+	got.SetWager(10)
+	require.NoError(t, repo.UpdateChallenge(ctx, got))
+	got, err = repo.GetChallenge(ctx, c.Scope(), "1")
+	require.NoError(t, err)
+	require.Equal(t, 10, got.Wager())
+	require.ErrorIs(t, repo.UpdateChallenge(ctx, newTestChallenge(t, "3", "g1", "c1", "x")), memory.ErrChallengeNotFound)
+
+	// a failed transition leaves the challenge unchanged
+	_, err = repo.TransitionChallenge(ctx, c.Scope(), "1", challenge.Open, func(c *challenge.Challenge) error {
+		c.SetWager(99)
+		return challenge.ErrNotClaimant
+	})
+	require.ErrorIs(t, err, challenge.ErrNotClaimant)
+	got, err = repo.GetChallenge(ctx, c.Scope(), "1")
+	require.NoError(t, err)
+	require.Equal(t, 10, got.Wager())
+
+	require.NoError(t, repo.DeleteChallenge(ctx, c.Scope(), "1"))
+	require.ErrorIs(t, repo.DeleteChallenge(ctx, c.Scope(), "1"), memory.ErrChallengeNotFound)
+	_, err = repo.TransitionChallenge(ctx, c.Scope(), "1", challenge.Open, func(*challenge.Challenge) error { return nil })
+	require.ErrorIs(t, err, memory.ErrChallengeNotFound)
+}
+
+func TestTransitionChallengeSingleClaim(t *testing.T) {
+	ctx := context.Background()
+	// two handles on one file stand for two instances of the bot
+	path := filepath.Join(t.TempDir(), "bot.db")
+	repos := []memory.ChallangeRespository{
+		NewChallenges(openTestDB(t, path), memory.Limits{}),
+		NewChallenges(openTestDB(t, path), memory.Limits{}),
+	}
+	c := newTestChallenge(t, "1", "g1", "c1", "challenger")
+	require.NoError(t, repos[0].CreateChallenge(ctx, c))
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	winners := []string{}
+	conflicts := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(repo memory.ChallangeRespository, userID string) {
+			defer wg.Done()
+			claimed, err := repo.TransitionChallenge(ctx, c.Scope(), "1", challenge.Open, func(c *challenge.Challenge) error {
+				return c.Accept(userID, time.Now())
+			})
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				winners = append(winners, claimed.ClaimedBy())
+				return
+			}
+			if errors.Is(err, memory.ErrStateConflict) {
+				conflicts++
+			}
+		}(repos[i%2], fmt.Sprintf("user-%d", i))
+	}
+	wg.Wait()
+	require.Len(t, winners, 1)
+	require.Equal(t, 49, conflicts)
+
+	opponent := &domain.Player{ID: winners[0], Choice: domain.Paper}
+	resolved, err := repos[1].TransitionChallenge(ctx, c.Scope(), "1", challenge.Claimed, func(c *challenge.Challenge) error {
+		return c.MakeChoice(opponent, time.Now())
+	})
+	require.NoError(t, err)
+	require.Equal(t, challenge.Resolved, resolved.Status())
+
+	current, err := repos[0].TransitionChallenge(ctx, c.Scope(), "1", challenge.Claimed, func(c *challenge.Challenge) error {
+		return c.MakeChoice(opponent, time.Now())
+	})
+	require.ErrorIs(t, err, memory.ErrStateConflict)
+	require.Equal(t, challenge.Resolved, current.Status())
+}
+
+func TestLimits(t *testing.T) {
+	ctx := context.Background()
+	repo := NewChallenges(newTestDB(t), memory.Limits{PerUser: 1, PerChannel: 2, PerGuild: 3})
+
+	require.NoError(t, repo.CreateChallenge(ctx, newTestChallenge(t, "1", "g1", "c1", "u1")))
+	err := repo.CreateChallenge(ctx, newTestChallenge(t, "2", "g1", "c1", "u1"))
+	var limitErr *memory.LimitError
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, memory.UserLimit, limitErr.Limit)
+
+	require.NoError(t, repo.CreateChallenge(ctx, newTestChallenge(t, "3", "g1", "c2", "u1")))
+	require.NoError(t, repo.CreateChallenge(ctx, newTestChallenge(t, "4", "g1", "c1", "u2")))
+	err = repo.CreateChallenge(ctx, newTestChallenge(t, "5", "g1", "c1", "u3"))
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, memory.ChannelLimit, limitErr.Limit)
+	err = repo.CreateChallenge(ctx, newTestChallenge(t, "6", "g1", "c3", "u3"))
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, memory.GuildLimit, limitErr.Limit)
+
+	require.NoError(t, repo.CreateChallenge(ctx, newTestChallenge(t, "7", "g2", "c1", "u1")))
+	require.NoError(t, repo.DeleteChallenge(ctx, challenge.Scope{GuildID: "g1", ChannelID: "c1"}, "1"))
+	require.NoError(t, repo.CreateChallenge(ctx, newTestChallenge(t, "8", "g1", "c1", "u1")))
+}
+
+func TestLimitsAcrossInstances(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "bot.db")
+	repos := []memory.ChallangeRespository{
+		NewChallenges(openTestDB(t, path), memory.Limits{PerUser: 1}),
+		NewChallenges(openTestDB(t, path), memory.Limits{PerUser: 1}),
+	}
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		go func(i int) {
+			errs <- repos[i%2].CreateChallenge(ctx, newTestChallenge(t, fmt.Sprint(i), "g1", "c1", "u1"))
+		}(i)
+	}
+	created := 0
+	for i := 0; i < 20; i++ {
+		if err := <-errs; err == nil {
+			created++
+		} else {
+			require.ErrorIs(t, err, memory.ErrChallengeLimitReached)
+		}
+	}
+	require.Equal(t, 1, created)
+}
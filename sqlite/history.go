@@ -0,0 +1,106 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/ekefan/discord-bot/domain/history"
+	"github.com/ekefan/discord-bot/memory"
+)
+
+// History keeps game records in the games table in the order they were appended
+type History struct {
+	db *sql.DB
+}
+
+func NewHistory(db *sql.DB) memory.HistoryRepository {
+	return &History{db: db}
+}
+
+func (sh *History) AppendGame(r history.Record) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return WithTx(ctx, sh.db, func(tx *sql.Tx) error {
+		var exists bool
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM games WHERE id = ?)`, r.ID).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return memory.ErrGameExists
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO games (id, guild_id, player_1, player_2, resolved_at, data) VALUES (?, ?, ?, ?, ?, ?)`,
+			r.ID, r.GuildID, r.Players[0], r.Players[1], r.ResolvedAt.UnixNano(), string(data))
+		return err
+	})
+}
+
+func (sh *History) Games(q history.Query) ([]history.Record, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	where := ` WHERE 1 = 1`
+	args := []any{}
+	if q.GuildID != "" {
+		where += ` AND guild_id = ?`
+		args = append(args, q.GuildID)
+	}
+	if q.UserID != "" {
+		where += ` AND (player_1 = ? OR player_2 = ?)`
+		args = append(args, q.UserID, q.UserID)
+	}
+	if q.OpponentID != "" {
+		where += ` AND (player_1 = ? OR player_2 = ?)`
+		args = append(args, q.OpponentID, q.OpponentID)
+	}
+	if !q.From.IsZero() {
+		where += ` AND resolved_at >= ?`
+		args = append(args, q.From.UnixNano())
+	}
+	if !q.To.IsZero() {
+		where += ` AND resolved_at < ?`
+		args = append(args, q.To.UnixNano())
+	}
+	records := []history.Record{}
+	var total int
+	err := WithTx(ctx, sh.db, func(tx *sql.Tx) error {
+		if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM games`+where, args...).Scan(&total); err != nil {
+			return err
+		}
+		// a negative limit has no limit in SQLite
+		limit := q.Limit
+		if limit <= 0 {
+			limit = -1
+		}
+		rows, err := tx.QueryContext(ctx, `SELECT data FROM games`+where+` ORDER BY seq DESC LIMIT ? OFFSET ?`,
+			append(args, limit, max(q.Offset, 0))...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var data string
+			if err := rows.Scan(&data); err != nil {
+				return err
+			}
+			var r history.Record
+			if err := json.Unmarshal([]byte(data), &r); err != nil {
+				return err
+			}
+			records = append(records, r)
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return records, total, nil
+}
@@ -0,0 +1,58 @@
+package sqlite
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/ekefan/discord-bot/domain/history"
+	"github.com/ekefan/discord-bot/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	repo := NewHistory(newTestDB(t))
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		require.NoError(t, repo.AppendGame(history.Record{
+			ID:         fmt.Sprint(i),
+			GuildID:    "g1",
+			Players:    [2]string{"a", "b"},
+			Throws:     [2]domain.RpsChoice{domain.Rock, domain.Scissor},
+			WinnerID:   "a",
+			ResolvedAt: start.Add(time.Duration(i) * time.Hour),
+		}))
+	}
+	require.ErrorIs(t, repo.AppendGame(history.Record{ID: "0", Players: [2]string{"a", "b"}}), memory.ErrGameExists)
+	require.ErrorIs(t, repo.AppendGame(history.Record{ID: "9"}), history.ErrInvalidRecord)
+
+	ids := func(records []history.Record) []string {
+		out := []string{}
+		for _, r := range records {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+	page, total, err := repo.Games(history.Query{UserID: "a", Offset: 1, Limit: 2})
+	require.NoError(t, err)
+	require.Equal(t, 5, total)
+	require.Equal(t, []string{"3", "2"}, ids(page))
+	require.Equal(t, domain.Scissor, page[0].Throws[1])
+	require.True(t, page[0].ResolvedAt.Equal(start.Add(3*time.Hour)))
+
+	page, total, err = repo.Games(history.Query{UserID: "b", OpponentID: "a", From: start.Add(3 * time.Hour)})
+	require.NoError(t, err)
+	require.Equal(t, 2, total)
+	require.Equal(t, []string{"4", "3"}, ids(page))
+
+	page, total, err = repo.Games(history.Query{GuildID: "g1", To: start.Add(time.Hour)})
+	require.NoError(t, err)
+	require.Equal(t, 1, total)
+	require.Equal(t, []string{"0"}, ids(page))
+
+	page, total, err = repo.Games(history.Query{UserID: "c", Offset: 10})
+	require.NoError(t, err)
+	require.Zero(t, total)
+	require.Empty(t, page)
+}
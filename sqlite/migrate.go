@@ -0,0 +1,371 @@
+package sqlite
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ekefan/discord-bot/logging"
+)
+
+var (
+	ErrInvalidMigration = errors.New("migration is not valid")
+	ErrChecksumMismatch = errors.New("applied migration was changed since it was applied")
+	ErrUnknownMigration = errors.New("database has a migration this build doesn't know")
+	ErrMigrationLocked  = errors.New("another migration is running")
+)
+
+const (
+	// migrationLockRetry is the wait between two attempts to take the migration lock
+	migrationLockRetry = 50 * time.Millisecond
+	// staleMigrationLock releases the lock of a migration that died holding it
+	staleMigrationLock = 10 * time.Minute
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration changes the schema from the previous version to Version, Down undoes it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Checksum identifies the up migration, a migration must not change once it was applied
+func (m Migration) Checksum() string {
+	sum := sha256.Sum256([]byte(m.Up))
+	return hex.EncodeToString(sum[:])
+}
+
+// Migrations returns the migrations of the schema of the repositories
+func Migrations() ([]Migration, error) {
+	return LoadMigrations(migrationFiles, "migrations")
+}
+
+// LoadMigrations reads the migrations in dir, named NNNN_name.up.sql and NNNN_name.down.sql,
+// and returns them by version. Every version needs an up migration
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		file := entry.Name()
+		base, ok := strings.CutSuffix(file, ".sql")
+		if entry.IsDir() || !ok {
+			continue
+		}
+		base, direction := strings.CutSuffix(base, ".up")
+		if !direction {
+			if base, direction = strings.CutSuffix(base, ".down"); !direction {
+				return nil, fmt.Errorf("%w: %s is neither an up nor a down migration", ErrInvalidMigration, file)
+			}
+		}
+		number, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: %s doesn't start with a version", ErrInvalidMigration, file)
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("%w: version %d is both %s and %s", ErrInvalidMigration, version, m.Name, name)
+		}
+		if strings.HasSuffix(file, ".up.sql") {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+	migrations := []Migration{}
+	for _, m := range byVersion {
+		if strings.TrimSpace(m.Up) == "" {
+			return nil, fmt.Errorf("%w: version %d has no up migration", ErrInvalidMigration, m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// MigrationStatus tells whether a migration was applied
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Migrator applies and reverts migrations. Applied migrations are recorded with
+// their checksum in schema_migrations, and a row in schema_lock keeps two
+// instances starting together from migrating at the same time
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+// NewMigrator migrates db with migrations, sorted by version
+func NewMigrator(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, migrations: migrations}
+}
+
+// Latest is the version of the last migration, zero when there is none
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every pending migration and returns how many were applied
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	return m.Migrate(ctx, m.Latest())
+}
+
+// Down reverts the last steps applied migrations and returns how many were reverted
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	if err := m.init(ctx); err != nil {
+		return 0, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	versions := make([]int, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	target := 0
+	if steps < len(versions) {
+		target = versions[len(versions)-1-steps]
+	}
+	return m.Migrate(ctx, target)
+}
+
+// Migrate applies the pending migrations up to target and reverts the applied ones
+// past it, returning how many migrations ran. Target zero reverts every migration
+func (m *Migrator) Migrate(ctx context.Context, target int) (int, error) {
+	if target != 0 && m.find(target) == nil {
+		return 0, fmt.Errorf("%w: unknown version %d", ErrInvalidMigration, target)
+	}
+	if err := m.init(ctx); err != nil {
+		return 0, err
+	}
+	unlock, err := m.lock(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if err := m.verify(applied); err != nil {
+		return 0, err
+	}
+	ran := 0
+	for i := len(m.migrations) - 1; i >= 0; i-- {
+		migration := m.migrations[i]
+		if _, ok := applied[migration.Version]; !ok || migration.Version <= target {
+			continue
+		}
+		if err := m.revert(ctx, migration); err != nil {
+			return ran, err
+		}
+		ran++
+	}
+	for _, migration := range m.migrations {
+		if _, ok := applied[migration.Version]; ok || migration.Version > target {
+			continue
+		}
+		if err := m.apply(ctx, migration); err != nil {
+			return ran, err
+		}
+		ran++
+	}
+	return ran, nil
+}
+
+// Status lists every migration and whether it was applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	if err := m.init(ctx); err != nil {
+		return nil, err
+	}
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.verify(applied); err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i].Migration = migration
+		if a, ok := applied[migration.Version]; ok {
+			statuses[i].Applied = true
+			statuses[i].AppliedAt = a.at
+		}
+	}
+	return statuses, nil
+}
+
+func (m *Migrator) find(version int) *Migration {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i]
+		}
+	}
+	return nil
+}
+
+// init creates the tables the migrator keeps its state in
+func (m *Migrator) init(ctx context.Context) error {
+	_, err := m.db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    INTEGER PRIMARY KEY,
+			name       TEXT    NOT NULL,
+			checksum   TEXT    NOT NULL,
+			applied_at INTEGER NOT NULL
+		);
+		CREATE TABLE IF NOT EXISTS schema_lock (
+			id        INTEGER PRIMARY KEY CHECK (id = 1),
+			owner     TEXT    NOT NULL,
+			locked_at INTEGER NOT NULL
+		);`)
+	return err
+}
+
+// appliedMigration is a row of schema_migrations
+type appliedMigration struct {
+	checksum string
+	at       time.Time
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int]appliedMigration, error) {
+	rows, err := m.db.QueryContext(ctx, `SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int]appliedMigration)
+	for rows.Next() {
+		var version int
+		var checksum string
+		var at int64
+		if err := rows.Scan(&version, &checksum, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedMigration{checksum: checksum, at: time.Unix(0, at).UTC()}
+	}
+	return applied, rows.Err()
+}
+
+// verify checks the applied migrations are the ones of this build
+func (m *Migrator) verify(applied map[int]appliedMigration) error {
+	for version, a := range applied {
+		migration := m.find(version)
+		if migration == nil {
+			return fmt.Errorf("%w: version %d", ErrUnknownMigration, version)
+		}
+		if migration.Checksum() != a.checksum {
+			return fmt.Errorf("%w: version %d %s", ErrChecksumMismatch, version, migration.Name)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) apply(ctx context.Context, migration Migration) error {
+	err := WithTx(ctx, m.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)`,
+			migration.Version, migration.Name, migration.Checksum(), time.Now().UnixNano())
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("apply migration %d %s: %w", migration.Version, migration.Name, err)
+	}
+	slog.Info("applied migration", "version", migration.Version, "name", migration.Name)
+	return nil
+}
+
+func (m *Migrator) revert(ctx context.Context, migration Migration) error {
+	if strings.TrimSpace(migration.Down) == "" {
+		return fmt.Errorf("%w: version %d has no down migration", ErrInvalidMigration, migration.Version)
+	}
+	err := WithTx(ctx, m.db, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, migration.Version)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("revert migration %d %s: %w", migration.Version, migration.Name, err)
+	}
+	slog.Info("reverted migration", "version", migration.Version, "name", migration.Name)
+	return nil
+}
+
+// lock takes the migration lock, waiting for it until ctx is done. A lock held
+// for longer than staleMigrationLock is taken over
+func (m *Migrator) lock(ctx context.Context) (unlock func(), err error) {
+	b := make([]byte, 16)
+	rand.Read(b)
+	owner := hex.EncodeToString(b)
+	for {
+		now := time.Now()
+		result, err := m.db.ExecContext(ctx, `
+			INSERT INTO schema_lock (id, owner, locked_at) VALUES (1, ?, ?)
+			ON CONFLICT (id) DO UPDATE SET owner = excluded.owner, locked_at = excluded.locked_at
+			WHERE schema_lock.locked_at < ?`,
+			owner, now.UnixNano(), now.Add(-staleMigrationLock).UnixNano())
+		if err != nil && ctx.Err() != nil {
+			// ctx ended while waiting on the database rather than between attempts
+			return nil, fmt.Errorf("%w: %w", ErrMigrationLocked, ctx.Err())
+		}
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := result.RowsAffected(); n > 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %w", ErrMigrationLocked, ctx.Err())
+		case <-time.After(migrationLockRetry):
+		}
+	}
+	return func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+		defer cancel()
+		if _, err := m.db.ExecContext(ctx, `DELETE FROM schema_lock WHERE owner = ?`, owner); err != nil {
+			slog.Error("could not release migration lock", logging.KeyError, err)
+		}
+	}, nil
+}
@@ -0,0 +1,126 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func tables(t *testing.T, m *Migrator) []string {
+	rows, err := m.db.Query(`SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name`)
+	require.NoError(t, err)
+	defer rows.Close()
+	names := []string{}
+	for rows.Next() {
+		var name string
+		require.NoError(t, rows.Scan(&name))
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestMigrations(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "bot.db"))
+	require.NoError(t, err)
+	defer db.Close()
+	migrations, err := Migrations()
+	require.NoError(t, err)
+	m := NewMigrator(db, migrations)
+	ctx := context.Background()
+
+	ran, err := m.Up(ctx)
+	require.NoError(t, err)
+	require.Equal(t, len(migrations), ran)
+	require.Equal(t, []string{"challenges", "games", "ratings", "schema_lock", "schema_migrations", "settings"}, tables(t, m))
+
+	// applied migrations are not applied again
+	ran, err = m.Up(ctx)
+	require.NoError(t, err)
+	require.Zero(t, ran)
+
+	ran, err = m.Down(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, ran)
+	require.Equal(t, []string{"challenges", "games", "schema_lock", "schema_migrations"}, tables(t, m))
+	statuses, err := m.Status(ctx)
+	require.NoError(t, err)
+	require.True(t, statuses[0].Applied)
+	require.False(t, statuses[len(statuses)-1].Applied)
+
+	ran, err = m.Migrate(ctx, 0)
+	require.NoError(t, err)
+	require.Equal(t, len(migrations)-1, ran)
+	require.Equal(t, []string{"schema_lock", "schema_migrations"}, tables(t, m))
+
+	_, err = m.Migrate(ctx, 99)
+	require.ErrorIs(t, err, ErrInvalidMigration)
+}
+
+func TestMigrationsVerified(t *testing.T) {
+	db := newTestDB(t)
+	migrations, err := Migrations()
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	changed := append([]Migration(nil), migrations...)
+	changed[0].Up += "\nCREATE TABLE extra (id INTEGER);"
+	_, err = NewMigrator(db, changed).Up(ctx)
+	require.ErrorIs(t, err, ErrChecksumMismatch)
+
+	// an older build doesn't know the latest migration
+	_, err = NewMigrator(db, migrations[:1]).Status(ctx)
+	require.ErrorIs(t, err, ErrUnknownMigration)
+}
+
+func TestMigrationLock(t *testing.T) {
+	db := newTestDB(t)
+	migrations, err := Migrations()
+	require.NoError(t, err)
+	m := NewMigrator(db, migrations)
+
+	_, err = db.Exec(`INSERT INTO schema_lock (id, owner, locked_at) VALUES (1, 'other', ?)`, time.Now().UnixNano())
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	_, err = m.Up(ctx)
+	require.ErrorIs(t, err, ErrMigrationLocked)
+
+	// the lock of a migration that died is taken over
+	_, err = db.Exec(`UPDATE schema_lock SET locked_at = ?`, time.Now().Add(-time.Hour).UnixNano())
+	require.NoError(t, err)
+	_, err = m.Up(context.Background())
+	require.NoError(t, err)
+	var locks int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM schema_lock`).Scan(&locks))
+	require.Zero(t, locks)
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := LoadMigrations(fstest.MapFS{
+		"m/0002_second.up.sql":  {Data: []byte("CREATE TABLE b (id INTEGER);")},
+		"m/0001_first.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER);")},
+		"m/0001_first.down.sql": {Data: []byte("DROP TABLE a;")},
+		"m/README.md":           {Data: []byte("ignored")},
+	}, "m")
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	require.Equal(t, Migration{Version: 1, Name: "first", Up: "CREATE TABLE a (id INTEGER);", Down: "DROP TABLE a;"}, migrations[0])
+	require.Equal(t, 2, migrations[1].Version)
+
+	for name, fsys := range map[string]fstest.MapFS{
+		"no version":   {"m/first.up.sql": {Data: []byte("SELECT 1;")}},
+		"no direction": {"m/0001_first.sql": {Data: []byte("SELECT 1;")}},
+		"no up":        {"m/0001_first.down.sql": {Data: []byte("SELECT 1;")}},
+		"two names": {
+			"m/0001_first.up.sql":   {Data: []byte("SELECT 1;")},
+			"m/0001_other.down.sql": {Data: []byte("SELECT 1;")},
+		},
+	} {
+		_, err := LoadMigrations(fsys, "m")
+		require.ErrorIs(t, err, ErrInvalidMigration, name)
+	}
+}
@@ -0,0 +1,11 @@
+CREATE TABLE challenges (
+    scope_key     TEXT    NOT NULL,
+    id            TEXT    NOT NULL,
+    guild_id      TEXT    NOT NULL,
+    challenger_id TEXT    NOT NULL,
+    status        INTEGER NOT NULL,
+    data          TEXT    NOT NULL,
+    PRIMARY KEY (scope_key, id)
+);
+
+CREATE INDEX challenges_guild ON challenges (guild_id);
@@ -0,0 +1,13 @@
+CREATE TABLE games (
+    seq         INTEGER PRIMARY KEY AUTOINCREMENT,
+    id          TEXT    NOT NULL UNIQUE,
+    guild_id    TEXT    NOT NULL,
+    player_1    TEXT    NOT NULL,
+    player_2    TEXT    NOT NULL,
+    resolved_at INTEGER NOT NULL,
+    data        TEXT    NOT NULL
+);
+
+CREATE INDEX games_guild ON games (guild_id, resolved_at);
+CREATE INDEX games_player_1 ON games (player_1);
+CREATE INDEX games_player_2 ON games (player_2);
@@ -0,0 +1,13 @@
+CREATE TABLE settings (
+    guild_id TEXT NOT NULL,
+    key      TEXT NOT NULL,
+    value    TEXT NOT NULL,
+    PRIMARY KEY (guild_id, key)
+);
+
+CREATE TABLE ratings (
+    guild_id TEXT    NOT NULL,
+    user_id  TEXT    NOT NULL,
+    rating   INTEGER NOT NULL,
+    PRIMARY KEY (guild_id, user_id)
+);
@@ -0,0 +1,69 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/ekefan/discord-bot/domain/rating"
+	"github.com/ekefan/discord-bot/memory"
+)
+
+// Ratings keeps the rating of each player per guild in the ratings table
+type Ratings struct {
+	db *sql.DB
+}
+
+func NewRatings(db *sql.DB) memory.RatingRepository {
+	return &Ratings{db: db}
+}
+
+func (sr *Ratings) Rating(guildID, userID string) (int, error) {
+	if userID == "" {
+		return 0, memory.ErrInvalidPlayerId
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var r int
+	err := sr.db.QueryRowContext(ctx, `SELECT rating FROM ratings WHERE guild_id = ? AND user_id = ?`, guildID, userID).Scan(&r)
+	if errors.Is(err, sql.ErrNoRows) {
+		return rating.Initial, nil
+	}
+	return r, err
+}
+
+func (sr *Ratings) SetRating(guildID, userID string, r int) error {
+	if userID == "" {
+		return memory.ErrInvalidPlayerId
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err := sr.db.ExecContext(ctx, `
+		INSERT INTO ratings (guild_id, user_id, rating) VALUES (?, ?, ?)
+		ON CONFLICT (guild_id, user_id) DO UPDATE SET rating = excluded.rating`,
+		guildID, userID, r)
+	return err
+}
+
+func (sr *Ratings) Standings(guildID string) ([]rating.Standing, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	rows, err := sr.db.QueryContext(ctx, `SELECT user_id, rating FROM ratings WHERE guild_id = ?`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	standings := []rating.Standing{}
+	for rows.Next() {
+		var s rating.Standing
+		if err := rows.Scan(&s.UserID, &s.Rating); err != nil {
+			return nil, err
+		}
+		standings = append(standings, s)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rating.SortStandings(standings)
+	return standings, nil
+}
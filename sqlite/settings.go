@@ -0,0 +1,61 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/ekefan/discord-bot/domain/settings"
+	"github.com/ekefan/discord-bot/memory"
+)
+
+// Settings keeps the settings guilds override in the settings table
+type Settings struct {
+	db *sql.DB
+}
+
+func NewSettings(db *sql.DB) memory.SettingsRepository {
+	return &Settings{db: db}
+}
+
+func (ss *Settings) Overrides(guildID string) (settings.Overrides, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	rows, err := ss.db.QueryContext(ctx, `SELECT key, value FROM settings WHERE guild_id = ?`, guildID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var overrides settings.Overrides
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		if overrides == nil {
+			overrides = make(settings.Overrides)
+		}
+		overrides[key] = value
+	}
+	return overrides, rows.Err()
+}
+
+func (ss *Settings) SetOverride(guildID, key, value string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err := ss.db.ExecContext(ctx, `
+		INSERT INTO settings (guild_id, key, value) VALUES (?, ?, ?)
+		ON CONFLICT (guild_id, key) DO UPDATE SET value = excluded.value`,
+		guildID, key, value)
+	return err
+}
+
+func (ss *Settings) ResetOverride(guildID, key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if key == "" {
+		_, err := ss.db.ExecContext(ctx, `DELETE FROM settings WHERE guild_id = ?`, guildID)
+		return err
+	}
+	_, err := ss.db.ExecContext(ctx, `DELETE FROM settings WHERE guild_id = ? AND key = ?`, guildID, key)
+	return err
+}
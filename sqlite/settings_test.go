@@ -0,0 +1,51 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/ekefan/discord-bot/domain/rating"
+	"github.com/ekefan/discord-bot/domain/settings"
+	"github.com/ekefan/discord-bot/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSettings(t *testing.T) {
+	repo := NewSettings(newTestDB(t))
+
+	overrides, err := repo.Overrides("g1")
+	require.NoError(t, err)
+	require.Empty(t, overrides)
+
+	require.NoError(t, repo.SetOverride("g1", settings.KeyEconomy, "on"))
+	require.NoError(t, repo.SetOverride("g1", settings.KeyEconomy, "off"))
+	require.NoError(t, repo.SetOverride("g1", settings.KeyChallengeTTL, "5m0s"))
+	require.NoError(t, repo.SetOverride("g2", settings.KeyEconomy, "off"))
+	require.NoError(t, repo.ResetOverride("g1", settings.KeyChallengeTTL))
+	require.NoError(t, repo.ResetOverride("g2", ""))
+
+	overrides, err = repo.Overrides("g1")
+	require.NoError(t, err)
+	require.Equal(t, settings.Overrides{settings.KeyEconomy: "off"}, overrides)
+	overrides, err = repo.Overrides("g2")
+	require.NoError(t, err)
+	require.Empty(t, overrides)
+}
+
+func TestRatings(t *testing.T) {
+	repo := NewRatings(newTestDB(t))
+
+	r, err := repo.Rating("g1", "a")
+	require.NoError(t, err)
+	require.Equal(t, rating.Initial, r)
+	_, err = repo.Rating("g1", "")
+	require.ErrorIs(t, err, memory.ErrInvalidPlayerId)
+
+	require.NoError(t, repo.SetRating("g1", "a", 1010))
+	require.NoError(t, repo.SetRating("g1", "b", 1040))
+	require.NoError(t, repo.SetRating("g1", "a", 1020))
+	require.NoError(t, repo.SetRating("g2", "c", 1100))
+
+	standings, err := repo.Standings("g1")
+	require.NoError(t, err)
+	require.Equal(t, []rating.Standing{{UserID: "b", Rating: 1040}, {UserID: "a", Rating: 1020}}, standings)
+}
@@ -0,0 +1,57 @@
+// sqlite package keeps the repositories in a SQLite database, a single file the
+// bot can restart on without losing its challenges, games, settings and ratings.
+//
+// It uses a pure Go driver so the bot still builds without cgo. The schema is
+// versioned by the migrations embedded in the package, see Migrator
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// timeout bounds every repository call
+const timeout = 5 * time.Second
+
+// Open opens the database at path, creating it when it doesn't exist. Transactions
+// take the write lock when they begin so concurrent read-modify-writes queue up
+// instead of failing, and a writer waits up to busy_timeout for another to finish
+func Open(path string) (*sql.DB, error) {
+	dsn := "file:" + path +
+		"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)&_txlock=immediate"
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	return db, nil
+}
+
+// WithTx runs fn in a transaction, committing it when fn returns nil and rolling
+// it back when fn returns an error or panics
+func WithTx(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
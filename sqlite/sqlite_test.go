@@ -0,0 +1,67 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/ekefan/discord-bot/domain"
+	"github.com/ekefan/discord-bot/domain/challenge"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDB opens a migrated database in a temporary directory
+func newTestDB(t *testing.T) *sql.DB {
+	return openTestDB(t, filepath.Join(t.TempDir(), "bot.db"))
+}
+
+// openTestDB opens and migrates the database at path, opening it again shares it as another instance would
+func openTestDB(t *testing.T, path string) *sql.DB {
+	db, err := Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	migrations, err := Migrations()
+	require.NoError(t, err)
+	_, err = NewMigrator(db, migrations).Up(context.Background())
+	require.NoError(t, err)
+	return db
+}
+
+func newTestChallenge(t *testing.T, id, guildID, channelID, userID string) *challenge.Challenge {
+	scope := challenge.Scope{GuildID: guildID, ChannelID: channelID}
+	c, err := challenge.NewChallenge(id, scope, &domain.Player{ID: userID, Choice: domain.Rock})
+	require.NoError(t, err)
+	return c
+}
+
+func TestWithTx(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	insert := func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, `INSERT INTO settings (guild_id, key, value) VALUES ('g1', 'economy', 'off')`)
+		return err
+	}
+	count := func() int {
+		var n int
+		require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM settings`).Scan(&n))
+		return n
+	}
+
+	require.ErrorIs(t, WithTx(ctx, db, func(tx *sql.Tx) error {
+		require.NoError(t, insert(tx))
+		return sql.ErrNoRows
+	}), sql.ErrNoRows)
+	require.Zero(t, count())
+
+	require.Panics(t, func() {
+		WithTx(ctx, db, func(tx *sql.Tx) error {
+			require.NoError(t, insert(tx))
+			panic("boom")
+		})
+	})
+	require.Zero(t, count())
+
+	require.NoError(t, WithTx(ctx, db, insert))
+	require.Equal(t, 1, count())
+}
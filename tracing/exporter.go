@@ -0,0 +1,87 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+var ErrUnknownExporter = errors.New("unknown trace exporter")
+
+// Exporter names accepted by NewExporter
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+)
+
+// Exporter receives every finished span
+type Exporter interface {
+	ExportSpan(span SpanData)
+}
+
+// NewExporter creates an exporter from its configured name,
+// none or an empty name returns a nil exporter
+func NewExporter(name string) (Exporter, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		return NewJSONExporter(os.Stdout), nil
+	default:
+		return nil, ErrUnknownExporter
+	}
+}
+
+// JSONExporter writes each span as a line of JSON
+type JSONExporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{enc: json.NewEncoder(w)}
+}
+
+func (e *JSONExporter) ExportSpan(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err := e.enc.Encode(span); err != nil {
+		slog.Error("could not export span", "span", span.Name, "error", err)
+	}
+}
+
+// InMemoryExporter keeps finished spans in memory, it is meant for tests
+type InMemoryExporter struct {
+	mu    sync.Mutex
+	spans []SpanData
+}
+
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{}
+}
+
+func (e *InMemoryExporter) ExportSpan(span SpanData) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = append(e.spans, span)
+}
+
+// Spans returns the spans exported so far in the order they ended
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	spans := make([]SpanData, len(e.spans))
+	copy(spans, e.spans)
+	return spans
+}
+
+// Reset drops all exported spans
+func (e *InMemoryExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spans = nil
+}
@@ -0,0 +1,236 @@
+// tracing package records spans for interaction handling and outbound
+// Discord calls.
+//
+// Span and trace identifiers follow the W3C Trace Context format so traces can be
+// continued by, or forwarded to, OpenTelemetry compatible systems
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Span status codes
+const (
+	StatusUnset = "unset"
+	StatusOK    = "ok"
+	StatusError = "error"
+)
+
+type TraceID [16]byte
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// IsValid returns false for the all zero trace id
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// IsValid returns false for the all zero span id
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// SpanContext identifies a span within a trace
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	Remote  bool
+}
+
+// IsValid reports whether both trace and span ids are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID.IsValid() && sc.SpanID.IsValid()
+}
+
+// SpanData is the immutable record of a finished span handed to exporters
+type SpanData struct {
+	Name          string         `json:"name"`
+	TraceID       string         `json:"trace_id"`
+	SpanID        string         `json:"span_id"`
+	ParentSpanID  string         `json:"parent_span_id,omitempty"`
+	Start         time.Time      `json:"start"`
+	End           time.Time      `json:"end"`
+	Duration      time.Duration  `json:"duration_ns"`
+	Attributes    map[string]any `json:"attributes,omitempty"`
+	Status        string         `json:"status"`
+	StatusMessage string         `json:"status_message,omitempty"`
+}
+
+// Span is a single timed operation within a trace
+type Span struct {
+	mu       sync.Mutex
+	name     string
+	sc       SpanContext
+	parent   SpanID
+	start    time.Time
+	end      time.Time
+	attrs    map[string]any
+	status   string
+	message  string
+	ended    bool
+	exporter Exporter
+}
+
+// SpanContext returns the identifiers of the span
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// SetAttributes records key value pairs on the span,
+// keys must be strings and a trailing key without a value is ignored
+func (s *Span) SetAttributes(kv ...any) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i+1 < len(kv); i += 2 {
+		key, ok := kv[i].(string)
+		if !ok {
+			key = fmt.Sprint(kv[i])
+		}
+		s.attrs[key] = kv[i+1]
+	}
+}
+
+// RecordError marks the span as failed with err, a nil error is ignored
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = StatusError
+	s.message = err.Error()
+}
+
+// SetStatus sets the status code and message of the span
+func (s *Span) SetStatus(status, message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+	s.message = message
+}
+
+// End finishes the span and hands it to the exporter, calling End more than
+// once has no effect
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	data := s.data()
+	exporter := s.exporter
+	s.mu.Unlock()
+
+	if exporter != nil {
+		exporter.ExportSpan(data)
+	}
+}
+
+func (s *Span) data() SpanData {
+	attrs := make(map[string]any, len(s.attrs))
+	for k, v := range s.attrs {
+		attrs[k] = v
+	}
+	data := SpanData{
+		Name:          s.name,
+		TraceID:       s.sc.TraceID.String(),
+		SpanID:        s.sc.SpanID.String(),
+		Start:         s.start,
+		End:           s.end,
+		Duration:      s.end.Sub(s.start),
+		Attributes:    attrs,
+		Status:        s.status,
+		StatusMessage: s.message,
+	}
+	if s.parent.IsValid() {
+		data.ParentSpanID = s.parent.String()
+	}
+	return data
+}
+
+type spanKey struct{}
+type remoteKey struct{}
+
+var (
+	exporterMu sync.RWMutex
+	exporter   Exporter
+)
+
+// SetExporter installs the exporter finished spans are sent to,
+// a nil exporter disables exporting while ids are still propagated
+func SetExporter(e Exporter) {
+	exporterMu.Lock()
+	defer exporterMu.Unlock()
+	exporter = e
+}
+
+func currentExporter() Exporter {
+	exporterMu.RLock()
+	defer exporterMu.RUnlock()
+	return exporter
+}
+
+// Start creates a span named name as a child of the span or remote span
+// context carried by ctx, and returns a context carrying the new span
+func Start(ctx context.Context, name string, kv ...any) (context.Context, *Span) {
+	span := &Span{
+		name:     name,
+		start:    time.Now(),
+		attrs:    make(map[string]any),
+		status:   StatusUnset,
+		exporter: currentExporter(),
+	}
+	parent := SpanContextFromContext(ctx)
+	if parent.IsValid() {
+		span.sc.TraceID = parent.TraceID
+		span.parent = parent.SpanID
+	} else {
+		rand.Read(span.sc.TraceID[:])
+	}
+	rand.Read(span.sc.SpanID[:])
+	span.sc.Sampled = true
+	span.SetAttributes(kv...)
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// SpanFromContext returns the span carried by ctx or nil, all span methods
+// are safe to call on a nil span
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// SpanContextFromContext returns the span context of the current span or of
+// a remote parent extracted from an incoming request
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if span := SpanFromContext(ctx); span != nil {
+		return span.sc
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+// ContextWithRemoteSpanContext returns a copy of ctx with sc as the parent of
+// spans started from it
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	sc.Remote = true
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
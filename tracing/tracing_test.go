@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpanHierarchy(t *testing.T) {
+	exporter := NewInMemoryExporter()
+	SetExporter(exporter)
+	defer SetExporter(nil)
+
+	ctx, root := Start(context.Background(), "root", "key", "value")
+	_, child := Start(ctx, "child")
+	child.RecordError(errors.New("failed"))
+	child.End()
+	root.End()
+	root.End()
+
+	spans := exporter.Spans()
+	require.Len(t, spans, 2)
+	require.Equal(t, "child", spans[0].Name)
+	require.Equal(t, spans[1].TraceID, spans[0].TraceID)
+	require.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+	require.Equal(t, StatusError, spans[0].Status)
+	require.Equal(t, "failed", spans[0].StatusMessage)
+	require.Empty(t, spans[1].ParentSpanID)
+	require.Equal(t, "value", spans[1].Attributes["key"])
+}
+
+func TestPropagation(t *testing.T) {
+	ctx, span := Start(context.Background(), "outbound")
+	header := http.Header{}
+	Inject(ctx, header)
+	require.NotEmpty(t, header.Get(TraceparentHeader))
+
+	remoteCtx := Extract(context.Background(), header)
+	_, remoteChild := Start(remoteCtx, "inbound")
+	require.Equal(t, span.SpanContext().TraceID, remoteChild.SpanContext().TraceID)
+	require.Equal(t, span.SpanContext().SpanID, remoteChild.parent)
+}
+
+func TestParseTraceparent(t *testing.T) {
+	testCases := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{name: "valid", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", valid: true},
+		{name: "unsupported version", value: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "zero trace id", value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{name: "non hex", value: "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01"},
+		{name: "empty", value: ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, ok := ParseTraceparent(tc.value)
+			require.Equal(t, tc.valid, ok)
+		})
+	}
+}
@@ -3,6 +3,8 @@ package util
 import (
 	"log/slog"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
@@ -12,6 +14,72 @@ type EnvConfig struct {
 	DiscordToken   string `mapstructure:"BOT_TOKEN"`
 	PublicKey      string `mapstructure:"PUBLIC_KEY"`
 	DiscordBaseUrl string `mapstructure:"DISCORD_BASE_URL"`
+	LogLevel       string `mapstructure:"LOG_LEVEL"`
+	LogFormat      string `mapstructure:"LOG_FORMAT"`
+	TraceExporter  string `mapstructure:"TRACE_EXPORTER"`
+
+	// open challenge limits, zero disables a limit
+	ChallengeLimitPerUser    int `mapstructure:"CHALLENGE_LIMIT_PER_USER"`
+	ChallengeLimitPerChannel int `mapstructure:"CHALLENGE_LIMIT_PER_CHANNEL"`
+	ChallengeLimitPerGuild   int `mapstructure:"CHALLENGE_LIMIT_PER_GUILD"`
+
+	// ChallengeTTL is how long a challenge stays playable, zero disables expiry
+	ChallengeTTL time.Duration `mapstructure:"CHALLENGE_TTL"`
+
+	// outbound webhooks for challenge events, WebhookURLs is comma separated and
+	// deliveries are signed with WebhookSecret, which must be set along with them
+	WebhookURLs           string `mapstructure:"WEBHOOK_URLS"`
+	WebhookSecret         string `mapstructure:"WEBHOOK_SECRET"`
+	WebhookMaxAttempts    int    `mapstructure:"WEBHOOK_MAX_ATTEMPTS"`
+	WebhookDeadLetterFile string `mapstructure:"WEBHOOK_DEAD_LETTER_FILE"`
+
+	// BotStrategy is the computer player's default strategy,
+	// games against it only count towards rankings when BotGamesRanked is set
+	BotStrategy    string `mapstructure:"BOT_STRATEGY"`
+	BotGamesRanked bool   `mapstructure:"BOT_GAMES_RANKED"`
+
+	// DailyCoins is how many coins /daily grants once per day
+	DailyCoins int `mapstructure:"DAILY_COINS"`
+
+	// CustomIDSecret signs the custom_ids of message components, when empty a random
+	// key is used for the lifetime of the process. Required when RedisURL is set, since
+	// components sent by one instance are clicked on another
+	CustomIDSecret string `mapstructure:"CUSTOM_ID_SECRET"`
+
+	// the economy, rounds, tournaments and rematches can be turned off for every guild
+	EconomyEnabled     bool `mapstructure:"ECONOMY_ENABLED"`
+	RoundsEnabled      bool `mapstructure:"ROUNDS_ENABLED"`
+	TournamentsEnabled bool `mapstructure:"TOURNAMENTS_ENABLED"`
+	RematchesEnabled   bool `mapstructure:"REMATCHES_ENABLED"`
+
+	// SettingsFile keeps the settings guilds override across restarts,
+	// when empty they are only kept in memory
+	SettingsFile string `mapstructure:"SETTINGS_FILE"`
+
+	// ThrottleLimits are how fast a user may use commands and components, written as
+	// name=burst/every pairs such as commands=5/10s,components=10/10s,challenge=3/30s
+	ThrottleLimits string `mapstructure:"THROTTLE_LIMITS"`
+
+	// RedisURL is a redis://[:password@]host[:port][/db] url, when set challenges are
+	// kept there under RedisPrefix so several instances of the bot can share them.
+	// Only challenges are shared: the ledger, rematch series, rounds and tournaments
+	// stay in each process, so the features using them must be turned off, and
+	// throttle limits apply to each instance on its own
+	RedisURL    string `mapstructure:"REDIS_URL"`
+	RedisPrefix string `mapstructure:"REDIS_PREFIX"`
+
+	// SQLitePath is the database file challenges, game history, ratings and settings are
+	// kept in when set, pending migrations are applied on start or with the migrate command.
+	// Challenges stay in redis when RedisURL is set too
+	SQLitePath string `mapstructure:"SQLITE_PATH"`
+
+	// GatewayEnabled connects to the gateway to show a presence and receive guild events,
+	// GatewayIntents are comma separated intent names, guild_members must be enabled in
+	// the developer portal for the bot to hear about members leaving
+	GatewayEnabled  bool   `mapstructure:"GATEWAY_ENABLED"`
+	GatewayURL      string `mapstructure:"GATEWAY_URL"`
+	GatewayIntents  string `mapstructure:"GATEWAY_INTENTS"`
+	GatewayCompress bool   `mapstructure:"GATEWAY_COMPRESS"`
 }
 
 // LoadConfig reads environment config from bot.env or loads them from
@@ -21,6 +89,35 @@ func LoadConfig() *EnvConfig {
 	viper.SetConfigType("env")
 	viper.AddConfigPath(".")
 
+	viper.SetDefault("LOG_LEVEL", "info")
+	viper.SetDefault("LOG_FORMAT", "text")
+	viper.SetDefault("TRACE_EXPORTER", "none")
+	viper.SetDefault("CHALLENGE_LIMIT_PER_USER", 1)
+	viper.SetDefault("CHALLENGE_LIMIT_PER_CHANNEL", 10)
+	viper.SetDefault("CHALLENGE_LIMIT_PER_GUILD", 50)
+	viper.SetDefault("CHALLENGE_TTL", "10m")
+	viper.SetDefault("WEBHOOK_URLS", "")
+	viper.SetDefault("WEBHOOK_SECRET", "")
+	viper.SetDefault("WEBHOOK_MAX_ATTEMPTS", 5)
+	viper.SetDefault("WEBHOOK_DEAD_LETTER_FILE", "webhook-dead-letters.jsonl")
+	viper.SetDefault("BOT_STRATEGY", "markov")
+	viper.SetDefault("BOT_GAMES_RANKED", false)
+	viper.SetDefault("DAILY_COINS", 100)
+	viper.SetDefault("CUSTOM_ID_SECRET", "")
+	viper.SetDefault("ECONOMY_ENABLED", true)
+	viper.SetDefault("ROUNDS_ENABLED", true)
+	viper.SetDefault("TOURNAMENTS_ENABLED", true)
+	viper.SetDefault("REMATCHES_ENABLED", true)
+	viper.SetDefault("SETTINGS_FILE", "")
+	viper.SetDefault("REDIS_URL", "")
+	viper.SetDefault("REDIS_PREFIX", "rps:")
+	viper.SetDefault("SQLITE_PATH", "")
+	viper.SetDefault("GATEWAY_ENABLED", false)
+	viper.SetDefault("GATEWAY_URL", "wss://gateway.discord.gg")
+	viper.SetDefault("GATEWAY_INTENTS", "guilds,guild_members")
+	viper.SetDefault("GATEWAY_COMPRESS", true)
+	viper.SetDefault("THROTTLE_LIMITS", "commands=5/10s,components=10/10s,challenge=3/30s")
+
 	viper.AutomaticEnv()
 	if err := viper.ReadInConfig(); err != nil {
 		slog.Error("cannot read in config variables", "error", err)
@@ -29,8 +126,19 @@ func LoadConfig() *EnvConfig {
 	config := EnvConfig{}
 	err := viper.Unmarshal(&config)
 	if err != nil {
-		slog.Error("unable to decode config into struct, %v", "details", err.Error())
+		slog.Error("unable to decode config into struct", "error", err)
 		os.Exit(1)
 	}
 	return &config
 }
+
+// SplitList splits a comma separated config value, dropping empty entries
+func SplitList(value string) []string {
+	items := []string{}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}